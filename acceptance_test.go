@@ -0,0 +1,438 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAcceptance runs the whole install pipeline, end to end, against
+// fixtures on a local artifact server: an app, an XMLUI snapshot and MCP
+// tools and test server releases whose binaries are built from
+// testdata/fixturetool. It installs, updates, reproduces the install from an
+// exported manifest and updates from a tampered release, checking the
+// layout, permissions and receipt after each, so the pipeline can be
+// refactored with confidence. Each install runs the launcher, built for the
+// test, as a child process, as it exits on failure, with its own home,
+// config, cache and state dirs. -short skips it.
+func TestAcceptance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the launcher and runs it end to end")
+	}
+	host, ok := hostPlatform()
+	if !ok {
+		t.Skipf("the releases have no build for %s/%s to test with", hostOS.goos(), host.Arch)
+	}
+	root := t.TempDir()
+	a := &acceptanceRun{t: t, root: root, srvDir: filepath.Join(root, "srv"), host: host, sums: map[string]string{}}
+	a.bin = a.build(".", "xmlui-bundler")
+	tool, err := os.ReadFile(a.build("./testdata/fixturetool", "fixturetool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.tool = tool
+	srv := httptest.NewServer(http.FileServer(http.Dir(a.srvDir)))
+	defer srv.Close()
+	a.baseURL = srv.URL
+	a.isolate()
+
+	installDir := filepath.Join(root, "install")
+	copyDir := filepath.Join(root, "copy")
+	var installed *receipt
+	a.writeFixtures(1)
+	if !t.Run("install", func(t *testing.T) {
+		a.launcher(t, 0, "--dir", installDir, "--app-source", a.baseURL+"/app.zip")
+		installed = a.checkInstall(t, installDir)
+	}) {
+		t.FailNow()
+	}
+	t.Run("update", func(t *testing.T) {
+		a.writeFixtures(2)
+		a.launcher(t, 0, "update", "--dir", installDir)
+		rcpt := a.checkInstall(t, installDir)
+		index := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()), "index.html")
+		if data, err := os.ReadFile(index); err != nil || !bytes.Contains(data, []byte("v2")) {
+			t.Fatalf("%s is not the updated app", index)
+		}
+		installed = rcpt
+	})
+	t.Run("manifest", func(t *testing.T) {
+		out := filepath.Join(root, "manifest")
+		a.launcher(t, 0, "export-manifest", "--dir", installDir, "--out", out)
+		a.launcher(t, 0, "--manifest", filepath.Join(out, manifestFile), "--dir", copyDir)
+		rcpt := a.checkInstall(t, copyDir)
+		if want, got := downloadsOf(installed), downloadsOf(rcpt); want != got {
+			t.Errorf("the copy's downloads differ from the install's:\n%s\n  vs\n%s", got, want)
+		}
+	})
+	t.Run("tampered release", func(t *testing.T) {
+		url, err := assetURL("mcp", a.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.writeChecksumList(url)
+		a.launcher(t, exitIntegrity, "update", "--dir", installDir)
+		rcpt := a.checkInstall(t, installDir)
+		if !rcpt.InstalledAt.Equal(installed.InstalledAt) {
+			t.Errorf("the failed update rewrote the receipt")
+		}
+	})
+}
+
+// fixtureToolVersion is the version testdata/fixturetool reports.
+const fixtureToolVersion = "v1.0.0"
+
+// fixtureFile is a file of a fixture archive.
+type fixtureFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+// acceptanceRun is the state the acceptance checks share.
+type acceptanceRun struct {
+	t       *testing.T
+	root    string // the temp dir everything lives in
+	srvDir  string // the files the artifact server serves
+	baseURL string
+	bin     string // the launcher
+	tool    []byte // the fixture tools' executable
+	host    platform
+	sums    map[string]string // each served URL's SHA-256
+	rev     int               // the fixtures' revision
+}
+
+// build builds the main package pkg as name in the temp dir and returns its
+// path.
+func (a *acceptanceRun) build(pkg, name string) string {
+	a.t.Helper()
+	out := filepath.Join(a.root, "bin", name+hostOS.exeSuffix())
+	cmd := exec.Command("go", "build", "-o", out, pkg)
+	if data, err := cmd.CombinedOutput(); err != nil {
+		a.t.Fatalf("go build %s: %v\n%s", pkg, err, data)
+	}
+	return out
+}
+
+// isolate gives the test, and so the installs it runs, home, config, cache
+// and state dirs under the temp dir, and a config file pointing the
+// downloads at the artifact server.
+func (a *acceptanceRun) isolate() {
+	t := a.t
+	home := filepath.Join(a.root, "home")
+	env := map[string]string{
+		"HOME":            home,
+		"USERPROFILE":     home,
+		"APPDATA":         filepath.Join(home, "AppData", "Roaming"),
+		"LOCALAPPDATA":    filepath.Join(home, "AppData", "Local"),
+		"XDG_CONFIG_HOME": filepath.Join(home, ".config"),
+		"XDG_CACHE_HOME":  filepath.Join(home, ".cache"),
+		"XDG_STATE_HOME":  filepath.Join(home, ".local", "state"),
+		"DO_NOT_TRACK":    "1",
+		"XMLUI_PLAIN":     "1",
+	}
+	for _, dir := range []string{a.srvDir, env["APPDATA"], env["LOCALAPPDATA"], env["XDG_CONFIG_HOME"], env["XDG_CACHE_HOME"], env["XDG_STATE_HOME"]} {
+		if err := os.MkdirAll(dir, dirMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := launcherConfig{Artifacts: map[string]string{
+		"mcp":        a.baseURL + "/rel/",
+		"server":     a.baseURL + "/rel/",
+		"components": a.baseURL + "/xmlui.zip",
+	}}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env["XMLUI_BUNDLER_CONFIG"] = filepath.Join(a.root, configFileName)
+	if err := os.WriteFile(env["XMLUI_BUNDLER_CONFIG"], data, fileMode); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	// assetURL names the fixture releases as the installs will.
+	for _, c := range []string{"mcp", "server"} {
+		asset := releaseAssets[c]
+		old := asset
+		asset.BaseURL = cfg.Artifacts[c]
+		releaseAssets[c] = asset
+		t.Cleanup(func() { releaseAssets[c] = old })
+	}
+}
+
+// writeFixtures writes revision rev of the fixture app, XMLUI snapshot and
+// releases for this machine to the artifact server.
+func (a *acceptanceRun) writeFixtures(rev int) {
+	a.t.Helper()
+	a.rev = rev
+	text := func(s string) fixtureFile { return fixtureFile{[]byte(s), fileMode} }
+	tool := fixtureFile{a.tool, execMode()}
+	exe := a.host.exe()
+	scripts := hostOS.scriptExt()
+	release := func(c string, files map[string]fixtureFile) {
+		url, err := assetURL(c, a.host)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		a.serve(url, files)
+	}
+	v := fmt.Sprintf("v%d", rev)
+	app := map[string]fixtureFile{
+		"index.html":                    text(`<html><head><script src="xmlui/xmlui-standalone.umd.js"></script></head><body>` + v + "</body></html>\n"),
+		"Main.xmlui":                    text("<App>\n  <Text>Invoices " + v + "</Text>\n</App>\n"),
+		"config.json":                   text(`{"name": "{{xmlui.appName}}", "port": {{xmlui.port}}}` + "\n"),
+		"start.sh":                      {[]byte("#!/bin/sh\n./xmlui-test-server\n"), execMode()},
+		"start.bat":                     text("@echo off\r\nxmlui-test-server.exe\r\n"),
+		"xmlui/xmlui-standalone.umd.js": text("// XMLUI " + v + "\n"),
+	}
+	a.serve(a.baseURL+"/app.zip", prefixed(repoName+"-"+branchName+"/", app))
+	xmlui := map[string]fixtureFile{
+		"docs/pages/components/Button.md":        text("# Button\n\nRevision " + v + ".\n"),
+		"xmlui/src/components/Button/Button.tsx": text("export const Button = () => null;\n"),
+		"xmlui/src/components/Button/Button.md":  text("# Button\n"),
+		"docs/pages/components/Text.md":          text("# Text\n"),
+		"xmlui/src/components/Text/Text.tsx":     text("export const Text = () => null;\n"),
+	}
+	a.serve(a.baseURL+"/xmlui.zip", prefixed("xmlui-main/", xmlui))
+	mcp := map[string]fixtureFile{
+		"xmlui-mcp" + exe:          tool,
+		"xmlui-mcp-client" + exe:   tool,
+		"run-mcp-client" + scripts: {[]byte("#!/bin/sh\n./xmlui-mcp-client \"$@\"\n"), execMode()},
+	}
+	if scripts == ".sh" {
+		mcp["prepare-binaries.sh"] = fixtureFile{[]byte("#!/bin/sh\n"), execMode()}
+	}
+	release("mcp", mcp)
+	release("server", map[string]fixtureFile{"xmlui-test-server" + exe: tool})
+}
+
+func prefixed(prefix string, files map[string]fixtureFile) map[string]fixtureFile {
+	out := make(map[string]fixtureFile, len(files))
+	for name, f := range files {
+		out[prefix+name] = f
+	}
+	return out
+}
+
+// serve packs files into the archive url names, a zip or a .tar.gz, and
+// records its checksum.
+func (a *acceptanceRun) serve(url string, files map[string]fixtureFile) {
+	t := a.t
+	t.Helper()
+	rel, ok := strings.CutPrefix(url, a.baseURL+"/")
+	if !ok {
+		t.Fatalf("%s is not on the artifact server", url)
+	}
+	var data []byte
+	var err error
+	if strings.HasSuffix(rel, ".zip") {
+		data, err = zipFixture(files)
+	} else {
+		dir := filepath.Join(a.root, "pack")
+		os.RemoveAll(dir)
+		for name, f := range files {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			if err = os.MkdirAll(filepath.Dir(p), dirMode); err == nil {
+				err = os.WriteFile(p, f.data, f.mode)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err = tarDir(dir, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(a.srvDir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), dirMode); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	a.sums[url] = hex.EncodeToString(sum[:])
+	if err := os.WriteFile(p, data, fileMode); err != nil {
+		t.Fatal(err)
+	}
+	// Each revision is an hour newer, so the download cache's
+	// If-Modified-Since doesn't take one written in the same second as the
+	// last for it.
+	modified := time.Now().Add(time.Duration(a.rev) * time.Hour)
+	if err := os.Chtimes(p, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func zipFixture(files map[string]fixtureFile) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, f := range files {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+		h.SetMode(f.mode)
+		fw, err := w.CreateHeader(h)
+		if err == nil {
+			_, err = fw.Write(f.data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeChecksumList publishes, next to the release asset url, a checksum
+// list that doesn't match it, as a release whose asset was swapped would.
+func (a *acceptanceRun) writeChecksumList(url string) {
+	dir := filepath.Join(a.srvDir, filepath.FromSlash(strings.TrimPrefix(url[:strings.LastIndex(url, "/")], a.baseURL)))
+	list := strings.Repeat("0", 64) + "  " + path.Base(url) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, releaseChecksumLists[0]), []byte(list), fileMode); err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// launcher runs the launcher with args, as a user would, failing t unless it
+// exits with want. Its output is logged.
+func (a *acceptanceRun) launcher(t *testing.T, want int, args ...string) {
+	t.Helper()
+	cmd := exec.Command(a.bin, args...)
+	out, err := cmd.CombinedOutput()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	line := "xmlui-bundler " + strings.Join(args, " ")
+	if code != want {
+		t.Fatalf("%s exited %d, want %d\n%s", line, code, want, out)
+	}
+	t.Logf("%s exited %d\n%s", line, code, out)
+}
+
+// checkInstall checks the install in installDir: its layout and files
+// against its receipt, its modes, and that the receipt records the
+// fixtures it was installed from.
+func (a *acceptanceRun) checkInstall(t *testing.T, installDir string) *receipt {
+	t.Helper()
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		t.Fatalf("no receipt: %v", err)
+	}
+	mcpDir := filepath.Join(installDir, rcpt.toolsDir())
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	if err := smokeLayout(installDir, mcpDir, appDir, rcpt); err != nil {
+		t.Error(err)
+	}
+	if err := smokeAppAssets(appDir); err != nil {
+		t.Error(err)
+	}
+	checkModes(t, installDir, appDir, mcpDir, rcpt)
+	a.checkReceipt(t, rcpt)
+	return rcpt
+}
+
+// checkModes checks that the binaries and scripts can be run and that
+// nothing installed is writable by others.
+func checkModes(t *testing.T, installDir, appDir, mcpDir string, rcpt *receipt) {
+	t.Helper()
+	runnable := []string{}
+	for _, c := range rcpt.Components {
+		for _, b := range c.Binaries {
+			runnable = append(runnable, filepath.Join(installDir, filepath.FromSlash(b.Path)))
+		}
+	}
+	if hostOS.execBits() {
+		scripts, _ := filepath.Glob(filepath.Join(mcpDir, "*.sh"))
+		runnable = append(append(runnable, scripts...), filepath.Join(appDir, "start.sh"))
+	}
+	for _, p := range runnable {
+		info, err := os.Stat(p)
+		switch {
+		case err != nil:
+			t.Error(err)
+		case hostOS.execBits() && info.Mode().Perm()&0o111 == 0:
+			t.Errorf("%s is not executable (%v)", receiptKey(installDir, p), info.Mode().Perm())
+		}
+	}
+	if !hostOS.execBits() {
+		return
+	}
+	filepath.WalkDir(installDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().Perm()&0o002 != 0 {
+			t.Errorf("%s is writable by others (%v)", receiptKey(installDir, p), info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// checkReceipt checks that rcpt records each fixture it was installed from,
+// with the checksum it was served with, and the tools' versions.
+func (a *acceptanceRun) checkReceipt(t *testing.T, rcpt *receipt) {
+	t.Helper()
+	for _, want := range []struct {
+		name     string
+		binaries int
+	}{{"app", 0}, {"components", 0}, {"mcp", 2}, {"server", 1}} {
+		var c *receiptComponent
+		for i := range rcpt.Components {
+			if rcpt.Components[i].Name == want.name {
+				c = &rcpt.Components[i]
+			}
+		}
+		switch {
+		case c == nil:
+			t.Errorf("receipt: no %s component", want.name)
+			continue
+		case c.Unavailable != "":
+			t.Errorf("receipt: %s is unavailable: %s", want.name, c.Unavailable)
+			continue
+		case len(c.Downloads) == 0:
+			t.Errorf("receipt: %s records no download", want.name)
+		case len(c.Binaries) != want.binaries:
+			t.Errorf("receipt: %s records %d binaries, want %d", want.name, len(c.Binaries), want.binaries)
+		}
+		for _, d := range c.Downloads {
+			if sum, ok := a.sums[d.URL]; !ok {
+				t.Errorf("receipt: %s was downloaded from %s, not a fixture", want.name, d.URL)
+			} else if d.SHA256 != sum {
+				t.Errorf("receipt: %s records SHA-256 %q for %s, which was served with %s", want.name, d.SHA256, d.URL, sum)
+			}
+		}
+		for _, b := range c.Binaries {
+			if !strings.Contains(b.Version, fixtureToolVersion) {
+				t.Errorf("receipt: %s records version %q, want %s", b.Path, b.Version, fixtureToolVersion)
+			}
+		}
+	}
+}
+
+// downloadsOf lists the URLs and checksums rcpt records, one per line.
+func downloadsOf(rcpt *receipt) string {
+	var lines []string
+	for _, c := range rcpt.Components {
+		for _, d := range c.Downloads {
+			lines = append(lines, fmt.Sprintf("  %s %s %s", c.Name, d.URL, d.SHA256))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Git archives (snapshots of the app and XMLUI repos) are the largest
+// downloads, and are generated on the fly without a Content-Length, so they
+// can't be checked or resumed the way release assets are. Each one is
+// written straight into the download cache instead, next to a resume token
+// recording where it came from and the validators the server sent with it:
+//
+//   - an interrupted download continues with a Range request if the server
+//     advertised Accept-Ranges and a validator to send as If-Range, and
+//     starts over otherwise;
+//   - a completed archive is reused by the next run, e.g. after an
+//     interruption during extraction: as is for a commit snapshot, and
+//     after a conditional request (304 Not Modified) for a branch.
+const archiveCacheDir = "archives"
+
+// gitArchiveComponents are the fetch components that are git archives.
+var gitArchiveComponents = map[string]bool{"app": true, "components": true}
+
+// resumeToken describes the cached copy of one archive download.
+type resumeToken struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	AcceptRanges bool   `json:"acceptRanges,omitempty"`
+	// Complete is set, with SHA256, once the whole archive is in.
+	Complete bool   `json:"complete,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// validator is what to send as If-Range, or "" if there is none.
+func (t resumeToken) validator() string {
+	if t.ETag != "" {
+		return t.ETag
+	}
+	return t.LastModified
+}
+
+// archiveCachePaths are the cached data and resume token for url.
+func archiveCachePaths(url string) (data, token string, err error) {
+	dir, err := network.cacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	key := sha256Hex([]byte(url))[:16]
+	base := filepath.Join(dir, archiveCacheDir, key)
+	return base + ".archive", base + ".json", nil
+}
+
+func readResumeToken(path, url string) resumeToken {
+	var t resumeToken
+	if data, err := os.ReadFile(path); err != nil || json.Unmarshal(data, &t) != nil || t.URL != url {
+		return resumeToken{URL: url}
+	}
+	return t
+}
+
+func writeResumeToken(path string, t resumeToken) error {
+	data, _ := json.MarshalIndent(t, "", "  ")
+	return fsys.WriteFile(path, append(data, '\n'), fileMode)
+}
+
+// immutableArchive reports whether url is a snapshot of a commit, which
+// can't change.
+func immutableArchive(url string) bool {
+	return isCommitSHA(path.Base(url)) || isCommitSHA(path.Base(path.Dir(url)))
+}
+
+// fetchGitArchive downloads the git archive at url through the archive
+// cache, resuming or reusing an earlier download where it can, and returns
+// it with its SHA-256.
+func fetchGitArchive(url, label string) (data []byte, sum string, err error) {
+	err = restartStalled(func() error {
+		data, sum, err = fetchCachedArchive(url, label)
+		return err
+	})
+	return data, sum, err
+}
+
+// fetchCachedArchive is one try of fetchGitArchive.
+func fetchCachedArchive(url, label string) ([]byte, string, error) {
+	dataPath, tokenPath, err := archiveCachePaths(url)
+	if err == nil {
+		err = fsys.MkdirAll(filepath.Dir(dataPath), dirMode)
+	}
+	if err != nil {
+		// No cache: download as anything else is.
+		return downloadWithProgress(url, label)
+	}
+	t := readResumeToken(tokenPath, url)
+	have := int64(0)
+	if info, err := os.Stat(dataPath); err == nil {
+		have = info.Size()
+	}
+
+	header := http.Header{}
+	switch {
+	case t.Complete && have > 0:
+		if data, ok := cachedArchive(dataPath, t); ok && immutableArchive(url) {
+			fmt.Printf("Downloading %s...\n", label)
+			fmt.Printf("  Using the copy of %s downloaded earlier\n", path.Base(url))
+			return data, t.SHA256, nil
+		} else if ok && t.ETag != "" {
+			header.Set("If-None-Match", t.ETag)
+		} else if ok && t.LastModified != "" {
+			header.Set("If-Modified-Since", t.LastModified)
+		}
+	case have > 0 && t.AcceptRanges && t.validator() != "":
+		header.Set("Range", fmt.Sprintf("bytes=%d-", have))
+		header.Set("If-Range", t.validator())
+	}
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	resp, _, err := streamDownloadFrom(url, label, header, func(resp *http.Response) (io.Writer, error) {
+		if f != nil {
+			// Asked again after a stall.
+			f.Close()
+		}
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if resp.StatusCode == http.StatusPartialContent {
+			flag = os.O_WRONLY | os.O_APPEND
+			fmt.Printf("  Resuming after %s received earlier\n", humanBytes(have))
+		} else {
+			// A fresh copy, with the validators to resume or reuse it.
+			t = resumeToken{
+				URL:          url,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				AcceptRanges: resp.Header.Get("Accept-Ranges") == "bytes",
+			}
+			if err := writeResumeToken(tokenPath, t); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		f, err = fsys.OpenFile(dataPath, flag, fileMode)
+		return f, err
+	}, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		fmt.Printf("  %s is unchanged since the last download; using the cached copy\n", path.Base(url))
+		data, _ := cachedArchive(dataPath, t)
+		return data, t.SHA256, nil
+	}
+	if err := f.Close(); err != nil {
+		return nil, "", err
+	}
+	f = nil
+
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, "", err
+	}
+	// Without a Content-Length a dropped connection looks like the end of
+	// the body; only the archive's own end marks it complete.
+	if err := archiveEnded(data); err != nil {
+		how := "run again to download it afresh"
+		if t.AcceptRanges && t.validator() != "" {
+			how = "run again to resume it"
+		}
+		return nil, "", fmt.Errorf("the download of %s ended early, after %s (%v); %s", path.Base(url), humanBytes(int64(len(data))), err, how)
+	}
+	t.Complete, t.SHA256 = true, sha256Hex(data)
+	if err := writeResumeToken(tokenPath, t); err != nil {
+		warn("Could not record the cached copy of %s: %v", path.Base(url), err)
+	}
+	return data, t.SHA256, nil
+}
+
+// archiveEnded checks that data is a whole zip or gzip stream: a zip
+// with its central directory, or gzip data through to its checksummed
+// trailer. Other formats are taken as they are.
+func archiveEnded(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		_, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		return err
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err == nil {
+			_, err = io.Copy(io.Discard, gz)
+		}
+		return err
+	}
+	return nil
+}
+
+// cachedArchive reads the completed archive t describes, checking it
+// against the checksum recorded when it came in.
+func cachedArchive(dataPath string, t resumeToken) ([]byte, bool) {
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, false
+	}
+	return data, sha256Hex(data) == t.SHA256
+}
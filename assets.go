@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// releaseAsset describes how a component's release artifacts are named.
+// Pattern is expanded per platform: {name} is Name, and {os}, {arch} and
+// {ext} come from that platform's assetVars. An empty Ext marks a bare
+// binary rather than an archive, and drops ".{ext}".
+//
+// Dest optionally puts the component's binaries somewhere other than their
+// usual directory: a template (see destVars) per GOOS, or for any OS under
+// "default". The "destinations" of xmlui-bundler.json override it.
+type releaseAsset struct {
+	Name      string
+	BaseURL   string // release download directory, ending in /
+	Pattern   string
+	Platforms map[platform]assetVars
+	Dest      map[string]string
+}
+
+type assetVars struct {
+	OS, Arch, Ext string
+}
+
+// releaseAssets maps each binary component to its release naming. The Mac
+// builds predate the {os}-{goarch} convention and keep their short names.
+var releaseAssets = map[string]releaseAsset{
+	"mcp": {
+		Name:    "xmlui-mcp",
+		BaseURL: "https://github.com/jonudell/xmlui-mcp/releases/download/v1.0.0/",
+		Pattern: "{name}-{os}-{arch}.{ext}",
+		Platforms: map[platform]assetVars{
+			{"darwin", "arm64"}:  {"mac", "arm", "tar.gz"},
+			{"darwin", "amd64"}:  {"mac", "amd", "tar.gz"},
+			{"linux", "amd64"}:   {"linux", "amd64", "zip"},
+			{"windows", "amd64"}: {"windows", "amd64", "zip"},
+		},
+	},
+	"server": {
+		Name:    "xmlui-test-server",
+		BaseURL: "https://github.com/JonUdell/xmlui-test-server/releases/download/v1.0.0/",
+		Pattern: "{name}-{os}-{arch}.{ext}",
+		Platforms: map[platform]assetVars{
+			{"darwin", "arm64"}:  {"mac", "arm", "tar.gz"},
+			{"darwin", "amd64"}:  {"mac", "amd", "tar.gz"},
+			{"linux", "amd64"}:   {"linux", "amd64", "tar.gz"},
+			{"windows", "amd64"}: {"windows", "amd64", "zip"},
+		},
+	},
+}
+
+// assetName expands the pattern for p.
+func (a releaseAsset) assetName(p platform) (string, error) {
+	v, ok := a.Platforms[p]
+	if !ok {
+		return "", fmt.Errorf("%s has no build for %s/%s", a.Name, p.OS, p.Arch)
+	}
+	pattern := a.Pattern
+	if v.Ext == "" {
+		// A bare binary, e.g. xmlui-mcp-linux-amd64.
+		pattern = strings.ReplaceAll(pattern, ".{ext}", "")
+	}
+	return strings.NewReplacer("{name}", a.Name, "{os}", v.OS, "{arch}", v.Arch, "{ext}", v.Ext).Replace(pattern), nil
+}
+
+// assetURL returns the download URL of component's build for p.
+func assetURL(component string, p platform) (string, error) {
+	a, ok := releaseAssets[component]
+	if !ok {
+		return "", fmt.Errorf("no release assets registered for %s", component)
+	}
+	name, err := a.assetName(p)
+	if err != nil {
+		return "", err
+	}
+	return a.BaseURL + name, nil
+}
+
+// releaseAPI returns the GitHub API URL for the release a.BaseURL points
+// into, or "" if it isn't a GitHub release download URL.
+func (a releaseAsset) releaseAPI() string {
+	owner, repo, tag := a.release()
+	if owner == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/tags/%s", owner, repo, tag)
+}
+
+// release splits a GitHub release download BaseURL into its repository and
+// tag, or returns empty strings.
+func (a releaseAsset) release() (owner, repo, tag string) {
+	u, err := url.Parse(a.BaseURL)
+	if err != nil || u.Host != "github.com" {
+		return "", "", ""
+	}
+	// /owner/repo/releases/download/tag/
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 5 || parts[2] != "releases" || parts[3] != "download" {
+		return "", "", ""
+	}
+	return parts[0], parts[1], parts[4]
+}
+
+// checkReleaseAssets asks the GitHub releases API whether every component's
+// asset for each of platforms exists, so a missing build is reported before
+// anything is downloaded. It returns the components that lack one, with the
+// reason. An unreachable or rate-limited API only produces a warning; the
+// download itself is the final word.
+func checkReleaseAssets(platforms []platform) map[string]error {
+	components := make([]string, 0, len(releaseAssets))
+	for c := range releaseAssets {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+
+	missing := map[string]error{}
+	for _, c := range components {
+		a := releaseAssets[c]
+		for _, p := range platforms {
+			if _, err := a.assetName(p); err != nil {
+				missing[c] = err
+				break
+			}
+		}
+		api := a.releaseAPI()
+		if api == "" || missing[c] != nil {
+			continue
+		}
+		published, err := releaseAssetNames(api)
+		if err != nil {
+			warn("Could not check %s release assets: %v", a.Name, err)
+			continue
+		}
+		for _, p := range platforms {
+			name, _ := a.assetName(p)
+			if !published[name] {
+				var have []string
+				for n := range published {
+					have = append(have, n)
+				}
+				sort.Strings(have)
+				missing[c] = fmt.Errorf("release %s has no %s for %s/%s (it has: %s)", a.BaseURL, name, p.OS, p.Arch, strings.Join(have, ", "))
+				break
+			}
+		}
+	}
+	return missing
+}
+
+// releaseAssetNames fetches a GitHub release and returns its asset names.
+func releaseAssetNames(api string) (map[string]bool, error) {
+	var release struct {
+		Assets []struct{ Name string }
+	}
+	if err := getGitHubJSON(api, &release); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, a := range release.Assets {
+		names[a.Name] = true
+	}
+	return names, nil
+}
+
+// getGitHubJSON fetches a GitHub API URL, with the token if there is one,
+// and decodes the JSON answer into v.
+func getGitHubJSON(api string, v any) error {
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", api, nil)
+	if err != nil {
+		return err
+	}
+	applyRequestHeaders(req)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := network.token(req.URL.Host); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := network.do(req)
+	if err != nil {
+		return fmt.Errorf("%w%s", err, tlsHint(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", api, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
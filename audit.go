@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditLogFile is the append-only record, in the state dir, of every file
+// and directory the launcher creates, writes, links, renames, chmods or
+// deletes. Nothing ever truncates or rewrites it.
+const auditLogFile = "audit.log"
+
+// Audited operations.
+const (
+	auditCreate = "create"
+	auditWrite  = "write"
+	auditMkdir  = "mkdir"
+	auditRename = "rename"
+	auditChmod  = "chmod"
+	auditDelete = "delete"
+	auditLink   = "link"
+)
+
+// auditEntry is one line of the audit log.
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	To      string    `json:"to,omitempty"`
+	Mode    string    `json:"mode,omitempty"`
+}
+
+// auditLog appends entries to the audit log, opening it on first use.
+type auditLog struct {
+	once sync.Once
+	mu   sync.Mutex
+	f    *os.File
+}
+
+var audit auditLog
+
+// record appends an entry for path (and to, for renames). It never fails the
+// operation it describes; if the log can't be opened, that is said once.
+func (a *auditLog) record(op, path, to string, mode os.FileMode) {
+	a.once.Do(a.open)
+	if a.f == nil {
+		return
+	}
+	e := auditEntry{Time: time.Now().UTC(), PID: os.Getpid(), Command: auditCommand(), Op: op, Path: absPath(path)}
+	if to != "" {
+		e.To = absPath(to)
+	}
+	if mode != 0 {
+		e.Mode = fmt.Sprintf("%04o", mode.Perm())
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	a.f.Write(append(line, '\n'))
+	a.mu.Unlock()
+}
+
+// disable keeps the log closed for the rest of the run, for --ephemeral.
+func (a *auditLog) disable() {
+	a.once.Do(func() {})
+}
+
+// open uses os directly: the log and its directory are not themselves
+// audited.
+func (a *auditLog) open() {
+	dir, err := stateDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err == nil {
+		a.f, err = os.OpenFile(filepath.Join(dir, auditLogFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	}
+	if err != nil {
+		fmt.Printf("%s Could not open the audit log: %v\n", labelWarning, err)
+	}
+}
+
+// auditCommand is the subcommand the entries come from.
+func auditCommand() string {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		return os.Args[1]
+	}
+	return "install"
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// fsys performs the launcher's filesystem mutations: each method is the os
+// function of the same name, recorded in the audit log when it succeeds.
+var fsys auditedFS
+
+type auditedFS struct{}
+
+func (auditedFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	op := auditWrite
+	if _, err := os.Lstat(name); os.IsNotExist(err) {
+		op = auditCreate
+	}
+	err := os.WriteFile(name, data, perm)
+	if err == nil {
+		audit.record(op, name, "", perm)
+	}
+	return err
+}
+
+// OpenFile records a create or write if flag opens name for writing.
+func (auditedFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	op := ""
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		op = auditWrite
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			op = auditCreate
+		}
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err == nil && op != "" {
+		audit.record(op, name, "", perm)
+	}
+	return f, err
+}
+
+func (auditedFS) CreateTemp(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err == nil {
+		audit.record(auditCreate, f.Name(), "", 0)
+	}
+	return f, err
+}
+
+// MkdirAll records the shallowest directory it created, if any.
+func (auditedFS) MkdirAll(path string, perm os.FileMode) error {
+	first := ""
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		}
+		first = p
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	err := os.MkdirAll(path, perm)
+	if err == nil && first != "" {
+		audit.record(auditMkdir, first, "", perm)
+	}
+	return err
+}
+
+func (auditedFS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := os.MkdirTemp(dir, pattern)
+	if err == nil {
+		audit.record(auditMkdir, name, "", 0)
+	}
+	return name, err
+}
+
+func (auditedFS) Rename(oldpath, newpath string) error {
+	err := os.Rename(oldpath, newpath)
+	if err == nil {
+		audit.record(auditRename, oldpath, newpath, 0)
+	}
+	return err
+}
+
+// Symlink records the link as created, with the path it points to.
+func (auditedFS) Symlink(target, name string) error {
+	err := os.Symlink(target, name)
+	if err == nil {
+		audit.record(auditLink, name, target, 0)
+	}
+	return err
+}
+
+// Link records the hard link as created, with the file it shares.
+func (auditedFS) Link(existing, name string) error {
+	err := os.Link(existing, name)
+	if err == nil {
+		audit.record(auditLink, name, existing, 0)
+	}
+	return err
+}
+
+func (auditedFS) Chmod(name string, mode os.FileMode) error {
+	err := os.Chmod(name, mode)
+	if err == nil {
+		audit.record(auditChmod, name, "", mode)
+	}
+	return err
+}
+
+func (auditedFS) Remove(name string) error {
+	err := os.Remove(name)
+	if err == nil {
+		audit.record(auditDelete, name, "", 0)
+	}
+	return err
+}
+
+// RemoveAll records one delete for the whole tree, if there was one.
+func (auditedFS) RemoveAll(path string) error {
+	_, statErr := os.Lstat(path)
+	err := os.RemoveAll(path)
+	if err == nil && statErr == nil {
+		audit.record(auditDelete, path, "", 0)
+	}
+	return err
+}
+
+// runAudit implements the `audit` command group.
+func runAudit(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler audit show [--since DURATION] [--op OP] [--path TEXT] [--last N] [--json]")
+		return exitUsage
+	}
+	switch args[0] {
+	case "show":
+		return runAuditShow(args[1:])
+	default:
+		fmt.Printf("Unknown audit command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// runAuditShow implements `audit show`: it prints the audit log, optionally
+// filtered, for review.
+func runAuditShow(args []string) int {
+	fs := newFlagSet("audit show")
+	since := fs.Duration("since", 0, "only show entries newer than this, e.g. 24h")
+	op := fs.String("op", "", "only show this operation: create, write, mkdir, rename, chmod, delete or link")
+	pathText := fs.String("path", "", "only show entries whose path contains this text")
+	last := fs.Int("last", 0, "only show the last N matching entries")
+	asJSON := fs.Bool("json", false, "print the matching entries as JSON lines")
+	fs.Parse(args)
+
+	dir, err := stateDir()
+	if err != nil {
+		fmt.Println("Could not locate the state directory:", err)
+		return exitFailure
+	}
+	logPath := filepath.Join(dir, auditLogFile)
+	f, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		fmt.Println("The audit log is empty: the launcher has changed no files yet")
+		return exitOK
+	}
+	if err != nil {
+		fmt.Println("Could not read the audit log:", err)
+		return exitFailure
+	}
+	defer f.Close()
+
+	var cutoff time.Time
+	if *since > 0 {
+		cutoff = time.Now().Add(-*since)
+	}
+	var entries []auditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	bad := 0
+	for sc.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			bad++
+			continue
+		}
+		if e.Time.Before(cutoff) || *op != "" && e.Op != *op ||
+			*pathText != "" && !strings.Contains(e.Path, *pathText) && !strings.Contains(e.To, *pathText) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Println("Could not read the audit log:", err)
+		return exitFailure
+	}
+	if *last > 0 && len(entries) > *last {
+		entries = entries[len(entries)-*last:]
+	}
+
+	for _, e := range entries {
+		if *asJSON {
+			line, _ := json.Marshal(e)
+			fmt.Println(string(line))
+			continue
+		}
+		target := e.Path
+		if e.To != "" {
+			target += " -> " + e.To
+		}
+		if e.Mode != "" {
+			target += " (" + e.Mode + ")"
+		}
+		fmt.Printf("%s  %-12s %-6s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), fmt.Sprintf("%s[%d]", e.Command, e.PID), e.Op, target)
+	}
+	if !*asJSON {
+		fmt.Printf("%d entries in %s\n", len(entries), logPath)
+		if bad > 0 {
+			fmt.Printf("%s %d lines of the log could not be read\n", labelWarning, bad)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// `auth login` saves the GitHub token in the system keyring where there is
+// one (see systemKeyring), and otherwise in tokenFile in the state dir,
+// sealed as sealToken describes. Either spares lab machines tokens in shell
+// history or plaintext env files.
+const tokenFile = "github-token"
+
+// keyring is a credential store of the OS, holding the one saved token.
+type keyring interface {
+	// name says where the token is, e.g. "the macOS Keychain".
+	name() string
+	// get returns the saved token, or an error satisfying os.IsNotExist
+	// if there is none.
+	get() (string, error)
+	set(token string) error
+	// remove deletes the saved token, if there is one.
+	remove() error
+}
+
+// keyringService and keyringAccount identify the token in a keyring.
+const (
+	keyringService = "xmlui-bundler"
+	keyringAccount = "github-token"
+)
+
+// githubUserAPI is asked who a token belongs to when logging in.
+const githubUserAPI = "https://api.github.com/user"
+
+var (
+	storedTokenOnce sync.Once
+	storedToken     string
+)
+
+// githubToken returns the token to send to GitHub: $GITHUB_TOKEN if set,
+// otherwise the one saved by `auth login`, otherwise "".
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	storedTokenOnce.Do(func() {
+		token, _, err := loadToken()
+		if err != nil && !os.IsNotExist(err) {
+			warn("Could not read the saved GitHub token (%v); run `xmlui-bundler auth login` again", err)
+		}
+		storedToken = token
+	})
+	return storedToken
+}
+
+func tokenPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, tokenFile), nil
+}
+
+// loadToken returns the saved token and where it was found: the system
+// keyring, or else the token file.
+func loadToken() (token, source string, err error) {
+	if kr := systemKeyring(); kr != nil {
+		if token, err := kr.get(); err == nil {
+			return token, kr.name(), nil
+		} else if !os.IsNotExist(err) {
+			return "", "", fmt.Errorf("%s: %w", kr.name(), err)
+		}
+	}
+	path, err := tokenPath()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := unsealToken(data)
+	if err != nil {
+		return "", "", err
+	}
+	return string(sealed), path, nil
+}
+
+// saveToken saves token in the system keyring, or in the token file if
+// there is none or it fails, and says where. A token file left from before
+// is removed once the keyring has the token.
+func saveToken(token string) (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+	if kr := systemKeyring(); kr != nil {
+		err := kr.set(token)
+		if err == nil {
+			if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
+				warn("Could not remove the old token file %s: %v", path, err)
+			}
+			return kr.name(), nil
+		}
+		warn("Could not save the token in %s (%v); saving it to a file instead", kr.name(), err)
+	}
+	sealed, err := sealToken([]byte(token))
+	if err != nil {
+		return "", err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	// Written privately whatever --file-mode says.
+	if err := fsys.WriteFile(path, sealed, 0600); err != nil {
+		return "", err
+	}
+	fmt.Printf("  The token file is %s\n", tokenFileProtection)
+	return path, nil
+}
+
+// runAuth implements the `auth` command group.
+func runAuth(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler auth login|logout|status")
+		return exitUsage
+	}
+	switch args[0] {
+	case "login":
+		return runAuthLogin(args[1:])
+	case "logout":
+		return runAuthLogout(args[1:])
+	case "status":
+		return runAuthStatus(args[1:])
+	default:
+		fmt.Printf("Unknown auth command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// runAuthLogin implements `auth login`: it reads a token from the terminal
+// without echoing it (or from stdin when piped), checks it with GitHub and
+// saves it.
+func runAuthLogin(args []string) int {
+	fs := newFlagSet("auth login")
+	noVerify := fs.Bool("no-verify", false, "save the token without checking it with GitHub")
+	fs.Parse(args)
+
+	var token string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Print("GitHub token (input is hidden): ")
+		b, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			fmt.Println("Could not read the token:", err)
+			return exitFailure
+		}
+		token = string(b)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println("Could not read the token from stdin:", err)
+			return exitFailure
+		}
+		token = line
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		fmt.Println("No token given")
+		return exitFailure
+	}
+
+	if !*noVerify {
+		login, err := githubLogin(token)
+		if err != nil {
+			fmt.Println("GitHub did not accept the token:", err)
+			return exitFailure
+		}
+		fmt.Printf("%s Token belongs to %s\n", glyphOK, login)
+	}
+	path, err := saveToken(token)
+	if err != nil {
+		fmt.Println("Could not save the token:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Saved the token in %s\n", glyphOK, path)
+	if os.Getenv("GITHUB_TOKEN") != "" {
+		fmt.Println("Note: GITHUB_TOKEN is set and takes precedence over the saved token")
+	}
+	return exitOK
+}
+
+// runAuthLogout implements `auth logout`.
+func runAuthLogout(args []string) int {
+	fs := newFlagSet("auth logout")
+	fs.Parse(args)
+
+	removed := false
+	if kr := systemKeyring(); kr != nil {
+		if _, err := kr.get(); err == nil {
+			if err := kr.remove(); err != nil {
+				fmt.Printf("Could not remove the token from %s: %v\n", kr.name(), err)
+				return exitFailure
+			}
+			fmt.Printf("%s Removed the saved token from %s\n", glyphOK, kr.name())
+			removed = true
+		}
+	}
+	path, err := tokenPath()
+	if err != nil {
+		fmt.Println("Could not locate the saved token:", err)
+		return exitFailure
+	}
+	if err := fsys.Remove(path); os.IsNotExist(err) {
+		if !removed {
+			fmt.Println("No saved token")
+		}
+		return exitOK
+	} else if err != nil {
+		fmt.Println("Could not remove the saved token:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Removed the saved token from %s\n", glyphOK, path)
+	return exitOK
+}
+
+// runAuthStatus implements `auth status`: it reports which token would be
+// used, without printing it.
+func runAuthStatus(args []string) int {
+	fs := newFlagSet("auth status")
+	fs.Parse(args)
+
+	source := "GITHUB_TOKEN"
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		var err error
+		token, source, err = loadToken()
+		if os.IsNotExist(err) {
+			fmt.Println("Not logged in; run `xmlui-bundler auth login` or set GITHUB_TOKEN")
+			return exitFailure
+		}
+		if err != nil {
+			fmt.Println("Could not read the saved token:", err)
+			return exitFailure
+		}
+	}
+	login, err := githubLogin(token)
+	if err != nil {
+		fmt.Printf("Token from %s is not accepted by GitHub: %v\n", source, err)
+		return exitFailure
+	}
+	fmt.Printf("%s Logged in to GitHub as %s (token from %s)\n", glyphOK, login, source)
+	return exitOK
+}
+
+// githubLogin returns the login of the user token belongs to.
+func githubLogin(token string) (string, error) {
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", githubUserAPI, nil)
+	if err != nil {
+		return "", err
+	}
+	applyRequestHeaders(req)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := network.do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w%s", err, tlsHint(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
@@ -0,0 +1,211 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sealedTokenMagic starts a token sealed by sealToken.
+var sealedTokenMagic = []byte("xmlui-token-v1\n")
+
+// tokenFileProtection is what protects the token file, said plainly.
+const tokenFileProtection = "readable only by you; its scrambling is no encryption, so keep it out of backups and shared dirs"
+
+// sealToken scrambles token with AES-GCM under a key derived from the
+// machine ID and uid. That is obfuscation, not protection: the machine ID
+// is world-readable, so anyone with a copy of the file and a look at the
+// machine can derive the key. It keeps the token from being read at a
+// glance or found by a grep for "ghp_"; only the file's 0600 permissions
+// keep other users out, which is why the system keyring comes first.
+func sealToken(token []byte) ([]byte, error) {
+	gcm, err := tokenCipher(launcherName)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, sealedTokenMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, token, sealedTokenMagic), nil
+}
+
+func unsealToken(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, sealedTokenMagic) {
+		return nil, fmt.Errorf("not a saved token")
+	}
+	data = data[len(sealedTokenMagic):]
+	// Tokens saved under the launcher's old name have its key.
+	for _, name := range []string{launcherName, legacyLauncherName} {
+		gcm, err := tokenCipher(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < gcm.NonceSize() {
+			return nil, fmt.Errorf("saved token is truncated")
+		}
+		if token, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], sealedTokenMagic); err == nil {
+			return token, nil
+		}
+	}
+	return nil, fmt.Errorf("saved token was made on another machine or account, or is damaged")
+}
+
+// tokenCipher is the cipher of the token key, derived for the launcher
+// named name.
+func tokenCipher(name string) (cipher.AEAD, error) {
+	id, err := machineID()
+	if err != nil {
+		return nil, fmt.Errorf("no machine key: %w", err)
+	}
+	key := sha256.Sum256([]byte(name + " token key\x00" + id + "\x00" + strconv.Itoa(os.Getuid())))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var ioregUUID = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+// machineID returns a stable identifier of this machine: the hardware UUID
+// on macOS, the systemd or D-Bus machine ID elsewhere.
+func machineID() (string, error) {
+	if hostOS.goos() == "darwin" {
+		out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+		if err != nil {
+			return "", err
+		}
+		m := ioregUUID.FindSubmatch(out)
+		if m == nil {
+			return "", fmt.Errorf("ioreg reported no IOPlatformUUID")
+		}
+		return string(m[1]), nil
+	}
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := string(bytes.TrimSpace(data)); id != "" {
+				return id, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no /etc/machine-id")
+}
+
+// systemKeyring is the macOS Keychain, through security(1), or the Secret
+// Service (GNOME Keyring, KWallet) of the desktop session, through
+// libsecret's secret-tool; nil where neither can be used, e.g. over SSH
+// or on a server.
+func systemKeyring() keyring {
+	if hostOS.goos() == "darwin" {
+		if _, err := exec.LookPath("security"); err == nil {
+			return macKeychain{}
+		}
+		return nil
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil
+	}
+	return secretService{}
+}
+
+// macKeychain keeps the token as a generic password in the login keychain.
+type macKeychain struct{}
+
+// errKeychainNotFound is security(1)'s exit status for a missing item.
+const errKeychainNotFound = 44
+
+func (macKeychain) name() string { return "the macOS Keychain" }
+
+func (macKeychain) get() (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w").Output()
+	if exitCode(err) == errKeychainNotFound {
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// set gives the token to security on stdin, in its interactive mode, as on
+// its command line any user's ps would show it.
+func (macKeychain) set(token string) error {
+	if strings.ContainsAny(token, "\"\\ \t\r\n") {
+		return fmt.Errorf("the token has characters security can't take")
+	}
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -w \"%s\"\n", keyringService, keyringAccount, token))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (macKeychain) remove() error {
+	err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", keyringAccount).Run()
+	if exitCode(err) == errKeychainNotFound {
+		return nil
+	}
+	return err
+}
+
+// secretService keeps the token in the desktop session's Secret Service.
+type secretService struct{}
+
+func (secretService) name() string { return "the desktop keyring" }
+
+var secretAttrs = []string{"service", keyringService, "account", keyringAccount}
+
+func (secretService) get() (string, error) {
+	out, err := exec.Command("secret-tool", append([]string{"lookup"}, secretAttrs...)...).Output()
+	// secret-tool exits 1 with no output for a missing secret.
+	if exitCode(err) == 1 && len(out) == 0 {
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// set gives the token to secret-tool on stdin, keeping it off the command
+// line.
+func (secretService) set(token string) error {
+	cmd := exec.Command("secret-tool", append([]string{"store", "--label=xmlui-bundler GitHub token"}, secretAttrs...)...)
+	cmd.Stdin = strings.NewReader(token)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (secretService) remove() error {
+	return exec.Command("secret-tool", append([]string{"clear"}, secretAttrs...)...).Run()
+}
+
+// exitCode is the exit status of the command that returned err, or -1 if
+// it didn't run to an exit.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// tokenEntropy is mixed into DPAPI so the blob is only this program's.
+// Tokens saved under the launcher's old name have legacyTokenEntropy.
+var (
+	tokenEntropy       = []byte(launcherName + " token")
+	legacyTokenEntropy = []byte(legacyLauncherName + " token")
+)
+
+// tokenFileProtection is what protects the token file, said plainly.
+const tokenFileProtection = "encrypted with DPAPI, so only your Windows account on this machine can read it"
+
+// systemKeyring is nil: the token file's DPAPI encryption is what the
+// Credential Manager would use as well.
+func systemKeyring() keyring { return nil }
+
+// sealToken encrypts token with DPAPI, which ties it to the current user
+// on this machine.
+func sealToken(token []byte) ([]byte, error) {
+	return dpapi(token, tokenEntropy, true)
+}
+
+func unsealToken(data []byte) ([]byte, error) {
+	token, err := dpapi(data, tokenEntropy, false)
+	if err != nil {
+		token, err = dpapi(data, legacyTokenEntropy, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("saved token was made on another machine or account: %w", err)
+	}
+	return token, nil
+}
+
+func dpapi(data, entropy []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty token")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	blob := windows.DataBlob{Size: uint32(len(entropy)), Data: &entropy[0]}
+	var out windows.DataBlob
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, &blob, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, &blob, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte{}, unsafe.Slice(out.Data, out.Size)...), nil
+}
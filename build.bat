@@ -1,6 +1,6 @@
 @echo off
 echo Building xmlui-bundler.exe...
-go build -o xmlui-bundler.exe xmlui-bundler.go
+go build -o xmlui-bundler.exe .
 if %errorlevel% neq 0 (
     echo Build failed!
     exit /b %errorlevel%
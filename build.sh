@@ -1,5 +1,5 @@
 #!/bin/bash
-go build xmlui-bundler.go
+go build -o xmlui-bundler .
 chmod +x xmlui-bundler
 
 
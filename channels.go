@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Release channels for --channel. Without one, the launcher installs the
+// releases it was built with.
+const (
+	channelStable  = "stable"  // the latest release of each binary and of XMLUI
+	channelBeta    = "beta"    // the latest release or prerelease
+	channelNightly = "nightly" // the rolling nightly builds and branch heads
+)
+
+// nightlyTag is the release that CI replaces with each nightly build.
+const nightlyTag = "nightly"
+
+// xmluiComponentsURL and xmluiComponentsRef are where the XMLUI components
+// come from; a channel other than nightly moves them to a release tag.
+var (
+	xmluiComponentsURL = xmluiRepoZip
+	xmluiComponentsRef = "main"
+)
+
+// channelFlag accepts a channel name.
+type channelFlag struct{ channel *string }
+
+func (f channelFlag) String() string {
+	if f.channel == nil {
+		return ""
+	}
+	return *f.channel
+}
+
+func (f channelFlag) Set(s string) error {
+	switch s {
+	case "", channelStable, channelBeta, channelNightly:
+		*f.channel = s
+		return nil
+	}
+	return fmt.Errorf("want stable, beta or nightly")
+}
+
+// applyChannel points releaseAssets and the XMLUI components at the
+// releases channel selects, asking the GitHub API which those are.
+func applyChannel(channel string) error {
+	if channel == "" {
+		return nil
+	}
+	components := make([]string, 0, len(releaseAssets))
+	for c := range releaseAssets {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+	for _, c := range components {
+		a := releaseAssets[c]
+		owner, repo, _ := a.release()
+		if owner == "" {
+			return fmt.Errorf("%s is not downloaded from a GitHub release, so --channel can't move it", a.Name)
+		}
+		tag, err := channelTag(owner, repo, channel)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Name, err)
+		}
+		a.BaseURL = fmt.Sprintf("https://github.com/%s/%s/releases/download/%s/", owner, repo, url.PathEscape(tag))
+		releaseAssets[c] = a
+		fmt.Printf("  %s %s: %s\n", channel, a.Name, tag)
+	}
+	if channel == channelNightly {
+		return nil
+	}
+	tag, err := channelTag("xmlui-com", "xmlui", channel)
+	if err != nil {
+		return fmt.Errorf("XMLUI: %w", err)
+	}
+	xmluiComponentsURL = "https://codeload.github.com/xmlui-com/xmlui/zip/refs/tags/" + url.PathEscape(tag)
+	xmluiComponentsRef = tag
+	fmt.Printf("  %s XMLUI: %s\n", channel, tag)
+	return nil
+}
+
+// channelTag returns the release tag of owner/repo that channel selects.
+func channelTag(owner, repo, channel string) (string, error) {
+	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
+	var tag string
+	switch channel {
+	case channelNightly:
+		return nightlyTag, nil
+	case channelStable:
+		var release struct {
+			TagName string `json:"tag_name"`
+		}
+		if err := getGitHubJSON(api+"/latest", &release); err != nil {
+			return "", err
+		}
+		tag = release.TagName
+	case channelBeta:
+		// Newest first, prereleases included.
+		var releases []struct {
+			TagName string `json:"tag_name"`
+		}
+		if err := getGitHubJSON(api+"?per_page=1", &releases); err != nil {
+			return "", err
+		}
+		if len(releases) > 0 {
+			tag = releases[0].TagName
+		}
+	}
+	if tag == "" {
+		return "", fmt.Errorf("no %s release of %s/%s", channel, owner, repo)
+	}
+	return tag, nil
+}
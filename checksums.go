@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Releases publish checksums for their assets in one of two forms: a list
+// in sha256sum format next to the assets (releaseChecksumLists), or an
+// ASSET.sha256 per asset. fetch looks for both when neither the lockfile
+// nor this launcher pins a release asset's checksum, and verifies the
+// download against what it finds. --checksum-file names such a list
+// explicitly, for mirrors that carry the assets but not the release's
+// checksums; it is checked for every download it lists.
+
+// releaseChecksumLists are the names releases publish their checksum list
+// under, in the order they are tried.
+var releaseChecksumLists = []string{"checksums.txt", "SHA256SUMS", "sha256sums.txt"}
+
+// checksumList maps asset names, and the URLs some lists give instead, to
+// their SHA-256.
+type checksumList map[string]string
+
+// parseChecksumList reads sha256sum output: "SHA256  NAME" lines, the name
+// marked binary with a leading *, skipping blank lines and # comments.
+func parseChecksumList(text string) checksumList {
+	sums := checksumList{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") || len(fields[0]) != 64 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// lookup is the checksum the list gives for url, by URL or by file name.
+func (l checksumList) lookup(url string) string {
+	if sum := l[url]; sum != "" {
+		return sum
+	}
+	return l[path.Base(url)]
+}
+
+// loadChecksumFile reads the --checksum-file list from a path or URL.
+func loadChecksumFile(where string) (checksumList, error) {
+	var data []byte
+	var err error
+	if strings.Contains(where, "://") {
+		var status int
+		if data, status, err = getSmall(where); err == nil && status != http.StatusOK {
+			err = fmt.Errorf("%s: %d %s", where, status, http.StatusText(status))
+		}
+	} else {
+		data, err = os.ReadFile(where)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := parseChecksumList(string(data))
+	if len(l) == 0 {
+		return nil, fmt.Errorf("%s lists no SHA-256 checksums", where)
+	}
+	return l, nil
+}
+
+// releaseList is the checksum list a release publishes, and its name.
+type releaseList struct {
+	name string
+	sums checksumList
+}
+
+// releaseLists caches the checksum list of each release directory, nil
+// where it publishes none, as the MCP tools and test server of one release
+// share it.
+var releaseLists = struct {
+	sync.Mutex
+	byDir map[string]*releaseList
+}{byDir: map[string]*releaseList{}}
+
+// discoverChecksum finds the checksum the release of url publishes for it:
+// in ASSET.sha256, else in one of releaseChecksumLists in the same
+// directory. It returns the checksum and the file it came from, or "".
+func discoverChecksum(url string) (string, string) {
+	if sum := publishedChecksum(url); sum != "" {
+		return sum, path.Base(url) + ".sha256"
+	}
+	dir := url[:strings.LastIndex(url, "/")+1]
+	releaseLists.Lock()
+	defer releaseLists.Unlock()
+	list, seen := releaseLists.byDir[dir]
+	if !seen {
+		for _, name := range releaseChecksumLists {
+			data, status, err := getSmall(dir + name)
+			if err != nil || status != http.StatusOK {
+				continue
+			}
+			if sums := parseChecksumList(string(data)); len(sums) > 0 {
+				list = &releaseList{name, sums}
+				break
+			}
+		}
+		releaseLists.byDir[dir] = list
+	}
+	if list != nil {
+		if sum := list.sums.lookup(url); sum != "" {
+			return sum, list.name
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// updateCheckFile, in an install's state dir, records what the last
+// `check-updates` found: the notification file for machines without a
+// desktop, and the app commit later checks compare against.
+const updateCheckFile = "update-check.json"
+
+type updateCheck struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	// InstalledAt is the receipt's when AppCommit was seen, so an update
+	// of the install starts the comparison afresh.
+	InstalledAt time.Time `json:"installedAt"`
+	// AppCommit is the commit the app's branch was at on the first check
+	// after the install: the receipt doesn't record one.
+	AppCommit string            `json:"appCommit,omitempty"`
+	Updates   []componentUpdate `json:"updates"`
+	// Notified is what the last desktop notification announced, so the
+	// same updates aren't announced on every run.
+	Notified []componentUpdate `json:"notified,omitempty"`
+}
+
+// componentUpdate is a newer build of an installed component.
+type componentUpdate struct {
+	Component string `json:"component"`
+	Installed string `json:"installed"`
+	Available string `json:"available"`
+}
+
+// runCheckUpdates implements `check-updates`: it asks where the install's
+// MCP tools, test server and app come from whether there are newer ones,
+// and reports them, without changing the install. --notify is for a cron
+// job or Scheduled Task (see `service install --update-checks`): it also
+// shows a desktop notification when the updates found are new.
+func runCheckUpdates(args []string) int {
+	fs := newFlagSet("check-updates")
+	dir := installDirFlag(fs)
+	notify := fs.Bool("notify", false, "show a desktop notification when there are updates not announced before")
+	var channel string
+	fs.Var(channelFlag{&channel}, "channel", "compare with this release channel, stable, beta or nightly (default: the install's, or stable)")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	if channel == "" {
+		channel = rcpt.Channel
+	}
+	if channel == "" {
+		channel = channelStable
+	}
+	statePath, err := installStatePath(installDir, updateCheckFile)
+	if err != nil {
+		fmt.Println("Could not locate the state directory:", err)
+		return exitFailure
+	}
+	var state updateCheck
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	if !state.InstalledAt.Equal(rcpt.InstalledAt) {
+		state.InstalledAt, state.AppCommit, state.Notified = rcpt.InstalledAt, "", nil
+	}
+
+	fmt.Printf("Checking %s for updates (%s channel)...\n", installDir, channel)
+	code := exitOK
+	state.Updates = nil
+	for _, c := range []string{"mcp", "server", "app"} {
+		var u *componentUpdate
+		var note string
+		if c == "app" {
+			u, note, err = appUpdate(rcpt, &state)
+		} else {
+			u, note, err = releaseUpdate(rcpt, c, channel)
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("  %s %s: %v\n", glyphFail, c, err)
+			code = exitCodeOf(err)
+		case u != nil:
+			fmt.Printf("  %s: %s is available (installed: %s)\n", c, u.Available, u.Installed)
+			state.Updates = append(state.Updates, *u)
+		default:
+			fmt.Printf("  %s %s: %s\n", glyphOK, c, note)
+		}
+	}
+
+	if len(state.Updates) == 0 {
+		fmt.Println("Everything is up to date")
+	} else {
+		fmt.Printf("Run `xmlui-bundler update --dir %s` to install the updates", installDir)
+		if rcpt.Channel == "" && slices.ContainsFunc(state.Updates, func(u componentUpdate) bool { return u.Component != "app" }) {
+			fmt.Printf(" (with --channel %s for the binaries, or get a newer launcher)", channel)
+		}
+		fmt.Println()
+	}
+	if *notify && len(state.Updates) > 0 && !slices.Equal(state.Updates, state.Notified) {
+		if err := desktopNotify("XMLUI updates available", updatesSummary(state.Updates)); err != nil {
+			fmt.Printf("  Could not show a desktop notification (%v); the updates are recorded in %s\n", err, statePath)
+		} else {
+			state.Notified = state.Updates
+		}
+	}
+	state.CheckedAt = time.Now().UTC()
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := fsys.MkdirAll(filepath.Dir(statePath), dirMode); err == nil {
+		err = fsys.WriteFile(statePath, append(data, '\n'), fileMode)
+	}
+	if err != nil {
+		warn("Could not record the check in %s: %v", statePath, err)
+	}
+	return code
+}
+
+// updatesSummary is the text of the notification for updates.
+func updatesSummary(updates []componentUpdate) string {
+	lines := make([]string, len(updates))
+	for i, u := range updates {
+		lines[i] = fmt.Sprintf("%s: %s (installed: %s)", u.Component, u.Available, u.Installed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// releaseUpdate compares the GitHub release the receipt says component came
+// from with the one channel selects now. Without an update, note says why
+// there is none.
+func releaseUpdate(rcpt *receipt, component, channel string) (*componentUpdate, string, error) {
+	c := rcpt.component(component, "")
+	switch {
+	case c.Unavailable != "":
+		return nil, "not installed (" + c.Unavailable + "); `xmlui-bundler update` tries it again", nil
+	case localPathOf(c.Source) != "":
+		return nil, "installed from a local build, " + localPathOf(c.Source), nil
+	}
+	i := strings.LastIndex(c.Source, "/")
+	owner, repo, tag := releaseAsset{BaseURL: c.Source[:i+1]}.release()
+	if owner == "" {
+		return nil, "not from a GitHub release, so not checked", nil
+	}
+	if tag == nightlyTag {
+		// The tag never moves; its assets are replaced.
+		asset := c.Source[i+1:]
+		var release struct {
+			Assets []struct {
+				Name      string
+				UpdatedAt time.Time `json:"updated_at"`
+			}
+		}
+		if err := getGitHubJSON(fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/tags/%s", owner, repo, nightlyTag), &release); err != nil {
+			return nil, "", err
+		}
+		for _, a := range release.Assets {
+			if a.Name == asset && a.UpdatedAt.After(rcpt.InstalledAt) {
+				return &componentUpdate{component, "nightly of " + rcpt.InstalledAt.Format(time.DateOnly), "nightly of " + a.UpdatedAt.Format(time.DateOnly)}, "", nil
+			}
+		}
+		return nil, "the nightly build is the one installed", nil
+	}
+	if channel == channelNightly {
+		return &componentUpdate{component, tag, nightlyTag}, "", nil
+	}
+	latest, err := channelTag(owner, repo, channel)
+	if err != nil {
+		return nil, "", err
+	}
+	if compareVersions(latest, tag) <= 0 {
+		return nil, fmt.Sprintf("%s is the latest %s release", tag, channel), nil
+	}
+	return &componentUpdate{component, tag, latest}, "", nil
+}
+
+// appUpdate reports whether the app's branch has moved on from state's
+// AppCommit, recording the commit it is at if there is none yet.
+func appUpdate(rcpt *receipt, state *updateCheck) (*componentUpdate, string, error) {
+	if rcpt.Flat {
+		return nil, "a --flat install's app is your own project", nil
+	}
+	if p := localPathOf(rcpt.component("app", "").Source); p != "" {
+		return nil, "installed from a local build, " + p, nil
+	}
+	spec, ref := rcpt.AppSource, rcpt.AppRef
+	if spec == "" {
+		spec, ref = defaultAppSource, branchName
+	}
+	if isCommitSHA(ref) {
+		return nil, fmt.Sprintf("pinned to commit %.12s", ref), nil
+	}
+	src, err := parseRepoSource(spec, ref, rcpt.AppProvider)
+	if err != nil {
+		return nil, "", err
+	}
+	if src.Provider == "archive" {
+		return nil, "a plain archive, which can't be checked without downloading it", nil
+	}
+	commit, err := resolveCommit(src, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	switch state.AppCommit {
+	case "":
+		state.AppCommit = commit
+		return nil, fmt.Sprintf("%s is at %.12s; later checks report new commits", ref, commit), nil
+	case commit:
+		return nil, fmt.Sprintf("%s is still at %.12s", ref, commit), nil
+	}
+	return &componentUpdate{"app", fmt.Sprintf("%s at %.12s", ref, state.AppCommit), fmt.Sprintf("%.12s", commit)}, "", nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// launcherTarget is the kind of the launcher's own executable among clean's
+// targets.
+const launcherTarget = "launcher executable"
+
+// runClean implements `clean`: it frees disk space used by the launcher's own
+// leftovers without touching the installed app, tools, or knowledge base.
+func runClean(args []string) int {
+	fs := newFlagSet("clean")
+	dirFlag := installDirFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "list what would be removed without removing it")
+	stagingAge := fs.Duration("staging-age", time.Hour, "only remove staging directories older than this")
+	launcher := fs.Bool("launcher", false, "also remove the cleanup scripts and, if it is in --dir, this launcher, as cleanup.sh and cleanup.bat do")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dirFlag)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+
+	type target struct{ kind, path string }
+	var targets []target
+	if dir, err := network.cacheDir(); err == nil {
+		if _, err := os.Stat(dir); err == nil {
+			targets = append(targets, target{"download cache", dir})
+		}
+	}
+	for _, parent := range []string{installDir, os.TempDir()} {
+		for _, dir := range staleStagingDirs(parent, *stagingAge) {
+			targets = append(targets, target{"staging directory", dir})
+		}
+	}
+	for _, pattern := range []string{"*.zip", "*.tar.gz", "*.tgz"} {
+		matches, _ := filepath.Glob(filepath.Join(installDir, pattern))
+		for _, m := range matches {
+			targets = append(targets, target{"leftover archive", m})
+		}
+	}
+
+	if *launcher {
+		for _, name := range []string{"cleanup.sh", "cleanup.bat", "cleanup.ps1"} {
+			p := filepath.Join(installDir, name)
+			if _, err := os.Stat(p); err == nil {
+				targets = append(targets, target{"cleanup script", p})
+			}
+		}
+		if exe, err := os.Executable(); err == nil {
+			if exe, err = filepath.EvalSymlinks(exe); err == nil && pathContains([]string{installDir}, filepath.Dir(exe)) {
+				targets = append(targets, target{launcherTarget, exe})
+			}
+		}
+	}
+
+	// State of installs whose directory has since been deleted.
+	index, err := readInstallIndex()
+	if err == nil {
+		for _, dir := range orphanedStateDirs(index) {
+			targets = append(targets, target{"state of a removed install", dir})
+		}
+	}
+
+	if len(targets) == 0 {
+		fmt.Println("Nothing to clean")
+		return exitOK
+	}
+
+	var reclaimed int64
+	status := exitOK
+	for _, t := range targets {
+		size := dirSize(t.path)
+		if *dryRun {
+			fmt.Printf("Would remove %s %s (%s)\n", t.kind, t.path, humanBytes(size))
+			reclaimed += size
+			continue
+		}
+		remove := fsys.RemoveAll
+		if t.kind == launcherTarget {
+			// Windows can't remove a running executable directly.
+			remove = func(string) error { return selfDelete() }
+		}
+		if err := remove(t.path); err != nil {
+			fmt.Printf("%s Could not remove %s: %v\n", labelWarning, t.path, err)
+			status = exitFilesystem
+			continue
+		}
+		fmt.Printf("Removed %s %s (%s)\n", t.kind, t.path, humanBytes(size))
+		reclaimed += size
+	}
+	for _, parent := range []string{installDir, os.TempDir()} {
+		fsys.Remove(filepath.Join(parent, stagingDirName))
+	}
+	if index != nil && !*dryRun {
+		index.write()
+	}
+
+	if *dryRun {
+		fmt.Printf("Would reclaim %s\n", humanBytes(reclaimed))
+	} else {
+		fmt.Printf("%s Reclaimed %s\n", glyphOK, humanBytes(reclaimed))
+	}
+	return status
+}
+
+// humanBytes formats a byte count as B, KB, MB or GB.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
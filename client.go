@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// clientOptions is everything that decides how the launcher talks to the
+// network. Nothing builds its own client or reads credentials directly; it
+// all goes through network, which the TLS and retry flags configure in one
+// place. It is internal to the launcher, not an extension point: code
+// outside package main can't reach it.
+type clientOptions struct {
+	// transport carries requests; nil means http.DefaultTransport.
+	// configureTLS replaces it.
+	transport http.RoundTripper
+	retry     retryPolicy
+	// cacheDir returns the per-user download cache.
+	cacheDir func() (string, error)
+	// auth supplies tokens for requests to hosts that need them.
+	auth authProvider
+}
+
+// authProvider returns the token to send to host, or "" for none.
+type authProvider interface {
+	token(host string) string
+}
+
+// envOrLoginAuth is the default authProvider: $GITHUB_TOKEN or the token
+// saved by `auth login`, for every host that asks.
+type envOrLoginAuth struct{}
+
+func (envOrLoginAuth) token(host string) string { return githubToken() }
+
+// retryPolicy says how often and how patiently a failed request is retried.
+// Only network errors and 429/502/503/504 responses are retried; a
+// Retry-After header is honored up to maxBackoff.
+type retryPolicy struct {
+	// attempts is the total number of tries; values below 1 mean 1.
+	attempts int
+	// backoff is the wait before the first retry, doubled for each one
+	// after it up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// stall is how long a download may receive nothing before it is
+	// retried (see streamDownloadFrom); 0 waits forever.
+	stall time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{attempts: 3, backoff: time.Second, maxBackoff: 30 * time.Second, stall: defaultStallTimeout}
+
+// network holds the options in effect.
+var network = &clientOptions{
+	retry:    defaultRetryPolicy,
+	cacheDir: cacheDir,
+	auth:     envOrLoginAuth{},
+}
+
+func (o *clientOptions) httpClient() *http.Client {
+	return &http.Client{Transport: storageTransport{o.transport}}
+}
+
+// token returns the token for host from the auth provider.
+func (o *clientOptions) token(host string) string {
+	if o.auth == nil {
+		return ""
+	}
+	return o.auth.token(host)
+}
+
+// do sends req, retrying per the retry policy. Requests are GETs without
+// bodies, so they can be resent as they are.
+func (o *clientOptions) do(req *http.Request) (*http.Response, error) {
+	client := o.httpClient()
+	attempts := max(o.retry.attempts, 1)
+	wait := o.retry.backoff
+	rec, started := metrics.start(req.URL.String()), time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if attempt == attempts || !retryable(req.Context(), resp, err) {
+			metrics.update(rec, func(r *requestRecord) {
+				r.Retries = attempt - 1
+				r.LatencyMs = time.Since(started).Milliseconds()
+				if err != nil {
+					r.Error = err.Error()
+				} else {
+					r.Status = resp.StatusCode
+				}
+			})
+			return resp, err
+		}
+		delay := wait
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				delay = time.Duration(s) * time.Second
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		delay = min(delay, o.retry.maxBackoff)
+		fmt.Printf("  %v; retrying in %v (attempt %d of %d)\n", err, delay, attempt+1, attempts)
+		select {
+		case <-req.Context().Done():
+			metrics.update(rec, func(r *requestRecord) {
+				r.Retries = attempt
+				r.LatencyMs = time.Since(started).Milliseconds()
+				r.Error = req.Context().Err().Error()
+			})
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		wait = min(2*wait, o.retry.maxBackoff)
+	}
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// Certificate problems won't go away by themselves.
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && tlsHint(err) == ""
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retriesFlag sets how many times a failed request is retried.
+type retriesFlag struct{ p *retryPolicy }
+
+func (f retriesFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return strconv.Itoa(f.p.attempts - 1)
+}
+
+func (f retriesFlag) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a number of retries, got %q", s)
+	}
+	f.p.attempts = n + 1
+	return nil
+}
@@ -0,0 +1,61 @@
+// Command xmlui-dist writes the Homebrew formula and Scoop manifest for a
+// launcher release. The release workflow runs it after computing checksums:
+//
+//	go run ./cmd/xmlui-dist -tag v1.2.3 -checksums checksums.txt -out release_assets
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jonudell/xmlui-bundler/dist"
+)
+
+func main() {
+	tag := flag.String("tag", "", "release tag, e.g. v1.2.3")
+	checksums := flag.String("checksums", "checksums.txt", "sha256sum-format checksums of the release assets")
+	baseURL := flag.String("base-url", "", "download URL prefix for the assets (default: GitHub release for -tag)")
+	out := flag.String("out", ".", "directory to write xmlui-bundler.rb and xmlui-bundler.json into")
+	flag.Parse()
+
+	if *tag == "" {
+		fmt.Fprintln(os.Stderr, "xmlui-dist: -tag is required")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*checksums)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xmlui-dist:", err)
+		os.Exit(1)
+	}
+	sums, err := dist.ParseChecksums(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xmlui-dist:", err)
+		os.Exit(1)
+	}
+
+	r := dist.Release{Tag: *tag, BaseURL: *baseURL, Checksums: sums}
+	outputs := []struct {
+		name string
+		gen  func(dist.Release) ([]byte, error)
+	}{
+		{dist.Binary + ".rb", dist.HomebrewFormula},
+		{dist.Binary + ".json", dist.ScoopManifest},
+	}
+	for _, o := range outputs {
+		data, err := o.gen(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "xmlui-dist:", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*out, o.name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "xmlui-dist:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Wrote", path)
+	}
+}
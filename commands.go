@@ -0,0 +1,435 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is one top-level command of the launcher. The table below is the
+// one place commands are declared: main dispatches through it, and help,
+// the flag sets' --help (see newFlagSet) and the tui and gui front ends
+// are generated from it.
+type command struct {
+	name    string
+	aliases []string
+	group   string
+	// usage is what follows the command's name in its synopsis.
+	usage   string
+	summary string
+	// subcommands, for a command group, are its own commands, dispatched
+	// by run itself.
+	subcommands []string
+	examples    []string
+	run         func(args []string) int
+}
+
+// commandGroups are the headings of help, in order.
+var commandGroups = []string{"Install", "Run", "Check", "Share and deploy", "Housekeeping", "Front ends"}
+
+// globalFlags are taken by every command, anywhere before a "--" (see
+// plainOutput).
+var globalFlags = []struct{ name, help string }{
+	{"--plain", "sequential plain-text output without progress redraws, glyphs or color, for screen readers and logs (or set XMLUI_PLAIN)"},
+	{"--no-color", "no ANSI colors (or set NO_COLOR)"},
+	{"-h, --help", "the command's flags and examples"},
+}
+
+// commands is filled in by init, as help and the front ends refer to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "install", aliases: []string{"i"}, group: "Install", usage: "[flags]",
+			summary: "install or repair the app, MCP tools and test server (what running without a command does)",
+			examples: []string{
+				"xmlui-bundler install --dir ~/xmlui",
+				"xmlui-bundler install --profile classroom --locked",
+				"xmlui-bundler install --flat --dir ./my-app",
+			},
+			run: runInstall},
+		{name: "update", aliases: []string{"up", "upgrade"}, group: "Install", usage: "[flags]",
+			summary: "download newer components into an install, rewriting only changed files",
+			examples: []string{
+				"xmlui-bundler update --dir ~/xmlui",
+				"xmlui-bundler update --channel beta",
+			},
+			run: runUpdate},
+		{name: "migrate", group: "Install", usage: "[--dir DIR] [--dry-run]",
+			summary:  "bring an install made by an older launcher up to this one's layout, reconstructing its receipt",
+			examples: []string{"xmlui-bundler migrate --dry-run"},
+			run:      runMigrate},
+		{name: "lock", group: "Install", usage: "[--dir DIR] [flags]",
+			summary: "pin every download to a commit or release and checksum in " + lockFile,
+			examples: []string{
+				"xmlui-bundler lock --dir ~/xmlui",
+				"xmlui-bundler lock --features pdf --xmlui-npm latest",
+			},
+			run: runLock},
+		{name: "init", group: "Install", usage: "[flags] NAME",
+			summary: "scaffold a new XMLUI app with a test server",
+			examples: []string{
+				"xmlui-bundler init my-app",
+				"xmlui-bundler init --mcp --port 8090 my-app",
+			},
+			run: runInit},
+		{name: "serve", aliases: []string{"start"}, group: "Run", usage: "[--dir DIR] [flags]",
+			summary: "start the test server for the installed app and wait until it answers",
+			examples: []string{
+				"xmlui-bundler serve --dir ~/xmlui",
+				"xmlui-bundler serve --watch",
+			},
+			run: runServe},
+		{name: "playground", aliases: []string{"try"}, group: "Run", usage: "[--port N] [--keep] [-- INSTALL FLAGS]",
+			summary: "a throwaway install in a temporary directory, deleted when the server stops",
+			examples: []string{
+				"xmlui-bundler playground",
+				"xmlui-bundler playground -- --features animations",
+			},
+			run: runPlayground},
+		{name: "watch", group: "Run", usage: "[--dir DIR] [flags]",
+			summary:  "follow the app's branch and apply each new commit to the install",
+			examples: []string{"xmlui-bundler watch --serve"},
+			run:      runWatch},
+		{name: "mcp", group: "Run", usage: "COMMAND [flags]", subcommands: []string{"test", "client", "index", "prepare"},
+			summary: "the installed MCP tools: smoke test, interactive client, knowledge-base index, quarantine and permissions",
+			examples: []string{
+				"xmlui-bundler mcp test --query Button",
+				"xmlui-bundler mcp client",
+				"xmlui-bundler mcp index --query Table",
+				"xmlui-bundler mcp prepare",
+			},
+			run: runMCP},
+		{name: "server", group: "Run", usage: "COMMAND [flags]", subcommands: []string{"test", "static"},
+			summary: "the test server: check the app's routes, or serve an app as static files",
+			examples: []string{
+				"xmlui-bundler server test --dir ~/xmlui",
+				"xmlui-bundler server static --port 8081 ./xmlui-invoice",
+			},
+			run: runServer},
+		{name: "doctor", group: "Check", usage: "[--dir DIR] [--fix]",
+			summary:  "check every installed file against the receipt, and with --fix restore damaged ones",
+			examples: []string{"xmlui-bundler doctor --fix"},
+			run:      runDoctor},
+		{name: "smoke", aliases: []string{"verify"}, group: "Check", usage: "[--dir DIR] [flags]",
+			summary:  "run every post-install check in a row and end with one pass/fail",
+			examples: []string{"xmlui-bundler smoke --dir ~/xmlui"},
+			run:      runSmoke},
+		{name: "check-updates", aliases: []string{"outdated"}, group: "Check", usage: "[--dir DIR] [--notify]",
+			summary:  "report newer MCP tools, test server and app without changing the install",
+			examples: []string{"xmlui-bundler check-updates"},
+			run:      runCheckUpdates},
+		{name: "provenance", group: "Check", usage: "[--dir DIR] [--json]",
+			summary:  "where each installed binary came from, its checksum and signature",
+			examples: []string{"xmlui-bundler provenance --json"},
+			run:      runProvenance},
+		{name: "audit", group: "Check", usage: "show [flags]", subcommands: []string{"show"},
+			summary:  "the log of every file the launcher wrote, moved or removed",
+			examples: []string{"xmlui-bundler audit show --since 24h --op delete"},
+			run:      runAudit},
+		{name: "stats", group: "Check", usage: "[--last N] [--json]",
+			summary:  "the recorded download history, by run and by host",
+			examples: []string{"xmlui-bundler stats --last 5"},
+			run:      runStats},
+		{name: "where", aliases: []string{"which"}, group: "Check", usage: "[BINARY]...",
+			summary:  "every match on PATH for the installed binaries, first match first",
+			examples: []string{"xmlui-bundler where xmlui-mcp"},
+			run:      runWhere},
+		{name: "env", group: "Check", usage: "[--dir DIR] [--shell SHELL]",
+			summary:  "the environment variables for an install, to eval in a shell",
+			examples: []string{`eval "$(xmlui-bundler env)"`},
+			run:      runEnv},
+		{name: "export-manifest", aliases: []string{"export"}, group: "Share and deploy", usage: "[--dir DIR] [--out DIR] [--force]",
+			summary: "turn an install's receipt into a manifest and lockfile others can install from",
+			examples: []string{
+				"xmlui-bundler export-manifest --out ./share",
+				"xmlui-bundler --manifest ./share --dir ~/xmlui",
+			},
+			run: runExportManifest},
+		{name: "dockerize", aliases: []string{"docker"}, group: "Share and deploy", usage: "[--dir DIR] [--out DIR] [--force]",
+			summary:  "write a Docker build context and compose file for the installed app",
+			examples: []string{"xmlui-bundler dockerize --out ./docker"},
+			run:      runDockerize},
+		{name: "service", group: "Share and deploy", usage: "install|uninstall [flags]", subcommands: []string{"install", "uninstall"},
+			summary: "run the test server as a login service (launchd, systemd or a Scheduled Task)",
+			examples: []string{
+				"xmlui-bundler service install --update-checks weekly",
+				"xmlui-bundler service uninstall",
+			},
+			run: runService},
+		{name: "clean", group: "Housekeeping", usage: "[--dir DIR] [flags]",
+			summary:  "free the disk space of the launcher's leftovers, without touching the install",
+			examples: []string{"xmlui-bundler clean --dry-run"},
+			run:      runClean},
+		{name: "reset-data", group: "Housekeeping", usage: "[--dir DIR] [--yes]",
+			summary:  "restore the demo databases to their installed state",
+			examples: []string{"xmlui-bundler reset-data --yes"},
+			run:      runResetData},
+		{name: "auth", group: "Housekeeping", usage: "login|logout|status", subcommands: []string{"login", "logout", "status"},
+			summary:  "store, remove or show the GitHub token for downloads",
+			examples: []string{"xmlui-bundler auth login", "xmlui-bundler auth status"},
+			run:      runAuth},
+		{name: "telemetry", group: "Housekeeping", usage: "status|on|off", subcommands: []string{"status", "on", "off"},
+			summary:  "the anonymous usage report setting",
+			examples: []string{"xmlui-bundler telemetry status"},
+			run:      runTelemetry},
+		{name: "version", group: "Housekeeping", usage: "",
+			summary: "print the launcher's version",
+			run:     runVersion},
+		{name: "help", group: "Housekeeping", usage: "[COMMAND]",
+			summary:  "the commands, or one command's flags and examples",
+			examples: []string{"xmlui-bundler help", "xmlui-bundler help update"},
+			run:      runHelp},
+		{name: "cli", group: "Front ends", usage: "[COMMAND] [flags]",
+			summary:  "the command line, as without a front end: cli update is update, cli alone installs",
+			examples: []string{"xmlui-bundler cli --dir ~/xmlui", "xmlui-bundler cli doctor"},
+			run:      runCLI},
+		{name: "tui", aliases: []string{"menu"}, group: "Front ends", usage: "",
+			summary:  "pick commands from a menu in the terminal",
+			examples: []string{"xmlui-bundler tui"},
+			run:      runTUI},
+		{name: "gui", group: "Front ends", usage: "[--port N] [--no-open]",
+			summary:  "pick commands on a page in the browser, served on 127.0.0.1 for this session",
+			examples: []string{"xmlui-bundler gui", "xmlui-bundler gui --no-open --port 9090"},
+			run:      runGUI},
+	}
+}
+
+// lookupCommand finds a command by name or alias.
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name || containsString(commands[i].aliases, name) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// dispatch runs the command args name with the rest of args. Command
+// groups answer --help themselves, as their dispatchers only know their
+// own commands.
+func dispatch(args []string) int {
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Printf("Unknown command: %s%s\n", args[0], suggestCommand(args[0]))
+		fmt.Println("Run `xmlui-bundler help` for the commands")
+		return exitUsage
+	}
+	rest := args[1:]
+	if len(cmd.subcommands) > 0 && len(rest) > 0 && isHelpFlag(rest[0]) {
+		printCommandHelp(os.Stdout, cmd)
+		return exitOK
+	}
+	return cmd.run(rest)
+}
+
+func isHelpFlag(a string) bool {
+	return a == "-h" || a == "-help" || a == "--help"
+}
+
+func isVersionFlag(a string) bool {
+	return a == "-version" || a == "--version"
+}
+
+// suggestCommand is " (did you mean NAME?)" for a mistyped command name,
+// or "".
+func suggestCommand(name string) string {
+	best, bestDist := "", 3
+	for _, c := range commands {
+		for _, n := range append([]string{c.name}, c.aliases...) {
+			d := editDistance(name, n)
+			if strings.HasPrefix(n, name) && len(name) >= 2 {
+				d = 1
+			}
+			if d < bestDist {
+				best, bestDist = c.name, d
+			}
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// newFlagSet is flag.NewFlagSet for the command (or "group command")
+// name, whose --help shows the command's synopsis, aliases and examples
+// from the table around its flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fields := strings.Fields(name)
+		cmd := lookupCommand(fields[0])
+		if cmd == nil {
+			fmt.Fprintf(out, "Usage: xmlui-bundler %s [flags]\n", name)
+			fs.PrintDefaults()
+			return
+		}
+		usage := cmd.usage
+		if len(fields) > 1 {
+			usage = "[flags]"
+		}
+		fmt.Fprintf(out, "Usage: xmlui-bundler %s %s\n", name, usage)
+		if len(fields) == 1 {
+			fmt.Fprintf(out, "\n%s.\n", capitalize(cmd.summary))
+			if len(cmd.aliases) > 0 {
+				fmt.Fprintf(out, "Aliases: %s\n", strings.Join(cmd.aliases, ", "))
+			}
+		}
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+		printExamples(out, cmd, name)
+		printGlobalFlags(out)
+	}
+	return fs
+}
+
+// printCommandHelp describes cmd, for help COMMAND and the --help of a
+// command group.
+func printCommandHelp(out io.Writer, cmd *command) {
+	fmt.Fprintf(out, "Usage: xmlui-bundler %s %s\n\n%s.\n", cmd.name, cmd.usage, capitalize(cmd.summary))
+	if len(cmd.aliases) > 0 {
+		fmt.Fprintf(out, "Aliases: %s\n", strings.Join(cmd.aliases, ", "))
+	}
+	if len(cmd.subcommands) > 0 {
+		fmt.Fprintf(out, "\nCommands: %s\nEach takes --help for its flags.\n", strings.Join(cmd.subcommands, ", "))
+	}
+	printExamples(out, cmd, cmd.name)
+	printGlobalFlags(out)
+}
+
+// printExamples prints those of cmd's examples that run name.
+func printExamples(out io.Writer, cmd *command, name string) {
+	var examples []string
+	for _, e := range cmd.examples {
+		rest, ok := strings.CutPrefix(e, "xmlui-bundler "+name)
+		if ok && (rest == "" || rest[0] == ' ') || !strings.HasPrefix(e, "xmlui-bundler ") && name == cmd.name {
+			examples = append(examples, e)
+		}
+	}
+	if len(examples) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nExamples:")
+	for _, e := range examples {
+		fmt.Fprintf(out, "  %s\n", e)
+	}
+}
+
+func printGlobalFlags(out io.Writer) {
+	fmt.Fprintln(out, "\nGlobal flags:")
+	for _, f := range globalFlags {
+		fmt.Fprintf(out, "  %-12s %s\n", f.name, f.help)
+	}
+}
+
+// printCommands is the overview help prints: every command by group.
+func printCommands(out io.Writer) {
+	fmt.Fprintln(out, "xmlui-bundler installs the XMLUI invoice app, the XMLUI MCP tools and the test server, and looks after the install.")
+	fmt.Fprintln(out, "\nUsage:")
+	fmt.Fprintln(out, "  xmlui-bundler [flags]            install, as `install`")
+	fmt.Fprintln(out, "  xmlui-bundler COMMAND [flags]")
+	fmt.Fprintln(out, "  xmlui-bundler help COMMAND       a command's flags and examples")
+	fmt.Fprintln(out, "  xmlui-bundler --version          the launcher's version, as `version`")
+	for _, g := range commandGroups {
+		fmt.Fprintf(out, "\n%s:\n", g)
+		for _, c := range commands {
+			if c.group != g {
+				continue
+			}
+			names := strings.Join(append([]string{c.name}, c.aliases...), ", ")
+			fmt.Fprintf(out, "  %-26s %s\n", names, c.summary)
+		}
+	}
+	printGlobalFlags(out)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// runHelp implements `help [COMMAND]`.
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printCommands(os.Stdout)
+		return exitOK
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Printf("Unknown command: %s%s\n", args[0], suggestCommand(args[0]))
+		return exitUsage
+	}
+	if len(cmd.subcommands) > 0 || cmd.name == "help" || cmd.name == "version" {
+		printCommandHelp(os.Stdout, cmd)
+		return exitOK
+	}
+	// The command's flag set prints its help and exits.
+	return cmd.run([]string{"--help"})
+}
+
+// runVersion implements `version`.
+func runVersion(args []string) int {
+	fmt.Println(launcherName, version)
+	return exitOK
+}
+
+// runInstall implements `install`, which is also what the launcher does
+// without a command.
+func runInstall(args []string) int {
+	var opts installOptions
+	fs := newFlagSet("install")
+	installFlags(fs, &opts)
+	args, err := expandProfiles(args)
+	if err != nil {
+		fmt.Println("Invalid --profile:", err)
+		return exitUsage
+	}
+	if args, err = expandManifest(args); err != nil {
+		fmt.Println("Invalid --manifest:", err)
+		return exitUsage
+	}
+	fs.Parse(args)
+
+	install(opts)
+	return exitOK
+}
+
+// runCLI implements `cli`: the command line itself, for symmetry with the
+// other front ends and for scripts that name the mode they want.
+func runCLI(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runInstall(args)
+	}
+	if c := lookupCommand(args[0]); c != nil && c.group == "Front ends" {
+		fmt.Printf("cli runs commands, not the %s front end\n", c.name)
+		return exitUsage
+	}
+	return dispatch(args)
+}
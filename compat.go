@@ -0,0 +1,343 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The compatibility matrix says which versions of the XMLUI runtime, the
+// MCP tools, the test server and the launcher work together, and which
+// XMLUI an app needs. Install and update check the versions they installed
+// against it and refuse, or warn, rather than leave an install that breaks
+// once it runs.
+//
+//go:embed compat.json
+var builtinCompatMatrix []byte
+
+// compatMatrixURL is where a newer matrix is fetched from, set at build time
+// with -ldflags "-X main.compatMatrixURL=https://..." or by "compatURL" in
+// the config file; without one the built-in matrix is used. The last one
+// fetched is kept in the cache dir for when the fetch fails.
+var compatMatrixURL = ""
+
+const (
+	compatCacheFile = "compat.json"
+	// appRequirementsFile is where an app states the versions it needs,
+	// e.g. {"xmlui": ">=0.9"}.
+	appRequirementsFile = "xmlui-requirements.json"
+)
+
+// compatMatrix is the format of compat.json.
+type compatMatrix struct {
+	Updated string       `json:"updated,omitempty"`
+	Rules   []compatRule `json:"rules"`
+	// Apps maps an app repository (owner/repo) to the versions it needs.
+	Apps map[string]compatRequirements `json:"apps,omitempty"`
+}
+
+// compatRequirements maps a part of the install (xmlui, mcp, server or
+// launcher) to a version constraint: space-separated comparisons such as
+// ">=0.9 <2", where a bare version matches itself and its patch releases.
+type compatRequirements map[string]string
+
+// compatRule requires the versions in Require of an install whose versions
+// match If. Level "error" fails the install, "warn" warns.
+type compatRule struct {
+	If      compatRequirements `json:"if"`
+	Require compatRequirements `json:"require"`
+	Level   string             `json:"level"`
+	Reason  string             `json:"reason,omitempty"`
+}
+
+// compatProblem is a requirement an install's versions don't meet.
+type compatProblem struct {
+	fatal bool
+	msg   string
+}
+
+// loadCompatMatrix reads the matrix override names (a file or URL), else
+// fetches the configured one, falling back to the last one fetched and
+// then to the built-in one.
+func loadCompatMatrix(override string) (*compatMatrix, string, error) {
+	where := override
+	if where == "" {
+		where = compatMatrixURL
+		if cfg, _, err := readConfig(); err == nil && cfg.CompatURL != "" {
+			where = cfg.CompatURL
+		}
+	}
+	if where == "" {
+		m, err := parseCompatMatrix(builtinCompatMatrix)
+		return m, "built in", err
+	}
+	var data []byte
+	var err error
+	if strings.Contains(where, "://") {
+		data, _, err = downloadAsset(where, "compatibility matrix", "")
+	} else {
+		data, err = os.ReadFile(where)
+	}
+	if err == nil {
+		var m *compatMatrix
+		if m, err = parseCompatMatrix(data); err == nil {
+			if dir, derr := cacheDir(); derr == nil && fsys.MkdirAll(dir, dirMode) == nil {
+				fsys.WriteFile(filepath.Join(dir, compatCacheFile), data, fileMode)
+			}
+			return m, where, nil
+		}
+	}
+	if override != "" {
+		return nil, where, err
+	}
+	fmt.Printf("  Could not fetch the compatibility matrix from %s (%v)\n", where, err)
+	if dir, derr := cacheDir(); derr == nil {
+		if data, rerr := os.ReadFile(filepath.Join(dir, compatCacheFile)); rerr == nil {
+			if m, perr := parseCompatMatrix(data); perr == nil {
+				return m, "cached", nil
+			}
+		}
+	}
+	m, err := parseCompatMatrix(builtinCompatMatrix)
+	return m, "built in", err
+}
+
+func parseCompatMatrix(data []byte) (*compatMatrix, error) {
+	var m compatMatrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for i, r := range m.Rules {
+		if r.Level != "error" && r.Level != "warn" {
+			return nil, fmt.Errorf("rule %d: level %q: want error or warn", i+1, r.Level)
+		}
+		for _, reqs := range []compatRequirements{r.If, r.Require} {
+			if err := reqs.check(); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+		}
+	}
+	for app, reqs := range m.Apps {
+		if err := reqs.check(); err != nil {
+			return nil, fmt.Errorf("app %s: %w", app, err)
+		}
+	}
+	return &m, nil
+}
+
+// check reports a constraint of reqs that is not well formed.
+func (reqs compatRequirements) check() error {
+	for part, constraint := range reqs {
+		switch part {
+		case "xmlui", "mcp", "server", "launcher":
+		default:
+			return fmt.Errorf("unknown part %q: want xmlui, mcp, server or launcher", part)
+		}
+		for _, c := range strings.Fields(constraint) {
+			if !compatConstraintRe.MatchString(c) {
+				return fmt.Errorf("%s: %q is not a version comparison", part, c)
+			}
+		}
+	}
+	return nil
+}
+
+var compatConstraintRe = regexp.MustCompile(`^(?:[<>]=?|=)?v?\d+(?:\.\d+){0,2}(?:-[0-9A-Za-z.]+)?$`)
+
+// satisfies reports whether version v meets constraint.
+func satisfies(v, constraint string) bool {
+	for _, c := range strings.Fields(constraint) {
+		op := c[:len(c)-len(strings.TrimLeft(c, "<>="))]
+		want := c[len(op):]
+		cmp := compareVersions(v, want)
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=":
+			ok = cmp == 0
+		default:
+			ok = v == want || strings.HasPrefix(v, want+".") || strings.HasPrefix(v, want+"-")
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// installedVersions are the versions of the parts of rcpt's install that
+// can be told: the XMLUI runtime the app loads (else the npm assets, else
+// the component snapshot's release), the MCP tools' and test server's
+// releases, and this launcher's. Branch heads and dev builds have none.
+func installedVersions(rcpt *receipt) map[string]string {
+	versions := map[string]string{}
+	tagVersion := func(tag string) string {
+		return runtimeVersionRe.FindString(tag)
+	}
+	for _, name := range []string{"components", xmluiNPMComponent, xmluiRuntimeComponent} {
+		for _, c := range rcpt.Components {
+			if c.Name != name || c.Unavailable != "" || len(c.Downloads) == 0 {
+				continue
+			}
+			d := c.Downloads[0]
+			tag := d.Tag
+			if _, ref, ok := strings.Cut(d.URL, "/refs/tags/"); ok && tag == "" {
+				tag = ref
+			}
+			if v := tagVersion(tag); v != "" {
+				versions["xmlui"] = v
+			}
+		}
+	}
+	for _, c := range rcpt.Components {
+		if c.Name != "mcp" && c.Name != "server" || c.Unavailable != "" {
+			continue
+		}
+		for _, d := range c.Downloads {
+			if v := tagVersion(d.Tag); v != "" {
+				versions[c.Name] = v
+				break
+			}
+		}
+		if versions[c.Name] == "" {
+			for _, b := range c.Binaries {
+				if v := tagVersion(b.Version); v != "" {
+					versions[c.Name] = v
+					break
+				}
+			}
+		}
+	}
+	if v := tagVersion(version); v != "" {
+		versions["launcher"] = v
+	}
+	return versions
+}
+
+// appRequirements are the versions the app in appDir needs: those its
+// appRequirementsFile states, else the matrix's entry for its repository.
+func appRequirements(m *compatMatrix, rcpt *receipt, appDir string) (compatRequirements, string, error) {
+	data, err := os.ReadFile(filepath.Join(appDir, appRequirementsFile))
+	if err == nil {
+		var reqs compatRequirements
+		if err := json.Unmarshal(data, &reqs); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", appRequirementsFile, err)
+		}
+		if err := reqs.check(); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", appRequirementsFile, err)
+		}
+		return reqs, appRequirementsFile, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+	source := rcpt.AppSource
+	if source == "" {
+		source = defaultAppSource
+	}
+	source = strings.TrimSuffix(strings.TrimSuffix(source, "/"), ".git")
+	for app, reqs := range m.Apps {
+		if source == app || strings.HasSuffix(source, "/"+app) {
+			return reqs, "the matrix's entry for " + app, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// checkCompat lists the requirements of the matrix and the app in appDir
+// that rcpt's versions don't meet. A requirement on a version that can't be
+// told is not checked.
+func checkCompat(m *compatMatrix, rcpt *receipt, appDir string) ([]compatProblem, map[string]string, error) {
+	versions := installedVersions(rcpt)
+	var problems []compatProblem
+	unmet := func(reqs compatRequirements) []string {
+		var out []string
+		for _, part := range sortedParts(reqs) {
+			if v := versions[part]; v != "" && !satisfies(v, reqs[part]) {
+				out = append(out, fmt.Sprintf("%s %s, not %s", part, reqs[part], v))
+			}
+		}
+		return out
+	}
+	applies := func(reqs compatRequirements) bool {
+		for part, constraint := range reqs {
+			if v := versions[part]; v == "" || !satisfies(v, constraint) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, r := range m.Rules {
+		if !applies(r.If) {
+			continue
+		}
+		if missing := unmet(r.Require); len(missing) > 0 {
+			var when []string
+			for _, part := range sortedParts(r.If) {
+				when = append(when, part+" "+versions[part])
+			}
+			msg := fmt.Sprintf("with %s, the install needs %s", strings.Join(when, " and "), strings.Join(missing, "; "))
+			if r.Reason != "" {
+				msg += ": " + r.Reason
+			}
+			problems = append(problems, compatProblem{fatal: r.Level == "error", msg: msg})
+		}
+	}
+	reqs, from, err := appRequirements(m, rcpt, appDir)
+	if err != nil {
+		return nil, versions, err
+	}
+	if missing := unmet(reqs); len(missing) > 0 {
+		problems = append(problems, compatProblem{fatal: true, msg: fmt.Sprintf("the app needs %s (%s)", strings.Join(missing, "; "), from)})
+	}
+	return problems, versions, nil
+}
+
+func sortedParts(reqs compatRequirements) []string {
+	parts := make([]string, 0, len(reqs))
+	for part := range reqs {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return parts
+}
+
+// reportCompat prints the versions checked and warns of each problem,
+// failing the install at the first fatal one unless ignore is set.
+func reportCompat(problems []compatProblem, versions map[string]string, from string, ignore bool) {
+	var parts []string
+	for _, part := range []string{"xmlui", "mcp", "server", "launcher"} {
+		if v := versions[part]; v != "" {
+			parts = append(parts, part+" "+v)
+		}
+	}
+	if len(problems) == 0 {
+		if len(parts) > 0 {
+			fmt.Printf("%s Compatible versions: %s (matrix %s)\n", glyphOK, strings.Join(parts, ", "), from)
+		}
+		return
+	}
+	for _, p := range problems {
+		if !p.fatal || ignore {
+			warn("Incompatible versions: %s", p.msg)
+		}
+	}
+	for _, p := range problems {
+		if p.fatal && !ignore {
+			fatal("Incompatible versions", classify(exitCompat, fmt.Errorf("%s; --ignore-compat installs them anyway", p.msg)))
+		}
+	}
+}
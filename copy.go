@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCopyWorkers bounds the files copyFiles copies at once; beyond a few
+// the disk, not the CPU, is the limit.
+const maxCopyWorkers = 8
+
+// copyJob is one file for copyFiles.
+type copyJob struct{ src, dst string }
+
+// copyFiles copies the tree at src into dst with a pool of workers,
+// streaming each file rather than reading it whole. Directories are created
+// first, so workers only ever write files. On a terminal it shows how many
+// files are done; it stops at the first error, or when the install is
+// interrupted.
+func copyFiles(src, dst string) error {
+	var jobs []copyJob
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return fsys.MkdirAll(target, dirMode)
+		}
+		jobs = append(jobs, copyJob{path, target})
+		return nil
+	})
+	if err != nil || len(jobs) == 0 {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(downloadCtx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		done     atomic.Int64
+	)
+	queue := make(chan copyJob)
+	workers := min(runtime.NumCPU(), maxCopyWorkers, len(jobs))
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				if err := copyFile(j.src, j.dst, fileMode); err != nil {
+					once.Do(func() { firstErr = err; cancel() })
+					continue
+				}
+				done.Add(1)
+			}
+		}()
+	}
+
+	started := time.Now()
+	stopProgress := showCopyProgress(&done, len(jobs), started)
+feed:
+	for _, j := range jobs {
+		select {
+		case queue <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	stopProgress()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := downloadCtx.Err(); err != nil {
+		return fmt.Errorf("copy interrupted after %d of %d files: %w", done.Load(), len(jobs), err)
+	}
+	if elapsed := time.Since(started); elapsed >= time.Second {
+		fmt.Printf("  Copied %d files in %v (%.0f files/s)\n", len(jobs), elapsed.Round(100*time.Millisecond), float64(len(jobs))/elapsed.Seconds())
+	}
+	return nil
+}
+
+// showCopyProgress redraws "N/M files" in place while a copy runs, with
+// --progress bar only. The returned func stops it and clears the line.
+func showCopyProgress(done *atomic.Int64, total int, started time.Time) func() {
+	if console.progress != progressBar {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(200 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				fmt.Print("\r" + strings.Repeat(" ", console.lineWidth()-1) + "\r")
+				return
+			case <-tick.C:
+				n := done.Load()
+				rate := float64(n) / max(time.Since(started).Seconds(), 0.001)
+				fmt.Printf("\r  %d/%d files (%.0f files/s)", n, total, rate)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-finished
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Binary release assets are kept in the download cache after each download,
+// and become the base for delta updates: when the publisher puts
+// ASSET.sha256 (the checksum of the new build) and ASSET.OLD.bsdiff (a
+// bsdiff patch from the build whose SHA-256 starts with the 12 hex digits
+// OLD) next to an asset, update downloads just the patch. The patched result
+// must match the checksum; anything else falls back to the full download.
+
+// assetCacheDir is where the last download of each asset is kept.
+const assetCacheDir = "assets"
+
+// errNoDelta means no patch applies and the asset must be downloaded whole.
+var errNoDelta = errors.New("no delta patch")
+
+func cachedAssetPath(url string) (string, error) {
+	dir, err := network.cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, assetCacheDir, path.Base(url)), nil
+}
+
+// cacheAsset keeps data as the base for the next delta update of url.
+func cacheAsset(url string, data []byte) {
+	p, err := cachedAssetPath(url)
+	if err != nil || fsys.MkdirAll(filepath.Dir(p), dirMode) != nil {
+		return
+	}
+	tmp := p + ".tmp"
+	if fsys.WriteFile(tmp, data, fileMode) == nil {
+		fsys.Remove(p)
+		fsys.Rename(tmp, p)
+	}
+}
+
+// fetchDelta rebuilds the asset at url from the cached previous download,
+// either because it is unchanged or by applying the published patch. want is
+// the expected checksum if the lockfile pins one; otherwise the published
+// URL.sha256 is. It returns the data and its checksum, or errNoDelta when
+// there is nothing to build from.
+func fetchDelta(url, label, want string) ([]byte, string, error) {
+	p, err := cachedAssetPath(url)
+	if err != nil {
+		return nil, "", errNoDelta
+	}
+	old, err := os.ReadFile(p)
+	if err != nil {
+		return nil, "", errNoDelta
+	}
+	if want == "" {
+		if want = publishedChecksum(url); want == "" {
+			return nil, "", errNoDelta
+		}
+	}
+	oldSum := sha256Hex(old)
+	if oldSum == want {
+		fmt.Printf("Downloading %s...\n", label)
+		fmt.Printf("  %s is unchanged since the last download; using the cached copy\n", path.Base(url))
+		return old, oldSum, nil
+	}
+	patchURL := url + "." + oldSum[:12] + ".bsdiff"
+	patch, status, err := getSmall(patchURL)
+	if err != nil || status != http.StatusOK {
+		return nil, "", errNoDelta
+	}
+	data, err := bspatch(old, patch)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", patchURL, err)
+	}
+	if sha256Hex(data) != want {
+		return nil, "", fmt.Errorf("%s: the patched file does not match checksum %s", patchURL, want)
+	}
+	fmt.Printf("Downloading %s...\n", label)
+	fmt.Printf("  Applied delta patch %s (%s instead of %s)\n", path.Base(patchURL), humanBytes(int64(len(patch))), humanBytes(int64(len(data))))
+	return data, want, nil
+}
+
+// publishedChecksum returns the SHA-256 published as url.sha256 (in
+// sha256sum format), or "" if there is none.
+func publishedChecksum(url string) string {
+	sum, status, err := getSmall(url + ".sha256")
+	if err != nil || status != http.StatusOK {
+		return ""
+	}
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// getSmall fetches a checksum or patch without progress output.
+func getSmall(url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	applyRequestHeaders(req)
+	resp, err := network.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, nil
+	}
+	data, err := io.ReadAll(countingReader{resp.Body})
+	return data, resp.StatusCode, err
+}
+
+// maxPatchSeek bounds how far a patch may move its position in the old
+// file, far beyond any real file (the new one is at most 1<<30 bytes), so
+// position arithmetic can't overflow.
+const maxPatchSeek = 1 << 40
+
+// bspatch applies a bsdiff 4 (BSDIFF40) patch to old.
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != "BSDIFF40" {
+		return nil, errors.New("not a BSDIFF40 patch")
+	}
+	// Each length is checked against what remains rather than summed, as
+	// the sums of values a corrupt patch chose can overflow.
+	ctrlLen, diffLen, newSize := offtin(patch[8:]), offtin(patch[16:]), offtin(patch[24:])
+	n := int64(len(patch))
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || newSize > 1<<30 || ctrlLen > n-32 || diffLen > n-32-ctrlLen {
+		return nil, errors.New("corrupt patch header")
+	}
+	body := patch[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	out := make([]byte, newSize)
+	var oldPos, newPos int64
+	buf := make([]byte, 8)
+	for newPos < newSize {
+		var c [3]int64
+		for i := range c {
+			if _, err := io.ReadFull(ctrl, buf); err != nil {
+				return nil, fmt.Errorf("corrupt patch: %w", err)
+			}
+			c[i] = offtin(buf)
+		}
+		if c[0] < 0 || c[1] < 0 || c[0] > newSize-newPos || c[1] > newSize-newPos-c[0] ||
+			c[2] > maxPatchSeek || c[2] < -maxPatchSeek {
+			return nil, errors.New("corrupt patch: bad control block")
+		}
+		if _, err := io.ReadFull(diff, out[newPos:newPos+c[0]]); err != nil {
+			return nil, fmt.Errorf("corrupt patch: %w", err)
+		}
+		for i := int64(0); i < c[0]; i++ {
+			if o := oldPos + i; o >= 0 && o < int64(len(old)) {
+				out[newPos+i] += old[o]
+			}
+		}
+		newPos += c[0]
+		oldPos += c[0]
+		if _, err := io.ReadFull(extra, out[newPos:newPos+c[1]]); err != nil {
+			return nil, fmt.Errorf("corrupt patch: %w", err)
+		}
+		newPos += c[1]
+		oldPos += c[2]
+		if oldPos > maxPatchSeek || oldPos < -maxPatchSeek {
+			return nil, errors.New("corrupt patch: bad control block")
+		}
+	}
+	return out, nil
+}
+
+// offtin decodes bsdiff's sign-magnitude little-endian 64-bit integers.
+func offtin(b []byte) int64 {
+	v := int64(binary.LittleEndian.Uint64(b[:8]) &^ (1 << 63))
+	if b[7]&0x80 != 0 {
+		return -v
+	}
+	return v
+}
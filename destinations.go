@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// destDefault is the key of a destinations map that applies on any OS
+// without its own entry.
+const destDefault = "default"
+
+// destVars are the values a destination template can use, e.g.
+// "{{.InstallDir}}/bin" or "{{.ToolsDir}}/{{.OS}}-{{.Arch}}".
+type destVars struct {
+	OS, Arch   string
+	InstallDir string
+	// ToolsDir and AppDir are where the MCP tools and the app go by
+	// default.
+	ToolsDir, AppDir string
+}
+
+// destination expands the destination template for component on host, from
+// the "destinations" of xmlui-bundler.json or else the component's
+// releaseAsset. A relative result is taken relative to the install dir, and
+// it must lie inside it. "" means no override: the binaries go where they
+// always have.
+func destination(cfg *launcherConfig, component string, host platform, v destVars) (string, error) {
+	dests := releaseAssets[component].Dest
+	if d, ok := cfg.Destinations[component]; ok {
+		dests = d
+	}
+	text, ok := dests[host.OS]
+	if !ok {
+		text = dests[destDefault]
+	}
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(component).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("destination of %s: %w", component, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("destination of %s: %w", component, err)
+	}
+	dir := filepath.FromSlash(b.String())
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(v.InstallDir, dir)
+	}
+	dir = filepath.Clean(dir)
+	if rel, err := filepath.Rel(v.InstallDir, dir); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("destination of %s, %s, is outside the install dir %s", component, dir, v.InstallDir)
+	}
+	return dir, nil
+}
+
+// binDestinations works out where the MCP tools' and the test server's
+// binaries go in an install, given its tools and app dirs, and records any
+// override in rcpt.BinDirs. An --all-platforms install keeps them where its
+// dispatch scripts expect them.
+func binDestinations(rcpt *receipt, installDir, toolsDir, appDir string, host platform, allPlatforms bool) (mcpBinDir, serverBinDir string, err error) {
+	mcpBinDir, serverBinDir = toolsDir, appDir
+	if rcpt.Flat {
+		serverBinDir = toolsDir
+	}
+	cfg, _, err := readConfig()
+	if err != nil {
+		return "", "", err
+	}
+	v := destVars{OS: host.OS, Arch: host.Arch, InstallDir: installDir, ToolsDir: toolsDir, AppDir: appDir}
+	for _, d := range []struct {
+		component string
+		dir       *string
+	}{{"mcp", &mcpBinDir}, {"server", &serverBinDir}} {
+		dest, err := destination(cfg, d.component, host, v)
+		if err != nil {
+			return "", "", err
+		}
+		if dest == "" || dest == *d.dir {
+			continue
+		}
+		if allPlatforms {
+			warn("Ignoring the destination of %s: an --all-platforms install keeps the binaries next to their dispatch scripts", d.component)
+			continue
+		}
+		if rcpt.BinDirs == nil {
+			rcpt.BinDirs = map[string]string{}
+		}
+		*d.dir = dest
+		rcpt.BinDirs[d.component] = receiptKey(installDir, dest)
+	}
+	return mcpBinDir, serverBinDir, nil
+}
+
+// dropMoved removes the files of old that files no longer has, wherever
+// they are: syncTree only clears out its own destination, so this catches
+// what a changed destination left behind. It returns how many it removed.
+func dropMoved(installDir string, old, files map[string]string) (int, error) {
+	removed := 0
+	for key := range old {
+		if _, ok := files[key]; ok {
+			continue
+		}
+		path := filepath.Join(installDir, filepath.FromSlash(key))
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if err := journal.preserve(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
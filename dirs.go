@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// launcherName names the launcher's per-user directories and files.
+// legacyLauncherName is the name it had before: what exists under it is
+// still found.
+const (
+	launcherName       = "xmlui-bundler"
+	legacyLauncherName = "xmlui-launcher"
+)
+
+// launcherDir is the launcher's directory in base: base/launcherName, or
+// base/legacyLauncherName where only a launcher of the old name made one.
+// That one is used as it is rather than moved, as login services and
+// running servers refer to the paths in it.
+func launcherDir(base string) string {
+	dir := filepath.Join(base, launcherName)
+	if _, err := os.Lstat(dir); errors.Is(err, fs.ErrNotExist) {
+		legacy := filepath.Join(base, legacyLauncherName)
+		if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+			return legacy
+		}
+	}
+	return dir
+}
+
+// cacheDir is the per-user download cache, e.g. ~/.cache/xmlui-bundler.
+// $XDG_CACHE_HOME is honored on every OS, as $XDG_STATE_HOME is by
+// stateDir.
+func cacheDir() (string, error) {
+	if base := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(base) {
+		return launcherDir(base), nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return launcherDir(base), nil
+}
+
+// dirSize returns the total size of the files under path.
+func dirSize(path string) int64 {
+	var total int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLegacyNames(t *testing.T) {
+	base := t.TempDir()
+	if got, want := launcherDir(base), filepath.Join(base, launcherName); got != want {
+		t.Errorf("launcherDir of an empty dir = %q, want %q", got, want)
+	}
+	legacy := filepath.Join(base, legacyLauncherName)
+	if err := os.Mkdir(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got := launcherDir(base); got != legacy {
+		t.Errorf("launcherDir beside only the old name's dir = %q, want %q", got, legacy)
+	}
+	if err := os.Mkdir(filepath.Join(base, launcherName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := launcherDir(base), filepath.Join(base, launcherName); got != want {
+		t.Errorf("launcherDir beside both = %q, want %q", got, want)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, legacyLockFile), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(lockfilePath(dir)); got != legacyLockFile {
+		t.Errorf("lockfilePath beside only the old lockfile = %q, want %q", got, legacyLockFile)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lockFile), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(lockfilePath(dir)); got != lockFile {
+		t.Errorf("lockfilePath beside both = %q, want %q", got, lockFile)
+	}
+
+	profile := "a\n" + legacyProfileBlockStart + "\nold\n" + legacyProfileBlockEnd + "\nb\n"
+	block := profileBlockStart + "\nnew\n" + profileBlockEnd + "\n"
+	if got, want := replaceProfileBlock(profile, block), "a\n"+block+"b\n"; got != want {
+		t.Errorf("replaceProfileBlock of the old name's block = %q, want %q", got, want)
+	}
+	both := "a\n" + block + legacyProfileBlockStart + "\nold\n" + legacyProfileBlockEnd + "\n"
+	if got, want := replaceProfileBlock(both, block), "a\n"+block; got != want {
+		t.Errorf("replaceProfileBlock of both blocks = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+// Package dist generates package-manager manifests (a Homebrew formula and a
+// Scoop manifest) for a launcher release from its version and checksums.
+package dist
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+var formulaTemplate = template.Must(template.ParseFS(templates, "templates/formula.rb.tmpl"))
+
+const (
+	// Binary is the executable name inside each release archive.
+	Binary = "xmlui-bundler"
+
+	defaultHomepage    = "https://github.com/jonudell/xmlui-bundler"
+	defaultDescription = "Installer for the XMLUI getting-started bundle"
+)
+
+// Release describes one published launcher release.
+type Release struct {
+	// Tag is the git tag, e.g. "v1.2.3".
+	Tag string
+	// BaseURL is where the assets are downloadable, ending in "/". Empty means
+	// GitHub releases for Homepage.
+	BaseURL     string
+	Homepage    string
+	Description string
+	// Checksums maps asset file names to hex SHA-256 digests.
+	Checksums map[string]string
+}
+
+// Artifact is a release asset for one platform.
+type Artifact struct {
+	OS, Arch string
+	Filename string
+	URL      string
+	SHA256   string
+}
+
+// platforms lists the launcher builds, in the order manifests list them.
+var platforms = []struct{ os, arch string }{
+	{"darwin", "arm64"},
+	{"darwin", "amd64"},
+	{"linux", "arm64"},
+	{"linux", "amd64"},
+	{"windows", "arm64"},
+	{"windows", "amd64"},
+}
+
+// AssetName is the archive name for a platform: xmlui-bundler-<os>-<arch>.<ext>.
+func AssetName(goos, goarch string) string {
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("%s-%s-%s.%s", Binary, goos, goarch, ext)
+}
+
+// Version is the tag without its leading "v", as package managers expect.
+func (r Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+func (r Release) homepage() string {
+	if r.Homepage != "" {
+		return r.Homepage
+	}
+	return defaultHomepage
+}
+
+func (r Release) baseURL() string {
+	if r.BaseURL != "" {
+		return strings.TrimSuffix(r.BaseURL, "/") + "/"
+	}
+	return r.homepage() + "/releases/download/" + r.Tag + "/"
+}
+
+func (r Release) description() string {
+	if r.Description != "" {
+		return r.Description
+	}
+	return defaultDescription
+}
+
+// Artifacts returns the platforms that have a checksummed asset in r.
+func (r Release) Artifacts() []Artifact {
+	var out []Artifact
+	for _, p := range platforms {
+		name := AssetName(p.os, p.arch)
+		sum, ok := r.Checksums[name]
+		if !ok {
+			continue
+		}
+		out = append(out, Artifact{OS: p.os, Arch: p.arch, Filename: name, URL: r.baseURL() + name, SHA256: sum})
+	}
+	return out
+}
+
+// ParseChecksums reads sha256sum-style lines ("<hex>  <file>", with an
+// optional '*' binary marker) into a file name to digest map.
+func ParseChecksums(rd io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+	sc := bufio.NewScanner(rd)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			return nil, fmt.Errorf("checksums line %d: want '<sha256>  <file>', got %q", line, text)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums, sc.Err()
+}
+
+type formulaArch struct {
+	Name        string
+	URL, SHA256 string
+}
+
+type formulaOS struct {
+	Name   string
+	Arches []formulaArch
+}
+
+// HomebrewFormula renders a formula covering the macOS and Linux artifacts.
+func HomebrewFormula(r Release) ([]byte, error) {
+	byOS := map[string]*formulaOS{}
+	var order []string
+	for _, a := range r.Artifacts() {
+		name := map[string]string{"darwin": "macos", "linux": "linux"}[a.OS]
+		if name == "" {
+			continue
+		}
+		arch := map[string]string{"arm64": "arm", "amd64": "intel"}[a.Arch]
+		if byOS[name] == nil {
+			byOS[name] = &formulaOS{Name: name}
+			order = append(order, name)
+		}
+		byOS[name].Arches = append(byOS[name].Arches, formulaArch{Name: arch, URL: a.URL, SHA256: a.SHA256})
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no macOS or Linux artifacts in checksums for %s", r.Tag)
+	}
+	sort.Strings(order)
+
+	data := struct {
+		Tag, Version, Description, Homepage, Binary string
+		FormulaOS                                   []formulaOS
+	}{r.Tag, r.Version(), r.description(), r.homepage(), Binary, nil}
+	for _, name := range order {
+		data.FormulaOS = append(data.FormulaOS, *byOS[name])
+	}
+
+	var buf bytes.Buffer
+	if err := formulaTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type scoopArch struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash,omitempty"`
+}
+
+type scoopManifest struct {
+	Version      string               `json:"version"`
+	Description  string               `json:"description"`
+	Homepage     string               `json:"homepage"`
+	Architecture map[string]scoopArch `json:"architecture"`
+	Bin          string               `json:"bin"`
+	CheckVer     map[string]string    `json:"checkver"`
+	AutoUpdate   struct {
+		Architecture map[string]scoopArch `json:"architecture"`
+	} `json:"autoupdate"`
+}
+
+// scoopArches maps GOARCH to Scoop's architecture keys.
+var scoopArches = map[string]string{"amd64": "64bit", "arm64": "arm64"}
+
+// ScoopManifest renders a Scoop manifest covering the Windows artifacts.
+func ScoopManifest(r Release) ([]byte, error) {
+	m := scoopManifest{
+		Version:      r.Version(),
+		Description:  r.description(),
+		Homepage:     r.homepage(),
+		Architecture: map[string]scoopArch{},
+		Bin:          Binary + ".exe",
+		CheckVer:     map[string]string{"github": r.homepage()},
+	}
+	m.AutoUpdate.Architecture = map[string]scoopArch{}
+	for _, a := range r.Artifacts() {
+		if a.OS != "windows" {
+			continue
+		}
+		key := scoopArches[a.Arch]
+		m.Architecture[key] = scoopArch{URL: a.URL, Hash: a.SHA256}
+		m.AutoUpdate.Architecture[key] = scoopArch{
+			URL: r.homepage() + "/releases/download/v$version/" + a.Filename,
+		}
+	}
+	if len(m.Architecture) == 0 {
+		return nil, fmt.Errorf("no Windows artifacts in checksums for %s", r.Tag)
+	}
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,95 @@
+package dist
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testChecksums are a release's checksums.txt, with an asset for every
+// platform and a file that is none of them.
+const testChecksums = `
+1111111111111111111111111111111111111111111111111111111111111111  xmlui-bundler-darwin-arm64.tar.gz
+2222222222222222222222222222222222222222222222222222222222222222  xmlui-bundler-darwin-amd64.tar.gz
+3333333333333333333333333333333333333333333333333333333333333333  xmlui-bundler-linux-arm64.tar.gz
+4444444444444444444444444444444444444444444444444444444444444444 *xmlui-bundler-linux-amd64.tar.gz
+5555555555555555555555555555555555555555555555555555555555555555  xmlui-bundler-windows-arm64.zip
+6666666666666666666666666666666666666666666666666666666666666666  xmlui-bundler-windows-amd64.zip
+7777777777777777777777777777777777777777777777777777777777777777  xmlui-bundle-linux-amd64.zip
+`
+
+func testRelease(t *testing.T) Release {
+	t.Helper()
+	sums, err := ParseChecksums(strings.NewReader(testChecksums))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Release{Tag: "v1.2.3", Checksums: sums}
+}
+
+// golden compares got with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file (go test -update rewrites it):\n%s", name, got)
+	}
+}
+
+func TestHomebrewFormula(t *testing.T) {
+	got, err := HomebrewFormula(testRelease(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "xmlui-bundler.rb", got)
+	if !bytes.Contains(got, []byte(`bin.install "`+Binary+`"`)) {
+		t.Errorf("the formula doesn't install %s", Binary)
+	}
+}
+
+func TestScoopManifest(t *testing.T) {
+	got, err := ScoopManifest(testRelease(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "xmlui-bundler.json", got)
+}
+
+func TestManifestsNeedArtifacts(t *testing.T) {
+	r := Release{Tag: "v1.2.3", Checksums: map[string]string{"xmlui-bundler-windows-amd64.zip": strings.Repeat("a", 64)}}
+	if _, err := HomebrewFormula(r); err == nil {
+		t.Error("HomebrewFormula made a formula without macOS or Linux artifacts")
+	}
+	r.Checksums = map[string]string{"xmlui-bundler-linux-amd64.tar.gz": strings.Repeat("a", 64)}
+	if _, err := ScoopManifest(r); err == nil {
+		t.Error("ScoopManifest made a manifest without Windows artifacts")
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	if _, err := ParseChecksums(strings.NewReader("abc  file\n")); err == nil {
+		t.Error("ParseChecksums accepted a short digest")
+	}
+	sums, err := ParseChecksums(strings.NewReader("# comment\n\n" + strings.Repeat("A", 64) + " *f.zip\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sums["f.zip"] != strings.Repeat("a", 64) {
+		t.Errorf("sums = %v, want f.zip's digest lowercased", sums)
+	}
+}
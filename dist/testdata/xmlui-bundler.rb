@@ -0,0 +1,36 @@
+# Generated by xmlui-dist for v1.2.3; do not edit.
+class XmluiBundler < Formula
+  desc "Installer for the XMLUI getting-started bundle"
+  homepage "https://github.com/jonudell/xmlui-bundler"
+  version "1.2.3"
+
+  on_linux do
+    on_arm do
+      url "https://github.com/jonudell/xmlui-bundler/releases/download/v1.2.3/xmlui-bundler-linux-arm64.tar.gz"
+      sha256 "3333333333333333333333333333333333333333333333333333333333333333"
+    end
+    on_intel do
+      url "https://github.com/jonudell/xmlui-bundler/releases/download/v1.2.3/xmlui-bundler-linux-amd64.tar.gz"
+      sha256 "4444444444444444444444444444444444444444444444444444444444444444"
+    end
+  end
+
+  on_macos do
+    on_arm do
+      url "https://github.com/jonudell/xmlui-bundler/releases/download/v1.2.3/xmlui-bundler-darwin-arm64.tar.gz"
+      sha256 "1111111111111111111111111111111111111111111111111111111111111111"
+    end
+    on_intel do
+      url "https://github.com/jonudell/xmlui-bundler/releases/download/v1.2.3/xmlui-bundler-darwin-amd64.tar.gz"
+      sha256 "2222222222222222222222222222222222222222222222222222222222222222"
+    end
+  end
+
+  def install
+    bin.install "xmlui-bundler"
+  end
+
+  test do
+    assert_match version.to_s, shell_output("#{bin}/xmlui-bundler version")
+  end
+end
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dockerPlatform is the build the container runs: the only Linux one the
+// test server releases.
+var dockerPlatform = platform{"linux", "amd64"}
+
+// dockerBaseImage is small, but has the sh that start.sh needs.
+const dockerBaseImage = "debian:bookworm-slim"
+
+// runDockerize implements `dockerize`: it writes a Docker build context for
+// the installed app, with the Linux test server in place of this machine's,
+// a Dockerfile and a compose file, so others can run the app in a container
+// without the launcher.
+func runDockerize(args []string) int {
+	fs := newFlagSet("dockerize")
+	dir := installDirFlag(fs)
+	out := fs.String("out", "", "directory to write the build context to (default: docker/ in the install dir)")
+	force := fs.Bool("force", false, "replace --out if it already exists")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	if rcpt.Flat {
+		fmt.Println("A --flat install has no app of its own to put in a container")
+		return exitFailure
+	}
+	if *out == "" {
+		*out = filepath.Join(installDir, "docker")
+	}
+	if *out, err = filepath.Abs(*out); err != nil {
+		fmt.Println("Invalid --out:", err)
+		return exitFailure
+	}
+	if hasPathPrefix(*out, filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))) {
+		fmt.Println("--out can't be inside the app, which is copied into it")
+		return exitFailure
+	}
+	if _, err := os.Stat(*out); err == nil {
+		if !*force {
+			fmt.Printf("%s already exists; pass --force to replace it\n", *out)
+			return exitFailure
+		}
+		if err := fsys.RemoveAll(*out); err != nil {
+			fmt.Println(err)
+			return exitFailure
+		}
+	}
+	if err := dockerize(installDir, rcpt, *out); err != nil {
+		fmt.Println("Failed to write the Docker build context:", err)
+		fsys.RemoveAll(*out)
+		return exitFailure
+	}
+	fmt.Printf("%s Wrote a Docker build context to %s\n", glyphOK, *out)
+	fmt.Printf("  Run it with: cd %s && docker compose up --build\n", *out)
+	fmt.Printf("  Then open:   http://localhost:%d\n", rcpt.port())
+	return exitOK
+}
+
+// dockerize writes the build context for the install in installDir to out:
+// app/ (the app, with the Linux test server), Dockerfile, compose.yaml and
+// .dockerignore.
+func dockerize(installDir string, rcpt *receipt, out string) error {
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	ctxApp := filepath.Join(out, "app")
+	if err := fsys.MkdirAll(out, dirMode); err != nil {
+		return err
+	}
+	fmt.Printf("Copying %s...\n", rcpt.appDir())
+	if err := copyTree(appDir, ctxApp); err != nil {
+		return err
+	}
+	// This machine's server (or an --all-platforms dispatch script) makes
+	// way for the Linux build.
+	for _, name := range []string{"xmlui-test-server", "xmlui-test-server.exe", "xmlui-test-server.cmd"} {
+		if err := fsys.Remove(filepath.Join(ctxApp, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	stage, err := os.MkdirTemp("", "xmlui-dockerize-")
+	if err != nil {
+		return err
+	}
+	defer fsys.RemoveAll(stage)
+	tmpServer, _, _, _, err := stageServer(installOptions{}, stage, dockerPlatform, nil)
+	if err != nil {
+		return fmt.Errorf("the Linux test server: %w", err)
+	}
+	if err := copyTree(tmpServer, ctxApp); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"Dockerfile", dockerfile(appDir, rcpt.port())},
+		{"compose.yaml", composeFile(filepath.Base(appDir), rcpt.port())},
+		{".dockerignore", "*\n!app/\n"},
+	}
+	for _, f := range files {
+		if err := fsys.WriteFile(filepath.Join(out, f.name), []byte(f.data), fileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dockerfile runs the app's start script, or the test server itself if it
+// has none, on port with the environment of the app's variant.
+func dockerfile(appDir string, port int) string {
+	var b strings.Builder
+	b.WriteString("# Generated by xmlui-bundler dockerize.\n")
+	fmt.Fprintf(&b, "FROM --platform=%s/%s %s\n", dockerPlatform.OS, dockerPlatform.Arch, dockerBaseImage)
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("COPY app/ /app/\n")
+	b.WriteString("RUN chmod +x /app/xmlui-test-server\n")
+	fmt.Fprintf(&b, "ENV PORT=%d\n", port)
+	for _, kv := range variantEnv(appDir) {
+		k, v, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&b, "ENV %s=%s\n", k, strconv.Quote(v))
+	}
+	fmt.Fprintf(&b, "EXPOSE %d\n", port)
+	if _, err := os.Stat(filepath.Join(appDir, "start.sh")); err == nil {
+		b.WriteString(`CMD ["sh", "./start.sh"]` + "\n")
+	} else {
+		b.WriteString(`CMD ["/app/xmlui-test-server"]` + "\n")
+	}
+	return b.String()
+}
+
+// composeFile defines one service, name, built from the Dockerfile beside
+// it and published on port.
+func composeFile(name string, port int) string {
+	return fmt.Sprintf(`# Generated by xmlui-bundler dockerize.
+services:
+  %s:
+    build: .
+    platform: %s/%s
+    ports:
+      - "%d:%d"
+    restart: unless-stopped
+`, composeServiceName(name), dockerPlatform.OS, dockerPlatform.Arch, port, port)
+}
+
+// composeServiceName makes name a valid compose service name: lower case
+// letters, digits, - and _.
+func composeServiceName(name string) string {
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+	if s = strings.Trim(s, "-_"); s == "" {
+		return "app"
+	}
+	return s
+}
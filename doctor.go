@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// doctorComponents are the components whose files doctor checks and --fix
+// can restore. The app, including its seed databases, is the user's to edit,
+// so only files missing from it are reported.
+var doctorComponents = map[string]bool{"components": true, "mcp": true, "server": true}
+
+// fileProblem is a file that no longer matches the receipt.
+type fileProblem struct {
+	component, key string
+	missing        bool
+}
+
+func (p fileProblem) String() string {
+	if p.missing {
+		return fmt.Sprintf("%s: %s is missing", p.component, p.key)
+	}
+	return fmt.Sprintf("%s: %s is corrupted", p.component, p.key)
+}
+
+// runDoctor implements `doctor`: it checks every installed file against the
+// hashes in the receipt and, with --fix, re-downloads the components with
+// damaged files and puts back just those files.
+func runDoctor(args []string) int {
+	fs := newFlagSet("doctor")
+	dir := installDirFlag(fs)
+	fix := fs.Bool("fix", false, "re-download components with missing or corrupted files and restore those files")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	// Repairs must extract files exactly as the install did.
+	keepLineEndings = rcpt.KeepLineEndings
+
+	scriptDirs := []string{filepath.Join(installDir, rcpt.toolsDir())}
+	if !rcpt.Flat {
+		scriptDirs = append(scriptDirs, filepath.Join(installDir, filepath.FromSlash(rcpt.appDir())))
+	}
+	prereqs := scriptPrereqs(installDir, rcpt, false, scriptDirs...)
+	for _, p := range prereqs {
+		fmt.Println(glyphFail, p)
+	}
+
+	problems, checked := checkInstall(installDir, rcpt)
+	if len(problems) == 0 {
+		fmt.Printf("%s All %d files match the receipt\n", glyphOK, checked)
+		if len(prereqs) > 0 {
+			return exitFailure
+		}
+		return exitOK
+	}
+	byComponent := map[string][]fileProblem{}
+	var appMissing bool
+	for _, p := range problems {
+		fmt.Println(glyphFail, p)
+		if doctorComponents[p.component] {
+			byComponent[p.component] = append(byComponent[p.component], p)
+		} else {
+			appMissing = true
+		}
+	}
+	if appMissing {
+		fmt.Println("Run `xmlui-bundler update` to restore missing app files; it leaves your changes alone")
+	}
+	if !*fix {
+		if len(byComponent) > 0 {
+			fmt.Println("Run `xmlui-bundler doctor --fix` to re-download the damaged components")
+		}
+		return exitFailure
+	}
+	if len(byComponent) == 0 {
+		return exitFailure
+	}
+
+	stage, err := stageDir(os.TempDir())
+	if err != nil {
+		fmt.Println("Failed to create staging directory:", err)
+		return exitFailure
+	}
+	defer runCleanups()
+	defer metrics.save("doctor --fix")
+	status := exitOK
+	for _, c := range rcpt.Components {
+		if len(byComponent[c.Name]) == 0 {
+			continue
+		}
+		if err := repairComponent(installDir, stage, c, byComponent[c.Name]); err != nil {
+			fmt.Printf("%s Could not repair %s: %v\n", glyphFail, c.Name, err)
+			status = exitFailure
+		}
+	}
+	if appMissing {
+		status = exitFailure
+	}
+	return status
+}
+
+// checkInstall hashes the files the receipt records and returns those that
+// are missing or differ, sorted, and how many files it checked.
+func checkInstall(installDir string, rcpt *receipt) ([]fileProblem, int) {
+	var problems []fileProblem
+	checked := 0
+	for _, c := range rcpt.Components {
+		if c.Name != "app" && !doctorComponents[c.Name] {
+			continue
+		}
+		for key, want := range c.Files {
+			checked++
+			h, err := hashFile(filepath.Join(installDir, filepath.FromSlash(key)))
+			switch {
+			case os.IsNotExist(err):
+				problems = append(problems, fileProblem{c.Name, key, true})
+			case c.Name == "app":
+			case err != nil || h != want:
+				problems = append(problems, fileProblem{c.Name, key, false})
+			}
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].component != problems[j].component {
+			return problems[i].component < problems[j].component
+		}
+		return problems[i].key < problems[j].key
+	})
+	return problems, checked
+}
+
+// repairComponent downloads c again from the URL the receipt recorded and
+// restores each of problems from the file in the archive with the recorded
+// hash, wherever the install step had put it. A download that differs from
+// the one the receipt or lockfile records is refused. Nothing else is
+// touched.
+func repairComponent(installDir, stage string, c receiptComponent, problems []fileProblem) error {
+	if c.Source == "" {
+		return fmt.Errorf("the receipt has no download URL for it")
+	}
+	data, sum, err := downloadAsset(c.Source, c.Name, "")
+	if err != nil {
+		return err
+	}
+	// The download has to be the archive the install came from, or its
+	// files aren't the ones the receipt's hashes vouch for.
+	for _, d := range c.Downloads {
+		if d.URL == c.Source && d.SHA256 != sum {
+			return &integrityError{fmt.Sprintf("checksum mismatch for %s: got %s, the receipt records %s; upstream changed since the install, so run `xmlui-bundler update`", c.Source, sum, d.SHA256)}
+		}
+	}
+	if l, err := readLockfile(installDir); err == nil {
+		for _, a := range l.Artifacts {
+			if a.URL == c.Source && a.SHA256 != sum {
+				return &integrityError{fmt.Sprintf("checksum mismatch for %s: %s expects %s", c.Source, lockFile, a.SHA256)}
+			}
+		}
+	}
+	tmp := filepath.Join(stage, c.Name)
+	binary := ""
+	if a, ok := releaseAssets[c.Name]; ok {
+		binary = a.Name + thisMachine().exe()
+	}
+	if err := unpackAsset(data, tmp, binary); err != nil {
+		return err
+	}
+	byHash := map[string]string{}
+	err = filepath.Walk(tmp, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		h, err := hashFile(path)
+		if err == nil {
+			byHash[h] = path
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	unfixable := 0
+	for _, p := range problems {
+		src, ok := byHash[c.Files[p.key]]
+		if !ok {
+			fmt.Printf("  %s is not in the download; upstream may have changed since the install, so run `xmlui-bundler update`\n", p.key)
+			unfixable++
+			continue
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(installDir, filepath.FromSlash(p.key))
+		if err := fsys.MkdirAll(filepath.Dir(dst), dirMode); err != nil {
+			return err
+		}
+		fsys.Remove(dst)
+		if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		// Zip archives carry no modes; the install step made these executable.
+		if isExecutableName(p.key) || info.Mode()&0111 != 0 {
+			chmodExec(dst)
+		}
+		fmt.Printf("  %s Restored %s\n", glyphOK, p.key)
+	}
+	if unfixable > 0 {
+		return fmt.Errorf("%d of %d files could not be restored", unfixable, len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// envFileSh and envFilePs1 set installEnv's variables for this install when
+// sourced: `. ./env.sh` or `. .\env.ps1`. They are written next to the
+// getting-started guide (into the tools dir for --flat, whose install dir
+// is the user's project).
+const (
+	envFileSh  = "env.sh"
+	envFilePs1 = "env.ps1"
+)
+
+// envVar is one installed location exported to tutorials and other tools.
+type envVar struct {
+	Name, Value string
+}
+
+// installEnv lists the variables describing the install in installDir, in a
+// stable order. Binaries that aren't installed are left out; the app
+// variant's own test server environment comes last.
+func installEnv(installDir string, rcpt *receipt) []envVar {
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	mcpDir := filepath.Join(installDir, rcpt.toolsDir())
+	serverDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("server")))
+	vars := []envVar{
+		{"XMLUI_INSTALL_DIR", installDir},
+		{"XMLUI_APP_DIR", appDir},
+		{"XMLUI_MCP_DIR", mcpDir},
+	}
+	mcpBinDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("mcp")))
+	for _, b := range []struct{ name, dir, bin string }{
+		{"XMLUI_MCP_BIN", mcpBinDir, "xmlui-mcp"},
+		{"XMLUI_MCP_CLIENT_BIN", mcpBinDir, "xmlui-mcp-client"},
+		{"XMLUI_SERVER_BIN", serverDir, "xmlui-test-server"},
+	} {
+		if p, err := mcpBinary(b.dir, b.bin); err == nil {
+			vars = append(vars, envVar{b.name, p})
+		}
+	}
+	vars = append(vars,
+		envVar{"XMLUI_DOCS_DIR", filepath.Join(mcpDir, "docs")},
+		envVar{"XMLUI_SRC_DIR", filepath.Join(mcpDir, "src")},
+		envVar{"XMLUI_PORT", fmt.Sprint(rcpt.port())},
+		envVar{"XMLUI_APP_URL", fmt.Sprintf("http://localhost:%d", rcpt.port())},
+	)
+	if rcpt.Variant != "" {
+		vars = append(vars, envVar{"XMLUI_VARIANT", rcpt.Variant})
+		for _, kv := range variantEnv(appDir) {
+			k, v, _ := strings.Cut(kv, "=")
+			vars = append(vars, envVar{k, v})
+		}
+	}
+	return vars
+}
+
+// shQuote quotes s for a POSIX shell.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// formatEnv renders vars for shell: sh, powershell, cmd or json.
+func formatEnv(vars []envVar, shell string) (string, error) {
+	var b strings.Builder
+	switch shell {
+	case "sh":
+		for _, v := range vars {
+			fmt.Fprintf(&b, "export %s=%s\n", v.Name, shQuote(v.Value))
+		}
+	case "powershell":
+		for _, v := range vars {
+			fmt.Fprintf(&b, "$env:%s = %s\n", v.Name, psQuote(v.Value))
+		}
+	case "cmd":
+		for _, v := range vars {
+			fmt.Fprintf(&b, "set \"%s=%s\"\r\n", v.Name, v.Value)
+		}
+	case "json":
+		m := map[string]string{}
+		for _, v := range vars {
+			m[v.Name] = v.Value
+		}
+		data, _ := json.MarshalIndent(m, "", "  ")
+		b.Write(append(data, '\n'))
+	default:
+		return "", fmt.Errorf("unknown shell %q: want sh, powershell, cmd or json", shell)
+	}
+	return b.String(), nil
+}
+
+// envFileDir is where writeEnvFiles puts the env files of an install.
+func envFileDir(installDir string, rcpt *receipt) string {
+	if rcpt.Flat {
+		return filepath.Join(installDir, rcpt.toolsDir())
+	}
+	return installDir
+}
+
+// envFiles renders env.sh and env.ps1 for the install.
+func envFiles(installDir string, rcpt *receipt) (sh, ps1 []byte) {
+	vars := installEnv(installDir, rcpt)
+	dir := envFileDir(installDir, rcpt)
+	shVars, _ := formatEnv(vars, "sh")
+	psVars, _ := formatEnv(vars, "powershell")
+	header := "# XMLUI install paths, generated by xmlui-bundler for " + installDir + ".\n"
+	sh = []byte(header + "# Source it: . " + filepath.Join(dir, envFileSh) + "\n" + shVars)
+	ps1 = []byte(crlf(header + "# Dot-source it: . " + psQuote(filepath.Join(dir, envFilePs1)) + "\n" + psVars))
+	return sh, ps1
+}
+
+// writeEnvFiles writes env.sh and env.ps1 for the install.
+func writeEnvFiles(installDir string, rcpt *receipt) error {
+	dir := envFileDir(installDir, rcpt)
+	sh, ps := envFiles(installDir, rcpt)
+	for _, name := range []string{envFileSh, envFilePs1} {
+		if err := journal.preserve(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	if err := fsys.WriteFile(filepath.Join(dir, envFileSh), sh, fileMode); err != nil {
+		return err
+	}
+	ps1 := filepath.Join(dir, envFilePs1)
+	if err := fsys.WriteFile(ps1, ps, fileMode); err != nil {
+		return err
+	}
+	unblockFile(ps1)
+	return nil
+}
+
+// runEnv implements `env`: it prints the variables of installEnv for the
+// install in --dir, e.g. for eval "$(xmlui-bundler env)".
+func runEnv(args []string) int {
+	fs := newFlagSet("env")
+	dir := installDirFlag(fs)
+	shell := fs.String("shell", hostOS.defaultShell(), "syntax to print: sh, powershell, cmd or json")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	out, err := formatEnv(installEnv(installDir, rcpt), *shell)
+	if err != nil {
+		fmt.Println(err)
+		return exitUsage
+	}
+	fmt.Print(out)
+	return exitOK
+}
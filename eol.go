@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jonudell/xmlui-bundler/extract"
+)
+
+// keepLineEndings is --keep-line-endings: extract scripts byte for byte.
+// Otherwise shell scripts get LF line endings (with CRLF, a shebang line
+// fails with "bad interpreter") and batch files CRLF (cmd.exe misreads
+// labels and multi-line blocks without it), whatever the archive had.
+var keepLineEndings bool
+
+// Line-ending treatments of an extracted file.
+const (
+	eolKeep  = iota
+	eolLF    // Unix shell scripts
+	eolCRLF  // Windows batch files
+	eolSniff // no extension: LF if it starts with #!
+)
+
+// lineEndingFor says how the file name is treated.
+func lineEndingFor(name string) int {
+	base := path.Base(name)
+	switch strings.ToLower(path.Ext(base)) {
+	case ".sh", ".bash", ".zsh", ".command":
+		return eolLF
+	case ".bat", ".cmd":
+		return eolCRLF
+	case "":
+		return eolSniff
+	}
+	return eolKeep
+}
+
+// eolTarget normalizes the line endings of scripts extracted into it.
+type eolTarget struct{ extract.Target }
+
+// normalizedTarget wraps t in an eolTarget unless --keep-line-endings.
+func normalizedTarget(t extract.Target) extract.Target {
+	if keepLineEndings {
+		return t
+	}
+	return eolTarget{t}
+}
+
+func (t eolTarget) Create(name string, mode fs.FileMode) (io.WriteCloser, error) {
+	w, err := t.Target.Create(name, mode)
+	if err != nil {
+		return nil, err
+	}
+	eol := lineEndingFor(name)
+	if eol == eolKeep {
+		return w, nil
+	}
+	return &eolWriter{w: w, eol: eol}, nil
+}
+
+// eolWriter converts line endings as it streams, carrying a CR that may
+// start a CRLF across writes.
+type eolWriter struct {
+	w       io.WriteCloser
+	eol     int
+	head    []byte // sniffed bytes, until the kind is known
+	pending bool   // a CR was held back
+	prev    byte   // the last byte written, for eolCRLF
+}
+
+func (e *eolWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if e.eol == eolSniff {
+		e.head = append(e.head, p...)
+		if len(e.head) < 2 {
+			return n, nil
+		}
+		e.eol = eolKeep
+		if bytes.HasPrefix(e.head, []byte("#!")) {
+			e.eol = eolLF
+		}
+		p, e.head = e.head, nil
+	}
+	var err error
+	switch e.eol {
+	case eolKeep:
+		_, err = e.w.Write(p)
+	case eolLF:
+		_, err = e.w.Write(e.toLF(p))
+	case eolCRLF:
+		_, err = e.w.Write(e.toCRLF(p))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (e *eolWriter) toLF(p []byte) []byte {
+	out := make([]byte, 0, len(p)+1)
+	if e.pending {
+		e.pending = false
+		if len(p) == 0 || p[0] != '\n' {
+			out = append(out, '\r')
+		}
+	}
+	for i, b := range p {
+		if b == '\r' {
+			if i+1 < len(p) {
+				if p[i+1] == '\n' {
+					continue
+				}
+			} else {
+				e.pending = true
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func (e *eolWriter) toCRLF(p []byte) []byte {
+	out := make([]byte, 0, len(p)+len(p)/16)
+	for _, b := range p {
+		if b == '\n' && e.prev != '\r' {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+		e.prev = b
+	}
+	return out
+}
+
+func (e *eolWriter) Close() error {
+	var err error
+	switch {
+	case e.head != nil:
+		_, err = e.w.Write(e.head)
+	case e.pending:
+		_, err = e.w.Write([]byte{'\r'})
+	}
+	if cerr := e.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventsFile is the NDJSON record of an install's last run, in its state
+// dir: every step, state change, progress snapshot, warning and error, so
+// support can reconstruct a failed run from this one file. The run before
+// is kept as eventsPrevFile.
+const (
+	eventsFile     = "events.ndjson"
+	eventsPrevFile = "events.1.ndjson"
+)
+
+// progressEvery throttles progress events per component.
+const progressEvery = 500 * time.Millisecond
+
+// event is one line of the events file; fields are set as they apply.
+type event struct {
+	Time      time.Time `json:"time"`
+	Seq       int       `json:"seq"`
+	Event     string    `json:"event"`
+	Step      int       `json:"step,omitempty"`
+	Component string    `json:"component,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Bytes     int64     `json:"bytes,omitempty"`
+	Total     int64     `json:"total,omitempty"`
+	Files     int       `json:"files,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	// Run describes the run, on the run-start event only.
+	Run *runInfo `json:"run,omitempty"`
+}
+
+type runInfo struct {
+	Command string   `json:"command"`
+	Version string   `json:"version"`
+	OS      string   `json:"os"`
+	Arch    string   `json:"arch"`
+	Args    []string `json:"args"`
+	Dir     string   `json:"dir"`
+}
+
+// eventLog writes the events file of the running install.
+type eventLog struct {
+	mu           sync.Mutex
+	f            *os.File
+	path         string
+	seq          int
+	started      time.Time
+	lastProgress map[string]time.Time
+}
+
+// events is the active install's event log; its methods are no-ops when nil.
+var events *eventLog
+
+// openEventLog starts installDir's events file for command, keeping the
+// previous run's file.
+func openEventLog(installDir, command string) (*eventLog, error) {
+	path, err := installStatePath(installDir, eventsFile)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := installStatePath(installDir, eventsPrevFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return nil, err
+	}
+	fsys.Rename(path, prev)
+	f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	l := &eventLog{f: f, path: path, started: time.Now(), lastProgress: map[string]time.Time{}}
+	l.emit(event{Event: "run-start", Run: &runInfo{
+		Command: command, Version: version, OS: hostOS.goos(), Arch: runtime.GOARCH,
+		Args: redactArgs(os.Args[1:]), Dir: installDir,
+	}})
+	return l, nil
+}
+
+// emit appends e, numbering and timestamping it.
+func (l *eventLog) emit(e event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	l.seq++
+	e.Seq = l.seq
+	e.Time = time.Now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.f.Write(append(line, '\n'))
+}
+
+// progress emits a progress snapshot for component, at most every
+// progressEvery.
+func (l *eventLog) progress(component string, bytes, total int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	due := time.Since(l.lastProgress[component]) >= progressEvery
+	if due {
+		l.lastProgress[component] = time.Now()
+	}
+	l.mu.Unlock()
+	if due {
+		l.emit(event{Event: "progress", Component: component, Bytes: bytes, Total: total})
+	}
+}
+
+// close ends the file with a run-end event carrying the outcome.
+func (l *eventLog) close(state string) {
+	if l == nil {
+		return
+	}
+	l.emit(event{Event: "run-end", State: state, Message: time.Since(l.started).Round(time.Millisecond).String()})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+}
+
+// redactArgs hides values that may be secrets (--header values, --set
+// values) from the recorded command line.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	hide := false
+	for i, a := range args {
+		switch {
+		case hide:
+			out[i] = "REDACTED"
+			hide = false
+		case a == "--header" || a == "-header" || a == "--set" || a == "-set":
+			out[i] = a
+			hide = true
+		case strings.HasPrefix(a, "--header=") || strings.HasPrefix(a, "-header=") ||
+			strings.HasPrefix(a, "--set=") || strings.HasPrefix(a, "-set="):
+			out[i] = a[:strings.Index(a, "=")+1] + "REDACTED"
+		default:
+			out[i] = a
+		}
+	}
+	return out
+}
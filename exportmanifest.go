@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manifestFile is what `export-manifest` writes next to a lockfile: the
+// install flags that reproduce an install's configuration. `--manifest
+// FILE` installs from the pair, with --locked against that lockfile.
+const manifestFile = "xmlui-manifest.json"
+
+type manifest struct {
+	LauncherVersion string    `json:"launcherVersion"`
+	ExportedAt      time.Time `json:"exportedAt"`
+	// Platform is the os-arch the exported install was for; without
+	// --all-platforms the lockfile pins only its binaries.
+	Platform     string   `json:"platform"`
+	AllPlatforms bool     `json:"allPlatforms,omitempty"`
+	Flags        []string `json:"flags"`
+	// Lockfile names the lockfile, relative to the manifest.
+	Lockfile string `json:"lockfile"`
+	// Organization is the install's organization notes, their README
+	// copied next to the manifest.
+	Organization *orgNotes `json:"organization,omitempty"`
+}
+
+// runExportManifest implements `export-manifest`: it turns an install's
+// receipt into a manifest of its flags and a lockfile of its downloads, so
+// another machine can install exactly the same thing.
+func runExportManifest(args []string) int {
+	fs := newFlagSet("export-manifest")
+	dir := installDirFlag(fs)
+	out := fs.String("out", "", "directory to write "+manifestFile+" and "+lockFile+" to (default: the current directory)")
+	force := fs.Bool("force", false, "overwrite an existing manifest or lockfile there")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s (%v)\n", installDir, err)
+		return exitFailure
+	}
+	outDir, err := resolveInstallDir(*out)
+	if err != nil {
+		fmt.Println("Invalid --out:", err)
+		return exitFailure
+	}
+	m, l, notes, err := exportManifest(rcpt)
+	if err != nil {
+		fmt.Println("Failed to export the install:", err)
+		return exitFailure
+	}
+
+	manifestPath := filepath.Join(outDir, manifestFile)
+	lockPath := filepath.Join(outDir, lockFile)
+	orgPath := filepath.Join(outDir, orgReadmeFile)
+	orgReadme, err := readOrgReadme(rcpt.Organization)
+	if err != nil {
+		fmt.Printf("Failed to read the organization's README %s: %v\n", rcpt.Organization.README, err)
+		return exitFailure
+	}
+	if rcpt.Organization != nil {
+		m.Organization = &orgNotes{Message: rcpt.Organization.Message, Mode: rcpt.Organization.Mode}
+		if orgReadme != nil {
+			m.Organization.README = orgReadmeFile
+		}
+	}
+	if !*force {
+		for _, p := range []string{manifestPath, lockPath, orgPath} {
+			if p == orgPath && orgReadme == nil {
+				continue
+			}
+			if _, err := os.Stat(p); err == nil {
+				fmt.Printf("%s already exists; use --force to overwrite it\n", p)
+				return exitFailure
+			}
+		}
+	}
+	if err := fsys.MkdirAll(outDir, dirMode); err != nil {
+		fmt.Println("Failed to create --out:", err)
+		return exitFailure
+	}
+	for _, f := range []struct {
+		path string
+		v    any
+	}{{lockPath, l}, {manifestPath, m}} {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err == nil {
+			err = fsys.WriteFile(f.path, append(data, '\n'), fileMode)
+		}
+		if err != nil {
+			fmt.Printf("Failed to write %s: %v\n", f.path, err)
+			return exitFailure
+		}
+	}
+	if orgReadme != nil {
+		if err := fsys.WriteFile(orgPath, orgReadme, fileMode); err != nil {
+			fmt.Printf("Failed to write %s: %v\n", orgPath, err)
+			return exitFailure
+		}
+	}
+	fmt.Printf("Wrote %s and %s (%d artifacts)\n", manifestPath, lockPath, len(l.Artifacts))
+	if len(m.Flags) > 0 {
+		fmt.Println("  Flags:", strings.Join(m.Flags, " "))
+	}
+	for _, n := range notes {
+		fmt.Printf("%s %s\n", labelWarning, n)
+	}
+	if !m.AllPlatforms {
+		fmt.Printf("  The lockfile pins the %s binaries only: install from it on a machine like this one, or export an --all-platforms install\n", m.Platform)
+	}
+	fmt.Printf("Install the same with: xmlui-bundler --manifest %s --dir DIR\n", manifestPath)
+	return exitOK
+}
+
+// exportManifest derives the manifest and lockfile from rcpt, with notes
+// on what a copy may not reproduce. The MCP client configs, PATH entries
+// and shortcuts an install made are for its own machine and left out.
+func exportManifest(rcpt *receipt) (*manifest, *lockfile, []string, error) {
+	p := platform{rcpt.OS, rcpt.Arch}
+	if p.OS == "" {
+		p = thisMachine()
+	}
+	m := &manifest{
+		LauncherVersion: version,
+		ExportedAt:      time.Now().UTC(),
+		Platform:        p.String(),
+		AllPlatforms:    rcpt.AllPlatforms,
+		Lockfile:        lockFile,
+	}
+	boolFlag := func(name string, on bool) {
+		if on {
+			m.Flags = append(m.Flags, "--"+name)
+		}
+	}
+	valueFlag := func(name, v string) {
+		if v != "" {
+			m.Flags = append(m.Flags, "--"+name, v)
+		}
+	}
+	if rcpt.Port != 0 && rcpt.Port != 8080 {
+		valueFlag("port", strconv.Itoa(rcpt.Port))
+	}
+	boolFlag("all-platforms", rcpt.AllPlatforms)
+	boolFlag("flat", rcpt.Flat)
+	boolFlag("full-source", rcpt.FullSource)
+	boolFlag("no-scripts", rcpt.NoScripts)
+	boolFlag("keep-line-endings", rcpt.KeepLineEndings)
+	for _, pattern := range rcpt.Prune {
+		valueFlag("prune", pattern)
+	}
+	valueFlag("features", strings.Join(rcpt.Features, ","))
+	valueFlag("channel", rcpt.Channel)
+	valueFlag("variant", rcpt.Variant)
+	valueFlag("xmlui-npm", rcpt.XMLUINPM)
+	valueFlag("xmlui-runtime", rcpt.XMLUIRuntime)
+	names := make([]string, 0, len(rcpt.Vars))
+	for name := range rcpt.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		valueFlag("set", name+"="+rcpt.Vars[name])
+	}
+
+	appSource, appRef := rcpt.AppSource, rcpt.AppRef
+	if appSource == "" {
+		appSource, appRef = defaultAppSource, branchName
+	}
+	l := &lockfile{
+		LauncherVersion: version,
+		CreatedAt:       m.ExportedAt,
+		AppSource:       appSource,
+		AppRef:          appRef,
+		AppProvider:     rcpt.AppProvider,
+	}
+	var notes, branches []string
+	for _, c := range rcpt.Components {
+		if c.Unavailable != "" {
+			if c.Name == "server" {
+				boolFlag("static-fallback", true)
+			}
+			notes = append(notes, fmt.Sprintf("%s was not installed (%s), so the lockfile has no entry for it", c.Name, c.Unavailable))
+			continue
+		}
+		for _, d := range c.Downloads {
+			if d.SHA256 == "" {
+				return nil, nil, nil, fmt.Errorf("the receipt has no checksum for %s (%s); run update to record one", c.Name, d.URL)
+			}
+			l.Artifacts = append(l.Artifacts, lockedArtifact{Component: c.Name, Platform: d.Platform, URL: d.URL, SHA256: d.SHA256})
+			if path := localPathOf(d.URL); path != "" {
+				notes = append(notes, fmt.Sprintf("%s was installed from %s, which other machines can only use at the same path", c.Name, path))
+			} else if gitArchiveComponents[c.Name] && strings.Contains(d.URL, "refs/heads/") {
+				branches = append(branches, c.Name)
+			}
+		}
+	}
+	if len(l.Artifacts) == 0 {
+		return nil, nil, nil, errors.New("the receipt records no downloads")
+	}
+	if len(branches) > 0 {
+		notes = append(notes, fmt.Sprintf("%s came from branch heads, whose archives change as the branches move, after which installs from this lockfile fail the checksum; `xmlui-bundler lock` pins commits instead", strings.Join(branches, " and ")))
+	}
+	return m, l, notes, nil
+}
+
+// expandManifest replaces a --manifest FILE in args with the manifest's
+// flags and --locked against its lockfile. Flags given as well come after
+// them, so they win.
+func expandManifest(args []string) ([]string, error) {
+	var path string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		v, ok := strings.CutPrefix(strings.TrimPrefix(a, "-"), "-manifest")
+		switch {
+		case !ok:
+			rest = append(rest, a)
+			continue
+		case v == "":
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("--manifest needs a file")
+			}
+			i++
+			v = args[i]
+		case strings.HasPrefix(v, "="):
+			v = v[1:]
+		default:
+			rest = append(rest, a)
+			continue
+		}
+		path = v
+	}
+	if path == "" {
+		return args, nil
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, manifestFile)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	lock := m.Lockfile
+	switch {
+	case lock == "":
+		lock = lockfilePath(filepath.Dir(path))
+	case !filepath.IsAbs(lock):
+		lock = filepath.Join(filepath.Dir(path), lock)
+	}
+	if _, err := os.Stat(lock); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s names %s, which is missing", path, lock)
+	}
+	flags := append(append([]string{}, m.Flags...), "--locked", "--lockfile", lock)
+	if org := m.Organization; org != nil {
+		if org.README != "" && !strings.Contains(org.README, "://") && !filepath.IsAbs(org.README) {
+			org.README = filepath.Join(filepath.Dir(path), org.README)
+		}
+		for _, f := range []struct{ name, value string }{{"org-message", org.Message}, {"org-readme", org.README}, {"org-mode", org.Mode}} {
+			if f.value != "" {
+				flags = append(flags, "--"+f.name, f.value)
+			}
+		}
+	}
+	fmt.Printf("Manifest %s: %s\n", path, strings.Join(m.Flags, " "))
+	if host := thisMachine().String(); !m.AllPlatforms && m.Platform != "" && m.Platform != host {
+		fmt.Printf("  It pins %s binaries; this machine is %s, so give --target-os and --target-arch or ask for an --all-platforms export\n", m.Platform, host)
+	}
+	return append(flags, rest...), nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jonudell/xmlui-bundler/extract"
+)
+
+// dirTarget extracts into a directory on disk, through fsys.
+type dirTarget string
+
+func (dirTarget) Concurrent() bool { return true }
+
+func (d dirTarget) Mkdir(name string) error {
+	return fsys.MkdirAll(filepath.Join(string(d), filepath.FromSlash(name)), dirMode)
+}
+
+func (d dirTarget) Create(name string, mode fs.FileMode) (io.WriteCloser, error) {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := fsys.MkdirAll(filepath.Dir(p), dirMode); err != nil {
+		return nil, err
+	}
+	f, err := fsys.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile leaves the mode of an existing file alone.
+	if mode&0111 != 0 && installFS.chmod {
+		f.Chmod(mode &^ umask)
+	}
+	return f, nil
+}
+
+// Symlink falls back to copying the target where links can't be made (on
+// Windows without Developer Mode, exFAT or a locked-down container mount,
+// say), so fails only if that isn't extracted yet either.
+func (d dirTarget) Symlink(name, target string) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := fsys.MkdirAll(filepath.Dir(p), dirMode); err != nil {
+		return err
+	}
+	fsys.Remove(p)
+	err := errSymlinksUnsupported
+	if installFS.symlink {
+		err = fsys.Symlink(filepath.FromSlash(target), p)
+	}
+	if err == nil {
+		return nil
+	}
+	resolved := filepath.Join(filepath.Dir(p), filepath.FromSlash(target))
+	if _, serr := os.Stat(resolved); serr != nil {
+		return fmt.Errorf("symbolic link %s -> %s can't be created here (%v) or copied from its target", name, target, err)
+	}
+	if rel, rerr := filepath.Rel(resolved, p); rerr == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("symbolic link %s -> %s can't be created here (%v), and its target contains it, so can't be copied", name, target, err)
+	}
+	return copyTree(resolved, p)
+}
+
+// Link falls back to a copy where hard links can't be made, e.g. across
+// filesystems or on FAT.
+func (d dirTarget) Link(name, existing string) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	src := filepath.Join(string(d), filepath.FromSlash(existing))
+	if err := fsys.MkdirAll(filepath.Dir(p), dirMode); err != nil {
+		return err
+	}
+	fsys.Remove(p)
+	if fsys.Link(src, p) == nil {
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("hard link %s -> %s: %w", name, existing, err)
+	}
+	return copyFile(src, p, info.Mode().Perm())
+}
+
+// Copied reports the bytes a link made as name took as a copy: none where
+// it is a symbolic link or, for a hard link, the same file as existing.
+func (d dirTarget) Copied(name, existing string) int64 {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	info, err := os.Lstat(p)
+	if err != nil || info.Mode()&fs.ModeSymlink != 0 {
+		return 0
+	}
+	if existing != "" {
+		if src, err := os.Stat(filepath.Join(string(d), filepath.FromSlash(existing))); err == nil && os.SameFile(src, info) {
+			return 0
+		}
+	}
+	return dirSize(p)
+}
+
+// isExecutableName reports whether an extracted file should be made
+// executable: scripts and the bundled binaries.
+func isExecutableName(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".sh") || base == "xmlui-mcp" ||
+		base == "xmlui-mcp-client" || base == "xmlui-test-server"
+}
+
+// extractOptions are the options of every extraction into an install:
+// --max-extract-* limits, modes by name, progress and the status counts.
+func extractOptions(strip int) extract.Options {
+	return extract.Options{
+		Strip:    strip,
+		Mode:     extractedMode,
+		Limits:   extractLimits,
+		Observer: &extractProgress{},
+	}
+}
+
+// extractedMode makes scripts and binaries executable, whatever the archive
+// says. No need to remove quarantine on macOS for tar.gz files as the
+// attribute won't be set on extraction.
+func extractedMode(name string) fs.FileMode {
+	if isExecutableName(name) {
+		return execMode()
+	}
+	return fileMode
+}
+
+func unzipTo(data []byte, dest string, strip int) error {
+	return extractErr(extract.Zip(data, normalizedTarget(dirTarget(dest)), extractOptions(strip)))
+}
+
+// unzipSubtreesTo unzips into dest only the entries under dirs, which are
+// slash-separated paths from the archive's root.
+func unzipSubtreesTo(data []byte, dest string, dirs []string) error {
+	opts := extractOptions(0)
+	opts.Keep = func(name string) bool {
+		for _, d := range dirs {
+			if name == d || strings.HasPrefix(name, d+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	return extractErr(extract.Zip(data, normalizedTarget(dirTarget(dest)), opts))
+}
+
+func untarGzTo(data []byte, dest string, strip int) error {
+	return extractErr(extract.TarGz(data, normalizedTarget(dirTarget(dest)), extractOptions(strip)))
+}
+
+// extractArchive unpacks a zip or tar.gz archive into dest, telling them
+// apart by content since archive endpoints often have no file extension.
+func extractArchive(data []byte, dest string, strip int) error {
+	return extractErr(extract.Extract(data, normalizedTarget(dirTarget(dest)), extractOptions(strip)))
+}
+
+// unpackAsset puts a downloaded release asset into dest. Archives are
+// extracted; a bare executable is written as binary, the name it has on its
+// platform (e.g. xmlui-mcp.exe), and made executable.
+func unpackAsset(data []byte, dest, binary string) error {
+	if !isExecutableImage(data) {
+		return extractArchive(data, dest, 0)
+	}
+	out, err := normalizedTarget(dirTarget(dest)).Create(binary, execMode())
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	status.addFile()
+	return nil
+}
+
+// isExecutableImage reports whether data is a program rather than an
+// archive: an ELF, Mach-O or PE binary, or a script.
+func isExecutableImage(data []byte) bool {
+	for _, magic := range [][]byte{
+		[]byte("\x7fELF"),
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf}, // Mach-O, big-endian
+		{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe}, // Mach-O, little-endian
+		{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+		[]byte("MZ"),             // PE
+		[]byte("#!"),
+	} {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	return false
+}
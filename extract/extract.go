@@ -0,0 +1,471 @@
+// Package extract unpacks zip and tar.gz archives into a Target, which may be
+// a directory, memory or another archive. It rejects entries that would
+// escape the target's root, directly or through a symbolic link extracted
+// earlier, and enforces Limits on what one archive may unpack to.
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Target receives what an extractor unpacks. Names are slash-separated and
+// relative to the target's root, with Options.Strip applied and anything
+// that would escape the root already rejected, so implementations need no
+// path checks of their own. Tests can extract into memory and embedders can
+// redirect output, e.g. into a zip they are building with ZipTarget.
+type Target interface {
+	// Mkdir creates a directory and any missing parents.
+	Mkdir(name string) error
+	// Create creates or truncates a file, creating its parent directories.
+	Create(name string, mode fs.FileMode) (io.WriteCloser, error)
+	// Symlink creates a symbolic link to target, a relative path already
+	// checked to stay inside the root.
+	Symlink(name, target string) error
+	// Link makes name the same file as existing, extracted earlier.
+	Link(name, existing string) error
+}
+
+// ConcurrentTarget is a Target whose files can be written from several
+// goroutines at once. Wrappers should pass the question on to the target
+// they wrap, with IsConcurrent.
+type ConcurrentTarget interface {
+	Target
+	Concurrent() bool
+}
+
+// IsConcurrent reports whether t may be written concurrently.
+func IsConcurrent(t Target) bool {
+	c, ok := t.(ConcurrentTarget)
+	return ok && c.Concurrent()
+}
+
+// LinkCopier is a Target whose links can fall back to copies, which Copied
+// measures after the fact, so the limits count them. Wrappers should pass
+// the question on to the target they wrap, with CopiedBytes.
+type LinkCopier interface {
+	Target
+	// Copied reports the bytes the link name took as a copy: none for a
+	// real link. existing is the hard link's file, "" for a symbolic link.
+	Copied(name, existing string) int64
+}
+
+// CopiedBytes is the bytes the link name took in t as a copy, if t copies
+// links.
+func CopiedBytes(t Target, name, existing string) int64 {
+	if c, ok := t.(LinkCopier); ok {
+		return c.Copied(name, existing)
+	}
+	return 0
+}
+
+// Observer hears about an extraction as it goes, e.g. to show progress.
+type Observer interface {
+	// Start is called first, with the archive's entry count, or 0 for a
+	// stream whose size isn't known.
+	Start(total int)
+	// Entry is called for each entry read, by its name in the archive.
+	Entry(name string)
+	// Wrote is called for each file written, from the goroutine that wrote
+	// it.
+	Wrote(name string)
+	// Skipped is called at the end if entries of kinds that can't be
+	// extracted as files were skipped, once, with a count for each kind,
+	// e.g. "FIFOs (2)".
+	Skipped(kinds []string)
+	// Finish is called last, however the extraction ended.
+	Finish()
+}
+
+// Options control an extraction. The zero value extracts everything, with
+// no limits.
+type Options struct {
+	// Strip drops that many leading components from each entry's name,
+	// like tar --strip-components.
+	Strip int
+	// Keep, if set, picks the entries to extract by their names after
+	// Strip. The rest are never decompressed.
+	Keep func(name string) bool
+	// Mode, if set, gives the mode of each extracted file from its name.
+	// Otherwise a file is 0755 if the archive makes it executable and 0644
+	// if not.
+	Mode func(name string) fs.FileMode
+	// Limits bound what the archive may unpack to.
+	Limits Limits
+	// Observer, if set, hears about the entries as they are extracted.
+	Observer Observer
+}
+
+// ErrFormat is an archive that is neither a zip nor a tar.gz.
+var ErrFormat = errors.New("unrecognized archive format")
+
+// Extract unpacks a zip or tar.gz archive into t, telling them apart by
+// content since archive endpoints often have no file extension.
+func Extract(data []byte, t Target, opts Options) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return Zip(data, t, opts)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return TarGz(data, t, opts)
+	default:
+		return ErrFormat
+	}
+}
+
+// Zip unpacks a zip archive into t. Entries are independent in a zip, so
+// files are written in parallel where t allows it.
+func Zip(data []byte, t Target, opts Options) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	obs := observer{opts.Observer}
+	obs.Start(len(r.File))
+	defer obs.Finish()
+	writes := newFileWrites(t)
+	defer writes.flush()
+	lt := newLimitedTarget(t, opts.Limits)
+	t = lt
+	links := extractedLinks{}
+	skipped := skippedEntries{}
+	defer skipped.report(obs)
+	for _, f := range r.File {
+		obs.Entry(f.Name)
+		name, ok, err := entryName(f.Name, opts.Strip)
+		if err != nil {
+			return err
+		}
+		if !ok || opts.Keep != nil && !opts.Keep(name) {
+			continue
+		}
+		if err := links.check(name); err != nil {
+			return err
+		}
+		if err := lt.declare(name, int64(f.UncompressedSize64)); err != nil {
+			return err
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := t.Mkdir(name); err != nil {
+				return err
+			}
+			continue
+		case mode&fs.ModeSymlink != 0:
+			// Zip stores a link's target as its content, which the target
+			// may copy in its place, so the writes before it must be done.
+			if err := writes.flush(); err != nil {
+				return err
+			}
+			if err := zipSymlink(t, links, f, name); err != nil {
+				return err
+			}
+			links[name] = true
+			continue
+		case !mode.IsRegular():
+			skipped.add("special files")
+			continue
+		}
+		err = writes.write(name, func() error {
+			in, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			return writeEntry(t, obs, name, opts.fileMode(name, mode), in)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return writes.flush()
+}
+
+// TarGz unpacks a gzipped tar archive into t.
+func TarGz(data []byte, t Target, opts Options) error {
+	gzReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzReader)
+	obs := observer{opts.Observer}
+	obs.Start(0)
+	defer obs.Finish()
+	links := extractedLinks{}
+	lt := newLimitedTarget(t, opts.Limits)
+	t = lt
+	skipped := skippedEntries{}
+	defer skipped.report(obs)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		obs.Entry(hdr.Name)
+		name, ok, err := entryName(hdr.Name, opts.Strip)
+		if err != nil {
+			return err
+		}
+		if !ok || opts.Keep != nil && !opts.Keep(name) {
+			continue
+		}
+		if err := links.check(name); err != nil {
+			return err
+		}
+		switch kind := tarEntryKind(hdr.Typeflag); kind {
+		case "":
+		case "-":
+			continue
+		default:
+			skipped.add(kind)
+			continue
+		}
+		if err := lt.declare(name, hdr.Size); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := t.Mkdir(name); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeSymlink:
+			target, err := links.inside(name, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := t.Symlink(name, target); err != nil {
+				return err
+			}
+			links[name] = true
+			continue
+		case tar.TypeLink:
+			existing, ok, err := entryName(hdr.Linkname, opts.Strip)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("archive entry %q is a hard link to %q, which is stripped away", hdr.Name, hdr.Linkname)
+			}
+			if err := links.check(existing); err != nil {
+				return err
+			}
+			if err := t.Link(name, existing); err != nil {
+				return err
+			}
+			continue
+		}
+		mode := opts.fileMode(name, hdr.FileInfo().Mode())
+		if err := writeEntry(t, obs, name, mode, tarReader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileMode is the mode to create the file name with, archived with mode.
+func (o Options) fileMode(name string, archived fs.FileMode) fs.FileMode {
+	if o.Mode != nil {
+		return o.Mode(name)
+	}
+	if archived&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// observer calls an Observer, if there is one.
+type observer struct{ o Observer }
+
+func (o observer) Start(total int) {
+	if o.o != nil {
+		o.o.Start(total)
+	}
+}
+
+func (o observer) Entry(name string) {
+	if o.o != nil {
+		o.o.Entry(name)
+	}
+}
+
+func (o observer) Wrote(name string) {
+	if o.o != nil {
+		o.o.Wrote(name)
+	}
+}
+
+func (o observer) Skipped(kinds []string) {
+	if o.o != nil {
+		o.o.Skipped(kinds)
+	}
+}
+
+func (o observer) Finish() {
+	if o.o != nil {
+		o.o.Finish()
+	}
+}
+
+// entryName maps an archive entry name to its name in the target after
+// dropping strip leading components, like tar --strip-components. ok is false
+// for entries stripped away entirely; names that would escape the target are
+// an error.
+func entryName(name string, strip int) (rel string, ok bool, err error) {
+	var parts []string
+	for _, p := range strings.Split(filepath.ToSlash(name), "/") {
+		if p != "" && p != "." {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) <= strip {
+		return "", false, nil
+	}
+	rel = path.Clean(strings.Join(parts[strip:], "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false, fmt.Errorf("archive entry %q escapes the destination directory", name)
+	}
+	if rel == "." {
+		return "", false, nil
+	}
+	return rel, true, nil
+}
+
+// extractedLinks are the symbolic links one extraction has made so far, by
+// name. A link is only checked as a path, so an entry whose path goes
+// through one could land wherever the link points, outside the root after
+// a chain like a/l -> .. and a/l/m -> ..; such entries are refused rather
+// than resolved.
+type extractedLinks map[string]bool
+
+// check refuses the entry name if it is, or goes through, a link made
+// earlier.
+func (l extractedLinks) check(name string) error {
+	for i := 0; i <= len(name); i++ {
+		if (i == len(name) || name[i] == '/') && l[name[:i]] {
+			return fmt.Errorf("archive entry %q goes through the symbolic link %q", name, name[:i])
+		}
+	}
+	return nil
+}
+
+// inside checks that a symbolic link name -> target, both in the target's
+// terms, points inside the root without going through a link made earlier,
+// and returns target as a clean relative path.
+func (l extractedLinks) inside(name, target string) (string, error) {
+	target = filepath.ToSlash(target)
+	if path.IsAbs(target) || filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+		return "", fmt.Errorf("archive entry %q links to the absolute path %q", name, target)
+	}
+	var at []string
+	if dir := path.Dir(name); dir != "." {
+		at = strings.Split(dir, "/")
+	}
+	parts := strings.Split(target, "/")
+	for i, p := range parts {
+		switch p {
+		case "", ".":
+		case "..":
+			if len(at) == 0 {
+				return "", fmt.Errorf("archive entry %q links to %q, outside the destination directory", name, target)
+			}
+			at = at[:len(at)-1]
+		default:
+			at = append(at, p)
+			// The last part may be a link: the chain ends inside, as
+			// every link in it was checked the same way.
+			if i < len(parts)-1 && l[strings.Join(at, "/")] {
+				return "", fmt.Errorf("archive entry %q links to %q, through the symbolic link %q", name, target, strings.Join(at, "/"))
+			}
+		}
+	}
+	return path.Clean(target), nil
+}
+
+// skippedEntries counts archive entries of types that are not extracted,
+// by kind, for one report at the end rather than one per entry.
+type skippedEntries map[string]int
+
+// add counts an entry of kind.
+func (s skippedEntries) add(kind string) { s[kind]++ }
+
+// report tells obs what was skipped, if anything.
+func (s skippedEntries) report(obs observer) {
+	if len(s) == 0 {
+		return
+	}
+	kinds := make([]string, 0, len(s))
+	for k := range s {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for i, k := range kinds {
+		kinds[i] = fmt.Sprintf("%s (%d)", k, s[k])
+	}
+	obs.Skipped(kinds)
+}
+
+// tarEntryKind names the tar entry types that are skipped, or returns ""
+// for the ones extracted: files, directories and links. Metadata entries
+// (pax global headers) are skipped silently, as "-".
+func tarEntryKind(flag byte) string {
+	switch flag {
+	case tar.TypeReg, tar.TypeRegA, tar.TypeCont, tar.TypeDir, tar.TypeSymlink, tar.TypeLink, tar.TypeGNUSparse:
+		return ""
+	case tar.TypeXGlobalHeader:
+		return "-"
+	case tar.TypeChar, tar.TypeBlock:
+		return "device files"
+	case tar.TypeFifo:
+		return "FIFOs"
+	}
+	return fmt.Sprintf("entries of type %q", flag)
+}
+
+// zipSymlink extracts the zip entry f, a symbolic link, as name.
+func zipSymlink(t Target, links extractedLinks, f *zip.File, name string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	raw, err := io.ReadAll(io.LimitReader(in, 4096))
+	in.Close()
+	if err != nil {
+		return err
+	}
+	target, err := links.inside(name, string(raw))
+	if err != nil {
+		return err
+	}
+	return t.Symlink(name, target)
+}
+
+// writeEntry copies one extracted file into t.
+func writeEntry(t Target, obs observer, name string, mode fs.FileMode, r io.Reader) error {
+	out, err := t.Create(name, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	obs.Wrote(name)
+	return nil
+}
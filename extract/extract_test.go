@@ -0,0 +1,425 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memTarget extracts into memory: files by name with their content and
+// mode, directories, and links as "-> target" or "= existing".
+type memTarget struct {
+	mu         sync.Mutex
+	files      map[string]string
+	modes      map[string]fs.FileMode
+	dirs       map[string]bool
+	links      map[string]string
+	concurrent bool
+}
+
+func newMemTarget() *memTarget {
+	return &memTarget{files: map[string]string{}, modes: map[string]fs.FileMode{}, dirs: map[string]bool{}, links: map[string]string{}}
+}
+
+func (m *memTarget) Concurrent() bool { return m.concurrent }
+
+func (m *memTarget) Mkdir(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirs[name] = true
+	return nil
+}
+
+func (m *memTarget) Create(name string, mode fs.FileMode) (io.WriteCloser, error) {
+	return &memFile{m: m, name: name, mode: mode}, nil
+}
+
+func (m *memTarget) Symlink(name, target string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[name] = "-> " + target
+	return nil
+}
+
+func (m *memTarget) Link(name, existing string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[existing]; !ok {
+		return fmt.Errorf("hard link %s -> %s: no such file", name, existing)
+	}
+	m.links[name] = "= " + existing
+	return nil
+}
+
+type memFile struct {
+	m    *memTarget
+	name string
+	mode fs.FileMode
+	buf  bytes.Buffer
+}
+
+func (f *memFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+
+func (f *memFile) Close() error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.m.files[f.name] = f.buf.String()
+	f.m.modes[f.name] = f.mode
+	return nil
+}
+
+// entry is one archive entry to build: a file (with mode 0644 unless set),
+// a directory (a name ending in /), or a link if link is set.
+type entry struct {
+	name, body string
+	mode       fs.FileMode
+	link       string
+	hard       bool
+}
+
+func zipOf(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch {
+		case strings.HasSuffix(e.name, "/"):
+			hdr.SetMode(fs.ModeDir | 0755)
+		case e.link != "":
+			hdr.SetMode(fs.ModeSymlink | 0777)
+			body = e.link
+		case e.mode != 0:
+			hdr.SetMode(e.mode)
+		default:
+			hdr.SetMode(0644)
+		}
+		f, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, body)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzOf(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		case e.hard:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, e.link, 0
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case e.mode == fs.ModeNamedPipe:
+			hdr.Typeflag, hdr.Size = tar.TypeFifo, 0
+		case e.mode != 0:
+			hdr.Mode = int64(e.mode)
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, e.body)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	entries := []entry{
+		{name: "repo-main/"},
+		{name: "repo-main/README.md", body: "hello"},
+		{name: "repo-main/bin/run.sh", body: "#!/bin/sh\n", mode: 0755},
+		{name: "repo-main/docs/a/b.md", body: "b"},
+	}
+	for _, tc := range []struct {
+		format string
+		data   []byte
+	}{
+		{"zip", zipOf(t, entries...)},
+		{"tar.gz", tarGzOf(t, entries...)},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			m := newMemTarget()
+			if err := Extract(tc.data, m, Options{Strip: 1}); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"README.md": "hello", "bin/run.sh": "#!/bin/sh\n", "docs/a/b.md": "b"}
+			if !reflect.DeepEqual(m.files, want) {
+				t.Errorf("files = %v, want %v", m.files, want)
+			}
+			if m.modes["bin/run.sh"] != 0755 || m.modes["README.md"] != 0644 {
+				t.Errorf("modes = %v, want run.sh 0755 and README.md 0644", m.modes)
+			}
+			if len(m.dirs) != 0 {
+				t.Errorf("dirs = %v, want the stripped root left out", m.dirs)
+			}
+		})
+	}
+}
+
+func TestExtractFormat(t *testing.T) {
+	if err := Extract([]byte("not an archive"), newMemTarget(), Options{}); !errors.Is(err, ErrFormat) {
+		t.Errorf("Extract = %v, want ErrFormat", err)
+	}
+}
+
+func TestExtractOptions(t *testing.T) {
+	data := zipOf(t,
+		entry{name: "docs/a.md", body: "a"},
+		entry{name: "src/b.ts", body: "b"},
+		entry{name: "tool", body: "#!"},
+	)
+	m := newMemTarget()
+	var seen []string
+	opts := Options{
+		Keep: func(name string) bool { return name != "src/b.ts" },
+		Mode: func(name string) fs.FileMode {
+			seen = append(seen, name)
+			if name == "tool" {
+				return 0700
+			}
+			return 0600
+		},
+	}
+	if err := Zip(data, m, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.files["src/b.ts"]; ok {
+		t.Error("extracted src/b.ts, which Keep refused")
+	}
+	if m.modes["tool"] != 0700 || m.modes["docs/a.md"] != 0600 {
+		t.Errorf("modes = %v, want those Mode gave", m.modes)
+	}
+	if want := []string{"docs/a.md", "tool"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("Mode was asked about %v, want %v", seen, want)
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	m := newMemTarget()
+	data := tarGzOf(t,
+		entry{name: "lib/real.txt", body: "x"},
+		entry{name: "lib/alias.txt", link: "real.txt"},
+		entry{name: "lib/copy.txt", link: "lib/real.txt", hard: true},
+		entry{name: "up", link: "lib/../lib"},
+	)
+	if err := TarGz(data, m, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"lib/alias.txt": "-> real.txt", "lib/copy.txt": "= lib/real.txt", "up": "-> lib"}
+	if !reflect.DeepEqual(m.links, want) {
+		t.Errorf("links = %v, want %v", m.links, want)
+	}
+}
+
+// TestExtractEscapes checks that no entry can land outside the root, by its
+// name or through links, in either format.
+func TestExtractEscapes(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []entry
+	}{
+		{"dot-dot name", []entry{{name: "root/../evil", body: "x"}}},
+		{"dot-dot after strip", []entry{{name: "root/a/../../../evil", body: "x"}}},
+		{"absolute link", []entry{{name: "root/l", link: "/etc"}}},
+		{"link out", []entry{{name: "root/l", link: "../.."}}},
+		{"file through link", []entry{{name: "root/l", link: "."}, {name: "root/l/f", body: "x"}}},
+		{"link chain", []entry{{name: "root/a/l", link: ".."}, {name: "root/a/l/m", link: ".."}}},
+		{"link target through link", []entry{{name: "root/a/l", link: ".."}, {name: "root/b", link: "a/l/x"}}},
+	} {
+		for _, format := range []string{"zip", "tar.gz"} {
+			t.Run(tc.name+"/"+format, func(t *testing.T) {
+				data := zipOf(t, tc.entries...)
+				if format == "tar.gz" {
+					data = tarGzOf(t, tc.entries...)
+				}
+				m := newMemTarget()
+				err := Extract(data, m, Options{Strip: 1})
+				if err == nil {
+					t.Fatalf("extracted %v and %v, want an error", m.files, m.links)
+				}
+				for name := range m.files {
+					if name == "evil" || strings.Contains(name, "l/") {
+						t.Errorf("wrote %s", name)
+					}
+				}
+			})
+		}
+	}
+	if err := TarGz(tarGzOf(t, entry{name: "root/h", link: "elsewhere/f", hard: true}), newMemTarget(), Options{Strip: 1}); err == nil {
+		t.Error("extracted a hard link to an entry stripped away")
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	big := strings.Repeat("x", 1000)
+	for _, tc := range []struct {
+		name    string
+		limits  Limits
+		entries []entry
+		limit   string
+	}{
+		{"total bytes", Limits{TotalBytes: 1500}, []entry{{name: "a", body: big}, {name: "b", body: big}}, LimitTotalBytes},
+		{"file bytes", Limits{FileBytes: 999}, []entry{{name: "a", body: big}}, LimitFileBytes},
+		{"files", Limits{Files: 2}, []entry{{name: "a"}, {name: "b"}, {name: "c"}}, LimitFiles},
+		{"depth", Limits{Depth: 3}, []entry{{name: "a/b/c"}, {name: "a/b/c/d"}}, LimitDepth},
+	} {
+		for _, format := range []string{"zip", "tar.gz"} {
+			t.Run(tc.name+"/"+format, func(t *testing.T) {
+				data := zipOf(t, tc.entries...)
+				if format == "tar.gz" {
+					data = tarGzOf(t, tc.entries...)
+				}
+				err := Extract(data, newMemTarget(), Options{Limits: tc.limits})
+				var limit LimitError
+				if !errors.As(err, &limit) || limit.Limit != tc.limit {
+					t.Fatalf("Extract = %v, want a %s LimitError", err, tc.limit)
+				}
+			})
+		}
+	}
+}
+
+// lyingTarget copies every hard link, as a filesystem without them would,
+// and says so through Copied.
+type lyingTarget struct{ *memTarget }
+
+func (l lyingTarget) Copied(name, existing string) int64 {
+	return int64(len(l.files[existing]))
+}
+
+func TestExtractLimitsCountCopies(t *testing.T) {
+	entries := []entry{{name: "a", body: strings.Repeat("x", 600)}}
+	for i := range 3 {
+		entries = append(entries, entry{name: fmt.Sprintf("h%d", i), link: "a", hard: true})
+	}
+	err := TarGz(tarGzOf(t, entries...), lyingTarget{newMemTarget()}, Options{Limits: Limits{TotalBytes: 2000}})
+	var limit LimitError
+	if !errors.As(err, &limit) || limit.Limit != LimitTotalBytes {
+		t.Fatalf("TarGz = %v, want the copies to go over the total", err)
+	}
+	if err := TarGz(tarGzOf(t, entries...), newMemTarget(), Options{Limits: Limits{TotalBytes: 2000}}); err != nil {
+		t.Errorf("TarGz = %v, want real links not to count", err)
+	}
+}
+
+// recorder is an Observer that notes what it heard.
+type recorder struct {
+	mu      sync.Mutex
+	total   int
+	entries []string
+	wrote   []string
+	skipped []string
+	done    bool
+}
+
+func (r *recorder) Start(total int)        { r.total = total }
+func (r *recorder) Entry(name string)      { r.entries = append(r.entries, name) }
+func (r *recorder) Skipped(kinds []string) { r.skipped = kinds }
+func (r *recorder) Finish()                { r.done = true }
+
+func (r *recorder) Wrote(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wrote = append(r.wrote, name)
+}
+
+func TestExtractObserver(t *testing.T) {
+	r := &recorder{}
+	data := tarGzOf(t,
+		entry{name: "f", body: "x"},
+		entry{name: "p1", mode: fs.ModeNamedPipe},
+		entry{name: "p2", mode: fs.ModeNamedPipe},
+	)
+	if err := TarGz(data, newMemTarget(), Options{Observer: r}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.entries) != 3 || !reflect.DeepEqual(r.wrote, []string{"f"}) || !r.done {
+		t.Errorf("heard entries %v and wrote %v (done %v), want 3 entries and f", r.entries, r.wrote, r.done)
+	}
+	if want := []string{"FIFOs (2)"}; !reflect.DeepEqual(r.skipped, want) {
+		t.Errorf("skipped %v, want %v", r.skipped, want)
+	}
+
+	r = &recorder{}
+	if err := Zip(zipOf(t, entry{name: "a"}, entry{name: "b"}), newMemTarget(), Options{Observer: r}); err != nil {
+		t.Fatal(err)
+	}
+	if r.total != 2 {
+		t.Errorf("Start(%d) for a zip of 2 entries", r.total)
+	}
+}
+
+// TestExtractConcurrent writes a zip through a concurrent target, where
+// each name must end up with its last entry's content.
+func TestExtractConcurrent(t *testing.T) {
+	var entries []entry
+	want := map[string]string{}
+	for i := range 200 {
+		name := fmt.Sprintf("d%d/f%d", i%7, i%50)
+		body := strings.Repeat(fmt.Sprint(i), 100)
+		entries = append(entries, entry{name: name, body: body})
+		want[name] = body
+	}
+	m := newMemTarget()
+	m.concurrent = true
+	if err := Zip(zipOf(t, entries...), m, Options{Limits: Limits{TotalBytes: 1 << 20}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.files, want) {
+		t.Error("concurrent writes lost the order of entries with the same name")
+	}
+}
+
+// TestZipTarget repacks a tar.gz as a zip and extracts that again.
+func TestZipTarget(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	data := tarGzOf(t,
+		entry{name: "dir/"},
+		entry{name: "dir/run.sh", body: "#!/bin/sh\n", mode: 0755},
+		entry{name: "dir/link", link: "run.sh"},
+	)
+	if err := TarGz(data, ZipTarget(w), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m := newMemTarget()
+	if err := Zip(buf.Bytes(), m, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if m.files["dir/run.sh"] != "#!/bin/sh\n" || m.modes["dir/run.sh"] != 0755 {
+		t.Errorf("run.sh = %q, %v after the round trip", m.files["dir/run.sh"], m.modes["dir/run.sh"])
+	}
+	if m.links["dir/link"] != "-> run.sh" || !m.dirs["dir"] {
+		t.Errorf("links %v and dirs %v after the round trip", m.links, m.dirs)
+	}
+	if err := TarGz(tarGzOf(t, entry{name: "a", body: "x"}, entry{name: "b", link: "a", hard: true}), ZipTarget(zip.NewWriter(io.Discard)), Options{}); err == nil {
+		t.Error("stored a hard link in a zip")
+	}
+}
@@ -0,0 +1,186 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// Limits bound what one archive may unpack to, so a malicious or corrupt
+// archive (a zip bomb, say) fails the extraction instead of filling the
+// disk. Zero means no limit.
+type Limits struct {
+	TotalBytes int64 // uncompressed bytes of all files
+	Files      int   // files and directories
+	FileBytes  int64 // uncompressed bytes of any one file
+	Depth      int   // path components of any entry
+}
+
+// The Limits, as LimitError.Limit names them.
+const (
+	LimitTotalBytes = "TotalBytes"
+	LimitFiles      = "Files"
+	LimitFileBytes  = "FileBytes"
+	LimitDepth      = "Depth"
+)
+
+var limitNames = map[string]string{
+	LimitTotalBytes: "total size",
+	LimitFiles:      "file count",
+	LimitFileBytes:  "file size",
+	LimitDepth:      "path depth",
+}
+
+// LimitError is an archive that went over one of its Limits.
+type LimitError struct {
+	Limit string // the field of Limits, e.g. LimitTotalBytes
+	Max   int64  // its value
+	Name  string // the entry that went over it
+}
+
+func (e LimitError) Error() string {
+	unit := ""
+	if e.Limit == LimitTotalBytes || e.Limit == LimitFileBytes {
+		unit = " bytes"
+	}
+	return fmt.Sprintf("%s: archive exceeds the %s limit of %d%s", e.Name, limitNames[e.Limit], e.Max, unit)
+}
+
+// limitedTarget enforces limits on what an extractor writes to its Target,
+// counting the bytes actually written rather than trusting the sizes the
+// archive declares.
+type limitedTarget struct {
+	Target
+	limits Limits
+	// mu guards total and files, for targets written concurrently.
+	mu    sync.Mutex
+	total int64
+	files int
+}
+
+func newLimitedTarget(t Target, limits Limits) *limitedTarget {
+	return &limitedTarget{Target: t, limits: limits}
+}
+
+// admit counts an entry named name and checks the count and depth limits.
+func (t *limitedTarget) admit(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files++
+	if l := t.limits.Files; l > 0 && t.files > l {
+		return LimitError{LimitFiles, int64(l), name}
+	}
+	if l := t.limits.Depth; l > 0 && strings.Count(name, "/")+1 > l {
+		return LimitError{LimitDepth, int64(l), name}
+	}
+	return nil
+}
+
+// declare checks the declared size of the entry name up front, so an
+// archive whose headers admit to being too big fails before anything is
+// written.
+func (t *limitedTarget) declare(name string, size int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l := t.limits.FileBytes; l > 0 && size > l {
+		return LimitError{LimitFileBytes, l, name}
+	}
+	if l := t.limits.TotalBytes; l > 0 && t.total+size > l {
+		return LimitError{LimitTotalBytes, l, name}
+	}
+	return nil
+}
+
+func (t *limitedTarget) Concurrent() bool { return IsConcurrent(t.Target) }
+
+func (t *limitedTarget) Mkdir(name string) error {
+	if err := t.admit(name); err != nil {
+		return err
+	}
+	return t.Target.Mkdir(name)
+}
+
+func (t *limitedTarget) Symlink(name, target string) error {
+	if err := t.admit(name); err != nil {
+		return err
+	}
+	if err := t.Target.Symlink(name, target); err != nil {
+		return err
+	}
+	return t.charge(name, CopiedBytes(t.Target, name, ""), false)
+}
+
+func (t *limitedTarget) Link(name, existing string) error {
+	if err := t.admit(name); err != nil {
+		return err
+	}
+	if err := t.Target.Link(name, existing); err != nil {
+		return err
+	}
+	return t.charge(name, CopiedBytes(t.Target, name, existing), true)
+}
+
+// charge counts the n bytes the link name took as a copy, one file's if
+// file, so a chain of links copying each other can't get around the limits.
+// The copy is measured once made, so it can go over by that one copy.
+func (t *limitedTarget) charge(name string, n int64, file bool) error {
+	if n == 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l := t.limits.FileBytes; file && l > 0 && n > l {
+		return LimitError{LimitFileBytes, l, name}
+	}
+	t.total += n
+	if l := t.limits.TotalBytes; l > 0 && t.total > l {
+		return LimitError{LimitTotalBytes, l, name}
+	}
+	return nil
+}
+
+func (t *limitedTarget) Create(name string, mode fs.FileMode) (io.WriteCloser, error) {
+	if err := t.admit(name); err != nil {
+		return nil, err
+	}
+	w, err := t.Target.Create(name, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedWriter{w: w, t: t, name: name}, nil
+}
+
+// limitedWriter counts one file's bytes against its target's limits.
+type limitedWriter struct {
+	w    io.WriteCloser
+	t    *limitedTarget
+	name string
+	n    int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	size := int64(len(p))
+	if l := w.t.limits.FileBytes; l > 0 && w.n+size > l {
+		return 0, LimitError{LimitFileBytes, l, w.name}
+	}
+	w.t.mu.Lock()
+	if l := w.t.limits.TotalBytes; l > 0 && w.t.total+size > l {
+		w.t.mu.Unlock()
+		return 0, LimitError{LimitTotalBytes, l, w.name}
+	}
+	// Counted up front, so concurrent writes can't overshoot together.
+	w.t.total += size
+	w.t.mu.Unlock()
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if n < len(p) {
+		w.t.mu.Lock()
+		w.t.total -= size - int64(n)
+		w.t.mu.Unlock()
+	}
+	return n, err
+}
+
+func (w *limitedWriter) Close() error { return w.w.Close() }
@@ -0,0 +1,66 @@
+package extract
+
+import (
+	"runtime"
+	"sync"
+)
+
+// fileWrites runs the writes of an extraction, on a few goroutines if its
+// target allows it, and inline otherwise. Entries that depend on files
+// written earlier (links, or a name seen twice) wait for them with flush.
+type fileWrites struct {
+	parallel bool
+	sem      chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	err      error
+	pending  map[string]bool
+}
+
+func newFileWrites(t Target) *fileWrites {
+	w := &fileWrites{parallel: IsConcurrent(t)}
+	if w.parallel {
+		w.sem = make(chan struct{}, min(runtime.NumCPU(), 8))
+		w.pending = map[string]bool{}
+	}
+	return w
+}
+
+// write runs fn, which writes the file name, and returns the first error of
+// any write so far.
+func (w *fileWrites) write(name string, fn func() error) error {
+	if !w.parallel {
+		return fn()
+	}
+	if w.pending[name] {
+		// The same name again: the later entry wins, as it does in order.
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.pending[name] = true
+	w.sem <- struct{}{}
+	w.wg.Add(1)
+	go func() {
+		defer func() { <-w.sem; w.wg.Done() }()
+		if err := fn(); err != nil {
+			w.mu.Lock()
+			if w.err == nil {
+				w.err = err
+			}
+			w.mu.Unlock()
+		}
+	}()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// flush waits for the writes under way and returns the first error.
+func (w *fileWrites) flush() error {
+	w.wg.Wait()
+	clear(w.pending)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
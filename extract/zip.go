@@ -0,0 +1,50 @@
+package extract
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ZipTarget extracts into a zip archive being written to w, e.g. to repack
+// a tar.gz as a zip. Hard links can't be stored and fail the extraction.
+func ZipTarget(w *zip.Writer) Target { return zipTarget{w} }
+
+type zipTarget struct{ w *zip.Writer }
+
+func (z zipTarget) Mkdir(name string) error {
+	_, err := z.w.Create(strings.TrimSuffix(name, "/") + "/")
+	return err
+}
+
+func (z zipTarget) Create(name string, mode fs.FileMode) (io.WriteCloser, error) {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	hdr.SetMode(mode)
+	w, err := z.w.CreateHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// Symlink stores the link as zip does, as an entry holding its target.
+func (z zipTarget) Symlink(name, target string) error {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Store}
+	hdr.SetMode(fs.ModeSymlink | 0777)
+	w, err := z.w.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (z zipTarget) Link(name, existing string) error {
+	return fmt.Errorf("hard link %s -> %s can't be stored in a zip", name, existing)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/jonudell/xmlui-bundler/extract"
+	"strings"
+	"sync"
+	"time"
+)
+
+// extractProgressDelay is how long an extraction runs before it reports
+// progress: only archives as big as the XMLUI repo snapshot, with its tens
+// of thousands of entries, take that long.
+const extractProgressDelay = time.Second
+
+// extractProgress reports entries processed (of total, if known) and the
+// directory being extracted, in the console's style, so a long extraction
+// doesn't look hung.
+type extractProgress struct {
+	mu      sync.Mutex
+	total   int
+	done    int
+	dir     string
+	started time.Time
+	drawn   time.Time
+	shown   bool
+	dots    int
+}
+
+// Start begins reporting on an archive of total entries, or 0 for a stream
+// whose size isn't known.
+func (p *extractProgress) Start(total int) {
+	status.setFilesTotal(total)
+	p.total, p.started = total, time.Now()
+}
+
+// Entry counts the archive entry name.
+func (p *extractProgress) Entry(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if d := path.Dir(strings.TrimSuffix(name, "/")); d != "." {
+		p.dir = d
+	}
+	if !p.shown {
+		if time.Since(p.started) < extractProgressDelay {
+			return
+		}
+		p.shown = true
+	}
+	switch console.progress {
+	case progressBar:
+		if time.Since(p.drawn) >= 100*time.Millisecond {
+			p.draw()
+		}
+	case progressDots:
+		// 20 dots for a known count, otherwise one per thousand entries.
+		want := p.done / 1000
+		if p.total > 0 {
+			want = p.done * 20 / p.total
+		}
+		if want > p.dots {
+			if p.dots == 0 {
+				fmt.Print("  ")
+			}
+			fmt.Print(strings.Repeat(".", want-p.dots))
+			p.dots = want
+		}
+	}
+}
+
+func (p *extractProgress) draw() {
+	p.drawn = time.Now()
+	counts := fmt.Sprintf("  Extracting %d entries ", p.done)
+	if p.total > 0 {
+		counts = fmt.Sprintf("  Extracting %d/%d entries (%d%%) ", p.done, p.total, p.done*100/p.total)
+	}
+	line := console.fit(counts, p.dir)
+	if pad := console.lineWidth() - 1 - len([]rune(line)); pad > 0 {
+		line += strings.Repeat(" ", pad)
+	}
+	fmt.Print("\r" + line)
+}
+
+// Wrote counts a file extracted in the install's status.
+func (p *extractProgress) Wrote(string) { status.addFile() }
+
+// Skipped warns once about the entries that weren't extracted.
+func (p *extractProgress) Skipped(kinds []string) {
+	warn("  Skipped archive entries that can't be extracted as files: %s", strings.Join(kinds, ", "))
+}
+
+// Finish ends the display, with a line saying how many entries there were
+// if the extraction took long enough to report on.
+func (p *extractProgress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.shown {
+		return
+	}
+	switch console.progress {
+	case progressBar:
+		fmt.Print("\r" + strings.Repeat(" ", console.lineWidth()-1) + "\r")
+	case progressDots:
+		if p.dots > 0 {
+			fmt.Println()
+		}
+	}
+	fmt.Printf("  Extracted %d entries in %s\n", p.done, time.Since(p.started).Round(100*time.Millisecond))
+}
+
+// eolTarget passes on its target's answers to the optional extract
+// interfaces.
+func (t eolTarget) Concurrent() bool { return extract.IsConcurrent(t.Target) }
+
+func (t eolTarget) Copied(name, existing string) int64 {
+	return extract.CopiedBytes(t.Target, name, existing)
+}
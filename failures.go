@@ -0,0 +1,166 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/jonudell/xmlui-bundler/extract"
+)
+
+// Exit codes of the launcher's commands, one per class of failure, so wrappers
+// can react to each without parsing the output. They are a contract: a
+// code never changes meaning, and new classes get new codes.
+const (
+	exitOK          = 0
+	exitFailure     = 1  // a failure of no class below
+	exitUsage       = 2  // invalid flags or arguments
+	exitNetwork     = 3  // a download or API request failed
+	exitIntegrity   = 4  // a download didn't match its checksum (lockfile, release or launcher)
+	exitArchive     = 5  // an archive was corrupt, of an unknown format or over an --max-extract limit
+	exitFilesystem  = 6  // the install dir or staging couldn't be written to
+	exitConfig      = 7  // the config file, lockfile, --channel, source or layout was unusable
+	exitVerify      = 8  // an installed binary or a --verify-* smoke test failed
+	exitStrict      = 9  // --strict turned a warning into a failure
+	exitPartial     = 10 // --keep-going: some components failed, the rest are installed
+	exitCompat      = 11 // the installed versions don't work together (see compat.go)
+	exitInterrupted = 130
+)
+
+// integrityError is a download whose bytes differ from the checksum it
+// should have.
+type integrityError struct{ msg string }
+
+func (e *integrityError) Error() string { return e.msg }
+
+// errArchiveFormat is an archive that is neither a zip nor a tar.gz.
+var errArchiveFormat = extract.ErrFormat
+
+// classError gives err the exit code of the step that failed with it, for
+// errors whose type doesn't tell their class. The step wraps its error with
+// classify where it reports it.
+type classError struct {
+	code int
+	err  error
+}
+
+func (e *classError) Error() string { return e.err.Error() }
+func (e *classError) Unwrap() error { return e.err }
+
+// classify marks err as a failure of the class with exit code code.
+func classify(code int, err error) error { return &classError{code, err} }
+
+// exitCodeOf is the exit code for a failure: by the type of err where it
+// tells, then by the class its step gave it (see classify).
+func exitCodeOf(err error) int {
+	var integrity *integrityError
+	var status *httpStatusError
+	var netErr net.Error
+	var urlErr *url.Error
+	var limit errExtractLimit
+	var class *classError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &integrity):
+		return exitIntegrity
+	case errors.As(err, &status), errors.As(err, &netErr), errors.As(err, &urlErr):
+		return exitNetwork
+	case errors.As(err, &limit), errors.Is(err, errArchiveFormat), errors.Is(err, zip.ErrFormat),
+		errors.Is(err, zip.ErrChecksum), errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.Is(err, tar.ErrHeader):
+		return exitArchive
+	}
+	if errors.As(err, &class) {
+		return class.code
+	}
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) {
+		return exitFilesystem
+	}
+	return exitFailure
+}
+
+// keepGoing, with --keep-going, collects the failures of components
+// instead of ending the install at the first: see attempt. It is nil for
+// the default, fail-fast behavior.
+var keepGoing *componentFailures
+
+type componentFailures struct {
+	// active is the component attempt is running, "" outside one.
+	active string
+	failed []componentFailure
+}
+
+type componentFailure struct {
+	component, msg string
+	err            error
+	code           int
+}
+
+// attempt runs f, the part of the install that installs component. With
+// --keep-going a failure in it that would end the install (a fatal call) is
+// recorded instead, and attempt returns false; otherwise fatal exits as
+// ever.
+func attempt(component string, f func()) (ok bool) {
+	if keepGoing == nil {
+		f()
+		return true
+	}
+	keepGoing.active = component
+	defer func() {
+		keepGoing.active = ""
+		if r := recover(); r != nil {
+			failure, isFailure := r.(componentFailure)
+			if !isFailure {
+				panic(r)
+			}
+			keepGoing.failed = append(keepGoing.failed, failure)
+			fmt.Printf("  %s %s failed; carrying on with the rest (--keep-going)\n", glyphFail, component)
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+// failComponent hands a fatal failure of the component being attempted
+// back to attempt, if --keep-going is on and one is.
+func failComponent(msg string, err error, code int) {
+	if keepGoing == nil || keepGoing.active == "" {
+		return
+	}
+	panic(componentFailure{component: keepGoing.active, msg: msg, err: err, code: code})
+}
+
+// skipComponent records component as not attempted, because of why.
+func skipComponent(component, why string) {
+	fmt.Printf("  %s Skipping the %s: %s\n", glyphFail, component, why)
+	keepGoing.failed = append(keepGoing.failed, componentFailure{component: component, msg: "Skipped", err: errors.New(why), code: exitPartial})
+}
+
+func (c *componentFailures) failedComponent(name string) bool {
+	if c == nil {
+		return false
+	}
+	return slices.ContainsFunc(c.failed, func(f componentFailure) bool { return f.component == name })
+}
+
+// summary reports the failures at the end of a --keep-going install and
+// returns its exit code: exitOK if there were none.
+func (c *componentFailures) summary() int {
+	if c == nil || len(c.failed) == 0 {
+		return exitOK
+	}
+	fmt.Printf("\n%s %d component(s) failed; everything else is installed:\n", glyphFail, len(c.failed))
+	for _, f := range c.failed {
+		fmt.Printf("  %s: %s: %v (exit code %d)\n", f.component, f.msg, f.err, f.code)
+	}
+	fmt.Println("  `xmlui-bundler update` tries them again")
+	return exitPartial
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// extensionPackage is an optional XMLUI extension that --features can add.
+// Its source and docs come from the XMLUI repo snapshot the components are
+// taken from, and go into the MCP knowledge base next to the core ones; its
+// built bundle is downloaded into the app's extensionLibDir.
+type extensionPackage struct {
+	// Src and Docs are paths in the XMLUI repo; Docs may be empty.
+	Src, Docs string
+	// DistURL is the extension's built JavaScript bundle.
+	DistURL string
+}
+
+var extensionPackages = map[string]extensionPackage{
+	"animations": {
+		Src:     "packages/xmlui-animations/src",
+		Docs:    "packages/xmlui-animations/docs",
+		DistURL: "https://unpkg.com/xmlui-animations/dist/xmlui-animations.js",
+	},
+	"pdf": {
+		Src:     "packages/xmlui-pdf/src",
+		Docs:    "packages/xmlui-pdf/docs",
+		DistURL: "https://unpkg.com/xmlui-pdf/dist/xmlui-pdf.js",
+	},
+	"spreadsheet": {
+		Src:     "packages/xmlui-spreadsheet/src",
+		Docs:    "packages/xmlui-spreadsheet/docs",
+		DistURL: "https://unpkg.com/xmlui-spreadsheet/dist/xmlui-spreadsheet.js",
+	},
+	"website-blocks": {
+		Src:     "packages/xmlui-website-blocks/src",
+		Docs:    "packages/xmlui-website-blocks/docs",
+		DistURL: "https://unpkg.com/xmlui-website-blocks/dist/xmlui-website-blocks.js",
+	},
+}
+
+// extensionLibDir is where extension bundles go, relative to the app dir.
+const extensionLibDir = "lib"
+
+// featureNames returns the known extension names, sorted.
+func featureNames() []string {
+	names := make([]string, 0, len(extensionPackages))
+	for n := range extensionPackages {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// featuresFlag collects comma-separated extension names; it may be repeated.
+type featuresFlag struct{ names *[]string }
+
+func (f featuresFlag) String() string {
+	if f.names == nil {
+		return ""
+	}
+	return strings.Join(*f.names, ",")
+}
+
+func (f featuresFlag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := extensionPackages[name]; !ok {
+			return fmt.Errorf("unknown feature %q (have: %s)", name, strings.Join(featureNames(), ", "))
+		}
+		if !containsString(*f.names, name) {
+			*f.names = append(*f.names, name)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// featureTrees returns the source and docs trees of features in the XMLUI
+// snapshot at sourceRoot as install trees under mcp/src/extensions and
+// mcp/docs/extensions. A feature missing from the snapshot is an error; a
+// missing docs tree only a warning.
+func featureTrees(features []string, sourceRoot, docsDir, srcDir string) ([]struct{ from, to string }, error) {
+	var trees []struct{ from, to string }
+	for _, name := range features {
+		pkg := extensionPackages[name]
+		src := filepath.Join(sourceRoot, filepath.FromSlash(pkg.Src))
+		if _, err := os.Stat(src); err != nil {
+			return nil, fmt.Errorf("the XMLUI repo has no %s for feature %s", pkg.Src, name)
+		}
+		trees = append(trees, struct{ from, to string }{src, filepath.Join(srcDir, "extensions", name)})
+		if pkg.Docs == "" {
+			continue
+		}
+		docs := filepath.Join(sourceRoot, filepath.FromSlash(pkg.Docs))
+		if _, err := os.Stat(docs); err != nil {
+			warn("  Feature %s has no %s in the XMLUI repo; the MCP server will only see its source", name, pkg.Docs)
+			continue
+		}
+		trees = append(trees, struct{ from, to string }{docs, filepath.Join(docsDir, "extensions", name)})
+	}
+	return trees, nil
+}
+
+// featureBundleName is the file a feature's bundle is saved as.
+func featureBundleName(name string) string {
+	return path.Base(extensionPackages[name].DistURL)
+}
+
+// installFeatureBundles downloads the bundle of each of opts.features into
+// extensionLibDir of appDir (the tools dir, for --flat), as component
+// feature-<name>, and on update removes the bundles of features no longer
+// wanted.
+func installFeatureBundles(opts installOptions, rcpt *receipt, stage, installDir, appDir string) error {
+	libDir := filepath.Join(appDir, extensionLibDir)
+	for _, name := range opts.features {
+		component := "feature-" + name
+		data, url, sum, err := opts.fetch(component, platform{}, extensionPackages[name].DistURL, name+" extension")
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(stage, component)
+		if err := fsys.MkdirAll(tmp, dirMode); err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(filepath.Join(tmp, featureBundleName(name)), data, fileMode); err != nil {
+			return err
+		}
+		files, _, err := syncTree(tmp, libDir, installDir, opts.previousFiles(component), nil)
+		if err != nil {
+			return err
+		}
+		c := rcpt.component(component, url)
+		c.Files, c.Downloads = files, []receiptDownload{newDownload(platform{}, url, sum)}
+		if opts.flat {
+			fmt.Printf("  Copy %s into your app and load it with a <script> tag\n", filepath.Join(libDir, featureBundleName(name)))
+		} else {
+			fmt.Printf("  Load it in the app with <script src=\"%s/%s\"></script>\n", extensionLibDir, featureBundleName(name))
+		}
+	}
+	if opts.previous == nil {
+		return nil
+	}
+	for _, c := range opts.previous.Components {
+		name, ok := strings.CutPrefix(c.Name, "feature-")
+		if !ok || containsString(opts.features, name) {
+			continue
+		}
+		for key := range c.Files {
+			if err := journal.preserve(filepath.Join(installDir, filepath.FromSlash(key))); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("  Removed the %s extension\n", name)
+	}
+	return nil
+}
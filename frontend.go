@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// The tui and gui front ends only pick a command and its arguments; the
+// command runs as a child process of this executable, exactly as typed on
+// the command line, so a failing command returns to the menu or page.
+
+// frontEndCommands are the commands the front ends offer, in help's order.
+func frontEndCommands() []*command {
+	var cmds []*command
+	for _, g := range commandGroups {
+		for i := range commands {
+			if c := &commands[i]; c.group == g && g != "Front ends" && c.name != "help" {
+				cmds = append(cmds, c)
+			}
+		}
+	}
+	return cmds
+}
+
+// selfCommand is this executable run with args.
+func selfCommand(ctx context.Context, args []string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, self, args...), nil
+}
+
+// splitArgs splits line into arguments at spaces outside of single or
+// double quotes, which it removes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// runTUI implements `tui`: a numbered menu of the commands on the
+// terminal, which asks for the chosen command's flags and runs it.
+func runTUI(args []string) int {
+	fs := newFlagSet("tui")
+	fs.Parse(args)
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println("tui needs a terminal; run the commands directly instead (see xmlui-bundler help)")
+		return exitUsage
+	}
+	cmds := frontEndCommands()
+	in := bufio.NewReader(os.Stdin)
+	prompt := func(text string) (string, bool) {
+		fmt.Print(text)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println()
+			return "", false
+		}
+		return strings.TrimSpace(line), true
+	}
+	for {
+		group := ""
+		for i, c := range cmds {
+			if c.group != group {
+				group = c.group
+				fmt.Printf("\n%s:\n", console.paint(styleStep, group))
+			}
+			fmt.Printf("  %2d  %-16s %s\n", i+1, c.name, c.summary)
+		}
+		choice, ok := prompt("\nCommand (number or name; q quits): ")
+		if !ok || choice == "q" || choice == "quit" {
+			return exitOK
+		}
+		var cmd *command
+		if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(cmds) {
+			cmd = cmds[n-1]
+		} else if cmd = lookupCommand(choice); cmd == nil || cmd.group == "Front ends" {
+			fmt.Printf("No command %q%s\n", choice, suggestCommand(choice))
+			continue
+		}
+		line, ok := prompt(fmt.Sprintf("Flags and arguments for %s %s (Enter for none, ? for its help): ", cmd.name, cmd.usage))
+		if !ok {
+			return exitOK
+		}
+		if line == "?" {
+			line = "--help"
+		}
+		cmdArgs, err := splitArgs(line)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s xmlui-bundler %s\n", glyphMore, strings.TrimSpace(cmd.name+" "+line))
+		c, err := selfCommand(context.Background(), append([]string{cmd.name}, cmdArgs...))
+		if err != nil {
+			fmt.Println(err)
+			return exitFailure
+		}
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		// Ctrl-C reaches the command, which decides; the menu stays.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		err = c.Run()
+		signal.Stop(sigs)
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			fmt.Printf("%s %s exited with code %d\n", glyphFail, cmd.name, exitErr.ExitCode())
+		case err != nil:
+			fmt.Printf("%s %s: %v\n", glyphFail, cmd.name, err)
+		default:
+			fmt.Printf("%s %s done\n", glyphOK, cmd.name)
+		}
+	}
+}
+
+// runGUI implements `gui`: the commands as a page in the browser. The page
+// is served on 127.0.0.1 only, behind a random token in its URL, as it runs
+// whatever it is asked to; commands get no stdin, and their output streams
+// into the page until they end or Stop is pressed.
+func runGUI(args []string) int {
+	fs := newFlagSet("gui")
+	port := fs.Int("port", 0, "port to serve the page on (default: a free one)")
+	noOpen := fs.Bool("no-open", false, "print the page's address instead of opening it in the browser")
+	fs.Parse(args)
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	token := hex.EncodeToString(buf)
+	authorized := func(r *http.Request) bool {
+		return subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(token)) == 1
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			http.Error(w, "open the address the launcher printed", http.StatusForbidden)
+			return
+		}
+		type entry struct{ Group, Name, Usage, Summary string }
+		var entries []entry
+		for _, c := range frontEndCommands() {
+			entries = append(entries, entry{c.group, c.name, c.usage, c.summary})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		guiPage.Execute(w, map[string]any{"Token": token, "Commands": entries, "Version": version})
+	})
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		cmd := lookupCommand(r.FormValue("command"))
+		if cmd == nil || cmd.group == "Front ends" {
+			http.Error(w, "unknown command", http.StatusBadRequest)
+			return
+		}
+		cmdArgs, err := splitArgs(r.FormValue("args"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, err := selfCommand(r.Context(), append([]string{cmd.name, "--plain"}, cmdArgs...))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		out := &flushWriter{w: w}
+		c.Stdout, c.Stderr = out, out
+		err = c.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			fmt.Fprintf(out, "\n%s exited with code %d\n", cmd.name, exitErr.ExitCode())
+		case err != nil:
+			fmt.Fprintf(out, "\n%s: %v\n", cmd.name, err)
+		default:
+			fmt.Fprintf(out, "\n%s done\n", cmd.name)
+		}
+	})
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(*port)))
+	if err != nil {
+		fmt.Println("Failed to serve the page:", err)
+		return exitFailure
+	}
+	url := fmt.Sprintf("http://%s/?token=%s", ln.Addr(), token)
+	fmt.Println("The launcher's page is at", url)
+	fmt.Println("Press Ctrl-C to stop it")
+	if !*noOpen {
+		if err := openBrowser(url); err != nil {
+			fmt.Println("  Could not open the browser:", err)
+		}
+	}
+	srv := &http.Server{Handler: mux}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// flushWriter sends each write on to the page at once.
+type flushWriter struct {
+	w io.Writer
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// openBrowser opens url in the user's default browser.
+func openBrowser(url string) error {
+	argv := hostOS.openCommand(url)
+	return exec.Command(argv[0], argv[1:]...).Start()
+}
+
+var guiPage = template.Must(template.New("gui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>xmlui-bundler</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; max-width: 60rem; }
+h2 { font-size: 1rem; margin: 1.5rem 0 .5rem; }
+button.cmd { margin: .2rem; }
+form { margin: 1rem 0; display: flex; gap: .5rem; }
+#args { flex: 1; font-family: monospace; }
+pre { background: #111; color: #eee; padding: 1rem; min-height: 10rem; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>xmlui-bundler {{.Version}}</h1>
+{{- $group := ""}}
+{{- range .Commands}}
+{{- if ne .Group $group}}{{$group = .Group}}<h2>{{.Group}}</h2>{{end}}
+<button class="cmd" type="button" title="{{.Summary}}" data-name="{{.Name}}" data-usage="{{.Usage}}">{{.Name}}</button>
+{{- end}}
+<form id="run">
+<label for="args" id="label">Choose a command</label>
+<input id="args" placeholder="flags and arguments">
+<button id="go" type="submit" disabled>Run</button>
+<button id="help" type="button" disabled>Help</button>
+<button id="stop" type="button" disabled>Stop</button>
+</form>
+<pre id="out" aria-live="polite"></pre>
+<script>
+const token = {{.Token}};
+let command = "", running = null;
+const $ = id => document.getElementById(id);
+document.querySelectorAll("button.cmd").forEach(b => b.onclick = () => {
+  command = b.dataset.name;
+  $("label").textContent = "xmlui-bundler " + command;
+  $("args").placeholder = b.dataset.usage;
+  $("go").disabled = $("help").disabled = false;
+  $("args").focus();
+});
+async function run(args) {
+  running = new AbortController();
+  $("stop").disabled = false;
+  $("out").textContent = "$ xmlui-bundler " + command + " " + args + "\n";
+  try {
+    const resp = await fetch("run", {method: "POST", signal: running.signal,
+      body: new URLSearchParams({token, command, args})});
+    const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+    for (;;) {
+      const {value, done} = await reader.read();
+      if (done) break;
+      $("out").textContent += value;
+    }
+  } catch (e) {
+    $("out").textContent += "\n" + (running.signal.aborted ? "Stopped" : e) + "\n";
+  }
+  $("stop").disabled = true;
+}
+$("run").onsubmit = e => { e.preventDefault(); run($("args").value); };
+$("help").onclick = () => run("--help");
+$("stop").onclick = () => running && running.abort();
+</script>
+</body>
+</html>
+`))
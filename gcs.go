@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gs: URLs are fetched from the Cloud Storage XML API with an OAuth token
+// found as Google's client libraries would: $GOOGLE_OAUTH_ACCESS_TOKEN, the
+// service account key or user credentials in
+// $GOOGLE_APPLICATION_CREDENTIALS or gcloud's application default
+// credentials, or the metadata server of a GCE, GKE or Cloud Run machine.
+// Without any, requests go unauthenticated, which public objects answer.
+// $STORAGE_EMULATOR_HOST sends them to an emulator instead.
+
+// gcsScope is all the access the launcher needs.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// gcsToken is found once per run.
+var gcsToken struct {
+	once  sync.Once
+	token string
+	err   error
+}
+
+// gcsRoundTrip sends req, for gs://bucket/object, to Cloud Storage.
+func gcsRoundTrip(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	gcsToken.once.Do(func() {
+		gcsToken.token, gcsToken.err = findGCSToken(base)
+	})
+	if gcsToken.err != nil {
+		return nil, fmt.Errorf("Google Cloud credentials: %w", gcsToken.err)
+	}
+	target := &url.URL{Scheme: "https", Host: "storage.googleapis.com"}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			target = u
+		} else {
+			target = &url.URL{Scheme: "http", Host: host}
+		}
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + req.URL.Host + req.URL.Path
+	r := storedRequest(req, target)
+	if gcsToken.token != "" {
+		r.Header.Set("Authorization", "Bearer "+gcsToken.token)
+	}
+	resp, err := base.RoundTrip(r)
+	return storedResponse(req, resp, err)
+}
+
+// findGCSToken goes down the credential chain. Only credentials that are
+// configured but unusable are an error; finding none means unauthenticated
+// requests.
+func findGCSToken(base http.RoundTripper) (string, error) {
+	if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+		return t, nil
+	}
+	client := &http.Client{Transport: base, Timeout: 30 * time.Second}
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = gcloudCredentialsPath()
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		token, err := gcsTokenFromFile(client, data)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return token, nil
+	}
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		return "", err
+	}
+	// Off Google Cloud this fails quickly, and requests go unauthenticated.
+	token, _ := gcsMetadataToken(&http.Client{Transport: base, Timeout: awsMetadataTimeout})
+	return token, nil
+}
+
+// gcloudCredentialsPath is where `gcloud auth application-default login`
+// writes the user's credentials.
+func gcloudCredentialsPath() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" && hostOS.goos() == "windows" {
+		dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+// gcsCredentialsFile is the part of a credentials file the launcher reads.
+type gcsCredentialsFile struct {
+	Type string `json:"type"`
+	// A service account key.
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	// Authorized user credentials.
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcsTokenFromFile exchanges the credentials file data for a token.
+func gcsTokenFromFile(client *http.Client, data []byte) (string, error) {
+	var f gcsCredentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return "", err
+	}
+	tokenURI := f.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+	form := url.Values{}
+	switch f.Type {
+	case "service_account":
+		assertion, err := gcsAssertion(f, tokenURI, time.Now())
+		if err != nil {
+			return "", err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", f.ClientID)
+		form.Set("client_secret", f.ClientSecret)
+		form.Set("refresh_token", f.RefreshToken)
+	default:
+		return "", fmt.Errorf("credentials of type %q are not supported; use a service account key or `gcloud auth application-default login`", f.Type)
+	}
+	resp, err := client.PostForm(tokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var answer struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&answer)
+	if resp.StatusCode != http.StatusOK || answer.AccessToken == "" {
+		return "", fmt.Errorf("token request: %s %s", resp.Status, answer.Error)
+	}
+	return answer.AccessToken, nil
+}
+
+// gcsAssertion is the signed JWT a service account exchanges for a token.
+func gcsAssertion(f gcsCredentialsFile, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(f.PrivateKey))
+	if block == nil {
+		return "", errors.New("the private key is not PEM")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		key, _ = parsed.(*rsa.PrivateKey)
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", errors.New("the private key is not an RSA key")
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   f.ClientEmail,
+		"scope": gcsScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// gcsMetadataToken asks the metadata server for the machine's service
+// account token.
+func gcsMetadataToken(client *http.Client) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var answer struct {
+		AccessToken string `json:"access_token"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", err
+	}
+	return answer.AccessToken, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// gettingStartedFile is generated into the install dir from what was
+// actually installed, replacing the static README the release workflow used
+// to copy in.
+const gettingStartedFile = "XMLUI_GETTING_STARTED_README.md"
+
+var gettingStartedTemplate = template.Must(template.New("getting-started").Parse(`# XMLUI Starter Kit
+
+Welcome to the XMLUI starter kit. Everything below refers to this install:
+
+    {{.InstallDir}}
+{{if .OrgReadme}}
+Your organization's notes for it (support contacts and the like) are in
+` + "`{{.OrgReadme}}`" + `.
+{{end}}
+## Layout
+{{if .Flat}}
+- ` + "`{{.ToolsDir}}/`" + ` the MCP server and client and the test server, added to this project by ` + "`--flat`" + `{{else}}
+- ` + "`{{.AppDir}}/`" + ` the invoice sample app and the test server
+- ` + "`{{.ToolsDir}}/`" + ` the MCP server and client{{end}}{{if .AllPlatforms}} (builds for every platform in ` + "`bin-<os>-<arch>/`" + `, run through the dispatch scripts next to them){{end}}
+- ` + "`{{.ToolsDir}}/docs/`" + ` and ` + "`{{.ToolsDir}}/src/`" + ` the XMLUI component docs and source the MCP server searches
+{{if .Binaries}}
+Installed binaries:
+{{range .Binaries}}
+- ` + "`{{.Path}}`" + ` ({{.Version}}){{end}}
+{{end}}
+{{if .Flat}}## Serve your app
+
+{{.Shell}}
+
+    cd {{.InstallDir}}
+    {{.StartCommand}}
+{{else}}## Run the invoice app
+
+{{.Shell}}
+
+    cd {{.AppDir}}
+    {{.StartCommand}}
+{{end}}
+Then open http://localhost:{{.Port}} in your browser.
+{{if .ServerUnavailable}}
+The test server is not installed: {{.ServerUnavailable}}. This command
+serves the app's files as they are, with no API, so pages that load data
+show errors; run ` + "`xmlui-bundler update`" + ` to try the test server again.
+{{end}}
+## Use the MCP server
+{{if .MCPUnavailable}}
+The MCP tools are not installed: {{.MCPUnavailable}}. The docs and source
+they search are in place; run ` + "`xmlui-bundler update`" + ` once a build for this
+machine is published to add them.
+{{else}}
+{{if .MCPClients}}These MCP clients were configured to use it:
+{{range .MCPClients}}
+- {{.}}{{end}}
+{{else}}No MCP client was configured during install. Point your client at:
+
+    {{.MCPBinary}}
+{{end}}
+To try the bundled interactive client:
+
+    cd {{.ToolsDir}}
+    {{.MCPClientCommand}}
+{{end}}
+## Installed paths
+
+{{if .EnvCommand}}To have XMLUI_APP_DIR, XMLUI_MCP_BIN, XMLUI_DOCS_DIR and the other
+install locations in your shell, source the file generated for it:
+
+    {{.EnvCommand}}
+
+{{end}}` + "`xmlui-bundler env --dir {{.InstallDir}}`" + ` prints them at any time
+(` + "`--shell sh|powershell|cmd|json`" + `).
+`))
+
+type gettingStartedData struct {
+	InstallDir       string
+	AppDir           string
+	ToolsDir         string
+	Flat             bool
+	Port             int
+	Shell            string
+	StartCommand     string
+	MCPBinary        string
+	MCPClientCommand string
+	MCPClients       []string
+	MCPUnavailable   string
+	// ServerUnavailable is why there is no test server; StartCommand
+	// serves static files instead.
+	ServerUnavailable string
+	AllPlatforms      bool
+	Binaries          []receiptBinary
+	EnvCommand        string
+	// OrgReadme names the organization's README next to the guide.
+	OrgReadme string
+}
+
+// gettingStarted is what the getting-started guide says about the install.
+func gettingStarted(installDir, appDir string, rcpt *receipt) gettingStartedData {
+	appRel, err := filepath.Rel(installDir, appDir)
+	if err != nil {
+		appRel = appDir
+	}
+	d := gettingStartedData{
+		InstallDir:   installDir,
+		AppDir:       filepath.ToSlash(appRel),
+		ToolsDir:     rcpt.toolsDir(),
+		Flat:         rcpt.Flat,
+		Port:         rcpt.Port,
+		MCPClients:   rcpt.MCPClients,
+		AllPlatforms: rcpt.AllPlatforms,
+	}
+	for _, c := range rcpt.Components {
+		d.Binaries = append(d.Binaries, c.Binaries...)
+		switch c.Name {
+		case "mcp":
+			d.MCPUnavailable = c.Unavailable
+		case "server":
+			d.ServerUnavailable = c.Unavailable
+		}
+	}
+
+	if rcpt.OS == "windows" {
+		d.Shell = "In Command Prompt or PowerShell:"
+		d.StartCommand = "start.bat"
+		exe := ".exe"
+		if rcpt.AllPlatforms {
+			exe = ".cmd"
+		}
+		if _, err := os.Stat(filepath.Join(appDir, "start.bat")); err != nil {
+			d.StartCommand = `.\xmlui-test-server` + exe
+			if rcpt.Flat {
+				d.StartCommand = `.\` + flatDirName + `\xmlui-test-server` + exe
+			}
+		}
+		d.MCPBinary = filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("mcp")), "xmlui-mcp"+exe)
+		d.MCPClientCommand = "run-mcp-client.bat"
+	} else {
+		d.Shell = "In a terminal:"
+		d.StartCommand = runCommand(filepath.Join(appDir, "start.sh"), "./start.sh")
+		server := filepath.Join(appDir, "xmlui-test-server")
+		// start.sh runs the server itself, which it can't without an
+		// execute bit.
+		if _, err := os.Stat(filepath.Join(appDir, "start.sh")); err != nil || !isExecutable(server) {
+			d.StartCommand = runCommand(server, "./xmlui-test-server")
+			if rcpt.Flat {
+				d.StartCommand = runCommand(filepath.Join(appDir, flatDirName, "xmlui-test-server"), "./"+flatDirName+"/xmlui-test-server")
+			}
+		}
+		mcp := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("mcp")), "xmlui-mcp")
+		d.MCPBinary = runCommand(mcp, mcp)
+		d.MCPClientCommand = runCommand(filepath.Join(installDir, rcpt.toolsDir(), "run-mcp-client.sh"), "./run-mcp-client.sh")
+	}
+
+	if _, moved := rcpt.BinDirs["server"]; moved || d.ServerUnavailable != "" {
+		d.StartCommand = "xmlui-bundler serve --dir " + installDir
+	}
+	if rcpt.NoScripts {
+		d.MCPClientCommand = "xmlui-bundler mcp client --dir .."
+	}
+	envDir := envFileDir(installDir, rcpt)
+	if _, err := os.Stat(filepath.Join(envDir, envFileSh)); err == nil {
+		d.EnvCommand = ". " + filepath.Join(envDir, envFileSh)
+		if rcpt.OS == "windows" {
+			d.EnvCommand = ". " + psQuote(filepath.Join(envDir, envFilePs1))
+		}
+	}
+	return d
+}
+
+// renderGettingStarted renders the guide d describes.
+func renderGettingStarted(d gettingStartedData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gettingStartedTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeGettingStarted renders the getting-started guide for this install and
+// returns a short summary for the console. The organization's notes, with
+// orgReadme the README they name, are added to both or take their place.
+func writeGettingStarted(installDir, appDir string, rcpt *receipt, orgReadme []byte) (string, error) {
+	d := gettingStarted(installDir, appDir, rcpt)
+	guide := guidePath(installDir, rcpt)
+	org := rcpt.Organization
+	if org == nil {
+		org = &orgNotes{}
+	}
+	orgPath := filepath.Join(filepath.Dir(guide), orgReadmeFile)
+	for _, p := range []string{guide, orgPath} {
+		// The notes stay removed if the organization no longer gives any.
+		if err := journal.preserve(p); err != nil {
+			return "", err
+		}
+	}
+	if orgReadme != nil && !org.replaces() {
+		if err := fsys.WriteFile(orgPath, orgReadme, fileMode); err != nil {
+			return "", err
+		}
+		d.OrgReadme = orgReadmeFile
+	}
+
+	text, err := renderGettingStarted(d)
+	if err != nil {
+		return "", err
+	}
+	if orgReadme != nil && org.replaces() {
+		text = orgReadme
+	}
+	if err := fsys.WriteFile(guide, text, fileMode); err != nil {
+		return "", err
+	}
+
+	var summary strings.Builder
+	if org.Message != "" && org.replaces() {
+		fmt.Fprintf(&summary, "%s\n", strings.TrimRight(org.Message, "\n"))
+		fmt.Fprintf(&summary, "  More in %s", receiptKey(installDir, guide))
+		return summary.String(), nil
+	}
+	if rcpt.Flat {
+		fmt.Fprintf(&summary, "  Serve the app: %s\n", d.StartCommand)
+	} else {
+		fmt.Fprintf(&summary, "  Start the app: cd %s && %s\n", d.AppDir, d.StartCommand)
+	}
+	fmt.Fprintf(&summary, "  Then open:     http://localhost:%d\n", d.Port)
+	if d.ServerUnavailable != "" {
+		fmt.Fprintf(&summary, "  Test server:   not installed (%s); serving %s\n", d.ServerUnavailable, staticServerNote)
+	}
+	if d.MCPUnavailable != "" {
+		fmt.Fprintf(&summary, "  MCP server:    not installed (%s); `xmlui-bundler update` retries it\n", d.MCPUnavailable)
+	} else {
+		fmt.Fprintf(&summary, "  MCP server:    %s\n", d.MCPBinary)
+	}
+	if d.OrgReadme != "" {
+		fmt.Fprintf(&summary, "  Your organization's notes: %s\n", receiptKey(installDir, orgPath))
+	}
+	fmt.Fprintf(&summary, "  More in %s", receiptKey(installDir, guide))
+	if org.Message != "" {
+		fmt.Fprintf(&summary, "\n\n%s", strings.TrimRight(org.Message, "\n"))
+	}
+	return summary.String(), nil
+}
+
+// guidePath is where the getting-started guide goes: the install dir, or
+// its bundle dir, as a flat install leaves the project's top level alone.
+func guidePath(installDir string, rcpt *receipt) string {
+	if rcpt.Flat {
+		return filepath.Join(installDir, flatDirName, gettingStartedFile)
+	}
+	return filepath.Join(installDir, gettingStartedFile)
+}
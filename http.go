@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// downloadCtx governs every request and tree copy; cancelDownloads aborts
+// them in flight when the user interrupts the install.
+var downloadCtx, cancelDownloads = context.WithCancel(context.Background())
+
+// configureTLS trusts the PEM certificates in caCertFile in addition to the
+// system roots (for proxies that re-sign TLS), or disables verification
+// entirely when insecure is set.
+func configureTLS(caCertFile string, insecure bool) error {
+	if caCertFile == "" && !insecure {
+		return nil
+	}
+	cfg := &tls.Config{}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificates found in %s", caCertFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if insecure {
+		fmt.Println("WARNING: TLS certificate verification is DISABLED (--insecure-skip-verify).")
+		fmt.Println("WARNING: Downloads can be tampered with by anyone on the network path.")
+		cfg.InsecureSkipVerify = true
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	network.transport = transport
+	return nil
+}
+
+// tlsHint explains certificate errors, which usually mean a corporate proxy
+// is re-signing TLS traffic.
+func tlsHint(err error) string {
+	var unknown x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	if errors.As(err, &unknown) || errors.As(err, &invalid) || errors.As(err, &hostname) {
+		return " (if you are behind a TLS-intercepting proxy, pass its root certificate with --ca-cert <pem>)"
+	}
+	return ""
+}
+
+// requestHeaders are added to every outgoing request. They are applied after
+// the defaults, so --header 'User-Agent: ...' overrides ours.
+var requestHeaders = http.Header{}
+
+func userAgent() string {
+	return launcherName + "/" + version
+}
+
+// applyRequestHeaders sets the User-Agent and any --header values on req.
+func applyRequestHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent())
+	for key, values := range requestHeaders {
+		req.Header.Del(key)
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+}
+
+// headerFlag collects repeatable --header 'Key: Value' flags into an
+// http.Header.
+type headerFlag struct {
+	h http.Header
+}
+
+func (f headerFlag) String() string {
+	var parts []string
+	for k, vs := range f.h {
+		for _, v := range vs {
+			parts = append(parts, k+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f headerFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return fmt.Errorf("want 'Key: Value', got %q", s)
+	}
+	f.h.Add(key, strings.TrimSpace(value))
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// initTemplates are the scaffolds `init` creates apps from. Each directory
+// under templates/init but common is a --template; its files are laid over
+// those in common. NAME.tmpl files are rendered with text/template and become
+// NAME (gitignore.tmpl becomes .gitignore); other files are copied as is.
+//
+//go:embed templates/init
+var initTemplates embed.FS
+
+const (
+	initTemplateRoot   = "templates/init"
+	initCommonTemplate = "common"
+	defaultInitTheme   = "light"
+	defaultInitAPIBase = "/api"
+)
+
+// appNamePattern is what `init` accepts as an app name: it names a
+// directory and goes into config.json and the page title as is.
+var appNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// initFlagVars are the template variables set by flags rather than --var.
+var initFlagVars = map[string]string{
+	"name":             "the app name argument",
+	"port":             "--port",
+	"serverDir":        "--mcp",
+	"windowsServerDir": "--mcp",
+}
+
+// initTemplateNames lists the templates `init --template` accepts, sorted.
+func initTemplateNames() []string {
+	entries, _ := initTemplates.ReadDir(initTemplateRoot)
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != initCommonTemplate {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runInit implements `init NAME`: it scaffolds a minimal XMLUI app in a new
+// directory and gives it a test server, either copied from an existing
+// install or, with --mcp, from a --flat install into the app that also sets
+// up the MCP tools and knowledge base.
+func runInit(args []string) int {
+	fs := newFlagSet("init")
+	dir := fs.String("dir", "", "directory to create the app in (default: the current directory)")
+	port := fs.Int("port", 8080, "port start.sh and start.bat serve the app on")
+	withMCP := fs.Bool("mcp", false, "also install the MCP tools, knowledge base and test server into the app's "+flatDirName+"/, as with --flat")
+	from := fs.String("from", "", "install to copy the test server from (default: the most recent install with one)")
+	tmpl := fs.String("template", "blank", "scaffold to start from: "+strings.Join(initTemplateNames(), ", "))
+	vars := varsFlag{}
+	fs.Var(vars, "var", "template variable as name=value, e.g. theme=dark or apiBase=https://api.example.com (repeatable)")
+	fs.Usage = func() {
+		fmt.Println("Usage: xmlui-bundler init [--dir DIR] [--port PORT] [--template NAME] [--var NAME=VALUE]... [--mcp] [--from INSTALL] NAME")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	name := fs.Arg(0)
+	if !appNamePattern.MatchString(name) {
+		fmt.Printf("Invalid app name %q: use letters, digits, '.', '_' and '-'\n", name)
+		return exitUsage
+	}
+	if !slices.Contains(initTemplateNames(), *tmpl) {
+		fmt.Printf("Unknown template %q; available: %s\n", *tmpl, strings.Join(initTemplateNames(), ", "))
+		return exitUsage
+	}
+	for k := range vars {
+		if how, ok := initFlagVars[k]; ok {
+			fmt.Printf("--var %s: %s is set by %s\n", k, k, how)
+			return exitUsage
+		}
+	}
+	parent, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	appDir := filepath.Join(parent, name)
+	if entries, err := os.ReadDir(appDir); err == nil && len(entries) > 0 {
+		fmt.Printf("%s already exists and is not empty\n", appDir)
+		return exitFailure
+	}
+
+	// Without --mcp, find the server before writing anything.
+	var server string
+	if !*withMCP {
+		if server, err = installedServer(*from); err != nil {
+			fmt.Println(err)
+			return exitFailure
+		}
+	}
+
+	data := map[string]any{
+		"name": name, "port": *port, "theme": defaultInitTheme, "apiBase": defaultInitAPIBase,
+		"serverDir": "", "windowsServerDir": "",
+	}
+	if *withMCP {
+		data["serverDir"], data["windowsServerDir"] = flatDirName+"/", flatDirName+`\`
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	if err := fsys.MkdirAll(appDir, dirMode); err != nil {
+		fmt.Println("Could not create the app directory:", err)
+		return exitFailure
+	}
+	written, err := scaffoldApp(appDir, *tmpl, data)
+	if err != nil {
+		fmt.Println("Could not write the app:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Created %s from the %s template: %s\n", glyphOK, appDir, *tmpl, strings.Join(written, ", "))
+
+	if *withMCP {
+		var opts installOptions
+		ifs := newFlagSet("install")
+		installFlags(ifs, &opts)
+		ifs.Parse([]string{"--flat", "--dir", appDir, "--port", fmt.Sprint(*port)})
+		// install exits on failure, leaving the scaffold in place.
+		install(opts)
+	} else if server != "" {
+		dst := filepath.Join(appDir, filepath.Base(server))
+		if err := copyFile(server, dst, execMode()); err != nil {
+			fmt.Println("Could not copy the test server:", err)
+			return exitFailure
+		}
+		fmt.Printf("%s Copied the test server from %s\n", glyphOK, server)
+	}
+
+	start := hostOS.startScript()
+	fmt.Printf("\nNext:\n  cd %s\n  %s\n  Then open http://localhost:%d\n", appDir, start, *port)
+	if !*withMCP && server == "" {
+		fmt.Println("  No installed test server was found; run `xmlui-bundler --flat` in the app to add one")
+	}
+	return exitOK
+}
+
+// scaffoldApp writes template tmpl, over common, into appDir, rendering its
+// .tmpl files with data, and returns the paths written, sorted. A variable
+// a template uses but data lacks is an error rather than "<no value>".
+func scaffoldApp(appDir, tmpl string, data map[string]any) ([]string, error) {
+	// Relative path in the app -> embedded file; the template's own win.
+	files := map[string]string{}
+	for _, layer := range []string{initCommonTemplate, tmpl} {
+		root := path.Join(initTemplateRoot, layer)
+		err := fs.WalkDir(initTemplates, root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel := strings.TrimPrefix(p, root+"/")
+			if strings.HasSuffix(rel, ".tmpl") {
+				rel = strings.TrimSuffix(rel, ".tmpl")
+				if path.Base(rel) == "gitignore" {
+					rel = path.Join(path.Dir(rel), ".gitignore")
+				}
+			}
+			files[rel] = p
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	funcs := template.FuncMap{"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	}}
+	// Render everything before writing anything, so a bad template or
+	// variable leaves no half-made app behind.
+	type output struct {
+		content []byte
+		mode    os.FileMode
+	}
+	outputs := map[string]output{}
+	for rel, p := range files {
+		content, err := initTemplates.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		mode := fileMode
+		if strings.HasSuffix(p, ".tmpl") {
+			t, err := template.New(path.Base(p)).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, data); err != nil {
+				return nil, err
+			}
+			content = buf.Bytes()
+			switch path.Ext(rel) {
+			case ".bat":
+				content = []byte(crlf(string(content)))
+			case ".sh":
+				mode = execMode()
+			}
+		}
+		outputs[rel] = output{content, mode}
+	}
+	var written []string
+	for rel, out := range outputs {
+		dst := filepath.Join(appDir, filepath.FromSlash(rel))
+		if err := fsys.MkdirAll(filepath.Dir(dst), dirMode); err != nil {
+			return nil, err
+		}
+		if err := fsys.WriteFile(dst, out.content, out.mode); err != nil {
+			return nil, err
+		}
+		written = append(written, rel)
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+// installedServer finds this machine's test server binary in the install at
+// from or, if from is "", in the most recently installed one that has it.
+// It returns "" (and no error) if there is none.
+func installedServer(from string) (string, error) {
+	var dirs []string
+	if from != "" {
+		dir, err := filepath.Abs(from)
+		if err != nil {
+			return "", err
+		}
+		if _, err := readReceipt(dir); err != nil {
+			return "", fmt.Errorf("no install found in %s: %w", dir, err)
+		}
+		dirs = []string{dir}
+	} else {
+		index, err := readInstallIndex()
+		if err != nil {
+			return "", err
+		}
+		for _, dir := range index {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	best, bestTime := "", int64(0)
+	hostBin := thisMachine().binDir()
+	for _, dir := range dirs {
+		rcpt, err := readReceipt(dir)
+		if err != nil {
+			continue
+		}
+		for _, c := range rcpt.Components {
+			if c.Name != "server" {
+				continue
+			}
+			for _, b := range c.Binaries {
+				parent := path.Base(path.Dir(b.Path))
+				if strings.HasPrefix(parent, "bin-") && parent != hostBin ||
+					!strings.HasPrefix(parent, "bin-") && (platform{rcpt.OS, rcpt.Arch}) != thisMachine() {
+					continue
+				}
+				p := filepath.Join(dir, filepath.FromSlash(b.Path))
+				if _, err := os.Stat(p); err != nil {
+					continue
+				}
+				if t := rcpt.InstalledAt.UnixNano(); best == "" || t > bestTime {
+					best, bestTime = p, t
+				}
+			}
+		}
+	}
+	if best == "" && from != "" {
+		return "", fmt.Errorf("the install in %s has no test server for this machine", from)
+	}
+	return best, nil
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// installOptions holds the flags accepted by the default install command.
+type installOptions struct {
+	addToPath         bool
+	addLauncherToPath bool
+	skipVersionCheck  bool
+	staleStagingDays  int
+	statusAddr        string
+	port              int
+	ephemeral         bool
+	dir               string
+	caCert            string
+	insecure          bool
+	vars              varsFlag
+	appSource         string
+	appRef            string
+	appProvider       string
+	stripComponents   int
+	allPlatforms      bool
+	flat              bool
+	noScripts         bool
+	configure         []string
+	locked            bool
+	verifyMCP         bool
+	verifyServer      bool
+	fullSource        bool
+	prune             []string
+	features          []string
+	channel           string
+	xmluiNPM          string
+	xmluiRuntime      string
+	compatMatrix      string
+	checksumFile      string
+	ignoreCompat      bool
+	staticFallback    bool
+	dryRun            bool
+	sandbox           bool
+	target            platform
+	variant           string
+	unblock           bool
+	local             localPaths
+	telemetry         string
+	windows           windowsScripts
+
+	// lock is the lockfile a --locked install must match.
+	lock *lockfile
+	// checksums is the --checksum-file list, if one was given.
+	checksums checksumList
+
+	// lockfile, if set, is where --locked reads it instead of the install
+	// dir.
+	lockfile string
+
+	// org is the organization's message and README for the install.
+	org orgNotes
+
+	// previous is the receipt of the install being updated, or nil for a
+	// fresh install.
+	previous *receipt
+}
+
+// installFlags registers the flags shared by install and update.
+func installFlags(fs *flag.FlagSet, opts *installOptions) {
+	fs.StringVar(&opts.dir, "dir", "", "install directory (default: the current directory)")
+	// Expanded by expandProfiles before parsing; registered for the usage.
+	fs.String("profile", "", "named set of flags from "+configFileName+" or built in: classroom, ci or minimal (flags given as well win)")
+	// Expanded by expandManifest before parsing; registered for the usage.
+	fs.String("manifest", "", "install what an export-manifest describes: its flags, --locked against the lockfile beside it (flags given as well win)")
+	fs.BoolVar(&opts.addToPath, "add-to-path", false, "add the mcp directory to the user PATH")
+	fs.BoolVar(&opts.addLauncherToPath, "add-launcher-to-path", false, "with --add-to-path, also add the directory containing this executable")
+	fs.BoolVar(&opts.windows.profile, "powershell-profile", false, "on Windows, import the generated PowerShell module (mcp\\"+psModuleFile+") in your PowerShell profiles")
+	fs.BoolVar(&opts.windows.shortcut, "shortcut", false, "on Windows, add a Start menu shortcut that starts the app")
+	fs.StringVar(&opts.windows.signWith, "sign-scripts", "", "on Windows, sign the generated PowerShell scripts with the code-signing certificate of this thumbprint in Cert:\\CurrentUser\\My")
+	fs.BoolVar(&opts.unblock, "unblock", false, "clear the download quarantine (Windows' Mark of the Web, macOS's com.apple.quarantine) from installed binaries that carry it, instead of only explaining how")
+	fs.BoolVar(&opts.skipVersionCheck, "skip-version-check", false, "don't run installed binaries with --version after extraction")
+	fs.IntVar(&opts.staleStagingDays, "stale-staging-days", 2, "remove leftover staging directories older than this many days")
+	fs.IntVar(&opts.port, "port", 8080, "port the test server will serve the app on")
+	fs.BoolVar(&opts.ephemeral, "ephemeral", false, "stage under the system temp dir, write no cleanup scripts or state, and delete this executable when done if it is a temporary copy")
+	fs.StringVar(&opts.statusAddr, "status-addr", "", "serve JSON install progress on this address, e.g. 127.0.0.1:0")
+	fs.StringVar(&opts.caCert, "ca-cert", "", "PEM file of extra CA certificates to trust, e.g. a corporate proxy's root")
+	fs.BoolVar(&opts.insecure, "insecure-skip-verify", false, "disable TLS certificate verification (dangerous)")
+	fs.StringVar(&opts.appSource, "app-source", defaultAppSource, "app repository (GitHub, GitLab, Bitbucket, Codeberg/Gitea) or .zip/.tar.gz URL")
+	fs.StringVar(&opts.appRef, "app-ref", branchName, "branch of --app-source to install")
+	localPathFlags(fs, &opts.local)
+	fs.StringVar(&opts.appProvider, "app-provider", "", "git host type for self-hosted --app-source: github, gitlab, bitbucket or gitea")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the install's steps (downloads, extraction, layout, permissions, configuration) without doing anything")
+	fs.BoolVar(&opts.staticFallback, "static-fallback", false, "if the test server can't be downloaded or has no build for this machine, install without it and let serve show the app as static files (no API)")
+	fs.StringVar(&opts.org.Message, "org-message", "", "your organization's message to show at the end of the install, e.g. where to get help (default: the config's \"organization\")")
+	fs.StringVar(&opts.org.README, "org-readme", "", "file or URL of your organization's README (support contacts, VPN notes…) to write into the install dir as "+orgReadmeFile)
+	fs.StringVar(&opts.org.Mode, "org-mode", "", "supplement (the default) adds the organization's message and README to the getting-started guide and summary; replace puts them in their place")
+	fs.StringVar(&opts.variant, "variant", "", "install this variant of the app, from its "+variantsDir+"/NAME directory or "+variantBranch+"NAME branch (e.g. sqlite or postgres)")
+	fs.StringVar(&opts.xmluiNPM, "xmlui-npm", "", "take the app's "+xmluiNPMDir+" assets from the xmlui npm package at this dist-tag, version or version prefix (e.g. latest, 0.9.1, 0.9) instead of the app repo")
+	fs.StringVar(&opts.xmluiRuntime, "xmlui-runtime", runtimeAuto, "put a prebuilt XMLUI standalone runtime into the app's "+xmluiRuntimeDir+"/ directory so it runs offline: a version, latest, npm:SPEC for the npm build, off, or auto to fetch the script the app's index.html loads from there when the app repo lacks it")
+	fs.StringVar(&opts.compatMatrix, "compat-matrix", "", "file or URL of the compatibility matrix to check the installed versions against, instead of the configured or built-in one")
+	fs.BoolVar(&opts.ignoreCompat, "ignore-compat", false, "only warn when the installed versions of XMLUI, the MCP tools, the test server and the app don't work together, instead of failing")
+	fs.Var(channelFlag{&opts.channel}, "channel", "release channel to install from: stable (latest releases), beta (prereleases too) or nightly (nightly builds and branch heads); default: the releases this launcher was built with")
+	fs.StringVar(&opts.target.OS, "target-os", hostOS.goos(), "provision the install for this OS (darwin, linux or windows) instead of this machine's, e.g. to prepare USB sticks for a workshop on other machines")
+	fs.StringVar(&opts.target.Arch, "target-arch", runtime.GOARCH, "provision the install for this architecture (amd64 or arm64) instead of this machine's")
+	fs.BoolVar(&opts.allPlatforms, "all-platforms", false, "install MCP tools and test server for every supported OS/arch, with dispatch scripts, e.g. for a shared network drive")
+	fs.BoolVar(&opts.flat, "flat", false, "add only the MCP tools, knowledge base and test server to an existing project, in "+flatDirName+"/ of --dir, without the sample app")
+	fs.BoolVar(&opts.noScripts, "no-scripts", false, "leave out the helper scripts (prepare-binaries, run-mcp-client, cleanup); use xmlui-bundler mcp prepare, mcp client and clean --launcher instead")
+	fs.BoolVar(&opts.fullSource, "full-source", false, "keep tests, stories and build artifacts in the XMLUI components snapshot")
+	fs.BoolVar(&opts.sandbox, "sandbox", false, "install as if the install dir's filesystem could neither change file modes nor make symbolic links, as on exFAT (which is detected): links become copies and binaries that aren't executable get wrapper scripts")
+	fs.BoolVar(&keepLineEndings, "keep-line-endings", false, "extract scripts as they are, rather than giving shell scripts LF and .bat/.cmd files CRLF line endings")
+	fs.Var(featuresFlag{&opts.features}, "features", "optional XMLUI extensions to add, comma-separated: "+strings.Join(featureNames(), ", ")+" (their docs and source join the MCP knowledge base, their bundles go into the app's "+extensionLibDir+" directory)")
+	fs.Var(stringsFlag{&opts.prune}, "prune", "extra name pattern to drop from the components snapshot, e.g. '*.md' (repeatable)")
+	fs.Var(telemetryFlag{&opts.telemetry}, "telemetry", "on to send one anonymous usage report (launcher version, OS/arch, success or failure) when this run ends; see \"xmlui-bundler telemetry status\". Default: the config's \"telemetry\" setting, itself off")
+	fs.BoolVar(&strictWarnings, "strict", false, "fail instead of warning when an expected file is missing or a step only partly succeeds, e.g. to validate release bundles in CI")
+	fs.BoolFunc("keep-going", "when a component fails, install the others anyway and list the failures at the end (exit code 10)", func(string) error {
+		keepGoing = &componentFailures{}
+		return nil
+	})
+	fs.BoolFunc("fail-fast", "stop at the first component that fails (the default; undoes an earlier --keep-going)", func(string) error {
+		keepGoing = nil
+		return nil
+	})
+	for _, c := range mcpClientConfigs {
+		fs.BoolFunc("configure-"+c.flag, "add the MCP server to "+c.name+"'s config, keeping its other servers, backing up the file and showing the change", func(v string) error {
+			on, err := strconv.ParseBool(v)
+			if on {
+				opts.configure = append(opts.configure, c.flag)
+			}
+			return err
+		})
+	}
+	fs.BoolVar(&opts.verifyMCP, "verify-mcp", false, "after installing, run the MCP server smoke test (as in: xmlui-bundler mcp test)")
+	fs.BoolVar(&opts.verifyServer, "verify-server", false, "after installing, start the test server and check the app's routes (as in: xmlui-bundler server test)")
+	fs.StringVar(&opts.checksumFile, "checksum-file", "", "file or URL of SHA-256 checksums in sha256sum format to verify downloads against, e.g. the checksums.txt of the release a mirror carries; releases' own checksums.txt and ASSET.sha256 are used without it")
+	fs.BoolVar(&opts.locked, "locked", false, "install exactly what "+lockFile+" in the install dir pins, verifying checksums")
+	fs.StringVar(&opts.lockfile, "lockfile", "", "with --locked, the lockfile to install from instead of the install dir's")
+	fs.IntVar(&opts.stripComponents, "strip-components", -1, "leading path components to drop from the app archive (default: strip its single top-level directory, if any)")
+	opts.vars = varsFlag{}
+	fs.Var(opts.vars, "set", "template variable name=value for {{xmlui.name}} placeholders in the app's config.json and index.html (repeatable)")
+	fs.Var(modeFlag{&dirMode, 0700}, "dir-mode", "permissions for created directories, before umask")
+	fs.Var(modeFlag{&fileMode, 0600}, "file-mode", "permissions for created files, before umask; executables also get x where r is set")
+	fs.Var(headerFlag{requestHeaders}, "header", "extra request header 'Key: Value' for all downloads (repeatable)")
+	fs.Var(sizeFlag{&extractLimits.TotalBytes}, "max-extract-size", "most bytes one archive may unpack to, e.g. 4GB; 0 for no limit")
+	fs.Var(sizeFlag{&extractLimits.FileBytes}, "max-extract-file-size", "most bytes any one file in an archive may unpack to; 0 for no limit")
+	fs.IntVar(&extractLimits.Files, "max-extract-files", extractLimits.Files, "most files and directories one archive may contain; 0 for no limit")
+	fs.IntVar(&extractLimits.Depth, "max-extract-depth", extractLimits.Depth, "most path components of any archive entry; 0 for no limit")
+	fs.Var(retriesFlag{&network.retry}, "retries", "times to retry a download after a network error or a 429/502/503/504 response, with exponential backoff")
+	fs.DurationVar(&network.retry.stall, "stall-timeout", defaultStallTimeout, "retry a download that receives nothing for this long, resuming it where the server allows; 0 waits forever")
+	fs.Var(progressFlag{}, "progress", "download progress: auto, bar, dots or plain (default: bar on a terminal, dots under CI or TERM=dumb, plain otherwise)")
+}
+
+// runUpdate implements `update`: it re-downloads every component into an
+// existing install and rewrites only the files whose hashes changed.
+func runUpdate(args []string) int {
+	var opts installOptions
+	fs := newFlagSet("update")
+	installFlags(fs, &opts)
+	args, err := expandProfiles(args)
+	if err != nil {
+		fmt.Println("Invalid --profile:", err)
+		return exitUsage
+	}
+	if args, err = expandManifest(args); err != nil {
+		fmt.Println("Invalid --manifest:", err)
+		return exitUsage
+	}
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(opts.dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	if _, _, err := installLayout(installDir); err != nil {
+		fmt.Printf("No install found in %s (%v); run xmlui-bundler there first\n", installDir, err)
+		return exitConfig
+	}
+	// Older launchers' installs are brought up to this one's layout first.
+	prev, err := migrateInstall(installDir, opts.dryRun)
+	if err != nil {
+		fmt.Printf("Failed to migrate the install in %s: %v\n", installDir, err)
+		return exitCodeOf(err)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["port"] && prev.Port != 0 {
+		opts.port = prev.Port
+	}
+	if !set["app-source"] && prev.AppSource != "" {
+		opts.appSource, opts.appRef, opts.appProvider = prev.AppSource, prev.AppRef, prev.AppProvider
+	}
+	if !set["all-platforms"] {
+		opts.allPlatforms = prev.AllPlatforms
+	}
+	// An install provisioned for another machine stays for it.
+	if !set["target-os"] && prev.OS != "" {
+		opts.target.OS = prev.OS
+	}
+	if !set["target-arch"] && prev.Arch != "" {
+		opts.target.Arch = prev.Arch
+	}
+	if !set["full-source"] {
+		opts.fullSource = prev.FullSource
+	}
+	if !set["flat"] {
+		opts.flat = prev.Flat
+	}
+	if !set["no-scripts"] {
+		opts.noScripts = prev.NoScripts
+	}
+	// Keep configured clients pointing at this install.
+	if len(opts.configure) == 0 {
+		for _, c := range mcpClientConfigs {
+			if slices.Contains(prev.MCPClients, c.name) {
+				opts.configure = append(opts.configure, c.flag)
+			}
+		}
+	}
+	if !set["keep-line-endings"] {
+		keepLineEndings = prev.KeepLineEndings
+	}
+	if !set["prune"] {
+		opts.prune = prev.Prune
+	}
+	if !set["features"] {
+		opts.features = prev.Features
+	}
+	if !set["channel"] {
+		opts.channel = prev.Channel
+	}
+	if !set["xmlui-npm"] {
+		opts.xmluiNPM = prev.XMLUINPM
+	}
+	if !set["xmlui-runtime"] && prev.XMLUIRuntime != "" {
+		opts.xmluiRuntime = prev.XMLUIRuntime
+	}
+	if !set["variant"] {
+		opts.variant = prev.Variant
+	}
+	// Components installed from local builds keep coming from them.
+	for _, c := range []string{"app", "mcp", "server"} {
+		if !set[c+"-path"] {
+			opts.local.set(c, localPathOf(prev.component(c, "").Source))
+		}
+	}
+	if !set["static-fallback"] {
+		opts.staticFallback = prev.component("server", "").Unavailable != ""
+	}
+	for k, v := range prev.Vars {
+		if _, ok := opts.vars[k]; !ok {
+			opts.vars[k] = v
+		}
+	}
+	opts.previous = prev
+	install(opts)
+	return exitOK
+}
+
+// fetchApp downloads the app at ref and extracts it into tmpApp, returning
+// the source, the app's root directory in tmpApp, and where it came from.
+func fetchApp(opts installOptions, ref, tmpApp string) (*repoSource, string, string, string) {
+	app, err := parseRepoSource(opts.appSource, ref, opts.appProvider)
+	if err != nil {
+		fatal("Failed to resolve app source", classify(exitConfig, err))
+	}
+	appZip, appURL, appSum := downloadApp(opts, app)
+	return app, unpackApp(opts, appZip, tmpApp), appURL, appSum
+}
+
+// downloadApp downloads the app archive, returning it and where it came
+// from.
+func downloadApp(opts installOptions, app *repoSource) ([]byte, string, string) {
+	appZip, appURL, appSum, err := opts.fetch("app", platform{}, app.URL, "XMLUI invoice app")
+	if err != nil {
+		fatal("Failed to download app", classify(exitNetwork, err))
+	}
+	return appZip, appURL, appSum
+}
+
+// unpackApp extracts the app archive into tmpApp and returns the app's root
+// directory in it.
+func unpackApp(opts installOptions, appZip []byte, tmpApp string) string {
+	status.setState("extracting")
+	fsys.MkdirAll(tmpApp, dirMode)
+	if err := extractArchive(appZip, tmpApp, max(opts.stripComponents, 0)); err != nil {
+		fatal("Failed to extract app", classify(exitArchive, err))
+	}
+
+	// With an explicit --strip-components the extraction dir is the root.
+	appRoot := tmpApp
+	if opts.stripComponents < 0 {
+		var err error
+		if appRoot, err = archiveRoot(tmpApp); err != nil {
+			fatal("Failed to organize app directory", fmt.Errorf("%w; --strip-components 0 installs the archive as it is", err))
+		}
+	}
+	return appRoot
+}
+
+// stageServer downloads the test server (every platform's with
+// --all-platforms) and unpacks it under stage. On failure it also returns
+// what failed, for the message.
+func stageServer(opts installOptions, stage string, host platform, serverBinaries []string) (tmpServer, serverURL string, serverDownloads []receiptDownload, what string, err error) {
+	if opts.allPlatforms {
+		tmpServer, serverURL, err = stageAllPlatforms(stage, "server", func(p platform) ([]byte, string, error) {
+			url, err := assetURL("server", p)
+			if err != nil {
+				return nil, "", err
+			}
+			data, url, sum, err := opts.fetch("server", p, url, fmt.Sprintf("test server (%s)", p))
+			if err == nil {
+				serverDownloads = append(serverDownloads, newDownload(p, url, sum))
+			}
+			return data, url, err
+		}, serverBinaries, nil)
+		if err != nil {
+			return "", "", nil, "Failed to download server", classify(exitNetwork, err)
+		}
+		return tmpServer, serverURL, serverDownloads, "", nil
+	}
+	serverArchive, serverURL, serverDownloads, err := fetchServer(opts, host)
+	if err != nil {
+		return "", "", nil, "Failed to download server", err
+	}
+	if tmpServer, err = unpackServer(serverArchive, stage, host); err != nil {
+		return "", "", nil, "Failed to extract server", err
+	}
+	return tmpServer, serverURL, serverDownloads, "", nil
+}
+
+// fetchServer downloads host's test server archive.
+func fetchServer(opts installOptions, host platform) ([]byte, string, []receiptDownload, error) {
+	serverURL, err := assetURL("server", host)
+	if err != nil && opts.local.server == "" {
+		return nil, "", nil, classify(exitNetwork, err)
+	}
+	serverArchive, serverURL, serverSum, err := opts.fetch("server", host, serverURL, "test server")
+	if err != nil {
+		return nil, "", nil, classify(exitNetwork, err)
+	}
+	return serverArchive, serverURL, []receiptDownload{newDownload(host, serverURL, serverSum)}, nil
+}
+
+// unpackServer unpacks host's test server archive into stage's server dir,
+// which it returns.
+func unpackServer(serverArchive []byte, stage string, host platform) (string, error) {
+	status.setState("extracting")
+	tmpServer := filepath.Join(stage, "server")
+	fsys.MkdirAll(tmpServer, dirMode)
+	if err := unpackAsset(serverArchive, tmpServer, releaseAssets["server"].Name+host.exe()); err != nil {
+		return "", classify(exitArchive, err)
+	}
+	collectLicenses("server", releaseAssets["server"].Name, tmpServer)
+	return tmpServer, nil
+}
+
+// previousFiles returns the file hashes the previous receipt recorded for a
+// component, or nil on a fresh install.
+func (opts installOptions) previousFiles(name string) map[string]string {
+	if opts.previous == nil {
+		return nil
+	}
+	for _, c := range opts.previous.Components {
+		if c.Name == name {
+			return c.Files
+		}
+	}
+	return nil
+}
+
+// newReceipt returns the receipt of an install as opts asks for it, before
+// anything is installed.
+func (opts installOptions) newReceipt() *receipt {
+	rcpt := newReceipt()
+	rcpt.OS, rcpt.Arch = opts.target.OS, opts.target.Arch
+	rcpt.Port = opts.port
+	rcpt.AllPlatforms = opts.allPlatforms
+	rcpt.FullSource = opts.fullSource
+	rcpt.Flat = opts.flat
+	rcpt.NoScripts = opts.noScripts
+	rcpt.KeepLineEndings = keepLineEndings
+	rcpt.Prune = opts.prune
+	rcpt.Features = opts.features
+	rcpt.XMLUINPM = opts.xmluiNPM
+	if opts.xmluiRuntime != runtimeAuto {
+		rcpt.XMLUIRuntime = opts.xmluiRuntime
+	}
+	rcpt.Variant = opts.variant
+	rcpt.Channel = opts.channel
+	return rcpt
+}
+
+func install(opts installOptions) {
+	installDir, err := resolveInstallDir(opts.dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		exit(exitUsage)
+	}
+	if err := opts.local.check(opts); err != nil {
+		fmt.Println(err)
+		exit(exitUsage)
+	}
+	if err := opts.checkTarget(); err != nil {
+		fmt.Println(err)
+		exit(exitUsage)
+	}
+	if opts.dryRun {
+		if err := printPlan(opts, installDir); err != nil {
+			fmt.Println("Failed to plan the install:", err)
+			exit(exitCodeOf(err))
+		}
+		exit(exitOK)
+	}
+	if opts.ephemeral {
+		// Leave nothing behind in the state dir either: no receipt, events,
+		// index entry, audit log, download history or seed snapshots.
+		audit.disable()
+	}
+	installDir = installDirGuard(opts, installDir)
+	if err := checkWritable(installDir); err != nil {
+		fmt.Println("Cannot install here:", err)
+		exit(exitFilesystem)
+	}
+	if err := fsys.MkdirAll(installDir, dirMode); err != nil {
+		fmt.Println("Failed to create install directory:", err)
+		exit(exitFilesystem)
+	}
+	installFS = probeFilesystem(installDir)
+	if opts.sandbox {
+		installFS = fsCapabilities{}
+	}
+	if d := installFS.describe(); d != "" {
+		fmt.Printf("%s The filesystem of %s %s\n", labelWarning, installDir, d)
+	}
+	if opts.locked {
+		if err := opts.applyLock(installDir); err != nil {
+			fmt.Println(err)
+			exit(exitConfig)
+		}
+	}
+	if opts.checksumFile != "" {
+		l, err := loadChecksumFile(opts.checksumFile)
+		if err != nil {
+			fmt.Println("Invalid --checksum-file:", err)
+			exit(exitConfig)
+		}
+		opts.checksums = l
+	}
+	rcpt := opts.newReceipt()
+	if err := opts.resolveOrgNotes(); err != nil {
+		fmt.Println("Invalid organization notes:", err)
+		exit(exitConfig)
+	}
+	if !opts.org.empty() {
+		org := opts.org
+		rcpt.Organization = &org
+	}
+	compat, compatFrom, err := loadCompatMatrix(opts.compatMatrix)
+	if err != nil {
+		fmt.Printf("Invalid compatibility matrix %s: %v\n", compatFrom, err)
+		exit(exitConfig)
+	}
+
+	resumeCmd, command := "xmlui-bundler", "install"
+	if opts.previous != nil {
+		resumeCmd, command = "xmlui-bundler update", "update"
+	}
+	beginTelemetry(command, opts.telemetry)
+	if !opts.ephemeral {
+		if events, err = openEventLog(installDir, command); err != nil {
+			warn("Could not start the events file: %v", err)
+		}
+		atExit(func() {
+			state := status.state()
+			if state == "running" {
+				state = "interrupted"
+			}
+			events.close(state)
+		})
+		atExit(func() { metrics.save(command) })
+	}
+	handleSignals(fmt.Sprintf("Nothing was left half-written. To resume, run `%s` again in %s", resumeCmd, installDir))
+	if err := configureTLS(opts.caCert, opts.insecure); err != nil {
+		fatal("Failed to load --ca-cert", classify(exitConfig, err))
+	}
+	if opts.statusAddr != "" {
+		url, err := serveStatus(opts.statusAddr)
+		if err != nil {
+			fatal("Failed to start status endpoint", err)
+		}
+		fmt.Printf("Status endpoint: %s\n", url)
+	}
+	stagingParent := installDir
+	if opts.ephemeral {
+		stagingParent = os.TempDir()
+	}
+	sweepStaleStaging(stagingParent, time.Duration(opts.staleStagingDays)*24*time.Hour)
+	stage, err := stageDir(stagingParent)
+	if err != nil {
+		fatal("Failed to create staging directory", err)
+	}
+	if journal, err = beginJournal(installDir, filepath.Join(stage, "backup")); err != nil {
+		fatal("Failed to start install journal", err)
+	}
+	atExit(journal.rollback)
+
+	host := opts.target
+	if opts.crossProvisioning() {
+		fmt.Printf("Provisioning for %s/%s: the install is for that machine, not this one\n", host.OS, host.Arch)
+	}
+	// mcpUnavailable, if set, is why the MCP tools are skipped;
+	// serverMissing, why the test server is.
+	var mcpUnavailable, serverMissing string
+	if _, err := assetURL("mcp", host); err != nil && !opts.allPlatforms && opts.local.mcp == "" {
+		mcpUnavailable = fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+	}
+	if opts.lock == nil {
+		if err := applyChannel(opts.channel); err != nil {
+			fatal("Failed to resolve --channel "+opts.channel, classify(exitConfig, err))
+		}
+		if err := applyArtifactStores(); err != nil {
+			fatal("Failed to load the artifact stores", classify(exitConfig, err))
+		}
+		platforms := []platform{host}
+		if opts.allPlatforms {
+			platforms = supportedPlatforms
+		}
+		missing := checkReleaseAssets(platforms)
+		for _, c := range []string{"mcp", "server"} {
+			if opts.local.path(c) != "" {
+				delete(missing, c)
+			}
+		}
+		if err := missing["mcp"]; err != nil && !opts.allPlatforms {
+			// The app and knowledge base are still worth having; the
+			// tools can come with a later update.
+			mcpUnavailable = fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+			warn("The MCP release has no build for this machine; installing everything else (%v)", err)
+			delete(missing, "mcp")
+		}
+		if err := missing["server"]; err != nil && !opts.allPlatforms {
+			serverMissing = offerStaticServer(opts, host, "Release assets are missing", classify(exitNetwork, err))
+			delete(missing, "server")
+		}
+		for _, c := range []string{"mcp", "server"} {
+			if err := missing[c]; err != nil {
+				fatal("Release assets are missing", classify(exitNetwork, err))
+			}
+		}
+	}
+
+	r := &installRun{opts: opts, rcpt: rcpt, host: host, installDir: installDir, stage: stage, compat: compat, compatFrom: compatFrom,
+		mcpUnavailable: mcpUnavailable, serverUnavailable: serverMissing}
+	p, err := r.pipeline()
+	if err != nil {
+		fatal("Failed to plan the install", err)
+	}
+	if err := p.Run(downloadCtx); err != nil {
+		checkInterrupted()
+		fatal("Install failed", err)
+	}
+	appDir, mcpDir, mcpBinDir, serverDir := r.appDir, r.mcpDir, r.mcpBinDir, r.serverBinDir
+
+	// What the launcher generates rather than downloads; the receipt doesn't
+	// record it.
+	envDir, guide := envFileDir(installDir, rcpt), guidePath(installDir, rcpt)
+	generated := []string{filepath.Join(envDir, envFileSh), filepath.Join(envDir, envFilePs1), guide, filepath.Join(filepath.Dir(guide), orgReadmeFile)}
+
+	// The final bundle should contain only these files/directories:
+	// - xmlui-invoice/  (the invoice app)
+	// - mcp/  (with docs/ and src/ inside it)
+	// - XMLUI_GETTING_STARTED_README.md
+	// The receipt and other bookkeeping live in the state dir.
+
+	// Write a cleanup script that will remove files not in the include list
+	if opts.ephemeral {
+		// Nothing to clean up: staging is outside the install dir and the
+		// executable removes itself below.
+	} else if opts.flat {
+		// The project's own archives are not ours to delete.
+	} else if opts.noScripts {
+		fmt.Println("Note: Run `xmlui-bundler clean --launcher` to remove the bundler executable and temporary files")
+	} else {
+		target := host.osPlatform()
+		name, script, run := target.cleanupScript(filepath.Base(os.Args[0]))
+		path := filepath.Join(installDir, name)
+		journal.preserve(path)
+		fsys.WriteFile(path, []byte(script), execMode())
+		generated = append(generated, path)
+		if target.execBits() {
+			chmodExec(path)
+		}
+		unblockFile(path)
+		fmt.Printf("Note: Run %s to remove the bundler executable and temporary files\n", run)
+	}
+
+	if host.OS == "windows" {
+		installWindowsScripts(opts.windows, installDir, mcpDir, mcpBinDir, appDir, serverDir, opts.port, !opts.ephemeral && !opts.flat && !opts.noScripts)
+	}
+
+	if opts.addToPath {
+		dirs := []string{mcpBinDir}
+		if opts.addLauncherToPath {
+			if exe, err := os.Executable(); err == nil {
+				dirs = append(dirs, filepath.Dir(exe))
+			}
+		}
+		where, err := addDirsToUserPath(dirs)
+		if err != nil {
+			warn("Could not update PATH: %v", err)
+		} else {
+			fmt.Printf("%s Added %s to PATH via %s\n", glyphOK, strings.Join(dirs, ", "), where)
+			fmt.Println("  Open a new terminal for the change to take effect")
+		}
+	}
+
+	if installFS.chmod && (isSystemLocation(installDir) || os.Geteuid() == 0) {
+		if err := makeInstalledReadable(installDir, rcpt, append(journal.createdPaths(), generated...)); err != nil {
+			warn("Could not make the install in %s readable for all users: %v", installDir, err)
+		}
+	}
+
+	checkInterrupted()
+	journal.commit()
+	status.finish()
+	runCleanups()
+	fmt.Println(glyphOK, "Organized layout complete")
+	fmt.Printf("\nInstall location: %s\n", installDir)
+	if r.summary != "" {
+		fmt.Println(r.summary)
+	}
+	reportSandboxed(installDir)
+
+	code := keepGoing.summary()
+	endTelemetry(code)
+
+	if opts.ephemeral {
+		if exe, ok := disposableExecutable(); !ok {
+			fmt.Printf("Note: Left %s in place, as it isn't a temporary copy\n", exe)
+		} else if err := selfDelete(); err != nil {
+			warn("Could not remove %s: %v", exe, err)
+		}
+	}
+	if code != exitOK {
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,1116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jonudell/xmlui-bundler/pipeline"
+)
+
+// planStage stands for the staging directory an install would create.
+const planStage = "<staging>"
+
+// installRun is one install or update as its pipeline runs it: what the
+// steps share, worked out when the pipeline is built or handed on from one
+// step to the next.
+type installRun struct {
+	opts       installOptions
+	rcpt       *receipt
+	host       platform
+	installDir string
+	stage      string
+	compat     *compatMatrix
+	compatFrom string
+
+	app                     *repoSource
+	appDir                  string
+	mcpDir, docsDir, srcDir string
+	mcpBinDir, serverBinDir string
+	// mcpUnavailable, if set, is why the MCP tools are skipped;
+	// serverUnavailable, why the test server is (see offerStaticServer).
+	mcpUnavailable, serverUnavailable string
+
+	appRef, appRoot, appURL, appSum string
+	appArchive                      []byte
+	xmluiURL, xmluiSum, sourceRoot  string
+	xmluiArchive                    []byte
+	layout                          componentLayout
+	mcpURL, mcpSum, mcpStaged       string
+	mcpArchive                      []byte
+	mcpDownloads                    []receiptDownload
+	// mcpExec is what the chmod mcp step makes executable.
+	mcpExec                 []string
+	serverURL, serverStaged string
+	serverArchive           []byte
+	serverDownloads         []receiptDownload
+	summary                 string
+}
+
+var (
+	mcpBinaries    = []string{"xmlui-mcp", "xmlui-mcp-client"}
+	serverBinaries = []string{"xmlui-test-server"}
+)
+
+// pipeline returns the steps of the install, in order. Each runs a part of
+// the install the launcher has always had, inside attempt, and undoes what
+// it wrote through the journal. Where things go is decided here, so the
+// same pipeline describes a --dry-run.
+func (r *installRun) pipeline() (*pipeline.Pipeline, error) {
+	opts, host := r.opts, r.host
+	stage := func(name string) string { return filepath.Join(r.stage, name) }
+	p := pipeline.New()
+
+	r.appDir = r.installDir
+	if opts.flat {
+		p.Steps = append(p.Steps, &pipeline.Step{Label: "app", Description: "skip the sample app (--flat)", Work: r.work(func() {
+			status.step(1, "Skipping the sample app (--flat)")
+			r.rcpt.AppDir = "."
+		}, nil)})
+	} else {
+		app, err := parseRepoSource(opts.appSource, opts.appRef, opts.appProvider)
+		if err != nil {
+			return nil, classify(exitConfig, fmt.Errorf("resolving the app source: %w", err))
+		}
+		r.app, r.appDir = app, filepath.Join(r.installDir, app.Name)
+		url := app.URL
+		if opts.local.app != "" {
+			url = localURL(opts.local.app)
+		}
+		strip := 1
+		if opts.stripComponents >= 0 {
+			strip = opts.stripComponents
+		}
+		p.Steps = append(p.Steps,
+			&pipeline.Download{Label: "app", URL: url, Work: r.work(r.downloadApp, nil)},
+			&pipeline.Extract{Label: "app", Archive: url, Dest: stage("app"), Strip: strip, Work: r.work(r.extractApp, r.removeStaged("app", "app-variant"))},
+			&pipeline.Layout{Label: "app", Moves: []pipeline.Move{{From: stage("app"), To: r.appDir}}, Work: r.journaled(r.layoutApp)},
+		)
+	}
+
+	r.mcpDir = filepath.Join(r.installDir, r.rcpt.toolsDir())
+	r.docsDir, r.srcDir = filepath.Join(r.mcpDir, "docs"), filepath.Join(r.mcpDir, "src")
+	if err := r.binDestinations(); err != nil {
+		return nil, err
+	}
+	p.Steps = append(p.Steps, &pipeline.Layout{Label: "tools", Dirs: []string{r.mcpDir, r.docsDir, r.srcDir}, Work: r.journaled(func() {
+		for _, dir := range []string{r.mcpDir, r.docsDir, r.srcDir} {
+			journal.mkdirAll(dir)
+		}
+	})})
+
+	p.Steps = append(p.Steps,
+		&pipeline.Download{Label: "components", URL: xmluiComponentsURL, Work: r.work(r.downloadComponents, nil)},
+		&pipeline.Extract{Label: "components", Archive: xmluiComponentsURL, Dest: stage("xmlui-source"), Strip: 1, Work: r.work(r.extractComponents, r.removeStaged("xmlui-source"))},
+		&pipeline.Layout{Label: "components", Moves: []pipeline.Move{
+			{From: stage("xmlui-source/" + defaultLayout.Docs), To: filepath.Join(r.docsDir, "pages", "components")},
+			{From: stage("xmlui-source/" + defaultLayout.Src), To: filepath.Join(r.srcDir, "components")},
+		}, Work: r.journaled(r.layoutComponents)},
+		&pipeline.Step{Label: "features", Description: r.describeFeatures(), Work: r.journaled(r.installFeatures)},
+	)
+
+	if r.mcpUnavailable != "" && !opts.allPlatforms {
+		p.Steps = append(p.Steps, &pipeline.Step{Label: "mcp", Description: "skip the MCP tools (" + r.mcpUnavailable + ")", Work: r.journaled(func() {
+			r.downloadMCP()
+			r.layoutMCP()
+		})})
+	} else if opts.allPlatforms {
+		p.Steps = append(p.Steps,
+			&pipeline.Download{Label: "mcp", URL: allPlatformsURLs("mcp"), Dest: stage("mcp"), Work: r.work(r.downloadMCP, r.removeStaged("mcp"))},
+			&pipeline.Layout{Label: "mcp", Moves: []pipeline.Move{{From: stage("mcp"), To: r.mcpDir}}, Work: r.journaled(r.layoutMCP)},
+		)
+	} else {
+		url, _ := assetURL("mcp", host)
+		if opts.local.mcp != "" {
+			url = localURL(opts.local.mcp)
+		}
+		var moves []pipeline.Move
+		var exec []string
+		for _, name := range r.mcpFiles() {
+			dst := r.mcpDest(name)
+			moves = append(moves, pipeline.Move{From: filepath.Join(stage("mcp"), name), To: dst})
+			if isExecutableName(name) {
+				exec = append(exec, dst)
+			}
+		}
+		p.Steps = append(p.Steps,
+			&pipeline.Download{Label: "mcp", URL: url, Work: r.work(r.downloadMCP, nil)},
+			&pipeline.Extract{Label: "mcp", Archive: url, Dest: stage("mcp"), Work: r.work(r.extractMCP, r.removeStaged("mcp"))},
+			&pipeline.Layout{Label: "mcp", Moves: moves, Work: r.journaled(r.layoutMCP)},
+		)
+		if host.osPlatform().execBits() {
+			p.Steps = append(p.Steps, &pipeline.Chmod{Label: "mcp", Paths: exec, Mode: execMode(), Work: r.chmod("mcp", func() []string { return r.mcpExec })})
+		}
+	}
+
+	if r.serverUnavailable != "" && !opts.allPlatforms {
+		p.Steps = append(p.Steps, &pipeline.Step{Label: "server", Description: "skip the test server (" + r.serverUnavailable + ")", Work: r.journaled(func() {
+			r.downloadServer()
+			r.layoutServer()
+		})})
+	} else if opts.allPlatforms {
+		p.Steps = append(p.Steps,
+			&pipeline.Download{Label: "server", URL: allPlatformsURLs("server"), Dest: stage("server"), Work: r.work(r.downloadServer, r.removeStaged("server"))},
+			&pipeline.Layout{Label: "server", Moves: []pipeline.Move{{From: stage("server"), To: r.serverBinDir}}, Work: r.journaled(r.layoutServer)},
+		)
+	} else {
+		url, _ := assetURL("server", host)
+		if opts.local.server != "" {
+			url = localURL(opts.local.server)
+		}
+		p.Steps = append(p.Steps,
+			&pipeline.Download{Label: "server", URL: url, Work: r.work(r.downloadServer, nil)},
+			&pipeline.Extract{Label: "server", Archive: url, Dest: stage("server"), Work: r.work(r.extractServer, r.removeStaged("server"))},
+			&pipeline.Layout{Label: "server", Moves: []pipeline.Move{{From: stage("server"), To: r.serverBinDir}}, Work: r.journaled(r.layoutServer)},
+		)
+	}
+
+	if host.osPlatform().execBits() && !opts.flat {
+		start := func() []string {
+			if r.appMissing() {
+				return nil
+			}
+			return []string{filepath.Join(r.appDir, "start.sh")}
+		}
+		p.Steps = append(p.Steps, &pipeline.Chmod{Label: "app", Paths: start(), Mode: execMode(), Work: r.chmod("", start)})
+	}
+	p.Steps = append(p.Steps, &pipeline.Step{Label: "verify", Description: r.describeVerify(), Work: r.work(r.verify, nil)})
+
+	for _, c := range mcpClientConfigs {
+		if !slices.Contains(opts.configure, c.flag) {
+			continue
+		}
+		path, err := c.path(r.installDir)
+		if err != nil {
+			return nil, fmt.Errorf("--configure-%s: %w", c.flag, err)
+		}
+		p.Steps = append(p.Steps, &pipeline.Configure{Label: c.name, Path: path, Work: r.configure(c, path)})
+	}
+
+	p.Steps = append(p.Steps, &pipeline.Step{Label: "receipt", Description: "record the install in its receipt in the state dir", Work: r.work(r.writeReceipt, nil)})
+	// The helper scripts' --no-scripts covers these too; `env` prints them.
+	if !opts.noScripts {
+		p.Steps = append(p.Steps, &pipeline.Generate{Label: "environment", Path: filepath.Join(envFileDir(r.installDir, r.rcpt), envFileSh), Work: r.journaled(func() {
+			if err := writeEnvFiles(r.installDir, r.rcpt); err != nil {
+				warn("Could not write %s and %s: %v", envFileSh, envFilePs1, err)
+			}
+		})})
+	}
+	p.Steps = append(p.Steps,
+		&pipeline.Generate{Label: "licenses", Path: filepath.Join(r.installDir, licensesDirName), Work: r.journaled(func() {
+			if err := writeLicenses(r.installDir, r.rcpt); err != nil {
+				warn("Could not collect the license files in %s/: %v", licensesDirName, err)
+			}
+		})},
+		&pipeline.Generate{Label: "getting started", Path: guidePath(r.installDir, r.rcpt), Work: r.journaled(r.writeGettingStarted)},
+	)
+	return p, nil
+}
+
+// work runs do as a step, undoing it with undo.
+func (r *installRun) work(do func(), undo func() error) pipeline.Work {
+	return pipeline.Work{
+		Do: func(context.Context) error {
+			do()
+			return nil
+		},
+		Undo: undo,
+	}
+}
+
+// journaled runs do as a step whose changes to the install dir, which go
+// through the journal, are its rollback.
+func (r *installRun) journaled(do func()) pipeline.Work {
+	var mark journalMark
+	return pipeline.Work{
+		Do: func(context.Context) error {
+			mark = journal.mark()
+			do()
+			return nil
+		},
+		Undo: func() error {
+			journal.rollbackTo(mark)
+			return nil
+		},
+	}
+}
+
+// removeStaged undoes a step that unpacks into the staging dirs names.
+func (r *installRun) removeStaged(names ...string) func() error {
+	return func() error {
+		var errs []error
+		for _, name := range names {
+			errs = append(errs, fsys.RemoveAll(filepath.Join(r.stage, name)))
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// chmod makes the paths makes returns executable, as part of component's
+// attempt when it names one, and puts their modes back on rollback.
+func (r *installRun) chmod(component string, paths func() []string) pipeline.Work {
+	modes := map[string]os.FileMode{}
+	do := func() {
+		for _, p := range paths() {
+			if info, err := os.Stat(p); err == nil {
+				modes[p] = info.Mode().Perm()
+			}
+			chmodExec(p)
+		}
+	}
+	return pipeline.Work{
+		Do: func(context.Context) error {
+			if component == "" {
+				do()
+			} else {
+				r.attempt(component, do)
+			}
+			return nil
+		},
+		Undo: func() error {
+			var errs []error
+			for p, mode := range modes {
+				errs = append(errs, fsys.Chmod(p, mode))
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// attempt runs f through attempt as part of installing component, unless
+// an earlier step of the component has failed. A failure makes the rest of
+// the install do without the component.
+func (r *installRun) attempt(component string, f func()) {
+	if keepGoing.failedComponent(component) || attempt(component, f) {
+		return
+	}
+	switch component {
+	case "app":
+		appDir := filepath.Join(r.installDir, filepath.FromSlash(r.rcpt.appDir()))
+		if r.opts.previous != nil {
+			// The server and bundles can still go in the app being updated.
+			r.rcpt.AppDir = r.opts.previous.AppDir
+			appDir = filepath.Join(r.installDir, filepath.FromSlash(r.opts.previous.appDir()))
+		}
+		if appDir != r.appDir {
+			r.appDir = appDir
+			if err := r.binDestinations(); err != nil {
+				fatal("Failed to resolve the binaries' destinations", err)
+			}
+		}
+	case "mcp":
+		r.mcpUnavailable = "its install failed"
+		r.rcpt.component("mcp", "").Unavailable = r.mcpUnavailable
+	case "server":
+		r.serverUnavailable = "its install failed"
+		r.rcpt.component("server", "").Unavailable = r.serverUnavailable
+	}
+}
+
+// appMissing reports whether this is a fresh install whose app failed, which
+// leaves nowhere to put the server and feature bundles.
+func (r *installRun) appMissing() bool {
+	return keepGoing.failedComponent("app") && r.opts.previous == nil
+}
+
+func (r *installRun) binDestinations() error {
+	var err error
+	r.mcpBinDir, r.serverBinDir, err = binDestinations(r.rcpt, r.installDir, r.mcpDir, r.appDir, r.host, r.opts.allPlatforms)
+	if err != nil {
+		return classify(exitConfig, fmt.Errorf("resolving the binaries' destinations: %w", err))
+	}
+	return nil
+}
+
+func (r *installRun) downloadApp() {
+	status.step(1, "Downloading XMLUI invoice app...")
+	r.attempt("app", func() {
+		status.begin("app")
+		r.appRef = r.opts.appRef
+		r.appArchive, r.appURL, r.appSum = downloadApp(r.opts, r.app)
+	})
+}
+
+func (r *installRun) extractApp() {
+	r.attempt("app", func() {
+		r.appRoot = unpackApp(r.opts, r.appArchive, filepath.Join(r.stage, "app"))
+		r.appArchive = nil
+		r.selectVariant()
+	})
+}
+
+// selectVariant applies --variant to the unpacked app. An app without a
+// variants directory keeps them on branches; the branch is fetched instead
+// unless --app-ref names another one.
+func (r *installRun) selectVariant() {
+	opts := r.opts
+	err := applyVariant(r.appRoot, opts.variant)
+	if err == errNoVariants && opts.variant != "" {
+		branch := variantBranch + opts.variant
+		switch {
+		case r.appRef == branch:
+			err = nil
+		case r.app.Provider != "archive" && opts.lock == nil && opts.local.app == "" && (r.appRef == branchName || strings.HasPrefix(r.appRef, variantBranch)):
+			fmt.Printf("  The app has no %s directory; trying branch %s\n", variantsDir, branch)
+			r.appRef = branch
+			r.app, r.appRoot, r.appURL, r.appSum = fetchApp(opts, r.appRef, filepath.Join(r.stage, "app-variant"))
+			err = nil
+		default:
+			err = fmt.Errorf("%w and --app-ref %s is not %s", err, r.appRef, branch)
+		}
+	} else if err == errNoVariants {
+		err = nil
+	}
+	if err != nil {
+		fatal("Failed to select the app variant", classify(exitConfig, err))
+	}
+}
+
+// layoutApp puts the unpacked app in place (or updates it), applying --set
+// values, and saves its seed databases.
+func (r *installRun) layoutApp() {
+	r.attempt("app", func() {
+		opts, rcpt, installDir, app, appRoot := r.opts, r.rcpt, r.installDir, r.app, r.appRoot
+		rcpt.AppSource, rcpt.AppRef, rcpt.AppProvider = opts.appSource, r.appRef, opts.appProvider
+		collectLicenses("app", "app ("+app.Name+")", appRoot)
+
+		// The npm package provides these instead; see installXMLUINPM.
+		if opts.xmluiNPM != "" {
+			if err := fsys.RemoveAll(filepath.Join(appRoot, filepath.FromSlash(xmluiNPMDir))); err != nil {
+				fatal("Failed to organize app directory", err)
+			}
+		}
+
+		// Filled in while staged, so the hashes are of the files as installed
+		// and an update's syncTree writes changed values like any change.
+		vars := map[string]string{"port": fmt.Sprint(opts.port), "appName": app.Name}
+		for k, v := range opts.vars {
+			vars[k] = v
+		}
+		if err := applyTemplateVars(appRoot, vars); err != nil {
+			fatal("Failed to apply template variables", classify(exitConfig, err))
+		}
+
+		var appDir string
+		var appFiles map[string]string
+		var err error
+		if opts.previous != nil {
+			appDir = filepath.Join(installDir, app.Name)
+			var st syncStats
+			appFiles, st, err = syncTree(appRoot, appDir, installDir, opts.previousFiles("app"), nil)
+			if err != nil {
+				fatal("Failed to update app", err)
+			}
+			fmt.Printf("  app: %s\n", st)
+		} else {
+			appDir, err = moveIntoPlace(appRoot, app, installDir)
+			if err != nil {
+				fatal("Failed to organize app directory", err)
+			}
+			if appFiles, err = hashTree(appDir, installDir); err != nil {
+				fatal("Failed to hash app files", err)
+			}
+		}
+		r.appDir = appDir
+		appComponent := rcpt.component("app", r.appURL)
+		appComponent.Files, appComponent.Downloads = appFiles, []receiptDownload{newDownload(platform{}, r.appURL, r.appSum)}
+		rcpt.AppDir = receiptKey(installDir, appDir)
+
+		if len(opts.vars) > 0 {
+			rcpt.Vars = opts.vars
+		}
+		if opts.variant != "" {
+			c, err := readVariantConfig(appDir)
+			if err != nil {
+				fatal("Failed to read the app variant", err)
+			}
+			fmt.Printf("  Variant %s", opts.variant)
+			if c.Description != "" {
+				fmt.Printf(": %s", c.Description)
+			}
+			fmt.Println()
+		}
+
+		if opts.ephemeral {
+			return
+		}
+		seedFiles, err := saveSeedData(installDir, appFiles)
+		if err != nil {
+			fatal("Failed to save seed database", err)
+		}
+		if len(seedFiles) > 0 {
+			rcpt.component("seed-data", r.appURL).Files = seedFiles
+			fmt.Printf("  Saved %d seed database(s) for reset-data\n", len(seedFiles))
+		}
+	})
+}
+
+func (r *installRun) downloadComponents() {
+	status.step(2, "Downloading XMLUI components...")
+	status.setState("done")
+	status.begin("components")
+	r.attempt("components", func() {
+		var err error
+		r.xmluiArchive, r.xmluiURL, r.xmluiSum, err = r.opts.fetch("components", platform{}, xmluiComponentsURL, "XMLUI repo")
+		if err != nil {
+			fatal("Failed to download XMLUI source", classify(exitNetwork, err))
+		}
+	})
+}
+
+// extractComponents unpacks the XMLUI source. Only the trees used are
+// written out, where the snapshot has them; the rest of the repo is many
+// times their size.
+func (r *installRun) extractComponents() {
+	r.attempt("components", func() {
+		cfg, _, err := readConfig()
+		if err != nil {
+			fatal("Failed to read the launcher config", classify(exitConfig, err))
+		}
+		tmpDir := filepath.Join(r.stage, "xmlui-source")
+		fsys.MkdirAll(tmpDir, dirMode)
+		status.setState("extracting")
+		if dirs := snapshotSubtrees(r.xmluiArchive, cfg.Layout, r.opts.features); dirs != nil {
+			err = unzipSubtreesTo(r.xmluiArchive, tmpDir, dirs)
+		} else {
+			err = unzipTo(r.xmluiArchive, tmpDir, 0)
+		}
+		if err != nil {
+			fatal("Failed to extract XMLUI source", classify(exitArchive, err))
+		}
+		r.xmluiArchive = nil
+
+		// Find the root of the extracted XMLUI source
+		if r.sourceRoot, err = archiveRoot(tmpDir); err != nil {
+			fatal("Failed to locate XMLUI source", classify(exitConfig, err))
+		}
+		collectLicenses("xmlui", "XMLUI components", r.sourceRoot)
+		if r.layout, err = resolveLayout(r.sourceRoot, cfg.Layout); err != nil {
+			fatal("Failed to locate XMLUI components", classify(exitConfig, err))
+		}
+	})
+}
+
+// layoutComponents places the components' docs and source under the tools
+// dir, pruned unless --full-source.
+func (r *installRun) layoutComponents() {
+	r.attempt("components", func() {
+		opts, installDir, sourceRoot := r.opts, r.installDir, r.sourceRoot
+		trees := []struct{ from, to string }{
+			{filepath.Join(sourceRoot, filepath.FromSlash(r.layout.Docs)), filepath.Join(r.docsDir, "pages", "components")},
+			{filepath.Join(sourceRoot, filepath.FromSlash(r.layout.Src)), filepath.Join(r.srcDir, "components")},
+		}
+		extra, err := featureTrees(opts.features, sourceRoot, r.docsDir, r.srcDir)
+		if err != nil {
+			fatal("Failed to add features", err)
+		}
+		trees = append(trees, extra...)
+		if !opts.fullSource {
+			rules := append(append([]string{}, defaultPruneRules...), opts.prune...)
+			var pruned int
+			var size int64
+			for _, t := range trees {
+				n, b, err := pruneTree(t.from, rules)
+				if err != nil {
+					fatal("Failed to prune XMLUI components", err)
+				}
+				pruned += n
+				size += b
+			}
+			if pruned > 0 {
+				fmt.Printf("  Pruned %d test, story and build files (%s); --full-source keeps them\n", pruned, humanBytes(size))
+			}
+		}
+		var componentFiles map[string]string
+		var total syncStats
+		for _, t := range trees {
+			var files map[string]string
+			if opts.previous != nil {
+				var st syncStats
+				files, st, err = syncTree(t.from, t.to, installDir, opts.previousFiles("components"), nil)
+				total.add(st)
+			} else {
+				fsys.MkdirAll(t.to, dirMode)
+				if err = copyFiles(t.from, t.to); err == nil {
+					files, err = hashTree(t.to, installDir)
+				}
+			}
+			if err != nil {
+				fatal("Failed to place XMLUI components", err)
+			}
+			componentFiles = mergeHashes(componentFiles, files)
+		}
+		if opts.previous != nil {
+			// Trees of features no longer wanted are gone from the list, so
+			// syncTree never saw their files.
+			for key := range opts.previousFiles("components") {
+				path := filepath.Join(installDir, filepath.FromSlash(key))
+				if _, ok := componentFiles[key]; ok {
+					continue
+				}
+				if _, err := os.Lstat(path); err != nil {
+					continue
+				}
+				if err := journal.preserve(path); err != nil {
+					fatal("Failed to remove dropped XMLUI components", err)
+				}
+				fsys.Remove(filepath.Dir(path))
+				total.Removed++
+			}
+			fmt.Printf("  components: %s\n", total)
+		}
+
+		fmt.Println(glyphOK, "Extracted components")
+		c := r.rcpt.component("components", r.xmluiURL)
+		c.Files, c.Downloads = componentFiles, []receiptDownload{newDownload(platform{}, r.xmluiURL, r.xmluiSum)}
+
+		// Clean up the source directory
+		_ = fsys.RemoveAll(filepath.Join(r.stage, "xmlui-source"))
+	})
+}
+
+// bundleDir is where the feature bundles go. A flat install keeps them with
+// the tools rather than in the project's own files.
+func (r *installRun) bundleDir() string {
+	if r.opts.flat {
+		return r.mcpDir
+	}
+	return r.appDir
+}
+
+func (r *installRun) describeFeatures() string {
+	var what []string
+	for _, f := range r.opts.features {
+		what = append(what, "the "+f+" bundle")
+	}
+	if r.opts.xmluiNPM != "" {
+		what = append(what, "XMLUI from npm ("+r.opts.xmluiNPM+")")
+	}
+	switch r.opts.xmluiRuntime {
+	case runtimeOff:
+	case runtimeAuto:
+		what = append(what, "the XMLUI runtime the app loads")
+	default:
+		what = append(what, "the XMLUI runtime "+r.opts.xmluiRuntime)
+	}
+	if len(what) == 0 {
+		return "nothing to add"
+	}
+	return fmt.Sprintf("fetch %s into %s", strings.Join(what, ", "), r.bundleDir())
+}
+
+func (r *installRun) installFeatures() {
+	opts, rcpt, stage, installDir := r.opts, r.rcpt, r.stage, r.installDir
+	if r.appMissing() {
+		if len(opts.features) > 0 || opts.xmluiNPM != "" || opts.xmluiRuntime != runtimeAuto && opts.xmluiRuntime != runtimeOff {
+			skipComponent("features", "the app failed")
+		}
+		return
+	}
+	r.attempt("features", func() {
+		bundleDir := r.bundleDir()
+		if err := installFeatureBundles(opts, rcpt, stage, installDir, bundleDir); err != nil {
+			fatal("Failed to install feature bundles", err)
+		}
+		if opts.xmluiNPM != "" {
+			if err := installXMLUINPM(opts, rcpt, stage, installDir, bundleDir); err != nil {
+				fatal("Failed to install XMLUI from npm", err)
+			}
+		}
+		appFiles := rcpt.component("app", "").Files
+		if spec, name := runtimePlan(opts, r.appDir, installDir, appFiles); spec == "" {
+			if err := dropXMLUIRuntime(opts, installDir, appFiles); err != nil {
+				fatal("Failed to remove the XMLUI runtime", err)
+			}
+		} else if err := installXMLUIRuntime(opts, rcpt, stage, installDir, bundleDir, spec, name); err != nil {
+			if opts.xmluiRuntime != runtimeAuto {
+				fatal("Failed to install the XMLUI runtime", err)
+			}
+			warn("Could not install the XMLUI runtime %s the app loads (%v); until `xmlui-bundler update` does, the app needs it from the network", name, err)
+		}
+	})
+}
+
+// mcpFiles lists the files of the MCP release installed, binaries first.
+func (r *installRun) mcpFiles() []string {
+	files := []string{"xmlui-mcp", "xmlui-mcp-client", "prepare-binaries.sh", "run-mcp-client.sh"}
+	if r.host.OS == "windows" {
+		files = []string{"xmlui-mcp.exe", "xmlui-mcp-client.exe", "run-mcp-client.bat"}
+	}
+	if r.opts.noScripts {
+		// The launcher's mcp prepare and mcp client do their job.
+		files = files[:2]
+	}
+	return files
+}
+
+// mcpDest is where the MCP release's file name goes: a destination override
+// can put the binaries apart from the scripts.
+func (r *installRun) mcpDest(name string) string {
+	if slices.Index(r.mcpFiles(), name) < 2 {
+		return filepath.Join(r.mcpBinDir, name)
+	}
+	return filepath.Join(r.mcpDir, name)
+}
+
+// allPlatformsURLs lists the release URLs of component for every platform,
+// for an --all-platforms install's description.
+func allPlatformsURLs(component string) string {
+	var urls []string
+	for _, p := range supportedPlatforms {
+		if url, err := assetURL(component, p); err == nil {
+			urls = append(urls, url)
+		}
+	}
+	return strings.Join(urls, ", ")
+}
+
+func (r *installRun) downloadMCP() {
+	status.step(3, "Downloading MCP tools...")
+	status.setState("done")
+	status.begin("mcp")
+	r.attempt("mcp", func() {
+		opts, host := r.opts, r.host
+		if opts.allPlatforms {
+			scripts := map[string]bool{"prepare-binaries.sh": true, "run-mcp-client.sh": true, "run-mcp-client.bat": true}
+			var err error
+			r.mcpStaged, r.mcpURL, err = stageAllPlatforms(r.stage, "mcp", func(p platform) ([]byte, string, error) {
+				url, err := assetURL("mcp", p)
+				if err != nil {
+					return nil, "", err
+				}
+				data, url, sum, err := opts.fetch("mcp", p, url, fmt.Sprintf("MCP tools (%s)", p))
+				if err == nil {
+					r.mcpDownloads = append(r.mcpDownloads, newDownload(p, url, sum))
+				}
+				return data, url, err
+			}, mcpBinaries, func(rel string) bool { return scripts[rel] && !opts.noScripts })
+			if err != nil {
+				fatal("Failed to download MCP tools", classify(exitNetwork, err))
+			}
+			return
+		}
+		if r.mcpUnavailable != "" {
+			return
+		}
+		url, _ := assetURL("mcp", host)
+		var err error
+		r.mcpArchive, r.mcpURL, r.mcpSum, err = opts.fetch("mcp", host, url, "MCP tools")
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+			r.mcpUnavailable = fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+			warn("The MCP release has no build for this machine; installing everything else (%v)", err)
+		} else if err != nil {
+			fatal("Failed to download MCP tools", classify(exitNetwork, err))
+		}
+	})
+}
+
+func (r *installRun) extractMCP() {
+	r.attempt("mcp", func() {
+		if r.mcpUnavailable != "" {
+			return
+		}
+		tmpMCP := filepath.Join(r.stage, "mcp")
+		fsys.MkdirAll(tmpMCP, dirMode)
+		status.setState("extracting")
+		if err := unpackAsset(r.mcpArchive, tmpMCP, releaseAssets["mcp"].Name+r.host.exe()); err != nil {
+			fatal("Failed to extract MCP tools", classify(exitArchive, err))
+		}
+		r.mcpArchive = nil
+		collectLicenses("mcp", releaseAssets["mcp"].Name, tmpMCP)
+		r.mcpStaged = tmpMCP
+	})
+}
+
+// layoutMCP puts the MCP tools in place, records them, and moves docs and
+// src left at the root level under the tools dir.
+func (r *installRun) layoutMCP() {
+	r.attempt("mcp", func() {
+		opts, rcpt, installDir, mcpDir := r.opts, r.rcpt, r.installDir, r.mcpDir
+		if opts.allPlatforms {
+			files, st, err := syncTree(r.mcpStaged, mcpDir, installDir, opts.previousFiles("mcp"), nil)
+			if err != nil {
+				fatal("Failed to place MCP tools", err)
+			}
+			c := rcpt.component("mcp", r.mcpURL)
+			c.Files, c.Downloads = files, r.mcpDownloads
+			if opts.previous != nil {
+				fmt.Printf("  mcp: %s\n", st)
+			}
+		} else if r.mcpUnavailable != "" {
+			rcpt.component("mcp", "").Unavailable = r.mcpUnavailable
+			fmt.Printf("  %s MCP tools not installed (%s); `xmlui-bundler update` will try again\n", glyphFail, r.mcpUnavailable)
+		} else {
+			r.placeMCP()
+		}
+
+		// Move docs and src under mcp if they exist at the root level; in a
+		// flat install they are the project's own.
+		if _, err := os.Stat(filepath.Join(installDir, "docs")); err == nil && !opts.flat {
+			if err := fsys.Rename(filepath.Join(installDir, "docs"), r.docsDir); err != nil {
+				warn("Could not move docs directory: %v", err)
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(installDir, "src")); err == nil && !opts.flat {
+			if err := fsys.Rename(filepath.Join(installDir, "src"), r.srcDir); err != nil {
+				warn("Could not move src directory: %v", err)
+			}
+		}
+
+		if opts.previous != nil {
+			if err := refreshSearchIndex(mcpDir); err != nil {
+				fatal("Failed to refresh the MCP search index", err)
+			}
+		}
+	})
+}
+
+// placeMCP moves this platform's unpacked MCP tools into place, noting the
+// files the chmod mcp step makes executable.
+func (r *installRun) placeMCP() {
+	opts, installDir, tmpMCP := r.opts, r.installDir, r.mcpStaged
+	expectedFiles := r.mcpFiles()
+	mcpComponent := r.rcpt.component("mcp", r.mcpURL)
+	mcpComponent.Downloads = []receiptDownload{newDownload(r.host, r.mcpURL, r.mcpSum)}
+	if opts.previous != nil {
+		binaries, scripts := map[string]bool{}, map[string]bool{}
+		for i, name := range expectedFiles {
+			if i < 2 {
+				binaries[name] = true
+			} else {
+				scripts[name] = true
+			}
+		}
+		// Each sync only sees (and so only removes) its own files.
+		prevBinaries, prevScripts := map[string]string{}, map[string]string{}
+		for key, h := range opts.previousFiles("mcp") {
+			if binaries[path.Base(key)] {
+				prevBinaries[key] = h
+			} else {
+				prevScripts[key] = h
+			}
+		}
+		files, st, err := syncTree(tmpMCP, r.mcpDir, installDir, prevScripts, func(rel string) bool { return scripts[rel] })
+		if err == nil {
+			var binFiles map[string]string
+			var binSt syncStats
+			binFiles, binSt, err = syncTree(tmpMCP, r.mcpBinDir, installDir, prevBinaries, func(rel string) bool { return binaries[rel] })
+			files = mergeHashes(files, binFiles)
+			st.add(binSt)
+		}
+		if err == nil {
+			var n int
+			n, err = dropMoved(installDir, prevBinaries, files)
+			st.Removed += n
+		}
+		if err != nil {
+			fatal("Failed to update MCP tools", err)
+		}
+		mcpComponent.Files = files
+		fmt.Printf("  mcp: %s\n", st)
+	}
+
+	for _, name := range expectedFiles {
+		src := filepath.Join(tmpMCP, name)
+		dst := r.mcpDest(name)
+		if opts.previous == nil {
+			journal.mkdirAll(filepath.Dir(dst))
+			if err := journal.preserve(dst); err != nil {
+				warn("  Skipping %s: %v", name, err)
+				continue
+			}
+			if err := movePath(src, dst); err != nil {
+				warn("  Skipping %s (not found?): %v", name, err)
+				continue
+			}
+			fmt.Printf("  Moved %s to %s\n", name, dst)
+			if h, err := hashFile(dst); err == nil {
+				mcpComponent.Files = mergeHashes(mcpComponent.Files, map[string]string{receiptKey(installDir, dst): h})
+			}
+		}
+		if isExecutableName(name) {
+			r.mcpExec = append(r.mcpExec, dst)
+		}
+	}
+
+	// Clean up the temporary MCP directory
+	_ = fsys.RemoveAll(tmpMCP)
+}
+
+func (r *installRun) downloadServer() {
+	status.step(4, "Downloading XMLUI test server...")
+	status.setState("done")
+	status.begin("server")
+	if r.appMissing() {
+		skipComponent("server", "the app failed")
+		r.serverUnavailable = "the app failed"
+		r.noteServerUnavailable()
+		return
+	}
+	r.attempt("server", func() {
+		if r.serverUnavailable != "" {
+			return
+		}
+		var err error
+		if r.opts.allPlatforms {
+			var what string
+			if r.serverStaged, r.serverURL, r.serverDownloads, what, err = stageServer(r.opts, r.stage, r.host, serverBinaries); err != nil {
+				r.serverUnavailable = offerStaticServer(r.opts, r.host, what, err)
+			}
+		} else if r.serverArchive, r.serverURL, r.serverDownloads, err = fetchServer(r.opts, r.host); err != nil {
+			r.serverUnavailable = offerStaticServer(r.opts, r.host, "Failed to download server", err)
+		}
+	})
+}
+
+func (r *installRun) extractServer() {
+	r.attempt("server", func() {
+		if r.serverUnavailable != "" {
+			return
+		}
+		var err error
+		if r.serverStaged, err = unpackServer(r.serverArchive, r.stage, r.host); err != nil {
+			r.serverUnavailable = offerStaticServer(r.opts, r.host, "Failed to extract server", err)
+		}
+		r.serverArchive = nil
+	})
+}
+
+func (r *installRun) layoutServer() {
+	r.attempt("server", func() {
+		opts, installDir := r.opts, r.installDir
+		if r.serverUnavailable != "" {
+			r.noteServerUnavailable()
+			return
+		}
+		serverFiles, serverStats, err := syncTree(r.serverStaged, r.serverBinDir, installDir, opts.previousFiles("server"), nil)
+		if err == nil {
+			var n int
+			n, err = dropMoved(installDir, opts.previousFiles("server"), serverFiles)
+			serverStats.Removed += n
+		}
+		if err != nil {
+			fatal("Failed to place server", err)
+		}
+		if opts.previous != nil {
+			fmt.Printf("  server: %s\n", serverStats)
+		}
+		serverComponent := r.rcpt.component("server", r.serverURL)
+		serverComponent.Files, serverComponent.Downloads = serverFiles, r.serverDownloads
+	})
+}
+
+func (r *installRun) noteServerUnavailable() {
+	r.rcpt.component("server", "").Unavailable = r.serverUnavailable
+	fmt.Printf("  %s Test server not installed (%s); `xmlui-bundler serve` will show the app as %s\n", glyphFail, r.serverUnavailable, staticServerNote)
+}
+
+func (r *installRun) describeVerify() string {
+	s := "run the installed binaries' --version and record them"
+	var tests []string
+	if r.opts.verifyMCP {
+		tests = append(tests, "the MCP server")
+	}
+	if r.opts.verifyServer {
+		tests = append(tests, "the test server")
+	}
+	if len(tests) > 0 {
+		s += "; smoke-test " + strings.Join(tests, " and ")
+	}
+	return s
+}
+
+// verify checks the installed binaries run, records their versions and
+// checks them against the compatibility matrix.
+func (r *installRun) verify() {
+	opts, rcpt, installDir, host := r.opts, r.rcpt, r.installDir, r.host
+	// A flat install's project scripts are the user's own.
+	scriptDirs := []string{r.mcpDir}
+	if !opts.flat && !r.appMissing() {
+		scriptDirs = append(scriptDirs, r.appDir)
+	}
+	for _, p := range scriptPrereqs(installDir, rcpt, true, scriptDirs...) {
+		warn("%s", p)
+	}
+
+	status.step(5, "Verifying installed binaries...")
+	status.setState("done")
+	status.begin("verify")
+	status.setState("verifying")
+	exe := host.exe()
+	type probe struct{ component, path string }
+	probes := []probe{
+		{"mcp", filepath.Join(r.mcpBinDir, "xmlui-mcp"+exe)},
+		{"mcp", filepath.Join(r.mcpBinDir, "xmlui-mcp-client"+exe)},
+		{"server", filepath.Join(r.serverBinDir, "xmlui-test-server"+exe)},
+	}
+	// Only this machine's builds can be run; the other platforms' are
+	// recorded unchecked.
+	hostBin := ""
+	if opts.allPlatforms {
+		probes = probes[:0]
+		for _, path := range platformBinaries(r.mcpDir, mcpBinaries) {
+			probes = append(probes, probe{"mcp", path})
+		}
+		for _, path := range platformBinaries(r.serverBinDir, serverBinaries) {
+			probes = append(probes, probe{"server", path})
+		}
+		if host, ok := hostPlatform(); ok {
+			hostBin = host.binDir()
+		}
+	}
+	var probed []string
+	for _, p := range probes {
+		probed = append(probed, p.path)
+	}
+	checkQuarantine(installDir, probed, opts.unblock)
+	if opts.crossProvisioning() && !opts.allPlatforms {
+		fmt.Printf("  The %s/%s binaries can't run here; `xmlui-bundler smoke` on that machine checks them\n", host.OS, host.Arch)
+	}
+	attempt("verify", func() {
+		for _, p := range probes {
+			if _, err := os.Stat(p.path); err != nil {
+				continue
+			}
+			v, signature := "unchecked", ""
+			onHost := !opts.allPlatforms && !opts.crossProvisioning() || filepath.Base(filepath.Dir(p.path)) == hostBin
+			if !opts.skipVersionCheck && onHost {
+				var err error
+				v, err = probeBinary(p.path)
+				if err != nil {
+					fatal("Installed binary is not usable on this machine", classify(exitVerify, err))
+				}
+				fmt.Printf("  %s: %s\n", filepath.Base(p.path), v)
+			}
+			if onHost {
+				signature = binarySignature(p.path)
+			}
+			sum, _ := hashFile(p.path)
+			rel, _ := filepath.Rel(installDir, p.path)
+			c := rcpt.component(p.component, "")
+			c.Binaries = append(c.Binaries, receiptBinary{Path: filepath.ToSlash(rel), Version: v, SHA256: sum, Signature: signature})
+		}
+		if opts.verifyMCP && r.mcpUnavailable != "" {
+			warn("Skipping --verify-mcp: the MCP tools were not installed (%s)", r.mcpUnavailable)
+		} else if opts.verifyMCP {
+			status.setState("testing mcp")
+			fmt.Println("Testing the MCP server...")
+			if err := testMCP(r.mcpDir, r.mcpBinDir, nil, "Button", 30*time.Second); err != nil {
+				fatal("MCP smoke test failed", classify(exitVerify, err))
+			}
+		}
+		if opts.verifyServer && r.serverUnavailable != "" {
+			warn("Skipping --verify-server: the test server was not installed (%s)", r.serverUnavailable)
+		} else if opts.verifyServer {
+			status.setState("testing server")
+			fmt.Println("Testing the test server...")
+			if err := testServer(r.appDir, r.serverBinDir, 0, nil, 30*time.Second); err != nil {
+				fatal("Test server smoke test failed", classify(exitVerify, err))
+			}
+		}
+	})
+	problems, versions, err := checkCompat(r.compat, rcpt, r.appDir)
+	if err != nil {
+		fatal("Failed to load the app's requirements", classify(exitConfig, err))
+	}
+	reportCompat(problems, versions, r.compatFrom, opts.ignoreCompat)
+}
+
+// configure points the MCP client c, whose config is at path, at the
+// install's MCP server, and puts the config back as it was on rollback.
+func (r *installRun) configure(c mcpClientConfig, path string) pipeline.Work {
+	var original []byte
+	var mode os.FileMode
+	configured := false
+	return pipeline.Work{
+		Do: func(context.Context) error {
+			if r.mcpUnavailable != "" {
+				warn("Not configuring %s: the MCP tools were not installed (%s)", c.name, r.mcpUnavailable)
+				return nil
+			}
+			if info, err := os.Stat(path); err == nil {
+				mode = info.Mode().Perm()
+				original, _ = os.ReadFile(path)
+			}
+			if err := configureMCPClient(c, r.installDir, r.mcpDir, r.mcpBinDir); err != nil {
+				warn("Could not configure %s: %v", c.name, err)
+				return nil
+			}
+			configured = true
+			r.rcpt.MCPClients = append(r.rcpt.MCPClients, c.name)
+			return nil
+		},
+		Undo: func() error {
+			if !configured {
+				return nil
+			}
+			r.rcpt.MCPClients = slices.DeleteFunc(r.rcpt.MCPClients, func(name string) bool { return name == c.name })
+			if original == nil {
+				return fsys.Remove(path)
+			}
+			return fsys.WriteFile(path, original, mode)
+		},
+	}
+}
+
+func (r *installRun) writeReceipt() {
+	if r.opts.ephemeral {
+		// Nothing to record.
+	} else if err := r.rcpt.write(r.installDir); err != nil {
+		warn("Could not write the install receipt: %v", err)
+	}
+	if r.opts.crossProvisioning() && !r.opts.ephemeral {
+		if err := handOffState(r.installDir); err != nil {
+			warn("Could not copy the install's bookkeeping for the %s/%s machine: %v", r.host.OS, r.host.Arch, err)
+		}
+	}
+}
+
+func (r *installRun) writeGettingStarted() {
+	orgReadme, err := readOrgReadme(r.rcpt.Organization)
+	if err != nil {
+		warn("Could not read the organization's README %s: %v", r.rcpt.Organization.README, err)
+	}
+	if r.summary, err = writeGettingStarted(r.installDir, r.appDir, r.rcpt, orgReadme); err != nil {
+		warn("Could not write %s: %v", gettingStartedFile, err)
+	}
+}
+
+// printPlan prints the steps an install opts asks for in installDir would
+// run, for --dry-run. URLs are the ones this launcher was built with (a
+// --channel is resolved only when installing).
+func printPlan(opts installOptions, installDir string) error {
+	host := opts.target
+	r := &installRun{opts: opts, rcpt: opts.newReceipt(), host: host, installDir: installDir, stage: planStage}
+	r.rcpt.MCPClients = opts.configure
+	if _, err := assetURL("mcp", host); err != nil && !opts.allPlatforms && opts.local.mcp == "" {
+		r.mcpUnavailable = fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+	}
+	if _, err := assetURL("server", host); err != nil && !opts.allPlatforms && opts.local.server == "" {
+		r.serverUnavailable = fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+	}
+	if !opts.flat {
+		// What the receipt will say, for the steps that render from it.
+		if app, err := parseRepoSource(opts.appSource, opts.appRef, opts.appProvider); err == nil {
+			r.rcpt.AppDir = app.Name
+		}
+	} else {
+		r.rcpt.AppDir = "."
+	}
+	p, err := r.pipeline()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("An install in %s would run these steps (nothing is changed):\n", installDir)
+	p.Describe(os.Stdout)
+	return nil
+}
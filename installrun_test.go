@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jonudell/xmlui-bundler/pipeline"
+)
+
+func TestInstallStepsRollBack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the chmod step needs exec bits")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Setenv("XMLUI_BUNDLER_CONFIG", "")
+
+	installDir := t.TempDir()
+	j, err := beginJournal(installDir, filepath.Join(installDir, ".backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal = j
+	t.Cleanup(func() { journal = nil })
+
+	var opts installOptions
+	installFlags(newFlagSet("install"), &opts)
+	opts.target = platform{OS: "linux", Arch: "amd64"}
+	r := &installRun{opts: opts, rcpt: opts.newReceipt(), host: opts.target, installDir: installDir, stage: t.TempDir()}
+	p, err := r.pipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := filepath.Join(r.appDir, "start.sh")
+	env := filepath.Join(envFileDir(installDir, r.rcpt), envFileSh)
+	for path, data := range map[string]string{start: "#!/bin/sh\n", env: "old\n"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var steps []pipeline.InstallStep
+	for _, name := range []string{"layout tools", "chmod app", "generate environment"} {
+		i := p.Index(name)
+		if i < 0 {
+			t.Fatalf("the install has no %s step", name)
+		}
+		steps = append(steps, p.Steps[i])
+	}
+	boom := errors.New("boom")
+	var ran []string
+	check := &pipeline.Step{Label: "check", Work: pipeline.Work{Do: func(context.Context) error {
+		for _, path := range []string{r.mcpDir, r.docsDir, r.srcDir, filepath.Join(filepath.Dir(env), envFilePs1)} {
+			if _, err := os.Stat(path); err == nil {
+				ran = append(ran, path)
+			}
+		}
+		if info, err := os.Stat(start); err == nil && info.Mode()&0100 != 0 {
+			ran = append(ran, start)
+		}
+		return boom
+	}}}
+	if err := pipeline.New(append(steps, check)...).Run(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("Run = %v, want %v", err, boom)
+	}
+	if len(ran) != 5 {
+		t.Errorf("the steps made %q; want the tools dirs, env.ps1 and an executable start.sh", ran)
+	}
+
+	for _, dir := range []string{r.mcpDir, r.docsDir, r.srcDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s is still there after the rollback (%v)", dir, err)
+		}
+	}
+	if info, err := os.Stat(start); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0644 {
+		t.Errorf("start.sh after the rollback has mode %#o, want 0644", info.Mode().Perm())
+	}
+	if data, err := os.ReadFile(env); string(data) != "old\n" {
+		t.Errorf("%s after the rollback = %q, %v; want the original", envFileSh, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(env), envFilePs1)); !os.IsNotExist(err) {
+		t.Errorf("%s is still there after the rollback (%v)", envFilePs1, err)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// componentLayout says where the XMLUI snapshot keeps the component docs
+// and source, as slash-separated paths relative to its root.
+type componentLayout struct {
+	Docs string `json:"docs,omitempty"`
+	Src  string `json:"src,omitempty"`
+}
+
+// defaultLayout is the monorepo layout the launcher was written against.
+var defaultLayout = componentLayout{Docs: "docs/pages/components", Src: "xmlui/src/components"}
+
+// layoutSearchDepth is how deep discovery looks for components directories.
+const layoutSearchDepth = 6
+
+// layoutSkipDirs are never searched.
+var layoutSkipDirs = map[string]bool{"node_modules": true, ".git": true, "dist": true, "build": true, ".next": true}
+
+// resolveLayout finds the component docs and source in the snapshot at root.
+// A path in override (from the "layout" of xmlui-bundler.json) must exist;
+// otherwise the default is used if present, and failing that the likeliest
+// components directory, with a warning. If there is none, the error lists
+// what the snapshot does have.
+func resolveLayout(root string, override componentLayout) (componentLayout, error) {
+	var found []string // components directories, for the error
+	discovered := false
+	pick := func(what, overridePath, defaultPath string, score func(string) int) (string, error) {
+		if overridePath != "" {
+			if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(overridePath))); err != nil || !info.IsDir() {
+				return "", fmt.Errorf("the %s path %s set in %s is not in the XMLUI snapshot", what, overridePath, configFileName)
+			}
+			return overridePath, nil
+		}
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(defaultPath))); err == nil && info.IsDir() {
+			return defaultPath, nil
+		}
+		if found == nil {
+			found = componentsDirs(root)
+		}
+		best, bestScore := "", 0
+		for _, d := range found {
+			if s := score(filepath.Join(root, filepath.FromSlash(d))); s > bestScore {
+				best, bestScore = d, s
+			}
+		}
+		if best == "" {
+			return "", nil
+		}
+		discovered = true
+		warn("  Upstream layout changed: %s is gone; using the component %s in %s", defaultPath, what, best)
+		return best, nil
+	}
+
+	var l componentLayout
+	var err error
+	if l.Docs, err = pick("docs", override.Docs, defaultLayout.Docs, docsScore); err != nil {
+		return l, err
+	}
+	if l.Src, err = pick("source", override.Src, defaultLayout.Src, srcScore); err != nil {
+		return l, err
+	}
+	if l.Docs != "" && l.Src != "" {
+		if discovered {
+			fmt.Printf("  Set \"layout\" in %s to make this explicit\n", configFileName)
+		}
+		return l, nil
+	}
+
+	var missing []string
+	if l.Docs == "" {
+		missing = append(missing, "component docs (expected "+defaultLayout.Docs+")")
+	}
+	if l.Src == "" {
+		missing = append(missing, "component source (expected "+defaultLayout.Src+")")
+	}
+	var top []string
+	if entries, err := os.ReadDir(root); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				top = append(top, e.Name()+"/")
+			}
+		}
+	}
+	msg := fmt.Sprintf("upstream layout changed: the XMLUI snapshot has no %s\n  top level: %s", strings.Join(missing, " or "), listOrNone(top))
+	msg += "\n  components directories: " + listOrNone(found)
+	msg += fmt.Sprintf("\n  set \"layout\": {\"docs\": ..., \"src\": ...} in %s to the right paths", configFileName)
+	return l, fmt.Errorf("%s", msg)
+}
+
+// snapshotSubtrees lists the directories of the XMLUI snapshot zip data
+// that an install uses, for unzipSubtreesTo: the component docs and source
+// of override (or the default layout), the trees of features and the
+// license files, under the archive's top-level directory. It returns nil when the snapshot has to be
+// extracted whole: when it has no single top-level directory, or lacks the
+// docs or source, so that resolveLayout can look for them or say what is
+// there instead.
+func snapshotSubtrees(data []byte, override componentLayout, features []string) []string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+	root := ""
+	for _, f := range r.File {
+		top, rest, _ := strings.Cut(strings.TrimPrefix(f.Name, "./"), "/")
+		switch {
+		case top == "pax_global_header" || top == "__MACOSX":
+			continue
+		case rest == "" && !f.FileInfo().IsDir():
+			// A file at the top level.
+			return nil
+		case root == "":
+			root = top
+		case top != root:
+			return nil
+		}
+	}
+	if root == "" {
+		return nil
+	}
+	found := func(dir string) bool {
+		prefix := root + "/" + dir + "/"
+		for _, f := range r.File {
+			if strings.HasPrefix(strings.TrimPrefix(f.Name, "./"), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	docs, src := cmp.Or(override.Docs, defaultLayout.Docs), cmp.Or(override.Src, defaultLayout.Src)
+	if !found(docs) || !found(src) {
+		return nil
+	}
+	dirs := []string{docs, src}
+	for _, name := range features {
+		pkg := extensionPackages[name]
+		dirs = append(dirs, pkg.Src)
+		if pkg.Docs != "" {
+			dirs = append(dirs, pkg.Docs)
+		}
+	}
+	for i, d := range dirs {
+		dirs[i] = root + "/" + strings.Trim(d, "/")
+	}
+	// And the license files at the top, for licenses/.
+	for _, f := range r.File {
+		name := strings.TrimPrefix(f.Name, "./")
+		if dir, file := path.Split(name); dir == root+"/" && isLicenseFile(file) {
+			dirs = append(dirs, name)
+		}
+	}
+	return dirs
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+	return strings.Join(items, ", ")
+}
+
+// componentsDirs lists the directories named components under root, as
+// slash-separated relative paths.
+func componentsDirs(root string) []string {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if layoutSkipDirs[d.Name()] || strings.Count(rel, string(filepath.Separator)) >= layoutSearchDepth {
+			return filepath.SkipDir
+		}
+		if d.Name() == "components" {
+			dirs = append(dirs, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs
+}
+
+// docsScore counts the Markdown pages named like components (Button.md) in
+// dir and its subdirectories.
+func docsScore(dir string) int {
+	n := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && startsUpper(d.Name()) {
+			switch strings.ToLower(filepath.Ext(d.Name())) {
+			case ".md", ".mdx":
+				n++
+			}
+		}
+		return nil
+	})
+	return n
+}
+
+// srcScore counts the subdirectories of dir holding TypeScript components.
+func srcScore(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		files, _ := os.ReadDir(filepath.Join(dir, e.Name()))
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), ".tsx") {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func startsUpper(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsUpper(r)
+}
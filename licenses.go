@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// licensesDirName collects the license files of the installed components,
+// for organizations that review them before passing a bundle around.
+const licensesDirName = "licenses"
+
+// licenseNoticeFile, in licensesDirName, lists each component's license.
+const licenseNoticeFile = "NOTICE.md"
+
+// componentLicenses are the license files found in one component's
+// download, by name.
+type componentLicenses struct {
+	component, label string
+	files            map[string][]byte
+}
+
+// installLicenses are the licenses this run has collected, in install
+// order.
+var installLicenses []componentLicenses
+
+// licenseSignatures identify the common licenses by a phrase of their text.
+// More specific ones come first: BSD-3-Clause has BSD-2-Clause's wording,
+// and the AGPL and LGPL mention the GPL.
+var licenseSignatures = []struct{ id, phrase string }{
+	{"MIT", "permission is hereby granted, free of charge"},
+	{"Apache-2.0", "apache license version 2.0"},
+	{"BSD-3-Clause", "neither the name of"},
+	{"BSD-2-Clause", "redistribution and use in source and binary forms"},
+	{"ISC", "permission to use, copy, modify, and/or distribute this software for any purpose"},
+	{"MPL-2.0", "mozilla public license version 2.0"},
+	{"AGPL-3.0", "gnu affero general public license"},
+	{"LGPL", "gnu lesser general public license"},
+	{"GPL", "gnu general public license"},
+	{"Unlicense", "this is free and unencumbered software"},
+}
+
+var licenseSpace = regexp.MustCompile(`[\s,]+`)
+
+// isLicenseFile reports whether name is a license or notice file: LICENSE,
+// LICENCE, COPYING or NOTICE, with or without an extension or a suffix such
+// as LICENSE-MIT.
+func isLicenseFile(name string) bool {
+	base := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, l := range []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"} {
+		if base == l || strings.HasPrefix(base, l+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// identifyLicense names the license of text, or "" if it isn't one of
+// licenseSignatures.
+func identifyLicense(text []byte) string {
+	norm := licenseSpace.ReplaceAllString(strings.ToLower(string(text)), " ")
+	for _, s := range licenseSignatures {
+		if strings.Contains(norm, licenseSpace.ReplaceAllString(s.phrase, " ")) {
+			return s.id
+		}
+	}
+	return ""
+}
+
+// collectLicenses keeps the license files at the top of dir, where
+// component's download was extracted, for writeLicenses. They are read
+// now, as the staging dir doesn't last.
+func collectLicenses(component, label, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	c := componentLicenses{component: component, label: label, files: map[string][]byte{}}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isLicenseFile(e.Name()) {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, e.Name())); err == nil {
+			c.files[e.Name()] = data
+		}
+	}
+	for i := range installLicenses {
+		if installLicenses[i].component == component {
+			installLicenses[i] = c
+			return
+		}
+	}
+	installLicenses = append(installLicenses, c)
+}
+
+// writeLicenses replaces licenses/ (in the tools dir of a --flat install)
+// with the license files collected, one directory per component, and a
+// NOTICE.md listing them, and prints the list.
+func writeLicenses(installDir string, rcpt *receipt) error {
+	if len(installLicenses) == 0 {
+		return nil
+	}
+	dir := filepath.Join(installDir, licensesDirName)
+	if rcpt.Flat {
+		dir = filepath.Join(installDir, rcpt.toolsDir(), licensesDirName)
+	}
+	if err := journal.preserve(dir); err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	var notice, summary strings.Builder
+	notice.WriteString("# Licenses of the bundled components\n\n")
+	notice.WriteString("Generated by xmlui-bundler from the license files in each component's download.\n\n")
+	notice.WriteString("| Component | License | Files |\n|---|---|---|\n")
+	for _, c := range installLicenses {
+		names := make([]string, 0, len(c.files))
+		for name := range c.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var ids []string
+		for i, name := range names {
+			data := c.files[name]
+			if id := identifyLicense(data); id != "" && !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+			names[i] = c.component + "/" + name
+			if err := fsys.MkdirAll(filepath.Join(dir, c.component), dirMode); err != nil {
+				return err
+			}
+			if err := fsys.WriteFile(filepath.Join(dir, c.component, name), data, fileMode); err != nil {
+				return err
+			}
+		}
+		license := strings.Join(ids, ", ")
+		switch {
+		case len(names) == 0:
+			license = "no license file in the download; ask its authors"
+		case license == "":
+			license = "not recognized; see the file"
+		}
+		fmt.Fprintf(&notice, "| %s | %s | %s |\n", c.label, license, strings.Join(names, ", "))
+		fmt.Fprintf(&summary, "  %s: %s\n", c.label, license)
+	}
+	if err := fsys.WriteFile(filepath.Join(dir, licenseNoticeFile), []byte(notice.String()), fileMode); err != nil {
+		return err
+	}
+	fmt.Printf("Licenses of the bundled components (copies in %s):\n%s", receiptKey(installDir, dir), summary.String())
+	return nil
+}
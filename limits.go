@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jonudell/xmlui-bundler/extract"
+)
+
+// extractLimits apply to every archive, so a malicious or corrupt archive (a
+// zip bomb, say) fails the install instead of filling the disk;
+// --max-extract-size and friends set them. The defaults leave ample room
+// for the XMLUI repo snapshot, the largest archive installed.
+var extractLimits = extract.Limits{
+	TotalBytes: 4 << 30,
+	Files:      250000,
+	FileBytes:  1 << 30,
+	Depth:      64,
+}
+
+// extractLimitFlags are the flags that set each of extractLimits.
+var extractLimitFlags = map[string]string{
+	extract.LimitTotalBytes: "--max-extract-size",
+	extract.LimitFiles:      "--max-extract-files",
+	extract.LimitFileBytes:  "--max-extract-file-size",
+	extract.LimitDepth:      "--max-extract-depth",
+}
+
+// errExtractLimit is an archive that went over one of extractLimits, told
+// in the terms of the flag that raises it.
+type errExtractLimit struct{ extract.LimitError }
+
+func (e errExtractLimit) Error() string {
+	what, limit := "", strconv.FormatInt(e.Max, 10)
+	switch e.Limit {
+	case extract.LimitTotalBytes:
+		what, limit = "total size", humanBytes(e.Max)
+	case extract.LimitFileBytes:
+		what, limit = "file size", humanBytes(e.Max)
+	case extract.LimitFiles:
+		what = "file count"
+	case extract.LimitDepth:
+		what = "path depth"
+	}
+	return fmt.Sprintf("%s: archive exceeds the %s limit of %s (raise it with %s)", e.Name, what, limit, extractLimitFlags[e.Limit])
+}
+
+func (e errExtractLimit) Unwrap() error { return e.LimitError }
+
+// extractErr puts a limit an extraction went over in the flag's terms.
+func extractErr(err error) error {
+	var limit extract.LimitError
+	if errors.As(err, &limit) {
+		return errExtractLimit{limit}
+	}
+	return err
+}
+
+// sizeFlag is a byte count given as 1073741824, 512MB, 2G or 1.5GiB, in
+// powers of 1024 as humanBytes prints them; 0 turns the limit off.
+type sizeFlag struct{ n *int64 }
+
+func (f sizeFlag) String() string {
+	if f.n == nil {
+		return ""
+	}
+	if *f.n == 0 {
+		return "0"
+	}
+	return strings.ReplaceAll(humanBytes(*f.n), " ", "")
+}
+
+func (f sizeFlag) Set(s string) error {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	mult := int64(1)
+	if i := strings.IndexAny(v, "KMGT"); i >= 0 && i == len(v)-1 {
+		mult = 1 << (10 * (strings.IndexByte("KMGT", v[i]) + 1))
+		v = v[:i]
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a size such as 512MB or 2GB, got %q", s)
+	}
+	*f.n = int64(n * float64(mult))
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liveReloadPath is where `serve --watch` pages listen for reloads, as a
+// server-sent event stream; it is served by the proxy, not the app.
+const liveReloadPath = "/__xmlui/livereload"
+
+// liveReloadEvery is how often `serve --watch` looks for changed files.
+const liveReloadEvery = 500 * time.Millisecond
+
+// liveReloadExts are the app files whose changes reload the page.
+var liveReloadExts = map[string]bool{".xmlui": true, ".css": true}
+
+// liveReloadScript is added to every HTML page the proxy serves.
+const liveReloadScript = `<script>(function () {
+  var es = new EventSource("` + liveReloadPath + `");
+  es.addEventListener("reload", function () { location.reload(); });
+})();</script>`
+
+// freeLocalPort asks the OS for a port nothing listens on.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// liveReloader is the `serve --watch` front end: a proxy to the test server
+// that adds liveReloadScript to HTML pages and tells them to reload when an
+// app source file changes.
+type liveReloader struct {
+	proxy   *httputil.ReverseProxy
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// newLiveReloader proxies to the test server at target.
+func newLiveReloader(target *url.URL) *liveReloader {
+	lr := &liveReloader{clients: map[chan struct{}]bool{}}
+	lr.proxy = httputil.NewSingleHostReverseProxy(target)
+	director := lr.proxy.Director
+	lr.proxy.Director = func(r *http.Request) {
+		director(r)
+		// Uncompressed, so the script can be added.
+		r.Header.Del("Accept-Encoding")
+	}
+	lr.proxy.ModifyResponse = injectLiveReload
+	return lr
+}
+
+func (lr *liveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != liveReloadPath {
+		lr.proxy.ServeHTTP(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[ch] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, ch)
+		lr.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// reload tells every connected page to reload and returns how many there
+// were.
+func (lr *liveReloader) reload() int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for ch := range lr.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return len(lr.clients)
+}
+
+// injectLiveReload adds liveReloadScript to an HTML response, before
+// </body> if there is one.
+func injectLiveReload(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append([]byte(liveReloadScript), body[i:]...)...)
+	} else {
+		body = append(body, liveReloadScript...)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	// The page changes with the script; don't let a cached copy skip it.
+	resp.Header.Del("ETag")
+	resp.Header.Set("Cache-Control", "no-store")
+	return nil
+}
+
+// sourceSnapshot maps each live-reload file under appDir (apart from the
+// tools in .xmlui/ and hidden or node_modules dirs) to its size and mtime.
+func sourceSnapshot(appDir string) map[string]string {
+	snap := map[string]string{}
+	filepath.WalkDir(appDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != appDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !liveReloadExts[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(appDir, p)
+		snap[filepath.ToSlash(rel)] = fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return snap
+}
+
+// changedFiles lists the files added, removed or modified between two
+// snapshots, sorted.
+func changedFiles(before, after map[string]string) []string {
+	var changed []string
+	for name, v := range after {
+		if before[name] != v {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// watchSources polls appDir and reloads lr's pages whenever a .xmlui or
+// .css file changes, until stop is closed.
+func watchSources(appDir string, lr *liveReloader, stop <-chan struct{}) {
+	snap := sourceSnapshot(appDir)
+	ticker := time.NewTicker(liveReloadEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		next := sourceSnapshot(appDir)
+		changed := changedFiles(snap, next)
+		snap = next
+		if len(changed) == 0 {
+			continue
+		}
+		fmt.Printf("%s Changed %s; reloading %d open pages\n", time.Now().Format("15:04:05"), strings.Join(changed, ", "), lr.reload())
+	}
+}
+
+// startLiveReload serves lr on port, reporting a failure to listen right
+// away.
+func startLiveReload(lr *liveReloader, port int) (*http.Server, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: lr}
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			fmt.Println("Live reload proxy stopped:", err)
+		}
+	}()
+	return srv, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localPaths are local builds to install in place of the app, MCP tools
+// and test server downloads (--app-path, --mcp-path, --server-path), for
+// developers of those components to try unreleased builds. Each is an
+// archive, a directory, or (for the binaries) a bare executable.
+type localPaths struct {
+	app, mcp, server string
+}
+
+func localPathFlags(fs *flag.FlagSet, l *localPaths) {
+	fs.StringVar(&l.app, "app-path", "", "install the app from this local .zip/.tar.gz or directory instead of --app-source")
+	fs.StringVar(&l.mcp, "mcp-path", "", "install the MCP tools from this local archive, directory or executable instead of the release")
+	fs.StringVar(&l.server, "server-path", "", "install the test server from this local archive, directory or executable instead of the release")
+}
+
+// path returns the local path given for component, or "".
+func (l localPaths) path(component string) string {
+	switch component {
+	case "app":
+		return l.app
+	case "mcp":
+		return l.mcp
+	case "server":
+		return l.server
+	}
+	return ""
+}
+
+// set points component at path.
+func (l *localPaths) set(component, path string) {
+	switch component {
+	case "app":
+		l.app = path
+	case "mcp":
+		l.mcp = path
+	case "server":
+		l.server = path
+	}
+}
+
+// check makes the local paths absolute, so they survive into the receipt,
+// and refuses the ones opts can't use.
+func (l *localPaths) check(opts installOptions) error {
+	for _, c := range []string{"app", "mcp", "server"} {
+		p := l.path(c)
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("--%s-path: %w", c, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return fmt.Errorf("--%s-path: %w", c, err)
+		}
+		switch {
+		case opts.locked:
+			return fmt.Errorf("--%s-path can't be combined with --locked, which installs only what %s pins", c, lockFile)
+		case c == "app" && opts.flat:
+			return fmt.Errorf("--app-path can't be combined with --flat, which installs no app")
+		case c != "app" && opts.allPlatforms:
+			return fmt.Errorf("--%s-path is one build; it can't be combined with --all-platforms", c)
+		}
+		l.set(c, abs)
+	}
+	return nil
+}
+
+// localURL is the file: URL the receipt records for a component installed
+// from path.
+func localURL(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		// C:/... on Windows.
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// localPathOf returns the path a receipt's file: URL stands for, or "" for
+// a download.
+func localPathOf(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "file" {
+		return ""
+	}
+	p := parsed.Path
+	if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// readLocal reads the local build of component at path as the archive a
+// download would have returned: a directory is packed up, under a top-level
+// directory of its own name for the app, as source archives have.
+func readLocal(component, path, label string) ([]byte, error) {
+	fmt.Printf("Using %s from %s\n", label, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(path)
+	}
+	prefix := ""
+	if component == "app" {
+		prefix = filepath.Base(path) + "/"
+	}
+	return tarDir(path, prefix)
+}
+
+// tarDir packs the files and directories under dir into a .tar.gz,
+// leaving out .git, with each name prefixed by prefix. A tarball rather than
+// a zip, as untarGz gives the binaries and scripts their execute bits.
+func tarDir(dir, prefix string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			warn("Skipping %s, which is not a file or directory", p)
+			return nil
+		}
+		h, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		h.Name = prefix + filepath.ToSlash(rel)
+		if info.IsDir() {
+			h.Name += "/"
+		}
+		if err := w.WriteHeader(h); err != nil || info.IsDir() {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("packing %s: %w", dir, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockFile pins every download to an exact commit or release and checksum.
+// `lock` writes it into the install dir; `--locked` installs refuse to fetch
+// anything it doesn't list or whose bytes differ. A legacyLockFile, of the
+// launcher's old name, is read where there is no lockFile.
+const (
+	lockFile       = launcherName + ".lock"
+	legacyLockFile = legacyLauncherName + ".lock"
+)
+
+type lockfile struct {
+	LauncherVersion string           `json:"launcherVersion"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	AppSource       string           `json:"appSource"`
+	AppRef          string           `json:"appRef,omitempty"`
+	AppProvider     string           `json:"appProvider,omitempty"`
+	Artifacts       []lockedArtifact `json:"artifacts"`
+}
+
+type lockedArtifact struct {
+	Component string `json:"component"`
+	// Platform is os-arch for per-platform binaries, empty otherwise.
+	Platform string `json:"platform,omitempty"`
+	// Commit is the SHA a branch was resolved to, for git sources.
+	Commit string `json:"commit,omitempty"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// artifact returns the pinned download for component on p (the zero
+// platform for platform-independent components), or nil.
+func (l *lockfile) artifact(component string, p platform) *lockedArtifact {
+	key := ""
+	if p.OS != "" {
+		key = p.String()
+	}
+	for i := range l.Artifacts {
+		if l.Artifacts[i].Component == component && l.Artifacts[i].Platform == key {
+			return &l.Artifacts[i]
+		}
+	}
+	return nil
+}
+
+func readLockfile(dir string) (*lockfile, error) {
+	return readLockfilePath(lockfilePath(dir))
+}
+
+// lockfilePath is the lockfile in dir: lockFile, or legacyLockFile if only
+// that exists.
+func lockfilePath(dir string) string {
+	path := filepath.Join(dir, lockFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		legacy := filepath.Join(dir, legacyLockFile)
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return path
+}
+
+func readLockfilePath(path string) (*lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l lockfile
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return &l, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fetch downloads a component archive from url. With --locked the URL comes
+// from the lockfile instead and the bytes must match its checksum; with a
+// local path (see localPaths) the archive is read from there. It
+// returns the data, the URL actually used and the data's SHA-256, measured
+// during the download whether or not a checksum was published for it.
+func (opts installOptions) fetch(component string, p platform, url, label string) ([]byte, string, string, error) {
+	if local := opts.local.path(component); local != "" {
+		data, err := readLocal(component, local, label)
+		if err != nil {
+			return nil, url, "", err
+		}
+		return data, localURL(local), sha256Hex(data), nil
+	}
+	var pinned *lockedArtifact
+	if opts.lock != nil {
+		if pinned = opts.lock.artifact(component, p); pinned == nil {
+			what := component
+			if p.OS != "" {
+				what += " for " + p.String()
+			}
+			return nil, url, "", fmt.Errorf("%s has no entry for %s; re-run `xmlui-bundler lock`", lockFile, what)
+		}
+		url = pinned.URL
+	}
+	// Binary assets are cached as the base for delta updates.
+	_, delta := releaseAssets[component]
+	delta = delta && !opts.ephemeral
+	// Short of a pin, the checksum comes from --checksum-file, this
+	// launcher, or what the release publishes, in that order.
+	want, known, knownFrom := "", "", ""
+	_, release := releaseAssets[component]
+	switch {
+	case pinned != nil:
+		want = pinned.SHA256
+	case opts.checksums.lookup(url) != "":
+		known, knownFrom = opts.checksums.lookup(url), "--checksum-file"
+	case release && knownChecksums[url] != "":
+		known = knownChecksums[url]
+	case release:
+		known, knownFrom = discoverChecksum(url)
+	}
+	if known != "" {
+		want = known
+	}
+	var data []byte
+	var sum string
+	err := errNoDelta
+	if delta {
+		if data, sum, err = fetchDelta(url, label, want); err != nil && err != errNoDelta {
+			fmt.Printf("  Delta update failed (%v); downloading in full\n", err)
+		}
+	}
+	if err != nil {
+		if gitArchiveComponents[component] && !opts.ephemeral {
+			data, sum, err = fetchGitArchive(url, label)
+		} else {
+			data, sum, err = downloadAsset(url, label, want)
+		}
+		if err != nil {
+			return nil, url, "", err
+		}
+	}
+	if delta {
+		cacheAsset(url, data)
+	}
+	if pinned != nil {
+		if sum != pinned.SHA256 {
+			return nil, url, "", &integrityError{fmt.Sprintf("checksum mismatch for %s: got %s, %s expects %s", url, sum, lockFile, pinned.SHA256)}
+		}
+		fmt.Printf("  %s Matches %s\n", glyphOK, lockFile)
+	} else if known != "" && knownFrom == "" {
+		if sum != known {
+			return nil, url, "", &integrityError{fmt.Sprintf("checksum mismatch for %s: got %s, this launcher release expects %s", url, sum, known)}
+		}
+		fmt.Printf("  %s Matches the checksum built into this launcher\n", glyphOK)
+	} else if known != "" {
+		if sum != known {
+			return nil, url, "", &integrityError{fmt.Sprintf("checksum mismatch for %s: got %s, %s expects %s", url, sum, knownFrom, known)}
+		}
+		fmt.Printf("  %s Matches %s\n", glyphOK, knownFrom)
+	}
+	return data, url, sum, nil
+}
+
+// applyLock loads the install dir's lockfile (or --lockfile) for --locked
+// and makes opts install exactly what it describes, refusing a conflicting
+// --app-source or --app-ref.
+func (opts *installOptions) applyLock(installDir string) error {
+	path := lockfilePath(installDir)
+	where := filepath.Base(path) + " in " + installDir
+	if opts.lockfile != "" {
+		path, where = opts.lockfile, "--lockfile "+opts.lockfile
+	}
+	l, err := readLockfilePath(path)
+	if err != nil {
+		return fmt.Errorf("--locked needs %s: %w", where, err)
+	}
+	if opts.appSource != defaultAppSource && opts.appSource != l.AppSource {
+		return fmt.Errorf("--app-source %s conflicts with %s (%s)", opts.appSource, lockFile, l.AppSource)
+	}
+	if opts.appRef != branchName && opts.appRef != l.AppRef {
+		return fmt.Errorf("--app-ref %s conflicts with %s (%s)", opts.appRef, lockFile, l.AppRef)
+	}
+	opts.appSource, opts.appRef, opts.appProvider = l.AppSource, l.AppRef, l.AppProvider
+	opts.lock = l
+	return nil
+}
+
+// resolveCommit asks src's hosting provider which commit ref points at.
+func resolveCommit(src *repoSource, ref string) (string, error) {
+	if isCommitSHA(ref) {
+		return ref, nil
+	}
+	var api string
+	switch src.Provider {
+	case "github":
+		api = fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", src.Owner, src.Name, url.PathEscape(ref))
+	case "gitlab":
+		api = fmt.Sprintf("%s/api/v4/projects/%s/repository/commits/%s", src.Base, url.PathEscape(src.Owner+"/"+src.Name), url.PathEscape(ref))
+	case "bitbucket":
+		api = fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/%s/commit/%s", src.Owner, src.Name, url.PathEscape(ref))
+	case "gitea":
+		api = fmt.Sprintf("%s/api/v1/repos/%s/%s/commits?sha=%s&limit=1", src.Base, src.Owner, src.Name, url.QueryEscape(ref))
+	default:
+		return "", fmt.Errorf("can't resolve refs for %s sources", src.Provider)
+	}
+
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", api, nil)
+	if err != nil {
+		return "", err
+	}
+	applyRequestHeaders(req)
+	if src.Provider == "github" {
+		// Returns just the SHA as text.
+		req.Header.Set("Accept", "application/vnd.github.sha")
+		if token := network.token(req.URL.Host); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := network.do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w%s", err, tlsHint(err))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s at %s: %s", src.Name, ref, resp.Status)
+	}
+
+	var sha string
+	switch src.Provider {
+	case "github":
+		sha = strings.TrimSpace(string(body))
+	case "gitlab":
+		var c struct{ ID string }
+		err = json.Unmarshal(body, &c)
+		sha = c.ID
+	case "bitbucket":
+		var c struct{ Hash string }
+		err = json.Unmarshal(body, &c)
+		sha = c.Hash
+	case "gitea":
+		var cs []struct{ SHA string }
+		if err = json.Unmarshal(body, &cs); err == nil && len(cs) > 0 {
+			sha = cs[0].SHA
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolving %s at %s: %w", src.Name, ref, err)
+	}
+	if !isCommitSHA(sha) {
+		return "", fmt.Errorf("resolving %s at %s: unexpected answer %q", src.Name, ref, sha)
+	}
+	return sha, nil
+}
+
+// runLock implements `lock`: it resolves the app and XMLUI source branches
+// to commits, downloads every artifact (MCP tools and test server for all
+// supported platforms) and writes their URLs and checksums to lockFile.
+func runLock(args []string) int {
+	fs := newFlagSet("lock")
+	dir := installDirFlag(fs)
+	appSource := fs.String("app-source", defaultAppSource, "app repository or .zip/.tar.gz URL to pin")
+	appRef := fs.String("app-ref", branchName, "branch of --app-source to pin")
+	appProvider := fs.String("app-provider", "", "git host type for self-hosted --app-source: github, gitlab, bitbucket or gitea")
+	caCert := fs.String("ca-cert", "", "PEM file of extra CA certificates to trust")
+	insecure := fs.Bool("insecure-skip-verify", false, "disable TLS certificate verification (dangerous)")
+	fs.Var(headerFlag{requestHeaders}, "header", "extra request header 'Key: Value' for all downloads (repeatable)")
+	var features []string
+	var channel string
+	xmluiNPM := fs.String("xmlui-npm", "", "also pin the xmlui npm package at this dist-tag, version or version prefix, for --locked installs with --xmlui-npm")
+	xmluiRuntime := fs.String("xmlui-runtime", "", "also pin the XMLUI runtime of this version, latest or npm:SPEC, for --locked installs that put one into the app")
+	fs.Var(channelFlag{&channel}, "channel", "pin the releases of this channel: stable, beta or nightly (default: the ones this launcher was built with)")
+	fs.Var(featuresFlag{&features}, "features", "optional XMLUI extensions to pin as well, comma-separated: "+strings.Join(featureNames(), ", "))
+	fs.Var(retriesFlag{&network.retry}, "retries", "times to retry a download after a network error or a 429/502/503/504 response, with exponential backoff")
+	fs.DurationVar(&network.retry.stall, "stall-timeout", defaultStallTimeout, "retry a download that receives nothing for this long, resuming it where the server allows; 0 waits forever")
+	knownOut := fs.String("known-checksums", "", "instead of a lockfile, write the checksums of the MCP tools and test server for all platforms to this file, to build into the launcher")
+	fs.Parse(args)
+	defer metrics.save("lock")
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	if err := configureTLS(*caCert, *insecure); err != nil {
+		fmt.Println("Failed to load --ca-cert:", err)
+		return exitConfig
+	}
+	if err := applyChannel(channel); err != nil {
+		fmt.Printf("Failed to resolve --channel %s: %v\n", channel, err)
+		return exitConfig
+	}
+	if err := applyArtifactStores(); err != nil {
+		fmt.Println("Failed to load the artifact stores:", err)
+		return exitConfig
+	}
+	if *knownOut != "" {
+		if err := writeKnownChecksums(*knownOut); err != nil {
+			fmt.Println("Failed to write --known-checksums:", err)
+			return exitCodeOf(err)
+		}
+		return exitOK
+	}
+
+	l := &lockfile{
+		LauncherVersion: version,
+		CreatedAt:       time.Now().UTC(),
+		AppSource:       *appSource,
+		AppRef:          *appRef,
+		AppProvider:     *appProvider,
+	}
+	pin := func(component string, p platform, commit, url, label string) error {
+		_, sum, err := downloadAsset(url, label, "")
+		if err != nil {
+			return err
+		}
+		if _, ok := releaseAssets[component]; ok {
+			if want, from := discoverChecksum(url); want != "" && want != sum {
+				return &integrityError{fmt.Sprintf("checksum mismatch for %s: got %s, %s expects %s", url, sum, from, want)}
+			}
+		}
+		a := lockedArtifact{Component: component, Commit: commit, URL: url, SHA256: sum}
+		if p.OS != "" {
+			a.Platform = p.String()
+		}
+		l.Artifacts = append(l.Artifacts, a)
+		return nil
+	}
+	pinRepo := func(component, spec, ref, provider, label string) error {
+		src, err := parseRepoSource(spec, ref, provider)
+		if err != nil {
+			return classify(exitConfig, err)
+		}
+		commit := ""
+		if src.Provider != "archive" {
+			if commit, err = resolveCommit(src, ref); err != nil {
+				return err
+			}
+			fmt.Printf("  %s %s is %s\n", src.Name, ref, commit)
+			if src, err = parseRepoSource(spec, commit, provider); err != nil {
+				return err
+			}
+		}
+		return pin(component, platform{}, commit, src.URL, label)
+	}
+
+	if err := pinRepo("app", *appSource, *appRef, *appProvider, "app"); err != nil {
+		fmt.Println("Failed to pin app:", err)
+		return exitCodeOf(err)
+	}
+	if err := pinRepo("components", xmluiRepo, xmluiComponentsRef, "github", "XMLUI repo"); err != nil {
+		fmt.Println("Failed to pin XMLUI components:", err)
+		return exitCodeOf(err)
+	}
+	for _, p := range supportedPlatforms {
+		for _, c := range []struct{ component, label string }{{"mcp", "MCP tools"}, {"server", "test server"}} {
+			url, err := assetURL(c.component, p)
+			if err == nil {
+				err = pin(c.component, p, "", url, fmt.Sprintf("%s (%s)", c.label, p))
+			}
+			if err != nil {
+				fmt.Printf("Failed to pin %s: %v\n", c.label, err)
+				return exitCodeOf(err)
+			}
+		}
+	}
+
+	for _, name := range features {
+		if err := pin("feature-"+name, platform{}, "", extensionPackages[name].DistURL, name+" extension"); err != nil {
+			fmt.Printf("Failed to pin the %s extension: %v\n", name, err)
+			return exitCodeOf(err)
+		}
+	}
+
+	if *xmluiNPM != "" {
+		rel, err := resolveNPM(xmluiNPMPackage, *xmluiNPM)
+		if err == nil {
+			fmt.Printf("  %s %s is %s\n", xmluiNPMPackage, *xmluiNPM, rel.Version)
+			err = pin(xmluiNPMComponent, platform{}, "", rel.Tarball, "XMLUI from npm")
+		}
+		if err != nil {
+			fmt.Println("Failed to pin XMLUI from npm:", err)
+			return exitCodeOf(err)
+		}
+	}
+
+	if *xmluiRuntime != "" {
+		u, v, _, err := resolveXMLUIRuntime(*xmluiRuntime)
+		if err == nil {
+			fmt.Printf("  XMLUI runtime %s is %s\n", *xmluiRuntime, v)
+			err = pin(xmluiRuntimeComponent, platform{}, "", u, "XMLUI runtime")
+		}
+		if err != nil {
+			fmt.Println("Failed to pin the XMLUI runtime:", err)
+			return exitCodeOf(err)
+		}
+	}
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	if err := fsys.MkdirAll(installDir, dirMode); err != nil {
+		fmt.Println(err)
+		return exitFilesystem
+	}
+	path := filepath.Join(installDir, lockFile)
+	if err := fsys.WriteFile(path, append(data, '\n'), fileMode); err != nil {
+		fmt.Println(err)
+		return exitFilesystem
+	}
+	// It supersedes one of the old name, which would otherwise linger.
+	if legacy := filepath.Join(installDir, legacyLockFile); fsys.Remove(legacy) == nil {
+		fmt.Printf("  Removed %s, which %s replaces\n", legacy, lockFile)
+	}
+	fmt.Printf("%s Pinned %d artifacts in %s\n", glyphOK, len(l.Artifacts), path)
+	fmt.Println("  Copy it into each install dir and run: xmlui-bundler --locked")
+	return exitOK
+}
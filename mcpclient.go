@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+)
+
+// runMCPClient implements `mcp client`: it runs the bundled interactive
+// xmlui-mcp-client against the installed server and its docs and source
+// trees, as run-mcp-client.sh and run-mcp-client.bat do, but the same way on
+// every platform.
+func runMCPClient(args []string) int {
+	fs := newFlagSet("mcp client")
+	dir := installDirFlag(fs)
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	mcpDir := toolsDirOf(installDir)
+	cmd, err := mcpClientCommand(mcpDir, binDirOf(installDir, "mcp"), fs.Args())
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// Ctrl-C belongs to the interactive client; it decides whether to quit.
+	// Catching it rather than ignoring it keeps the client from inheriting
+	// an ignored SIGINT.
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Println("Failed to run xmlui-mcp-client:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// mcpClientCommand prepares xmlui-mcp-client in mcpDir to start the server
+// with the docs and src trees, followed by extra.
+func mcpClientCommand(mcpDir, binDir string, extra []string) (*exec.Cmd, error) {
+	client, err := mcpBinary(binDir, "xmlui-mcp-client")
+	if err != nil {
+		return nil, err
+	}
+	server, err := mcpBinary(binDir, "xmlui-mcp")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{server, filepath.Join(mcpDir, "docs"), filepath.Join(mcpDir, "src")}
+	for _, d := range args[1:] {
+		if _, err := os.Stat(d); err != nil {
+			return nil, fmt.Errorf("the MCP server's %s directory is missing; run xmlui-bundler update", filepath.Base(d))
+		}
+	}
+	cmd := installedCommand(context.Background(), client, append(args, extra...)...)
+	cmd.Dir = mcpDir
+	return cmd, nil
+}
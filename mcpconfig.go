@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mcpServerName is the key of our entry in MCP client configs.
+const mcpServerName = "xmlui"
+
+// mcpClientConfig describes one MCP client's config file.
+type mcpClientConfig struct {
+	// flag is the NAME of --configure-NAME.
+	flag string
+	name string
+	// path is the config file for an install in installDir.
+	path func(installDir string) (string, error)
+	// key is the object in the file that maps server names to entries.
+	key string
+	// typed clients want "type": "stdio" in the entry.
+	typed bool
+}
+
+// mcpServerEntry is our server's entry in a client config.
+type mcpServerEntry struct {
+	Type    string   `json:"type,omitempty"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+var mcpClientConfigs = []mcpClientConfig{
+	{
+		flag: "claude", name: "Claude Desktop", key: "mcpServers",
+		path: func(string) (string, error) {
+			dir, err := hostOS.appDataDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(dir, "Claude", "claude_desktop_config.json"), nil
+		},
+	},
+	{
+		flag: "cursor", name: "Cursor", key: "mcpServers",
+		path: func(string) (string, error) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(home, ".cursor", "mcp.json"), nil
+		},
+	},
+	{
+		// The workspace config, so the server is there when the install
+		// dir is opened in VS Code.
+		flag: "vscode", name: "VS Code", key: "servers", typed: true,
+		path: func(installDir string) (string, error) {
+			return filepath.Join(installDir, ".vscode", "mcp.json"), nil
+		},
+	},
+}
+
+// jsonObject is a JSON object that keeps its keys in order and its values
+// as they were, so merging one entry leaves the rest of a user's file as it
+// was written.
+type jsonObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return errors.New("not a JSON object")
+	}
+	o.keys, o.values = nil, map[string]json.RawMessage{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := t.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if _, dup := o.values[key]; !dup {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = v
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(o.values[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o *jsonObject) set(key string, v json.RawMessage) {
+	if o.values == nil {
+		o.values = map[string]json.RawMessage{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// configureMCPClient adds or updates the xmlui entry in c's config file to
+// run the server in mcpDir. Everything else in the file, including other
+// servers, is kept; the original is backed up next to it and the change
+// shown as a diff. A file that isn't plain JSON (JSONC comments, say) is
+// left alone, with the entry printed to add by hand.
+func configureMCPClient(c mcpClientConfig, installDir, mcpDir, binDir string) error {
+	path, err := c.path(installDir)
+	if err != nil {
+		return err
+	}
+	server, err := mcpBinary(binDir, "xmlui-mcp")
+	if err != nil {
+		return err
+	}
+	entry := mcpEntry(c, server, mcpDir)
+
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated, err := mergeMCPEntry(c, path, original, entry)
+	if err != nil {
+		printManualEntry(c, path, entry)
+		return err
+	}
+	if bytes.Equal(updated, original) {
+		fmt.Printf("%s %s already runs this install's MCP server (%s)\n", glyphOK, c.name, path)
+		return nil
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return err
+	}
+	var backup string
+	if original != nil {
+		// The config may hold API keys, so the backup is no more readable
+		// than the original.
+		mode := fileMode
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		backup = fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+		if err := fsys.WriteFile(backup, original, mode); err != nil {
+			return fmt.Errorf("could not back up %s: %w", path, err)
+		}
+	}
+	if err := fsys.WriteFile(path, updated, fileMode); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s Configured %s: %s\n", glyphOK, c.name, path)
+	if backup != "" {
+		fmt.Printf("  Backed up the original to %s\n", backup)
+		// Compared as re-indented JSON, so only real changes show.
+		var before bytes.Buffer
+		if json.Indent(&before, original, "", "  ") != nil {
+			before.Write(original)
+		}
+		printDiff(before.String(), string(updated))
+	}
+	return nil
+}
+
+// mcpEntry is c's entry for running server on the docs and source in mcpDir.
+func mcpEntry(c mcpClientConfig, server, mcpDir string) json.RawMessage {
+	e := mcpServerEntry{Command: server, Args: []string{filepath.Join(mcpDir, "docs"), filepath.Join(mcpDir, "src")}}
+	if c.typed {
+		e.Type = "stdio"
+	}
+	entry, _ := json.Marshal(e)
+	return entry
+}
+
+// mergeMCPEntry returns the config file at path, whose contents are
+// original, with entry as its xmlui server and everything else as it was, or
+// original itself if it already has the entry. It fails for a file that
+// isn't plain JSON, which is left alone.
+func mergeMCPEntry(c mcpClientConfig, path string, original []byte, entry json.RawMessage) ([]byte, error) {
+	var root jsonObject
+	if len(bytes.TrimSpace(original)) > 0 {
+		if err := json.Unmarshal(original, &root); err != nil {
+			return nil, fmt.Errorf("%s is not plain JSON (%v), so it was left alone", path, err)
+		}
+	}
+	var servers jsonObject
+	if raw, ok := root.values[c.key]; ok {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return nil, fmt.Errorf("%q in %s is not an object, so the file was left alone", c.key, path)
+		}
+	}
+	if old, ok := servers.values[mcpServerName]; ok && jsonEqual(old, entry) {
+		return original, nil
+	}
+	servers.set(mcpServerName, entry)
+	merged, _ := json.Marshal(servers)
+	root.set(c.key, merged)
+	updated, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(updated, '\n'), nil
+}
+
+// printManualEntry shows the entry to add for a config file we won't edit.
+func printManualEntry(c mcpClientConfig, path string, entry json.RawMessage) {
+	var buf bytes.Buffer
+	json.Indent(&buf, entry, "    ", "  ")
+	fmt.Printf("  To use the XMLUI MCP server in %s, add this under %q in %s:\n    %q: %s\n", c.name, c.key, path, mcpServerName, buf.String())
+}
+
+// jsonEqual reports whether a and b are the same JSON value.
+func jsonEqual(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+// printDiff prints the lines that differ between before and after, with a
+// line of context around each change.
+func printDiff(before, after string) {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+	// Longest common subsequence, from the end.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			lines = append(lines, line{'+', b[j]})
+			j++
+		default:
+			lines = append(lines, line{'-', a[i]})
+			i++
+		}
+	}
+	const context = 1
+	near := func(k int) bool {
+		for d := -context; d <= context; d++ {
+			if n := k + d; n >= 0 && n < len(lines) && lines[n].op != ' ' {
+				return true
+			}
+		}
+		return false
+	}
+	gap := false
+	for k, l := range lines {
+		if !near(k) {
+			gap = true
+			continue
+		}
+		if gap {
+			fmt.Println("    ...")
+			gap = false
+		}
+		fmt.Printf("  %c %s\n", l.op, l.text)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// mcpIndexFile is the prebuilt search index `mcp index` writes into the mcp
+// directory, next to the docs and src trees it covers.
+const mcpIndexFile = "search-index.json"
+
+// indexedExts are the text files worth indexing in the knowledge base.
+var indexedExts = map[string]bool{
+	".md": true, ".mdx": true, ".xmlui": true, ".ts": true, ".tsx": true,
+	".js": true, ".jsx": true, ".json": true, ".scss": true, ".css": true,
+}
+
+// searchIndex is a plain inverted index: Terms maps each lower-cased word to
+// the documents containing it and how often.
+type searchIndex struct {
+	Version     int                  `json:"version"`
+	GeneratedAt time.Time            `json:"generatedAt"`
+	Documents   []indexedDocument    `json:"documents"`
+	Terms       map[string][]posting `json:"terms"`
+}
+
+type indexedDocument struct {
+	// Path is relative to the mcp directory, with forward slashes.
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	Words int    `json:"words"`
+}
+
+// posting is a document number and the term's count in it.
+type posting [2]int
+
+// buildSearchIndex indexes the docs and src trees under mcpDir.
+func buildSearchIndex(mcpDir string) (*searchIndex, error) {
+	idx := &searchIndex{Version: 1, GeneratedAt: time.Now().UTC(), Terms: map[string][]posting{}}
+	for _, tree := range []string{"docs", "src"} {
+		root := filepath.Join(mcpDir, tree)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !indexedExts[strings.ToLower(filepath.Ext(path))] {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(mcpDir, path)
+			if err != nil {
+				return err
+			}
+			counts := map[string]int{}
+			words := 0
+			for _, t := range tokenize(string(data)) {
+				counts[t]++
+				words++
+			}
+			n := len(idx.Documents)
+			idx.Documents = append(idx.Documents, indexedDocument{Path: filepath.ToSlash(rel), Title: documentTitle(path, data), Words: words})
+			for t, c := range counts {
+				idx.Terms[t] = append(idx.Terms[t], posting{n, c})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// tokenize splits s into lower-cased words of two or more letters or digits;
+// camelCase identifiers also yield their parts, so "DropdownMenu" is found
+// by "dropdown" and "menu".
+func tokenize(s string) []string {
+	var out []string
+	words := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for _, w := range words {
+		if len(w) < 2 {
+			continue
+		}
+		lower := strings.ToLower(w)
+		out = append(out, lower)
+		if parts := camelParts(w); len(parts) > 1 {
+			for _, p := range parts {
+				if len(p) >= 2 {
+					out = append(out, strings.ToLower(p))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func camelParts(w string) []string {
+	var parts []string
+	start := 0
+	r := []rune(w)
+	for i := 1; i < len(r); i++ {
+		if unicode.IsUpper(r[i]) && (unicode.IsLower(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			parts = append(parts, string(r[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(r[start:]))
+}
+
+// documentTitle is a Markdown file's first heading, else its base name.
+func documentTitle(path string, data []byte) string {
+	ext := filepath.Ext(path)
+	if ext == ".md" || ext == ".mdx" {
+		for _, line := range strings.SplitN(string(data), "\n", 50) {
+			if t, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+				return strings.TrimSpace(t)
+			}
+		}
+	}
+	return strings.TrimSuffix(filepath.Base(path), ext)
+}
+
+func (idx *searchIndex) write(mcpDir string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(filepath.Join(mcpDir, mcpIndexFile), data, fileMode)
+}
+
+func readSearchIndex(mcpDir string) (*searchIndex, error) {
+	data, err := os.ReadFile(filepath.Join(mcpDir, mcpIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var idx searchIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("%s: %w", mcpIndexFile, err)
+	}
+	return &idx, nil
+}
+
+// searchResult is a document and its score for a query.
+type searchResult struct {
+	doc   indexedDocument
+	score float64
+}
+
+// search ranks documents by the tf-idf of the query's words, boosting those
+// whose title contains one.
+func (idx *searchIndex) search(query string, limit int) []searchResult {
+	scores := map[int]float64{}
+	n := float64(len(idx.Documents))
+	for _, t := range tokenize(query) {
+		postings := idx.Terms[t]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + n/float64(len(postings)))
+		for _, p := range postings {
+			doc := idx.Documents[p[0]]
+			tf := float64(p[1]) / float64(max(doc.Words, 1))
+			scores[p[0]] += tf * idf
+			if strings.Contains(strings.ToLower(doc.Title), t) {
+				scores[p[0]] += idf
+			}
+		}
+	}
+	results := make([]searchResult, 0, len(scores))
+	for d, s := range scores {
+		results = append(results, searchResult{idx.Documents[d], s})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].doc.Path < results[j].doc.Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// refreshSearchIndex rebuilds mcpDir's index if an earlier `mcp index` made
+// one, so update never leaves it describing files that changed.
+func refreshSearchIndex(mcpDir string) error {
+	if _, err := os.Stat(filepath.Join(mcpDir, mcpIndexFile)); err != nil {
+		return nil
+	}
+	idx, err := buildSearchIndex(mcpDir)
+	if err != nil {
+		return err
+	}
+	// Rolling back the update puts the old index back with the old trees.
+	if err := journal.preserve(filepath.Join(mcpDir, mcpIndexFile)); err != nil {
+		return err
+	}
+	if err := idx.write(mcpDir); err != nil {
+		return err
+	}
+	fmt.Printf("  Refreshed %s (%d documents)\n", mcpIndexFile, len(idx.Documents))
+	return nil
+}
+
+// runMCPIndex implements `mcp index`: it builds a search index of the
+// installed docs and source that xmlui-mcp (or any other tool) can load
+// rather than scanning the trees at startup, or with --query searches it.
+func runMCPIndex(args []string) int {
+	fs := newFlagSet("mcp index")
+	dir := installDirFlag(fs)
+	query := fs.String("query", "", "search the existing index instead of building it")
+	limit := fs.Int("limit", 10, "with --query, how many results to show")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	mcpDir := toolsDirOf(installDir)
+
+	if *query != "" {
+		idx, err := readSearchIndex(mcpDir)
+		if err != nil {
+			fmt.Println("No search index (run `xmlui-bundler mcp index` first):", err)
+			return exitFailure
+		}
+		results := idx.search(*query, *limit)
+		if len(results) == 0 {
+			fmt.Printf("Nothing matches %q\n", *query)
+			return exitFailure
+		}
+		for _, r := range results {
+			fmt.Printf("%6.3f  %-30s %s\n", r.score, r.doc.Title, r.doc.Path)
+		}
+		return exitOK
+	}
+
+	started := time.Now()
+	idx, err := buildSearchIndex(mcpDir)
+	if err != nil {
+		fmt.Println("Failed to index the MCP knowledge base:", err)
+		return exitFailure
+	}
+	if len(idx.Documents) == 0 {
+		fmt.Printf("Nothing to index in %s; run xmlui-bundler update\n", mcpDir)
+		return exitFailure
+	}
+	if err := idx.write(mcpDir); err != nil {
+		fmt.Println("Failed to write the search index:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Indexed %d documents, %d terms in %v\n", glyphOK, len(idx.Documents), len(idx.Terms), time.Since(started).Round(time.Millisecond))
+	fmt.Printf("  Wrote %s; update keeps it current\n", filepath.Join(mcpDir, mcpIndexFile))
+	return exitOK
+}
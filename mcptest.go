@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mcpProtocolVersion is the MCP revision the smoke test speaks.
+const mcpProtocolVersion = "2024-11-05"
+
+// runMCP implements the `mcp` command group.
+func runMCP(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler mcp test [--dir DIR] [--query TEXT] [-- server args...]")
+		fmt.Println("       xmlui-bundler mcp client [--dir DIR] [-- client args...]")
+		fmt.Println("       xmlui-bundler mcp index [--dir DIR] [--query TEXT]")
+		fmt.Println("       xmlui-bundler mcp prepare [--dir DIR]")
+		return exitUsage
+	}
+	switch args[0] {
+	case "test":
+		return runMCPTest(args[1:])
+	case "client":
+		return runMCPClient(args[1:])
+	case "index":
+		return runMCPIndex(args[1:])
+	case "prepare":
+		return runMCPPrepare(args[1:])
+	default:
+		fmt.Printf("Unknown mcp command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// runMCPTest implements `mcp test`: it starts the installed xmlui-mcp over
+// stdio, performs the initialize handshake and calls its search tool.
+func runMCPTest(args []string) int {
+	fs := newFlagSet("mcp test")
+	dir := installDirFlag(fs)
+	query := fs.String("query", "Button", "text to search the component docs for")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long the whole test may take")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	if err := testMCP(toolsDirOf(installDir), binDirOf(installDir, "mcp"), fs.Args(), *query, *timeout); err != nil {
+		fmt.Println(glyphFail, "MCP smoke test failed:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// mcpBinary returns the installed tool name (xmlui-mcp or xmlui-mcp-client)
+// in mcpDir: the native binary, or the dispatch script of an
+// --all-platforms install.
+func mcpBinary(mcpDir, name string) (string, error) {
+	for _, n := range hostOS.executableNames(name) {
+		p := filepath.Join(mcpDir, n)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not installed in %s", name, mcpDir)
+}
+
+// testMCP runs the smoke test against the server in mcpDir, printing each
+// step. serverArgs are passed to xmlui-mcp.
+func testMCP(mcpDir, binDir string, serverArgs []string, query string, timeout time.Duration) error {
+	bin, err := mcpBinary(binDir, "xmlui-mcp")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s, err := startMCPSession(ctx, bin, mcpDir, serverArgs)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	fail := func(step string, err error) error {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("no answer within %v", timeout)
+		}
+		if tail := strings.TrimSpace(s.stderr.String()); tail != "" {
+			return fmt.Errorf("%s: %w\n  server stderr:\n%s", step, err, tail)
+		}
+		return fmt.Errorf("%s: %w", step, err)
+	}
+
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name, Version string
+		} `json:"serverInfo"`
+	}
+	err = s.call("initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": launcherName, "version": version},
+	}, &init)
+	if err != nil {
+		return fail("initialize", err)
+	}
+	if err := s.notify("notifications/initialized"); err != nil {
+		return fail("initialize", err)
+	}
+	fmt.Printf("  %s initialize: %s %s (protocol %s)\n", glyphOK, init.ServerInfo.Name, init.ServerInfo.Version, init.ProtocolVersion)
+
+	var list struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			InputSchema struct {
+				Properties map[string]struct {
+					Type string `json:"type"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := s.call("tools/list", map[string]any{}, &list); err != nil {
+		return fail("tools/list", err)
+	}
+	var names []string
+	for _, t := range list.Tools {
+		names = append(names, t.Name)
+	}
+	fmt.Printf("  %s tools/list: %s\n", glyphOK, strings.Join(names, ", "))
+
+	// Search with the tool's first string argument, preferring a required
+	// one, since the schema is all we know about it.
+	tool, arg := "", ""
+	for _, t := range list.Tools {
+		if !strings.Contains(t.Name, "search") {
+			continue
+		}
+		candidates := append([]string{}, t.InputSchema.Required...)
+		var rest []string
+		for p := range t.InputSchema.Properties {
+			rest = append(rest, p)
+		}
+		sort.Strings(rest)
+		for _, p := range append(candidates, rest...) {
+			if t.InputSchema.Properties[p].Type == "string" {
+				tool, arg = t.Name, p
+				break
+			}
+		}
+		if tool != "" {
+			break
+		}
+	}
+	if tool == "" {
+		return fail("tools/call", fmt.Errorf("no search tool with a string argument among %d tools", len(list.Tools)))
+	}
+
+	var result struct {
+		IsError bool `json:"isError"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := s.call("tools/call", map[string]any{"name": tool, "arguments": map[string]string{arg: query}}, &result); err != nil {
+		return fail("tools/call "+tool, err)
+	}
+	text := ""
+	for _, c := range result.Content {
+		text += c.Text
+	}
+	if result.IsError {
+		return fail("tools/call "+tool, fmt.Errorf("tool reported an error: %s", firstLine(text)))
+	}
+	if strings.TrimSpace(text) == "" {
+		return fail("tools/call "+tool, fmt.Errorf("empty result for %q", query))
+	}
+	fmt.Printf("  %s tools/call %s(%s=%q): %s\n", glyphOK, tool, arg, query, firstLine(text))
+	fmt.Println(glyphOK, "MCP server passed the smoke test")
+	return nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " " + glyphMore
+	}
+	if len(s) > 100 {
+		s = s[:100] + glyphMore
+	}
+	return s
+}
+
+// mcpSession is a JSON-RPC connection to an MCP server over its stdio,
+// one message per line.
+type mcpSession struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	stderr bytes.Buffer
+	nextID int
+}
+
+func startMCPSession(ctx context.Context, bin, dir string, args []string) (*mcpSession, error) {
+	s := &mcpSession{cmd: installedCommand(ctx, bin, args...)}
+	s.cmd.Dir = dir
+	s.cmd.Stderr = &s.stderr
+	// Don't let a grandchild holding stderr open stall Wait.
+	s.cmd.WaitDelay = 2 * time.Second
+	var err error
+	if s.in, err = s.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	s.out = bufio.NewReader(stdout)
+	if err := s.cmd.Start(); err != nil {
+		return nil, err
+	}
+	// Killing the server doesn't end a read if a child of it still holds
+	// stdout open, so close our end too.
+	go func() {
+		<-ctx.Done()
+		stdout.Close()
+	}()
+	return s, nil
+}
+
+func (s *mcpSession) send(msg map[string]any) error {
+	msg["jsonrpc"] = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.in.Write(append(data, '\n'))
+	return err
+}
+
+func (s *mcpSession) notify(method string) error {
+	return s.send(map[string]any{"method": method})
+}
+
+// call sends a request and decodes the matching response's result into
+// result, skipping notifications and requests from the server.
+func (s *mcpSession) call(method string, params, result any) error {
+	s.nextID++
+	id := s.nextID
+	if err := s.send(map[string]any{"id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+	for {
+		line, err := s.out.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("server closed its output")
+			}
+			return err
+		}
+		var resp struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("server wrote something that isn't JSON-RPC: %s", firstLine(string(line)))
+		}
+		if resp.ID == nil || *resp.ID != id || resp.Method != "" {
+			continue
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("error %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+func (s *mcpSession) close() {
+	s.in.Close()
+	done := make(chan struct{})
+	go func() {
+		s.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		s.cmd.Process.Kill()
+		<-done
+	}
+}
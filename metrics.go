@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyFile keeps download metrics of the last historyRuns runs in the
+// state dir, so slow or failing mirrors can be told apart from bad luck.
+const (
+	historyFile = "download-history.json"
+	historyRuns = 20
+)
+
+// requestRecord is what one request cost.
+type requestRecord struct {
+	URL     string `json:"url"`
+	Host    string `json:"host"`
+	Status  int    `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Retries int    `json:"retries,omitempty"`
+	// LatencyMs is the time until the response headers arrived, retries
+	// included.
+	LatencyMs int64 `json:"latencyMs"`
+	// Bytes and DurationMs cover the body, for downloads.
+	Bytes      int64 `json:"bytes,omitempty"`
+	DurationMs int64 `json:"durationMs,omitempty"`
+}
+
+type runRecord struct {
+	StartedAt time.Time        `json:"startedAt"`
+	Command   string           `json:"command"`
+	Requests  []*requestRecord `json:"requests"`
+}
+
+// metrics collects this run's requests.
+var metrics = &runMetrics{run: runRecord{StartedAt: time.Now().UTC()}}
+
+type runMetrics struct {
+	mu  sync.Mutex
+	run runRecord
+}
+
+// start records a request to rawURL and returns its record.
+func (m *runMetrics) start(rawURL string) *requestRecord {
+	rec := &requestRecord{URL: rawURL}
+	if u, err := url.Parse(rawURL); err == nil {
+		rec.Host = u.Host
+	}
+	m.mu.Lock()
+	m.run.Requests = append(m.run.Requests, rec)
+	m.mu.Unlock()
+	return rec
+}
+
+// last returns the newest record for rawURL, or a throwaway one.
+func (m *runMetrics) last(rawURL string) *requestRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.run.Requests) - 1; i >= 0; i-- {
+		if m.run.Requests[i].URL == rawURL {
+			return m.run.Requests[i]
+		}
+	}
+	return &requestRecord{}
+}
+
+// update changes a record under the lock, so saving never sees it torn.
+func (m *runMetrics) update(rec *requestRecord, f func(*requestRecord)) {
+	m.mu.Lock()
+	f(rec)
+	m.mu.Unlock()
+}
+
+// save appends this run to the history, keeping the last historyRuns runs.
+// Runs that made no requests are not recorded. Failures are ignored: the
+// history is only an aid.
+func (m *runMetrics) save(command string) {
+	m.mu.Lock()
+	run := m.run
+	run.Command = command
+	data, _ := json.Marshal(run)
+	m.mu.Unlock()
+	if len(run.Requests) == 0 {
+		return
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return
+	}
+	runs, _ := readHistory()
+	var copied runRecord
+	if json.Unmarshal(data, &copied) != nil {
+		return
+	}
+	runs = append(runs, copied)
+	if len(runs) > historyRuns {
+		runs = runs[len(runs)-historyRuns:]
+	}
+	out, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return
+	}
+	if fsys.MkdirAll(dir, dirMode) == nil {
+		fsys.WriteFile(filepath.Join(dir, historyFile), append(out, '\n'), fileMode)
+	}
+}
+
+func readHistory() ([]runRecord, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, historyFile))
+	if err != nil {
+		return nil, err
+	}
+	var runs []runRecord
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("%s: %w", historyFile, err)
+	}
+	return runs, nil
+}
+
+// runStats implements `stats`: it shows the recorded download history, run
+// by run and summed up per host.
+func runStats(args []string) int {
+	fs := newFlagSet("stats")
+	last := fs.Int("last", 5, "number of most recent runs to list (0 for all)")
+	asJSON := fs.Bool("json", false, "print the raw history as JSON")
+	fs.Parse(args)
+
+	runs, err := readHistory()
+	if os.IsNotExist(err) {
+		fmt.Println("No download history yet")
+		return exitOK
+	}
+	if err != nil {
+		fmt.Println("Could not read the download history:", err)
+		return exitFailure
+	}
+	if *last > 0 && len(runs) > *last {
+		runs = runs[len(runs)-*last:]
+	}
+	if *asJSON {
+		data, _ := json.MarshalIndent(runs, "", "  ")
+		fmt.Println(string(data))
+		return exitOK
+	}
+
+	type hostStats struct {
+		requests, failures, retries int
+		bytes, ms, latencyMs        int64
+	}
+	hosts := map[string]*hostStats{}
+	for _, run := range runs {
+		fmt.Printf("%s  %s\n", run.StartedAt.Local().Format("2006-01-02 15:04"), run.Command)
+		for _, r := range run.Requests {
+			size, rate := "", ""
+			if r.Bytes > 0 {
+				size, rate = humanBytes(r.Bytes), throughput(r.Bytes, r.DurationMs)
+			}
+			line := fmt.Sprintf("  %6dms %9s %11s  ", r.LatencyMs, size, rate)
+			var notes []string
+			if r.Retries > 0 {
+				notes = append(notes, fmt.Sprintf("%d retries", r.Retries))
+			}
+			if r.Error != "" {
+				notes = append(notes, glyphFail+" "+firstLine(r.Error))
+			}
+			if len(notes) > 0 {
+				line += strings.Join(notes, ", ") + "  "
+			}
+			line = console.fit(line, r.URL)
+			fmt.Println(line)
+
+			h := hosts[r.Host]
+			if h == nil {
+				h = &hostStats{}
+				hosts[r.Host] = h
+			}
+			h.requests++
+			h.retries += r.Retries
+			h.latencyMs += r.LatencyMs
+			h.bytes += r.Bytes
+			h.ms += r.DurationMs
+			if r.Error != "" {
+				h.failures++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(hosts))
+	for n := range hosts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Println("\nBy host:")
+	for _, n := range names {
+		h := hosts[n]
+		parts := []string{
+			fmt.Sprintf("%d requests", h.requests),
+			fmt.Sprintf("avg latency %dms", h.latencyMs/int64(h.requests)),
+		}
+		if h.bytes > 0 && h.ms > 0 {
+			parts = append(parts, throughput(h.bytes, h.ms))
+		}
+		if h.retries > 0 {
+			parts = append(parts, fmt.Sprintf("%d retries", h.retries))
+		}
+		if h.failures > 0 {
+			parts = append(parts, fmt.Sprintf("%d failed", h.failures))
+		}
+		fmt.Printf("  %-28s %s\n", n, strings.Join(parts, ", "))
+	}
+	return exitOK
+}
+
+// throughput formats bytes over ms as a rate.
+func throughput(bytes, ms int64) string {
+	if ms <= 0 {
+		return "-"
+	}
+	return humanBytes(bytes*1000/ms) + "/s"
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Installs keep the layout of the launcher that made them until they are
+// migrated. The receipt records the layout's version, and the migrations
+// below bring an install up to layoutVersion one version at a time, so only
+// they need to know the old layouts. update migrates before anything else;
+// `migrate` does it on its own, or shows what it would do.
+//
+//	0  the original launcher's, which wrote no receipt: the app and test
+//	   server in xmlui-invoice/, the MCP tools, docs and source in mcp/, and
+//	   after an interrupted run its xmlui-source/ and mcpTmp/ staging dirs,
+//	   or docs/ and src/ not yet moved into mcp/
+//	1  a receipt without file hashes, so update can't tell stale files from
+//	   the user's and doctor has nothing to check them against
+//	2  file hashes for every component
+const layoutVersion = 2
+
+// Dirs the original launcher staged downloads in, inside the install dir,
+// with what each could hold: the XMLUI snapshot's root dir, or the MCP
+// release's files not yet moved into mcp/.
+var legacyStagingDirs = []struct {
+	name  string
+	entry func(e fs.DirEntry) bool
+}{
+	{"xmlui-source", func(e fs.DirEntry) bool {
+		return e.IsDir() && strings.HasPrefix(e.Name(), "xmlui-")
+	}},
+	{"mcpTmp", func(e fs.DirEntry) bool {
+		switch strings.TrimSuffix(e.Name(), ".exe") {
+		case "xmlui-mcp", "xmlui-mcp-client", "prepare-binaries.sh", "run-mcp-client.sh", "run-mcp-client.bat":
+			return e.Type().IsRegular()
+		}
+		return false
+	}},
+}
+
+// isLegacyStaging reports whether dir holds nothing but what the original
+// launcher staged there, as entry tells, so removing it loses nothing of
+// the user's.
+func isLegacyStaging(dir string, entry func(e fs.DirEntry) bool) bool {
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if !entry(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// migration upgrades an install of layout to-1 to layout to.
+type migration struct {
+	to   int
+	what string
+	run  func(m *migrator) error
+}
+
+var migrations = []migration{
+	{1, "reconstruct the receipt of an install made before receipts", reconstructReceipt},
+	{2, "record the hashes of the installed files", hashLegacyFiles},
+}
+
+// migrator is the state of one install's migration.
+type migrator struct {
+	installDir string
+	rcpt       *receipt
+	dryRun     bool
+}
+
+// did reports a change the migration made, or would make.
+func (m *migrator) did(format string, args ...any) {
+	if m.dryRun {
+		format = "Would " + format
+	}
+	fmt.Printf("    "+format+"\n", args...)
+}
+
+// installLayout reads installDir's receipt and tells its layout version: 1
+// for receipts from before the version was recorded, 0 with no receipt for
+// an original launcher's install. Without a receipt or such an install it
+// returns readReceipt's error.
+func installLayout(installDir string) (*receipt, int, error) {
+	rcpt, err := readReceipt(installDir)
+	if err == nil {
+		if rcpt.Layout == 0 {
+			return rcpt, 1, nil
+		}
+		return rcpt, rcpt.Layout, nil
+	}
+	if errors.Is(err, os.ErrNotExist) && isLegacyInstall(installDir) {
+		return nil, 0, nil
+	}
+	return nil, 0, err
+}
+
+// isLegacyInstall reports whether installDir holds an original launcher's
+// install: its MCP tools in mcp/, or its app and component docs.
+func isLegacyInstall(installDir string) bool {
+	mcpDir := filepath.Join(installDir, "mcp")
+	if _, err := mcpBinary(mcpDir, "xmlui-mcp"); err == nil {
+		return true
+	}
+	for _, dir := range []string{filepath.Join(mcpDir, "docs"), filepath.Join(installDir, "docs")} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if info, err := os.Stat(filepath.Join(installDir, repoName)); err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// migrateInstall brings the install in installDir up to layoutVersion and
+// returns its receipt, which it writes unless dryRun is set. An install that
+// is already current is returned as it is.
+func migrateInstall(installDir string, dryRun bool) (*receipt, error) {
+	rcpt, from, err := installLayout(installDir)
+	if err != nil {
+		return nil, err
+	}
+	if from >= layoutVersion {
+		return rcpt, nil
+	}
+	if from == 0 {
+		fmt.Printf("%s was installed by an older launcher, without a receipt\n", installDir)
+	}
+	m := &migrator{installDir: installDir, rcpt: rcpt, dryRun: dryRun}
+	for _, mg := range migrations {
+		if mg.to <= from {
+			continue
+		}
+		fmt.Printf("  Layout %d to %d: %s\n", mg.to-1, mg.to, mg.what)
+		if err := mg.run(m); err != nil {
+			return nil, fmt.Errorf("migrating to layout %d: %w", mg.to, err)
+		}
+	}
+	m.rcpt.Layout = layoutVersion
+	if dryRun {
+		return m.rcpt, nil
+	}
+	if err := m.rcpt.write(installDir); err != nil {
+		return nil, fmt.Errorf("writing the migrated receipt: %w", err)
+	}
+	fmt.Printf("%s Migrated %s to layout %d\n", glyphOK, installDir, layoutVersion)
+	return m.rcpt, nil
+}
+
+// reconstructReceipt tidies an original launcher's install and writes a
+// receipt of what it finds: the app, components, MCP tools and test server
+// at the default sources, with the binaries that are there. It can't know
+// what was downloaded, so the receipt records no downloads or versions
+// until the next update.
+func reconstructReceipt(m *migrator) error {
+	for _, staging := range legacyStagingDirs {
+		name := staging.name
+		dir := filepath.Join(m.installDir, name)
+		if _, err := os.Lstat(dir); err != nil {
+			continue
+		}
+		if isLegacyStaging(dir, staging.entry) {
+			m.did("remove the leftover staging dir %s/", name)
+			if !m.dryRun {
+				if err := fsys.RemoveAll(dir); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		// Named like a staging dir but holding something else: the
+		// user's, perhaps, so it is kept under another name.
+		aside := fmt.Sprintf("%s.%s.bak", name, time.Now().Format("20060102-150405"))
+		m.did("move %s/ aside to %s/, as it holds more than the old launcher's downloads", name, aside)
+		if !m.dryRun {
+			if err := movePath(dir, filepath.Join(m.installDir, aside)); err != nil {
+				return err
+			}
+		}
+	}
+	mcpDir := filepath.Join(m.installDir, "mcp")
+	for _, name := range []string{"docs", "src"} {
+		src, dst := filepath.Join(m.installDir, name), filepath.Join(mcpDir, name)
+		if info, err := os.Stat(src); err != nil || !info.IsDir() {
+			continue
+		}
+		if _, err := os.Lstat(dst); err == nil {
+			warn("Leaving %s/ alone: mcp/%s/ exists as well", name, name)
+			continue
+		}
+		m.did("move %s/ into mcp/", name)
+		if !m.dryRun {
+			if err := fsys.MkdirAll(mcpDir, dirMode); err != nil {
+				return err
+			}
+			if err := movePath(src, dst); err != nil {
+				return err
+			}
+		}
+	}
+
+	rcpt := newReceipt()
+	if info, err := os.Stat(mcpDir); err == nil {
+		rcpt.InstalledAt = info.ModTime().UTC()
+	}
+	host := platform{rcpt.OS, rcpt.Arch}
+	hasDir := func(p string) bool {
+		info, err := os.Stat(p)
+		return err == nil && info.IsDir()
+	}
+	appDir := filepath.Join(m.installDir, repoName)
+	if hasDir(appDir) {
+		source := defaultAppSource
+		if src, err := parseRepoSource(defaultAppSource, branchName, ""); err == nil {
+			source = src.URL
+		}
+		rcpt.component("app", source)
+		rcpt.AppDir = repoName
+		m.did("record the app in %s/", repoName)
+	}
+	if hasDir(filepath.Join(mcpDir, "docs")) || hasDir(filepath.Join(m.installDir, "docs")) {
+		rcpt.component("components", xmluiComponentsURL)
+		m.did("record the XMLUI component docs and source in mcp/")
+	}
+	for _, t := range []struct {
+		name, label, dir string
+		binaries         []string
+	}{
+		{"mcp", "the MCP tools", mcpDir, []string{"xmlui-mcp", "xmlui-mcp-client"}},
+		{"server", "the test server", appDir, []string{"xmlui-test-server"}},
+	} {
+		source, _ := assetURL(t.name, host)
+		c := rcpt.component(t.name, source)
+		for _, name := range t.binaries {
+			p, err := mcpBinary(t.dir, name)
+			if err != nil {
+				continue
+			}
+			sum, _ := hashFile(p)
+			c.Binaries = append(c.Binaries, receiptBinary{Path: receiptKey(m.installDir, p), Version: "unchecked", SHA256: sum})
+		}
+		if len(c.Binaries) == 0 {
+			c.Unavailable = "not found by the migration"
+			continue
+		}
+		m.did("record %s in %s/", t.label, receiptKey(m.installDir, t.dir))
+	}
+	if len(rcpt.Components) == 0 {
+		return fmt.Errorf("%s has nothing a launcher installed", m.installDir)
+	}
+	m.rcpt = rcpt
+	return nil
+}
+
+// hashLegacyFiles records the files of each component the receipt lists
+// without them, from where the component's files live: the docs and source
+// in the tools dir, and the tools' and server's binaries and scripts. The
+// app's are left for update to record, as the user's own files in the app
+// dir can't be told from the app's, and update removes recorded files the
+// app no longer has.
+func hashLegacyFiles(m *migrator) error {
+	rcpt := m.rcpt
+	toolsDir := filepath.Join(m.installDir, rcpt.toolsDir())
+	var scripts []string
+	if hostOS.goos() == "windows" {
+		scripts = []string{"run-mcp-client.bat"}
+	} else {
+		scripts = []string{"prepare-binaries.sh", "run-mcp-client.sh"}
+	}
+	for i := range rcpt.Components {
+		c := &rcpt.Components[i]
+		if len(c.Files) > 0 || c.Unavailable != "" {
+			continue
+		}
+		files := map[string]string{}
+		var err error
+		switch c.Name {
+		case "components":
+			for _, name := range []string{"docs", "src"} {
+				dir := filepath.Join(toolsDir, name)
+				if _, serr := os.Stat(dir); serr != nil {
+					continue
+				}
+				var tree map[string]string
+				if tree, err = hashTree(dir, m.installDir); err != nil {
+					break
+				}
+				files = mergeHashes(files, tree)
+			}
+		case "mcp", "server":
+			paths := []string{}
+			for _, b := range c.Binaries {
+				paths = append(paths, filepath.Join(m.installDir, filepath.FromSlash(b.Path)))
+			}
+			if c.Name == "mcp" && !rcpt.NoScripts {
+				for _, name := range scripts {
+					paths = append(paths, filepath.Join(toolsDir, name))
+				}
+			}
+			for _, p := range paths {
+				if h, herr := hashFile(p); herr == nil {
+					files[receiptKey(m.installDir, p)] = h
+				}
+			}
+		default:
+			// The app's are left for update, and bundles and seed data
+			// have always been recorded with their files.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if len(files) > 0 {
+			c.Files = files
+			m.did("record %d %s files", len(files), c.Name)
+		}
+	}
+	return nil
+}
+
+// runMigrate implements `migrate`.
+func runMigrate(args []string) int {
+	fs := newFlagSet("migrate")
+	dir := installDirFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "show what the migration would change without changing anything")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	_, from, err := installLayout(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitConfig
+	}
+	if from >= layoutVersion {
+		fmt.Printf("%s %s already has layout %d\n", glyphOK, installDir, from)
+		return exitOK
+	}
+	if _, err := migrateInstall(installDir, *dryRun); err != nil {
+		fmt.Println("Failed to migrate the install:", err)
+		return exitCodeOf(err)
+	}
+	if *dryRun {
+		fmt.Printf("Run without --dry-run to migrate %s to layout %d\n", installDir, layoutVersion)
+		return exitOK
+	}
+	if from == 0 {
+		fmt.Println("  Run `xmlui-bundler update` to replace what the old launcher installed and record its downloads")
+	}
+	return exitOK
+}
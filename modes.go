@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// dirMode and fileMode are the permissions the bundler creates directories
+// and files with; the umask still applies. Executables get execMode.
+var (
+	dirMode  os.FileMode = 0755
+	fileMode os.FileMode = 0644
+)
+
+// execMode is fileMode with execute permission wherever it grants read.
+func execMode() os.FileMode {
+	return fileMode | (fileMode&0444)>>2
+}
+
+// chmodExec makes path executable. Chmod, unlike creating a file, ignores
+// the umask, so it is applied here. Where the filesystem can't change modes
+// it falls back to sandboxExec.
+func chmodExec(path string) error {
+	if !installFS.chmod {
+		return sandboxExec(path)
+	}
+	if err := fsys.Chmod(path, execMode()&^umask); err != nil {
+		return sandboxExec(path)
+	}
+	return nil
+}
+
+// modeFlag is a flag.Value for an octal permission such as 0750. The owner
+// must keep full access, or later steps (and updates) couldn't manage the
+// tree.
+type modeFlag struct {
+	mode *os.FileMode
+	need os.FileMode
+}
+
+func (f modeFlag) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#o", *f.mode)
+}
+
+func (f modeFlag) Set(s string) error {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0777 {
+		return fmt.Errorf("%q is not an octal permission like 0755", s)
+	}
+	m := os.FileMode(v)
+	if m&f.need != f.need {
+		return fmt.Errorf("%#o must include %#o for the owner", m, f.need)
+	}
+	if m&0002 != 0 {
+		fmt.Printf("%s %#o makes the install writable by every user on this machine\n", labelWarning, m)
+	}
+	*f.mode = m
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// umask is the process umask, read once at startup.
+var umask = func() os.FileMode {
+	m := syscall.Umask(0)
+	syscall.Umask(m)
+	return os.FileMode(m)
+}()
+
+// clearQuarantine removes macOS's com.apple.quarantine attribute from path,
+// which makes Gatekeeper kill a downloaded binary on first run. Elsewhere
+// there is nothing to clear.
+func clearQuarantine(path string) error {
+	if hostOS.goos() != "darwin" {
+		return nil
+	}
+	if !quarantined(path) {
+		// Not set (or unreadable, in which case removing fails anyway).
+		return nil
+	}
+	return unix.Removexattr(path, quarantineAttr)
+}
+
+// quarantined reports whether path carries macOS's quarantine attribute.
+func quarantined(path string) bool {
+	if hostOS.goos() != "darwin" {
+		return false
+	}
+	_, err := unix.Getxattr(path, quarantineAttr, nil)
+	return err == nil
+}
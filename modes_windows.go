@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// umask is always zero on Windows, where permissions come from ACLs.
+const umask os.FileMode = 0
+
+// clearQuarantine removes the mark of the web (the Zone.Identifier stream)
+// from path, so SmartScreen doesn't stop a downloaded binary.
+func clearQuarantine(path string) error {
+	unblockFile(path)
+	return nil
+}
+
+// quarantined reports whether path carries the mark of the web, e.g. from
+// being unpacked by Explorer out of a downloaded zip.
+func quarantined(path string) bool {
+	_, err := os.Stat(path + ":Zone.Identifier")
+	return err == nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// movePath renames src to dst, falling back to copy-and-delete when they
+// are on different filesystems (e.g. staging under os.TempDir()).
+func movePath(src, dst string) error {
+	err := fsys.Rename(src, dst)
+	if err == nil || !hostOS.crossDevice(err) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		fsys.RemoveAll(dst)
+		return err
+	}
+	return fsys.RemoveAll(src)
+}
+
+// copyTree copies a file or directory tree, preserving file modes.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return fsys.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fsys.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// disposableExecutable returns the running executable and reports whether
+// --ephemeral may delete it: only a copy in the system temp dir, such as a
+// curl | sh bootstrap's, or a go run build. One installed by a package
+// manager or kept in the user's own bin dir stays.
+func disposableExecutable() (string, bool) {
+	exe, err := os.Executable()
+	if err != nil {
+		return os.Args[0], false
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	tmp := os.TempDir()
+	if resolved, err := filepath.EvalSymlinks(tmp); err == nil {
+		tmp = resolved
+	}
+	if rel, err := filepath.Rel(tmp, exe); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return exe, true
+	}
+	// go run builds under $GOTMPDIR/go-build*/.../exe/.
+	for dir := filepath.Dir(exe); filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		if strings.HasPrefix(filepath.Base(dir), "go-build") {
+			return exe, true
+		}
+	}
+	return exe, false
+}
+
+// selfDelete removes the running executable, or where it can't be while it
+// runs, has a detached command remove it once we exit.
+func selfDelete() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if argv := hostOS.selfDeleteCommand(exe); argv != nil {
+		return exec.Command(argv[0], argv[1:]...).Start()
+	}
+	return fsys.Remove(exe)
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// desktopNotify shows a notification in the user's desktop session: with
+// osascript on macOS, and notify-send elsewhere.
+func desktopNotify(title, body string) error {
+	if hostOS.goos() == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(body), appleScriptQuote(title))
+		return runServiceTool("osascript", "-e", script)
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" && os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return errors.New("no desktop session")
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return errors.New("notify-send is not installed")
+	}
+	return runServiceTool("notify-send", "--app-name=xmlui-bundler", title, body)
+}
+
+// appleScriptQuote quotes s as an AppleScript string.
+func appleScriptQuote(s string) string {
+	r := []rune{'"'}
+	for _, c := range s {
+		if c == '"' || c == '\\' {
+			r = append(r, '\\')
+		}
+		r = append(r, c)
+	}
+	return string(append(r, '"'))
+}
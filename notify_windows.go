@@ -0,0 +1,29 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// desktopNotify shows a balloon notification from the notification area,
+// which every edition of Windows has, by way of PowerShell.
+func desktopNotify(title, body string) error {
+	script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; `+
+		`$n = New-Object System.Windows.Forms.NotifyIcon; `+
+		`$n.Icon = [System.Drawing.SystemIcons]::Information; `+
+		`$n.Visible = $true; `+
+		`$n.ShowBalloonTip(10000, %s, %s, 'Info'); `+
+		`Start-Sleep -Seconds 10; $n.Dispose()`,
+		psQuote(title), psQuote(body))
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --xmlui-npm installs the XMLUI standalone build from the npm registry
+// into the app's xmluiNPMDir instead of using the copy in the app repo. The
+// spec is a dist-tag (latest, next), an exact version, or a version prefix
+// such as 0.9, which takes the newest matching release.
+const (
+	xmluiNPMPackage    = "xmlui"
+	xmluiNPMComponent  = "xmlui-npm"
+	defaultNPMRegistry = "https://registry.npmjs.org"
+	// xmluiNPMDir is where the assets go, relative to the app dir.
+	xmluiNPMDir = "lib/xmlui"
+	// xmluiNPMAssets is the directory of the package tarball they come from.
+	xmluiNPMAssets = "dist/standalone"
+)
+
+// npmRegistry is the registry --xmlui-npm resolves against: npm's own
+// $npm_config_registry if set, so mirrors work as they do for npm.
+func npmRegistry() string {
+	if r := os.Getenv("npm_config_registry"); r != "" {
+		return strings.TrimSuffix(r, "/")
+	}
+	return defaultNPMRegistry
+}
+
+// npmPackument is the part of a registry package document we use.
+type npmPackument struct {
+	DistTags map[string]string `json:"dist-tags"`
+	Versions map[string]struct {
+		Dist struct {
+			Tarball   string `json:"tarball"`
+			Integrity string `json:"integrity"`
+		} `json:"dist"`
+	} `json:"versions"`
+}
+
+// npmRelease is a package version resolved from a spec.
+type npmRelease struct {
+	Version, Tarball, Integrity string
+}
+
+// resolveNPM resolves spec ("" meaning latest) to a published version of
+// pkg.
+func resolveNPM(pkg, spec string) (*npmRelease, error) {
+	if spec == "" {
+		spec = "latest"
+	}
+	api := npmRegistry() + "/" + url.PathEscape(pkg)
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", api, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyRequestHeaders(req)
+	// The abbreviated document, without every version's readme.
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	resp, err := network.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w%s", err, tlsHint(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", api, resp.Status)
+	}
+	var doc npmPackument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", api, err)
+	}
+
+	version := doc.DistTags[spec]
+	if version == "" {
+		if _, ok := doc.Versions[spec]; ok {
+			version = spec
+		}
+	}
+	if version == "" {
+		// A prefix takes the newest release under it; prereleases only if
+		// the prefix names one.
+		for v := range doc.Versions {
+			if !strings.HasPrefix(v, spec+".") || strings.Contains(v, "-") && !strings.Contains(spec, "-") {
+				continue
+			}
+			if version == "" || compareVersions(v, version) > 0 {
+				version = v
+			}
+		}
+	}
+	if version == "" {
+		tags := make([]string, 0, len(doc.DistTags))
+		for t := range doc.DistTags {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		return nil, fmt.Errorf("%s has no version or dist-tag %q (dist-tags: %s)", pkg, spec, strings.Join(tags, ", "))
+	}
+	dist := doc.Versions[version].Dist
+	if dist.Tarball == "" {
+		return nil, fmt.Errorf("%s@%s has no tarball", pkg, version)
+	}
+	return &npmRelease{Version: version, Tarball: dist.Tarball, Integrity: dist.Integrity}, nil
+}
+
+// compareVersions orders semver strings: numerically by release, and a
+// prerelease before its release.
+func compareVersions(a, b string) int {
+	relA, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	relB, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	partsA, partsB := strings.Split(relA, "."), strings.Split(relB, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
+
+// npmVersionFromTarball is the version in a registry tarball URL
+// (.../xmlui/-/xmlui-1.2.3.tgz), or "".
+func npmVersionFromTarball(pkg, u string) string {
+	name := strings.TrimSuffix(path.Base(u), ".tgz")
+	if !strings.HasPrefix(name, pkg+"-") {
+		return ""
+	}
+	return strings.TrimPrefix(name, pkg+"-")
+}
+
+// checkIntegrity verifies data against an npm Subresource Integrity string
+// (sha512-BASE64). Registries that publish only the legacy sha1 shasum are
+// accepted unverified, as npm does.
+func checkIntegrity(data []byte, integrity string) error {
+	for _, sri := range strings.Fields(integrity) {
+		algo, want, ok := strings.Cut(sri, "-")
+		if !ok || algo != "sha512" {
+			continue
+		}
+		sum := sha512.Sum512(data)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			return &integrityError{fmt.Sprintf("integrity mismatch: got sha512-%s, the registry has %s", got, sri)}
+		}
+		return nil
+	}
+	return nil
+}
+
+// installXMLUINPM downloads the XMLUI package opts.xmluiNPM selects and puts
+// its standalone build into xmluiNPMDir of appDir, as component xmlui-npm.
+// With --locked the tarball comes from the lockfile and the registry is not
+// asked.
+func installXMLUINPM(opts installOptions, rcpt *receipt, stage, installDir, appDir string) error {
+	rel := &npmRelease{}
+	if opts.lock == nil {
+		var err error
+		if rel, err = resolveNPM(xmluiNPMPackage, opts.xmluiNPM); err != nil {
+			return err
+		}
+	}
+	data, tarball, sum, err := opts.fetch(xmluiNPMComponent, platform{}, rel.Tarball, "XMLUI from npm")
+	if err != nil {
+		return err
+	}
+	if err := checkIntegrity(data, rel.Integrity); err != nil {
+		return fmt.Errorf("%s: %w", tarball, err)
+	}
+	if rel.Version == "" {
+		rel.Version = npmVersionFromTarball(xmluiNPMPackage, tarball)
+	}
+
+	// npm tarballs have everything under package/.
+	tmp := filepath.Join(stage, xmluiNPMComponent)
+	if err := fsys.MkdirAll(tmp, dirMode); err != nil {
+		return err
+	}
+	if err := extractArchive(data, tmp, 1); err != nil {
+		return err
+	}
+	collectLicenses(xmluiNPMComponent, xmluiNPMPackage+" "+rel.Version+" from npm", tmp)
+	assets := filepath.Join(tmp, filepath.FromSlash(xmluiNPMAssets))
+	if _, err := os.Stat(assets); err != nil {
+		return fmt.Errorf("%s@%s has no %s", xmluiNPMPackage, rel.Version, xmluiNPMAssets)
+	}
+	dest := filepath.Join(appDir, filepath.FromSlash(xmluiNPMDir))
+	files, st, err := syncTree(assets, dest, installDir, opts.previousFiles(xmluiNPMComponent), nil)
+	if err != nil {
+		return err
+	}
+	c := rcpt.component(xmluiNPMComponent, tarball)
+	d := newDownload(platform{}, tarball, sum)
+	d.Tag = rel.Version
+	c.Files, c.Downloads = files, []receiptDownload{d}
+	if opts.previous != nil {
+		fmt.Printf("  %s: %s\n", xmluiNPMComponent, st)
+	}
+	fmt.Printf("%s XMLUI %s from npm is in %s\n", glyphOK, rel.Version, dest)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// orgReadmeFile is the organization's README in the install dir, next to
+// the getting-started guide, when it supplements the guide.
+const orgReadmeFile = "ORGANIZATION_README.md"
+
+// orgNotes are what an organization adds to its installs: a message shown
+// at the end of every install and update, and a README (support contacts,
+// VPN notes, where to ask for help) written into the install dir. They come
+// from --org-message, --org-readme and --org-mode, else the config file's or
+// a manifest's "organization", else the install being updated.
+type orgNotes struct {
+	Message string `json:"message,omitempty"`
+	// README is a file path, relative to the config file or manifest
+	// naming it, or a URL of any scheme artifacts can be fetched with.
+	README string `json:"readme,omitempty"`
+	// Mode is "supplement" (the default), which adds the README and
+	// message to the getting-started guide and summary, or "replace", which
+	// puts them in their place.
+	Mode string `json:"mode,omitempty"`
+}
+
+func (n orgNotes) empty() bool {
+	return n.Message == "" && n.README == ""
+}
+
+func (n orgNotes) replaces() bool {
+	return n.Mode == "replace"
+}
+
+// resolveOrgNotes fills in opts.org from the config file, or the previous
+// receipt, when no --org-* flag set it, and checks the mode.
+func (opts *installOptions) resolveOrgNotes() error {
+	if opts.org.empty() {
+		cfg, path, err := readConfig()
+		if err != nil {
+			return err
+		}
+		var from *orgNotes
+		if cfg.Organization != nil && !cfg.Organization.empty() {
+			from = cfg.Organization
+			if r := from.README; r != "" && !strings.Contains(r, "://") && !filepath.IsAbs(r) {
+				from.README = filepath.Join(filepath.Dir(path), r)
+			}
+		} else if opts.previous != nil {
+			from = opts.previous.Organization
+		}
+		if from != nil {
+			mode := opts.org.Mode
+			opts.org = *from
+			if mode != "" {
+				opts.org.Mode = mode
+			}
+		}
+	}
+	switch opts.org.Mode {
+	case "", "supplement", "replace":
+	default:
+		return fmt.Errorf("organization mode %q: want supplement or replace", opts.org.Mode)
+	}
+	if opts.org.README != "" && strings.Contains(opts.org.README, "://") {
+		return checkStorageURL(opts.org.README)
+	}
+	if opts.org.README != "" {
+		abs, err := filepath.Abs(opts.org.README)
+		if err != nil {
+			return err
+		}
+		opts.org.README = abs
+	}
+	return nil
+}
+
+// readOrgReadme reads or downloads the README n names, or returns nil if it
+// names none.
+func readOrgReadme(n *orgNotes) ([]byte, error) {
+	if n == nil || n.README == "" {
+		return nil, nil
+	}
+	if strings.Contains(n.README, "://") {
+		data, _, err := downloadAsset(n.README, "organization README", "")
+		return data, err
+	}
+	return os.ReadFile(n.README)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// osPlatform is what differs between the operating systems the launcher runs
+// on and provisions installs for: executable names and modes, path case,
+// admin-owned locations, rename failures, the download quarantine, helper
+// scripts and opening things for the user. Deciding these through it, for
+// hostOS or for the platform an install targets, gives each quirk one home,
+// and lets the decisions for one OS be exercised on another. The system calls
+// behind them (xattrs, the registry, shortcuts) stay in the _unix and
+// _windows files.
+type osPlatform interface {
+	// goos is the GOOS it stands for.
+	goos() string
+	// exeSuffix ends the name of an executable.
+	exeSuffix() string
+	// execBits reports whether a file needs execute permission to run.
+	execBits() bool
+	// foldsCase reports whether paths that differ only in case are the same.
+	foldsCase() bool
+	// systemLocations are the shared, admin-owned install roots.
+	systemLocations() []string
+	// crossDevice reports whether err is a rename failure caused by source
+	// and destination being on different filesystems.
+	crossDevice(err error) bool
+	// scriptExt is the extension of the helper scripts written for it.
+	scriptExt() string
+	// startScript is how the app's start script is run from its directory.
+	startScript() string
+	// cleanupScript is the script that removes the launcher executable
+	// exeName and the downloads from an install dir, its file name, and how
+	// the user runs it.
+	cleanupScript(exeName string) (name, script, run string)
+	// elevationHint suggests how to install into dir with enough rights, or
+	// to choose alt instead.
+	elevationHint(dir, alt string) string
+	// quarantineEffect says what the download quarantine does to a binary.
+	quarantineEffect() string
+	// unquarantineCommand is the shell command that clears path's
+	// quarantine by hand.
+	unquarantineCommand(path string) string
+	// openCommand opens target, a URL or file, in the user's default
+	// application.
+	openCommand(target string) []string
+	// selfDeleteCommand removes the running executable exe once this process
+	// has exited, for where a running image can't be deleted; nil where it
+	// can be removed directly.
+	selfDeleteCommand(exe string) []string
+	// executableNames are the file names an installed executable called
+	// name may have, in the order they are looked for.
+	executableNames(name string) []string
+	// defaultShell is the syntax `env` prints without --shell.
+	defaultShell() string
+	// appDataDir is where desktop apps keep their per-user settings.
+	appDataDir() (string, error)
+	// browserLocations are where Chrome, Chromium and Edge are usually
+	// installed, beyond $PATH.
+	browserLocations() []string
+	// launchHint explains why the binary at path didn't start, from its
+	// error and output msg, where the cause is peculiar to the OS; "" if
+	// nothing does.
+	launchHint(path string, err error, msg string) string
+}
+
+// forOS is the osPlatform of goos. Unix systems other than macOS and Windows
+// are treated as Linux.
+func forOS(goos string) osPlatform {
+	switch goos {
+	case "windows":
+		return windowsPlatform{}
+	case "darwin":
+		return darwinPlatform{}
+	}
+	return linuxPlatform{posixPlatform{goos}}
+}
+
+// hostOS is the osPlatform of this machine.
+var hostOS = forOS(runtime.GOOS)
+
+// osPlatform is the osPlatform of p's OS.
+func (p platform) osPlatform() osPlatform { return forOS(p.OS) }
+
+// quarantineAttr is the extended attribute macOS marks downloads with.
+const quarantineAttr = "com.apple.quarantine"
+
+// posixPlatform is what macOS and Linux share.
+type posixPlatform struct{ name string }
+
+func (p posixPlatform) goos() string      { return p.name }
+func (posixPlatform) exeSuffix() string   { return "" }
+func (posixPlatform) execBits() bool      { return true }
+func (posixPlatform) foldsCase() bool     { return false }
+func (posixPlatform) scriptExt() string   { return ".sh" }
+func (posixPlatform) startScript() string { return "./start.sh" }
+
+func (posixPlatform) systemLocations() []string {
+	return []string{"/opt", "/usr", "/srv"}
+}
+
+func (posixPlatform) crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+func (posixPlatform) cleanupScript(exeName string) (string, string, string) {
+	script := "#!/bin/sh\n"
+	script += "echo Cleaning up temporary files...\n"
+	script += fmt.Sprintf("rm -f \"%s\"\n", exeName)
+	script += "rm -f *.zip\n"
+	script += "rm -f *.tar.gz\n"
+	script += "rm -f cleanup.sh\n"
+	return "cleanup.sh", script, "./cleanup.sh"
+}
+
+func (posixPlatform) elevationHint(dir, alt string) string {
+	return fmt.Sprintf("  Re-run with elevated rights:\n    sudo %s --dir %s\n  or choose a user location:\n    xmlui-bundler --dir %s", filepath.Base(os.Args[0]), dir, alt)
+}
+
+func (posixPlatform) quarantineEffect() string { return "" }
+
+func (posixPlatform) unquarantineCommand(string) string { return "" }
+
+func (posixPlatform) selfDeleteCommand(string) []string { return nil }
+
+func (posixPlatform) executableNames(name string) []string { return []string{name} }
+
+func (posixPlatform) defaultShell() string { return "sh" }
+
+// appDataDir is the XDG config dir: $XDG_CONFIG_HOME or ~/.config.
+func (posixPlatform) appDataDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+func (posixPlatform) browserLocations() []string { return nil }
+
+func (posixPlatform) launchHint(string, error, string) string { return "" }
+
+type linuxPlatform struct{ posixPlatform }
+
+func (linuxPlatform) openCommand(target string) []string {
+	return []string{"xdg-open", target}
+}
+
+// launchHint recognizes a glibc binary on a musl system, whose missing
+// dynamic loader makes exec report the binary itself as not found.
+func (linuxPlatform) launchHint(_ string, err error, _ string) string {
+	if errors.Is(err, os.ErrNotExist) {
+		return "The file exists but its dynamic loader does not; this usually means a glibc binary on a musl system (e.g. Alpine). Install gcompat or use a glibc-based distribution."
+	}
+	return ""
+}
+
+// darwinPlatform is macOS, whose Gatekeeper stops downloaded binaries that
+// carry the com.apple.quarantine attribute.
+type darwinPlatform struct{ posixPlatform }
+
+func (darwinPlatform) goos() string { return "darwin" }
+
+func (darwinPlatform) systemLocations() []string {
+	return append(posixPlatform{}.systemLocations(), "/Applications", "/Library")
+}
+
+func (darwinPlatform) quarantineEffect() string {
+	return "macOS will block them on first run"
+}
+
+func (darwinPlatform) unquarantineCommand(path string) string {
+	return "xattr -d " + quarantineAttr + " " + shQuote(path)
+}
+
+func (darwinPlatform) openCommand(target string) []string {
+	return []string{"open", target}
+}
+
+func (darwinPlatform) appDataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Application Support"), nil
+}
+
+func (darwinPlatform) browserLocations() []string {
+	return []string{
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	}
+}
+
+// launchHint recognizes Gatekeeper killing a quarantined binary.
+func (darwinPlatform) launchHint(path string, _ error, msg string) string {
+	if strings.Contains(msg, "killed") {
+		return fmt.Sprintf("macOS Gatekeeper likely blocked it. Run: xmlui-bundler mcp prepare (or xattr -d %s %q)", quarantineAttr, path)
+	}
+	return ""
+}
+
+// windowsPlatform is Windows: .exe and .bat files, case-insensitive paths,
+// permissions from ACLs rather than modes, and SmartScreen stopping files
+// with the mark of the web.
+type windowsPlatform struct{}
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, Windows' equivalent of EXDEV.
+const errNotSameDevice = syscall.Errno(17)
+
+func (windowsPlatform) goos() string        { return "windows" }
+func (windowsPlatform) exeSuffix() string   { return ".exe" }
+func (windowsPlatform) execBits() bool      { return false }
+func (windowsPlatform) foldsCase() bool     { return true }
+func (windowsPlatform) scriptExt() string   { return ".bat" }
+func (windowsPlatform) startScript() string { return "start.bat" }
+
+func (windowsPlatform) systemLocations() []string {
+	var roots []string
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "ProgramData"} {
+		if root := os.Getenv(env); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+func (windowsPlatform) crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV) || errors.Is(err, errNotSameDevice)
+}
+
+func (windowsPlatform) cleanupScript(exeName string) (string, string, string) {
+	script := "@echo off\r\n"
+	script += "echo Cleaning up temporary files...\r\n"
+	script += fmt.Sprintf("if exist \"%s\" del \"%s\"\r\n", exeName, exeName)
+	script += "if exist *.zip del *.zip\r\n"
+	script += "if exist cleanup.ps1 del cleanup.ps1\r\n"
+	script += "del cleanup.bat\r\n"
+	// installWindowsScripts writes cleanup.ps1 as well.
+	return "cleanup.bat", script, "cleanup.bat (or cleanup.ps1)"
+}
+
+func (windowsPlatform) elevationHint(_, alt string) string {
+	return fmt.Sprintf("  Re-run from a terminal opened with \"Run as administrator\", or choose a user location:\n    xmlui-bundler --dir %s", alt)
+}
+
+func (windowsPlatform) quarantineEffect() string {
+	return "SmartScreen will prompt each time one is started"
+}
+
+func (windowsPlatform) unquarantineCommand(path string) string {
+	return "Unblock-File -LiteralPath " + psQuote(path)
+}
+
+func (windowsPlatform) openCommand(target string) []string {
+	return []string{"rundll32", "url.dll,FileProtocolHandler", target}
+}
+
+// selfDeleteCommand has a detached cmd wait for us to exit, as Windows
+// refuses to delete a running image.
+func (windowsPlatform) selfDeleteCommand(exe string) []string {
+	return []string{"cmd", "/c", "ping -n 3 127.0.0.1 >nul & del /f /q \"" + exe + "\""}
+}
+
+// executableNames are the .exe and, for an --all-platforms install, its
+// .cmd dispatch script.
+func (windowsPlatform) executableNames(name string) []string {
+	return []string{name + ".exe", name + ".cmd"}
+}
+
+func (windowsPlatform) defaultShell() string { return "powershell" }
+
+func (windowsPlatform) appDataDir() (string, error) {
+	if dir := os.Getenv("APPDATA"); dir != "" {
+		return dir, nil
+	}
+	return "", errors.New("%APPDATA% is not set")
+}
+
+func (windowsPlatform) browserLocations() []string {
+	var paths []string
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LOCALAPPDATA"} {
+		if base := os.Getenv(env); base != "" {
+			paths = append(paths,
+				filepath.Join(base, `Google\Chrome\Application\chrome.exe`),
+				filepath.Join(base, `Microsoft\Edge\Application\msedge.exe`))
+		}
+	}
+	return paths
+}
+
+func (windowsPlatform) launchHint(string, error, string) string { return "" }
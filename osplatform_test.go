@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// asHost makes hostOS the platform of goos for the rest of the test, so
+// one machine can check the decisions made for every OS.
+func asHost(t *testing.T, goos string) {
+	t.Helper()
+	saved := hostOS
+	hostOS = forOS(goos)
+	t.Cleanup(func() { hostOS = saved })
+}
+
+var testedOSes = []string{"darwin", "linux", "windows"}
+
+func TestHostExecutables(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"xmlui-mcp", "xmlui-test-server.cmd"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, goos := range testedOSes {
+		t.Run(goos, func(t *testing.T) {
+			asHost(t, goos)
+			windows := goos == "windows"
+			if _, err := mcpBinary(dir, "xmlui-mcp"); (err == nil) == windows {
+				t.Errorf("mcpBinary found xmlui-mcp: %v, want %v", err == nil, !windows)
+			}
+			if _, err := mcpBinary(dir, "xmlui-test-server"); (err == nil) != windows {
+				t.Errorf("mcpBinary found the .cmd dispatch script: %v, want %v", err == nil, windows)
+			}
+			// The script isn't executable, which matters only where
+			// files need execute permission to run.
+			got := runCommand(filepath.Join(dir, "xmlui-mcp"), "./xmlui-mcp")
+			want := "sh ./xmlui-mcp"
+			if windows {
+				want = "./xmlui-mcp"
+			}
+			if got != want {
+				t.Errorf("runCommand = %q, want %q", got, want)
+			}
+			if got, want := thisMachine().exe(), map[bool]string{true: ".exe"}[windows]; got != want {
+				t.Errorf("exe() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHostInstallKey(t *testing.T) {
+	for _, goos := range testedOSes {
+		t.Run(goos, func(t *testing.T) {
+			asHost(t, goos)
+			a, err := installKey("/Users/Ann/XMLUI")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := installKey("/users/ann/xmlui")
+			if same := a == b; same != (goos == "windows") {
+				t.Errorf("installKey %q and %q: the same state dir %v, want %v", a, b, same, goos == "windows")
+			}
+		})
+	}
+}
+
+func TestHostProbeGuidance(t *testing.T) {
+	notFound := &os.PathError{Op: "fork/exec", Path: "/x", Err: syscall.ENOENT}
+	killed := errors.New("signal: killed")
+	for _, tc := range []struct {
+		goos      string
+		err       error
+		want, not string
+	}{
+		{"linux", notFound, "musl", ""},
+		{"darwin", notFound, "", "musl"},
+		{"darwin", killed, "Gatekeeper", ""},
+		{"linux", killed, "", "Gatekeeper"},
+		{"windows", killed, "", "Gatekeeper"},
+	} {
+		t.Run(tc.goos+"/"+tc.err.Error(), func(t *testing.T) {
+			asHost(t, tc.goos)
+			got := probeGuidance("/x", tc.err, "")
+			if tc.want != "" && !strings.Contains(got, tc.want) || tc.not != "" && strings.Contains(got, tc.not) {
+				t.Errorf("probeGuidance = %q, want it to mention %q and not %q", got, tc.want, tc.not)
+			}
+		})
+	}
+}
+
+func TestHostAppDataDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("APPDATA", filepath.Join(home, "roaming"))
+	want := map[string]string{
+		"darwin":  filepath.Join(home, "Library", "Application Support"),
+		"linux":   filepath.Join(home, "xdg"),
+		"windows": filepath.Join(home, "roaming"),
+	}
+	for _, goos := range testedOSes {
+		t.Run(goos, func(t *testing.T) {
+			asHost(t, goos)
+			dir, err := hostOS.appDataDir()
+			if err != nil || dir != want[goos] {
+				t.Errorf("appDataDir = %q, %v, want %q", dir, err, want[goos])
+			}
+			for _, c := range mcpClientConfigs {
+				if c.flag != "claude" {
+					continue
+				}
+				p, err := c.path(home)
+				if err != nil || !strings.HasPrefix(p, want[goos]+string(filepath.Separator)) {
+					t.Errorf("Claude Desktop's config is at %q, %v, want it in %s", p, err, want[goos])
+				}
+			}
+		})
+	}
+	asHost(t, "windows")
+	t.Setenv("APPDATA", "")
+	if _, err := hostOS.appDataDir(); err == nil {
+		t.Error("appDataDir succeeded on Windows without %APPDATA%")
+	}
+}
+
+func TestHostDefaults(t *testing.T) {
+	t.Setenv("ProgramFiles", t.TempDir())
+	for _, goos := range testedOSes {
+		t.Run(goos, func(t *testing.T) {
+			asHost(t, goos)
+			want := map[string]string{"windows": "powershell"}[goos]
+			if want == "" {
+				want = "sh"
+			}
+			if got := hostOS.defaultShell(); got != want {
+				t.Errorf("defaultShell = %q, want %q", got, want)
+			}
+			browsers := strings.Join(hostOS.browserLocations(), "\n")
+			if wantBrowser := map[string]string{"darwin": "Google Chrome.app", "windows": "chrome.exe"}[goos]; !strings.Contains(browsers, wantBrowser) || wantBrowser == "" && browsers != "" {
+				t.Errorf("browserLocations = %q, want one with %q", browsers, wantBrowser)
+			}
+			opts := installOptions{target: platform{goos, runtime.GOARCH}}
+			if opts.crossProvisioning() {
+				t.Errorf("an install for %s/%s is cross-provisioning on that platform", goos, runtime.GOARCH)
+			}
+			if r := newReceipt(); r.OS != goos {
+				t.Errorf("newReceipt records OS %q, want %q", r.OS, goos)
+			}
+		})
+	}
+}
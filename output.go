@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Progress styles, chosen by detectConsole or --progress.
+const (
+	progressBar   = "bar"   // redrawn in place on an interactive terminal
+	progressDots  = "dots"  // a dot per step, for CI logs and dumb terminals
+	progressPlain = "plain" // nothing but the start and end lines
+)
+
+// ciEnvVars are set by the CI systems whose logs should get dotted
+// progress instead of carriage-return redraws.
+var ciEnvVars = []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "TRAVIS", "TF_BUILD", "JENKINS_URL", "TEAMCITY_VERSION"}
+
+// consoleInfo describes where stdout goes.
+type consoleInfo struct {
+	progress string
+	// color is set on terminals that take ANSI colors, unless NO_COLOR is.
+	color bool
+	// width is the terminal width, or 0 when stdout isn't a terminal and
+	// lines should not be truncated.
+	width int
+}
+
+var console = detectConsole()
+
+func detectConsole() consoleInfo {
+	c := consoleInfo{progress: progressPlain}
+	fd := int(os.Stdout.Fd())
+	tty := term.IsTerminal(fd)
+	if tty {
+		c.width = 80
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			c.width = w
+		} else if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+			c.width = w
+		}
+	}
+	ci := false
+	for _, v := range ciEnvVars {
+		if os.Getenv(v) != "" {
+			ci = true
+			break
+		}
+	}
+	dumb := os.Getenv("TERM") == "dumb"
+	switch {
+	case ci || tty && dumb:
+		c.progress = progressDots
+	case tty:
+		c.progress = progressBar
+	}
+	c.color = tty && !dumb && os.Getenv("NO_COLOR") == "" && enableColor(fd)
+	return c
+}
+
+// ANSI styles for paint.
+const (
+	styleStep  = "1"
+	styleOK    = "32"
+	styleWarn  = "33"
+	styleError = "31"
+)
+
+// paint wraps s in an ANSI style if the console takes colors.
+func (c consoleInfo) paint(style, s string) string {
+	if !c.color {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
+
+// Result glyphs and the warning label; plain output spells the glyphs out.
+var (
+	glyphOK      = console.paint(styleOK, "✓")
+	glyphFail    = console.paint(styleError, "✗")
+	glyphMore    = "…"
+	labelWarning = console.paint(styleWarn, "Warning:")
+)
+
+// plainOutput takes --plain out of args, wherever it is before any "--",
+// and if it was there or XMLUI_PLAIN is set switches to plain output:
+// sequential lines without progress redraws, glyphs or truncation, which
+// screen readers follow best. Children are asked for no color either.
+// --no-color, taken out the same way, only turns color off.
+func plainOutput(args []string) []string {
+	plain := os.Getenv("XMLUI_PLAIN") != ""
+	out := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		if a == "--plain" || a == "-plain" {
+			plain = true
+			continue
+		}
+		if a == "--no-color" || a == "-no-color" {
+			console.color = false
+			glyphOK, glyphFail, labelWarning = "✓", "✗", "Warning:"
+			os.Setenv("NO_COLOR", "1")
+			continue
+		}
+		out = append(out, a)
+	}
+	if plain {
+		console = consoleInfo{progress: progressPlain}
+		glyphOK, glyphFail, glyphMore, labelWarning = "OK:", "Error:", "...", "Warning:"
+		os.Setenv("NO_COLOR", "1")
+	}
+	return out
+}
+
+// progressFlag is a flag.Value that overrides the detected progress style.
+type progressFlag struct{}
+
+func (progressFlag) String() string { return console.progress }
+
+func (progressFlag) Set(s string) error {
+	switch s {
+	case "auto":
+		console.progress = detectConsole().progress
+	case progressBar, progressDots, progressPlain:
+		console.progress = s
+	default:
+		return fmt.Errorf("want auto, bar, dots or plain")
+	}
+	return nil
+}
+
+// fit shortens a line to the terminal width by cutting the middle of s,
+// which keeps both the host and the file name of a URL readable. prefix is
+// printed before s and always kept.
+func (c consoleInfo) fit(prefix, s string) string {
+	room := c.width - 1 - len([]rune(prefix))
+	r := []rune(s)
+	if c.width == 0 || len(r) <= room || room < 10 {
+		return prefix + s
+	}
+	head := (room - 1) / 2
+	tail := room - 1 - head
+	return prefix + string(r[:head]) + glyphMore + string(r[len(r)-tail:])
+}
+
+// lineWidth is the width to draw a progress bar in, which --progress bar
+// may ask for even when stdout isn't a terminal.
+func (c consoleInfo) lineWidth() int {
+	if c.width == 0 {
+		return 80
+	}
+	return c.width
+}
+
+// progress renders download progress in the console's style.
+type progress struct {
+	mu       sync.Mutex
+	total    int64
+	done     int64
+	dots     int
+	drawn    time.Time
+	finished bool
+}
+
+func newProgress(total int64) *progress {
+	return &progress{total: total}
+}
+
+// dotEvery is how far a dot gets you when the size is unknown.
+const dotEvery = 512 << 10
+
+func (p *progress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	switch console.progress {
+	case progressBar:
+		if time.Since(p.drawn) >= 100*time.Millisecond {
+			p.draw()
+		}
+	case progressDots:
+		// 20 dots for a known size, otherwise one per dotEvery bytes.
+		want := int(p.done / dotEvery)
+		if p.total > 0 {
+			want = int(p.done * 20 / p.total)
+		}
+		if want > p.dots {
+			if p.dots == 0 {
+				fmt.Print("  ")
+			}
+			fmt.Print(strings.Repeat(".", want-p.dots))
+			p.dots = want
+		}
+	}
+}
+
+func (p *progress) draw() {
+	p.drawn = time.Now()
+	line := "  " + humanBytes(p.done)
+	if p.total > 0 {
+		pct := int(p.done * 100 / p.total)
+		counts := fmt.Sprintf(" %3d%% %s/%s", pct, humanBytes(p.done), humanBytes(p.total))
+		barWidth := console.lineWidth() - len(counts) - 5
+		if barWidth > 40 {
+			barWidth = 40
+		}
+		if barWidth > 0 {
+			filled := int(int64(barWidth) * p.done / p.total)
+			line = "  [" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "]" + counts
+		} else {
+			line = counts
+		}
+	}
+	fmt.Print("\r" + line)
+}
+
+// finish ends the progress display so the next line starts clean.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	switch console.progress {
+	case progressBar:
+		p.draw()
+		fmt.Print("\r" + strings.Repeat(" ", console.lineWidth()-1) + "\r")
+	case progressDots:
+		if p.dots > 0 {
+			fmt.Println()
+		}
+	}
+}
+
+// progressReader feeds what it reads to a progress display.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(int64(n))
+	return n, err
+}
+
+// strictWarnings is --strict: warnings from the install steps fail the
+// install instead, so CI can tell a complete bundle from one that merely
+// finished.
+var strictWarnings bool
+
+// warn prints format as a warning, keeping its leading indent, or with
+// --strict fails the install with it.
+func warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	body := strings.TrimLeft(msg, " ")
+	if strictWarnings {
+		fatal("Failed in strict mode", classify(exitStrict, errors.New(body)))
+	}
+	fmt.Printf("%s%s %s\n", msg[:len(msg)-len(body)], labelWarning, body)
+	events.emit(event{Event: "warning", Message: body})
+}
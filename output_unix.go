@@ -0,0 +1,7 @@
+//go:build !windows
+
+package main
+
+// enableColor reports whether the terminal at fd takes ANSI colors; every
+// Unix terminal but TERM=dumb, which detectConsole rules out, does.
+func enableColor(fd int) bool { return true }
@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// enableColor turns on ANSI escape processing for the console at fd, which
+// Windows 10 and later support but leave off; older consoles get no color.
+func enableColor(fd int) bool {
+	h := windows.Handle(fd)
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Marks around the launcher's block in a shell or PowerShell profile. A
+// block marked with the launcher's old name is replaced as well.
+const (
+	profileBlockStart       = "# >>> " + launcherName + " >>>"
+	profileBlockEnd         = "# <<< " + launcherName + " <<<"
+	legacyProfileBlockStart = "# >>> " + legacyLauncherName + " >>>"
+	legacyProfileBlockEnd   = "# <<< " + legacyLauncherName + " <<<"
+)
+
+// installedBinaries are the tools a bundle puts on disk that users may want
+// to invoke from anywhere.
+var installedBinaries = []string{"xmlui-mcp", "xmlui-mcp-client", "xmlui-test-server"}
+
+// runWhere implements `where`: like the Windows `where` command or `which -a`,
+// it lists every match for each binary on PATH, first match first.
+func runWhere(args []string) int {
+	fs := newFlagSet("where")
+	fs.Parse(args)
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = installedBinaries
+	}
+
+	status := exitOK
+	for _, name := range names {
+		matches := findOnPath(name)
+		if len(matches) == 0 {
+			fmt.Printf("%s: not found on PATH\n", name)
+			status = exitFailure
+			continue
+		}
+		fmt.Printf("%s:\n", name)
+		for i, m := range matches {
+			if i == 0 {
+				fmt.Printf("  %s (active)\n", m)
+			} else {
+				fmt.Printf("  %s (shadowed)\n", m)
+			}
+		}
+	}
+	return status
+}
+
+// findOnPath returns every executable named name found on PATH, in PATH order.
+// On Windows each PATHEXT extension is tried as well.
+func findOnPath(name string) []string {
+	candidates := []string{name}
+	if hostOS.goos() == "windows" && filepath.Ext(name) == "" {
+		pathext := os.Getenv("PATHEXT")
+		if pathext == "" {
+			pathext = ".COM;.EXE;.BAT;.CMD"
+		}
+		candidates = nil
+		for _, ext := range strings.Split(pathext, ";") {
+			if ext != "" {
+				candidates = append(candidates, name+strings.ToLower(ext))
+			}
+		}
+	}
+
+	var matches []string
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		for _, c := range candidates {
+			p := filepath.Join(dir, c)
+			info, err := os.Stat(p)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if hostOS.execBits() && info.Mode()&0111 == 0 {
+				continue
+			}
+			key := p
+			if hostOS.foldsCase() {
+				key = strings.ToLower(p)
+			}
+			if !seen[key] {
+				seen[key] = true
+				matches = append(matches, p)
+			}
+		}
+	}
+	return matches
+}
+
+// pathContains reports whether list already holds dir, ignoring trailing
+// separators and, on Windows, case.
+func pathContains(list []string, dir string) bool {
+	want := filepath.Clean(dir)
+	for _, entry := range list {
+		if entry == "" {
+			continue
+		}
+		got := filepath.Clean(entry)
+		if got == want || (hostOS.foldsCase() && strings.EqualFold(got, want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceProfileBlock swaps any previous launcher block in content for block,
+// or appends block if there is none, so repeated runs stay idempotent.
+func replaceProfileBlock(content, block string) string {
+	replaced := false
+	for _, m := range [][2]string{{profileBlockStart, profileBlockEnd}, {legacyProfileBlockStart, legacyProfileBlockEnd}} {
+		start := strings.Index(content, m[0])
+		if start < 0 {
+			continue
+		}
+		end := strings.Index(content[start:], m[1])
+		if end < 0 {
+			continue
+		}
+		end = start + end + len(m[1])
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		if replaced {
+			content = content[:start] + content[end:]
+		} else {
+			content = content[:start] + block + content[end:]
+			replaced = true
+		}
+	}
+	if replaced {
+		return content
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if content != "" {
+		content += "\n"
+	}
+	return content + block
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// addDirsToUserPath writes (or rewrites) a marked PATH snippet in the profile
+// of the user's login shell and returns the file it touched.
+func addDirsToUserPath(dirs []string) (string, error) {
+	profile, fish, err := shellProfilePath()
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	lines = append(lines, profileBlockStart)
+	for _, dir := range dirs {
+		if fish {
+			lines = append(lines, "fish_add_path -g "+fishQuote(dir))
+		} else {
+			lines = append(lines, "export PATH="+shQuote(dir)+`:"$PATH"`)
+		}
+	}
+	lines = append(lines, profileBlockEnd)
+	block := strings.Join(lines, "\n") + "\n"
+
+	existing, err := os.ReadFile(profile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	content := replaceProfileBlock(string(existing), block)
+
+	if err := fsys.MkdirAll(filepath.Dir(profile), 0755); err != nil {
+		return "", err
+	}
+	if err := fsys.WriteFile(profile, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	if fish {
+		// The file of the launcher's old name would add the dirs too.
+		fsys.Remove(filepath.Join(filepath.Dir(profile), legacyLauncherName+".fish"))
+	}
+	return profile, nil
+}
+
+// fishQuote quotes s for fish, in whose single quotes only \\ and \' are
+// escapes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// shellProfilePath picks the startup file for $SHELL.
+func shellProfilePath() (path string, fish bool, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false, err
+	}
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "zsh":
+		return filepath.Join(home, ".zshrc"), false, nil
+	case "bash":
+		if hostOS.goos() == "darwin" {
+			return filepath.Join(home, ".bash_profile"), false, nil
+		}
+		return filepath.Join(home, ".bashrc"), false, nil
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "conf.d", launcherName+".fish"), true, nil
+	default:
+		return filepath.Join(home, ".profile"), false, nil
+	}
+}
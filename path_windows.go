@@ -0,0 +1,75 @@
+//go:build windows
+
+package main
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// addDirsToUserPath appends dirs to the per-user Path value under
+// HKCU\Environment and notifies running programs of the change.
+func addDirsToUserPath(dirs []string) (string, error) {
+	const where = `HKCU\Environment\Path`
+
+	k, err := registry.OpenKey(registry.CURRENT_USER, "Environment", registry.QUERY_VALUE|registry.SET_VALUE)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	current, valType, err := k.GetStringValue("Path")
+	if err != nil && err != registry.ErrNotExist {
+		return "", err
+	}
+
+	var entries []string
+	if current != "" {
+		entries = strings.Split(current, ";")
+	}
+	changed := false
+	for _, dir := range dirs {
+		if !pathContains(entries, dir) {
+			entries = append(entries, dir)
+			changed = true
+		}
+	}
+	if !changed {
+		return where, nil
+	}
+
+	value := strings.Join(entries, ";")
+	// Keep the existing value type; REG_EXPAND_SZ is what Windows itself uses
+	// so that entries like %USERPROFILE% keep working.
+	if valType == registry.SZ {
+		err = k.SetStringValue("Path", value)
+	} else {
+		err = k.SetExpandStringValue("Path", value)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	broadcastSettingChange()
+	return where, nil
+}
+
+// broadcastSettingChange sends WM_SETTINGCHANGE so Explorer and new consoles
+// pick up the updated environment without a logoff.
+func broadcastSettingChange() {
+	const (
+		hwndBroadcast   = 0xffff
+		wmSettingChange = 0x001A
+		smtoAbortIfHung = 0x0002
+	)
+	env, err := windows.UTF16PtrFromString("Environment")
+	if err != nil {
+		return
+	}
+	proc := windows.NewLazySystemDLL("user32.dll").NewProc("SendMessageTimeoutW")
+	var result uintptr
+	proc.Call(hwndBroadcast, wmSettingChange, 0, uintptr(unsafe.Pointer(env)), smtoAbortIfHung, 5000, uintptr(unsafe.Pointer(&result)))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// installDirFlag registers --dir on fs; pass the result to resolveInstallDir.
+func installDirFlag(fs *flag.FlagSet) *string {
+	return fs.String("dir", "", "install directory (default: the current directory)")
+}
+
+// resolveInstallDir returns dir as an absolute path, defaulting to the
+// current directory.
+func resolveInstallDir(dir string) (string, error) {
+	if dir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(dir)
+}
+
+// isSystemLocation reports whether dir is a shared, admin-owned location
+// such as /opt or C:\Program Files.
+func isSystemLocation(dir string) bool {
+	for _, root := range hostOS.systemLocations() {
+		if hasPathPrefix(dir, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	path, prefix = filepath.Clean(path), filepath.Clean(prefix)
+	if hostOS.foldsCase() {
+		path, prefix = strings.ToLower(path), strings.ToLower(prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+string(os.PathSeparator))
+}
+
+// checkWritable verifies up front that dir (or, if it doesn't exist yet, its
+// nearest existing ancestor) accepts new files, so a permissions problem is
+// reported before anything is downloaded.
+func checkWritable(dir string) error {
+	probe := dir
+	for {
+		if _, err := os.Stat(probe); err == nil {
+			break
+		}
+		parent := filepath.Dir(probe)
+		if parent == probe {
+			break
+		}
+		probe = parent
+	}
+	f, err := fsys.CreateTemp(probe, ".xmlui-write-test-")
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("no write access to %s\n%s", probe, elevationHint(dir))
+		}
+		return err
+	}
+	f.Close()
+	fsys.Remove(f.Name())
+	return nil
+}
+
+// elevationHint suggests how to install into dir with enough rights, or
+// where to install instead.
+func elevationHint(dir string) string {
+	home, _ := os.UserHomeDir()
+	alt := filepath.Join(home, "xmlui")
+	return hostOS.elevationHint(dir, alt)
+}
+
+// makeInstalledReadable normalizes the modes of what this install put in
+// installDir so non-admin users can read everything (and run the
+// executables) but not modify it, regardless of root's umask: the files rcpt
+// records and the directories holding them below installDir, and the trees
+// in created, which the install created or generated whole. Files get
+// fileMode, or execMode if they were executable, and directories dirMode.
+// Nothing else is touched: installDir may be the current directory or a
+// home directory, whose other files (~/.ssh, say) are none of the install's
+// business. Windows ACLs under Program Files already grant Users read
+// access, so it does nothing where modes don't decide.
+func makeInstalledReadable(installDir string, rcpt *receipt, created []string) error {
+	if !hostOS.execBits() {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, c := range rcpt.Components {
+		for key := range c.Files {
+			keys[key] = true
+		}
+		for _, b := range c.Binaries {
+			keys[b.Path] = true
+		}
+	}
+	dirs := map[string]bool{}
+	var firstErr error
+	normalize := func(path string, dir bool) {
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return
+		}
+		mode := fileMode
+		if dir {
+			mode = dirMode
+		} else if info.Mode()&0111 != 0 {
+			mode = execMode()
+		}
+		if info.Mode().Perm() == mode {
+			return
+		}
+		if err := fsys.Chmod(path, mode); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for key := range keys {
+		if strings.HasPrefix(key, "../") || path.IsAbs(key) {
+			// Relocated by a destination outside the install dir.
+			continue
+		}
+		normalize(filepath.Join(installDir, filepath.FromSlash(key)), false)
+		for dir := path.Dir(key); dir != "." && dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			normalize(filepath.Join(installDir, filepath.FromSlash(dir)), true)
+		}
+	}
+	for _, root := range created {
+		if rel, err := filepath.Rel(installDir, root); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err == nil {
+				normalize(path, d.IsDir())
+			}
+			return nil
+		})
+	}
+	return firstErr
+}
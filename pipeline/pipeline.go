@@ -0,0 +1,137 @@
+// Package pipeline models an install as an ordered list of steps. Each step
+// can describe what it will do and undo what it did, so a pipeline that
+// fails or is cancelled part way leaves nothing half-installed. Steps can be
+// inserted, removed and reordered before the pipeline runs. The launcher
+// runs every install through one, and its --dry-run prints that pipeline's
+// descriptions instead.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// InstallStep is one step of an install.
+type InstallStep interface {
+	// Name identifies the step within its pipeline, e.g. "download app".
+	Name() string
+	// Describe says what Execute will do, for a dry run.
+	Describe() string
+	// Execute performs the step. It should return promptly, with ctx's
+	// error, once ctx is done.
+	Execute(ctx context.Context) error
+	// Rollback undoes what Execute did, including a partial Execute that
+	// failed. It is called at most once, and only after Execute.
+	Rollback() error
+}
+
+// Pipeline is an ordered list of steps.
+type Pipeline struct {
+	Steps []InstallStep
+}
+
+// New returns a pipeline of steps, in order.
+func New(steps ...InstallStep) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Index returns the position of the step called name, or -1.
+func (p *Pipeline) Index(name string) int {
+	for i, s := range p.Steps {
+		if s.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Pipeline) find(name string) (int, error) {
+	i := p.Index(name)
+	if i < 0 {
+		return -1, fmt.Errorf("pipeline has no step %q", name)
+	}
+	return i, nil
+}
+
+// Insert puts s at position i, moving later steps along. An i past the end
+// appends.
+func (p *Pipeline) Insert(i int, s InstallStep) {
+	i = max(0, min(i, len(p.Steps)))
+	p.Steps = append(p.Steps[:i], append([]InstallStep{s}, p.Steps[i:]...)...)
+}
+
+// InsertBefore puts s just before the step called name.
+func (p *Pipeline) InsertBefore(name string, s InstallStep) error {
+	i, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	p.Insert(i, s)
+	return nil
+}
+
+// InsertAfter puts s just after the step called name.
+func (p *Pipeline) InsertAfter(name string, s InstallStep) error {
+	i, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	p.Insert(i+1, s)
+	return nil
+}
+
+// Remove takes out the step called name.
+func (p *Pipeline) Remove(name string) error {
+	i, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	p.Steps = append(p.Steps[:i], p.Steps[i+1:]...)
+	return nil
+}
+
+// Move moves the step called name to position i.
+func (p *Pipeline) Move(name string, i int) error {
+	j, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	s := p.Steps[j]
+	p.Steps = append(p.Steps[:j], p.Steps[j+1:]...)
+	p.Insert(i, s)
+	return nil
+}
+
+// Describe writes each step's Describe, numbered, to w.
+func (p *Pipeline) Describe(w io.Writer) {
+	for i, s := range p.Steps {
+		fmt.Fprintf(w, "%d. %s: %s\n", i+1, s.Name(), s.Describe())
+	}
+}
+
+// Run executes the steps in order. If one fails, or ctx is done between or
+// during steps, the steps run so far (the failed one included) are rolled
+// back in reverse order and the error is returned along with any rollback
+// errors.
+func (p *Pipeline) Run(ctx context.Context) error {
+	for i, s := range p.Steps {
+		err := ctx.Err()
+		if err == nil {
+			if err = s.Execute(ctx); err == nil {
+				continue
+			}
+			err = fmt.Errorf("%s: %w", s.Name(), err)
+			i++
+		}
+		errs := []error{err}
+		for j := i - 1; j >= 0; j-- {
+			if rerr := p.Steps[j].Rollback(); rerr != nil {
+				errs = append(errs, fmt.Errorf("rolling back %s: %w", p.Steps[j].Name(), rerr))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
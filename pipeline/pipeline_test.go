@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorder builds steps that log what they do and undo.
+type recorder struct {
+	log []string
+}
+
+func (r *recorder) step(label string, fail error) *Step {
+	return &Step{Label: label, Description: "run " + label, Work: Work{
+		Do: func(context.Context) error {
+			r.log = append(r.log, "do "+label)
+			return fail
+		},
+		Undo: func() error {
+			r.log = append(r.log, "undo "+label)
+			return nil
+		},
+	}}
+}
+
+func TestRunRollsBack(t *testing.T) {
+	var r recorder
+	p := New(r.step("a", nil), r.step("b", nil))
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"do a", "do b"}; !reflect.DeepEqual(r.log, want) {
+		t.Errorf("a run that succeeds did %q, want %q", r.log, want)
+	}
+
+	r.log = nil
+	boom := errors.New("boom")
+	p = New(r.step("a", nil), r.step("b", boom), r.step("c", nil))
+	err := p.Run(context.Background())
+	if !errors.Is(err, boom) || !strings.HasPrefix(err.Error(), "b: ") {
+		t.Errorf("Run of a failing step = %v, want b's error", err)
+	}
+	if want := []string{"do a", "do b", "undo b", "undo a"}; !reflect.DeepEqual(r.log, want) {
+		t.Errorf("a run that fails did %q, want %q", r.log, want)
+	}
+
+	r.log = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	a := r.step("a", nil)
+	do := a.Do
+	a.Do = func(ctx context.Context) error {
+		cancel()
+		return do(ctx)
+	}
+	err = New(a, r.step("b", nil)).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run cancelled during a step = %v, want %v", err, context.Canceled)
+	}
+	if want := []string{"do a", "undo a"}; !reflect.DeepEqual(r.log, want) {
+		t.Errorf("a run cancelled during a did %q, want %q", r.log, want)
+	}
+}
+
+func TestRollbackErrors(t *testing.T) {
+	stuck := errors.New("stuck")
+	p := New(
+		&Layout{Label: "a", Work: Work{Undo: func() error { return stuck }}},
+		&Download{Label: "b", Work: Work{Do: func(context.Context) error { return errors.New("boom") }}},
+	)
+	err := p.Run(context.Background())
+	if !errors.Is(err, stuck) || !strings.Contains(err.Error(), "rolling back layout a: stuck") {
+		t.Errorf("Run whose rollback fails = %v, want the rollback's error too", err)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	var r recorder
+	p := New(r.step("a", nil), r.step("c", nil))
+	if err := p.InsertBefore("c", r.step("b", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.InsertAfter("c", r.step("d", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Move("a", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("x"); err == nil {
+		t.Error("Remove of a missing step succeeded")
+	}
+	var names []string
+	for _, s := range p.Steps {
+		names = append(names, s.Name())
+	}
+	if want := []string{"b", "d", "a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("edited pipeline = %q, want %q", names, want)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	p := New(
+		&Download{Label: "app", URL: "https://example.com/app.zip"},
+		&Extract{Label: "app", Archive: "app.zip", Dest: "stage/app", Strip: 1},
+		&Layout{Label: "app", Dirs: []string{"mcp"}, Moves: []Move{{From: "stage/app", To: "app"}}},
+		&Chmod{Label: "app", Paths: []string{"app/start.sh"}, Mode: 0o755},
+		&Configure{Label: "Claude", Path: "claude.json"},
+		&Generate{Label: "env", Path: "env.sh"},
+	)
+	var b bytes.Buffer
+	p.Describe(&b)
+	want := `1. download app: fetch https://example.com/app.zip
+2. extract app: unpack app.zip into stage/app, dropping 1 leading path component(s)
+3. layout app: create mcp; move stage/app to app
+4. chmod app: set mode 0755 on app/start.sh
+5. configure Claude: update claude.json
+6. generate env: write env.sh
+`
+	if b.String() != want {
+		t.Errorf("Describe wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
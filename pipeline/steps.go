@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Work is what a step does and undoes. The step types below only say what
+// their step is; whoever builds the pipeline supplies the Work, so the
+// launcher's steps run its own downloader, unpacker and journal. A nil Do
+// does nothing and a nil Undo has nothing to undo.
+type Work struct {
+	Do   func(ctx context.Context) error
+	Undo func() error
+}
+
+// Execute runs Do.
+func (w Work) Execute(ctx context.Context) error {
+	if w.Do == nil {
+		return nil
+	}
+	return w.Do(ctx)
+}
+
+// Rollback runs Undo.
+func (w Work) Rollback() error {
+	if w.Undo == nil {
+		return nil
+	}
+	return w.Undo()
+}
+
+// Step is a step that names and describes itself, for work none of the
+// other types fits.
+type Step struct {
+	Label       string
+	Description string
+	Work
+}
+
+func (s *Step) Name() string { return s.Label }
+
+func (s *Step) Describe() string { return s.Description }
+
+// Download fetches URL, into the file Dest or, when Dest is "", into memory
+// for the next step.
+type Download struct {
+	Label string
+	URL   string
+	Dest  string
+	Work
+}
+
+func (d *Download) Name() string { return "download " + d.Label }
+
+func (d *Download) Describe() string {
+	if d.Dest == "" {
+		return "fetch " + d.URL
+	}
+	return fmt.Sprintf("fetch %s to %s", d.URL, d.Dest)
+}
+
+// Extract unpacks Archive into the directory Dest, dropping Strip leading
+// path components from each entry.
+type Extract struct {
+	Label   string
+	Archive string
+	Dest    string
+	Strip   int
+	Work
+}
+
+func (e *Extract) Name() string { return "extract " + e.Label }
+
+func (e *Extract) Describe() string {
+	s := fmt.Sprintf("unpack %s into %s", e.Archive, e.Dest)
+	if e.Strip > 0 {
+		s += fmt.Sprintf(", dropping %d leading path component(s)", e.Strip)
+	}
+	return s
+}
+
+// Move is one move of a Layout.
+type Move struct {
+	From, To string
+}
+
+// Layout creates the directories Dirs and moves files or trees into place.
+type Layout struct {
+	Label string
+	Dirs  []string
+	Moves []Move
+	Work
+}
+
+func (l *Layout) Name() string { return "layout " + l.Label }
+
+func (l *Layout) Describe() string {
+	var parts []string
+	if len(l.Dirs) > 0 {
+		parts = append(parts, "create "+strings.Join(l.Dirs, ", "))
+	}
+	for _, m := range l.Moves {
+		parts = append(parts, fmt.Sprintf("move %s to %s", m.From, m.To))
+	}
+	if len(parts) == 0 {
+		return "nothing to move"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Chmod sets Mode on Paths.
+type Chmod struct {
+	Label string
+	Paths []string
+	Mode  fs.FileMode
+	Work
+}
+
+func (c *Chmod) Name() string { return "chmod " + c.Label }
+
+func (c *Chmod) Describe() string {
+	return fmt.Sprintf("set mode %#o on %s", c.Mode, strings.Join(c.Paths, ", "))
+}
+
+// Configure updates an existing config file, such as an MCP client's, at
+// Path.
+type Configure struct {
+	Label string
+	Path  string
+	Work
+}
+
+func (c *Configure) Name() string { return "configure " + c.Label }
+
+func (c *Configure) Describe() string { return "update " + c.Path }
+
+// Generate writes a file the install renders rather than downloads, at
+// Path.
+type Generate struct {
+	Label string
+	Path  string
+	Work
+}
+
+func (g *Generate) Name() string { return "generate " + g.Label }
+
+func (g *Generate) Describe() string { return "write " + g.Path }
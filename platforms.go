@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// platform is an OS/architecture pair the MCP tools and test server are
+// released for.
+type platform struct {
+	OS, Arch string
+}
+
+// supportedPlatforms are the builds --all-platforms fetches; each needs an
+// entry in every releaseAssets table.
+var supportedPlatforms = []platform{
+	{"darwin", "arm64"},
+	{"darwin", "amd64"},
+	{"linux", "amd64"},
+	{"windows", "amd64"},
+}
+
+func (p platform) String() string { return p.OS + "-" + p.Arch }
+
+func (p platform) exe() string { return p.osPlatform().exeSuffix() }
+
+// binDir is the subdirectory holding this platform's binaries in an
+// --all-platforms install.
+func (p platform) binDir() string { return "bin-" + p.String() }
+
+// unamePattern is a sh case pattern matching "$(uname -s)-$(uname -m)" on p.
+func (p platform) unamePattern() string {
+	machine := map[string]string{"amd64": "x86_64", "arm64": "arm64"}[p.Arch]
+	switch p.OS {
+	case "darwin":
+		return "Darwin-" + machine
+	case "linux":
+		if p.Arch == "arm64" {
+			machine = "aarch64"
+		}
+		return "Linux-" + machine
+	case "windows":
+		// Git Bash, MSYS2 and Cygwin.
+		return "MINGW*|MSYS*|CYGWIN*"
+	}
+	return p.String()
+}
+
+// thisMachine is the platform this process runs on, supported or not.
+func thisMachine() platform { return platform{hostOS.goos(), runtime.GOARCH} }
+
+// hostPlatform returns the supported platform this process runs on, if any.
+func hostPlatform() (platform, bool) {
+	for _, p := range supportedPlatforms {
+		if p == thisMachine() {
+			return p, true
+		}
+	}
+	return platform{}, false
+}
+
+// crossProvisioning reports whether the install is for another platform
+// than this machine's, with --target-os or --target-arch.
+func (opts installOptions) crossProvisioning() bool {
+	return opts.target != thisMachine()
+}
+
+// checkTarget rejects a --target-os/--target-arch without release builds,
+// and the flags that act on this machine, which isn't the one the install
+// is for.
+func (opts installOptions) checkTarget() error {
+	if !opts.crossProvisioning() {
+		return nil
+	}
+	if !slices.Contains(supportedPlatforms, opts.target) {
+		names := make([]string, len(supportedPlatforms))
+		for i, p := range supportedPlatforms {
+			names[i] = p.String()
+		}
+		return fmt.Errorf("--target-os/--target-arch %s has no MCP tools and test server builds; want one of %s", opts.target, strings.Join(names, ", "))
+	}
+	for _, f := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--all-platforms", opts.allPlatforms},
+		{"--add-to-path", opts.addToPath},
+		{"--configure-*", len(opts.configure) > 0},
+		{"--verify-mcp", opts.verifyMCP},
+		{"--verify-server", opts.verifyServer},
+		{"--powershell-profile", opts.windows.profile},
+		{"--shortcut", opts.windows.shortcut},
+	} {
+		if f.set {
+			return fmt.Errorf("%s can't be used with --target-os/--target-arch %s: it acts on this machine, not the one the install is for", f.flag, opts.target)
+		}
+	}
+	return nil
+}
+
+// stageAllPlatforms downloads, using fetch, the archive for every supported
+// platform and assembles them under stage/name: each platform's copy of binaries goes
+// in its binDir, other files that keep accepts go at the top (first platform
+// wins), and each binary gets a dispatch script that runs the right build.
+// It returns the assembled directory and the host platform's URL (or the
+// first one's, on an unsupported host).
+func stageAllPlatforms(stage, name string, fetch func(platform) ([]byte, string, error), binaries []string, keep func(rel string) bool) (string, string, error) {
+	out := filepath.Join(stage, name)
+	if err := fsys.MkdirAll(out, dirMode); err != nil {
+		return "", "", err
+	}
+	isBinary := map[string]bool{}
+	for _, b := range binaries {
+		isBinary[b] = true
+	}
+	hostURL := ""
+	for _, p := range supportedPlatforms {
+		status.setState("downloading")
+		data, url, err := fetch(p)
+		if err != nil {
+			return "", "", err
+		}
+		if host, ok := hostPlatform(); hostURL == "" || ok && host == p {
+			hostURL = url
+		}
+		status.setState("extracting")
+		tmp := filepath.Join(stage, name+"-"+p.String())
+		if err := unpackAsset(data, tmp, releaseAssets[name].Name+p.exe()); err != nil {
+			return "", "", fmt.Errorf("extracting %s build: %w", p, err)
+		}
+		if p == supportedPlatforms[0] {
+			collectLicenses(name, releaseAssets[name].Name, tmp)
+		}
+		err = filepath.Walk(tmp, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(tmp, path)
+			if err != nil {
+				return err
+			}
+			var dst string
+			if isBinary[strings.TrimSuffix(info.Name(), ".exe")] {
+				dst = filepath.Join(out, p.binDir(), info.Name())
+			} else if keep == nil || keep(filepath.ToSlash(rel)) {
+				dst = filepath.Join(out, rel)
+				if _, err := os.Stat(dst); err == nil {
+					return nil
+				}
+			} else {
+				return nil
+			}
+			if err := fsys.MkdirAll(filepath.Dir(dst), dirMode); err != nil {
+				return err
+			}
+			return movePath(path, dst)
+		})
+		if err != nil {
+			return "", "", err
+		}
+		fsys.RemoveAll(tmp)
+	}
+	if err := writeDispatchers(out, binaries); err != nil {
+		return "", "", err
+	}
+	// Zip entries don't carry Unix modes through extraction, and every
+	// platform's scripts must be runnable whichever machine did the install.
+	err := filepath.Walk(out, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if n := info.Name(); strings.HasSuffix(n, ".sh") || !strings.Contains(n, ".") {
+			return chmodExec(path)
+		}
+		return nil
+	})
+	return out, hostURL, err
+}
+
+// writeDispatchers writes, for each binary, a POSIX shell script and a
+// Windows .cmd of the same name that run the build for the current machine
+// out of its binDir.
+func writeDispatchers(dir string, binaries []string) error {
+	var cases strings.Builder
+	for _, p := range supportedPlatforms {
+		fmt.Fprintf(&cases, "%s) bin=%s exe=%s ;;\n", p.unamePattern(), p.binDir(), p.exe())
+	}
+	for _, name := range binaries {
+		sh := "#!/bin/sh\n" +
+			"# Written by xmlui-bundler --all-platforms: runs the " + name + " build for this machine.\n" +
+			"case \"$(uname -s)-$(uname -m)\" in\n" +
+			cases.String() +
+			"*) echo \"" + name + ": no build for $(uname -s) $(uname -m)\" >&2; exit 1 ;;\n" +
+			"esac\n" +
+			"exec \"$(dirname \"$0\")/$bin/" + name + "$exe\" \"$@\"\n"
+		if err := fsys.WriteFile(filepath.Join(dir, name), []byte(sh), execMode()); err != nil {
+			return err
+		}
+		cmd := "@echo off\r\n" +
+			"rem Written by xmlui-bundler --all-platforms: runs the Windows build of " + name + ".\r\n" +
+			"\"%~dp0" + platform{"windows", "amd64"}.binDir() + "\\" + name + ".exe\" %*\r\n"
+		if err := fsys.WriteFile(filepath.Join(dir, name+".cmd"), []byte(cmd), fileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// platformBinaries lists the paths of every platform's copy of binaries
+// under dir, as laid out by stageAllPlatforms.
+func platformBinaries(dir string, binaries []string) []string {
+	var paths []string
+	for _, p := range supportedPlatforms {
+		for _, b := range binaries {
+			path := filepath.Join(dir, p.binDir(), b+p.exe())
+			if _, err := os.Stat(path); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// runPlayground implements `playground`: a throwaway install for a quick
+// demo. Everything goes into one temporary directory (the install, its
+// state and the download cache) which is deleted when the server stops, so
+// nothing is left behind. Flags after -- are passed to the install.
+func runPlayground(args []string) int {
+	fs := newFlagSet("playground")
+	port := fs.Int("port", 0, "port to serve the app on (default: 8080, or a free one if that is taken)")
+	keep := fs.Bool("keep", false, "leave the playground directory in place when the server stops")
+	fs.Parse(args)
+
+	if *port == 0 {
+		*port = 8080
+		if !portFree(*port) {
+			p, err := freeLocalPort()
+			if err != nil {
+				fmt.Println("Could not find a free port:", err)
+				return exitFailure
+			}
+			*port = p
+		}
+	}
+	root, err := os.MkdirTemp("", "xmlui-playground-")
+	if err != nil {
+		fmt.Println("Could not create the playground directory:", err)
+		return exitFailure
+	}
+	installDir := filepath.Join(root, "install")
+	defer func() {
+		if *keep {
+			fmt.Printf("Kept the playground in %s\n", root)
+			return
+		}
+		if err := fsys.RemoveAll(root); err != nil {
+			warn("Could not remove the playground %s: %v", root, err)
+			return
+		}
+		fmt.Printf("%s Removed the playground %s\n", glyphOK, root)
+	}()
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Println("Could not find this executable:", err)
+		return exitFailure
+	}
+	// The install and serve runs keep their bookkeeping and downloads in
+	// the playground too.
+	env := append(os.Environ(),
+		"XDG_STATE_HOME="+filepath.Join(root, "state"),
+		"XDG_CACHE_HOME="+filepath.Join(root, "cache"))
+	run := func(args ...string) error {
+		cmd := exec.Command(self, args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Env = env
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		// Ctrl-C reaches the child as well, which stops on its own; this
+		// process stays to clean up, passing on a SIGTERM sent to it alone.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		go func() {
+			for sig := range sigs {
+				if sig != os.Interrupt {
+					cmd.Process.Signal(sig)
+				}
+			}
+		}()
+		return cmd.Wait()
+	}
+
+	fmt.Printf("Installing a playground in %s\n", installDir)
+	installArgs := append([]string{"--dir", installDir, "--port", fmt.Sprint(*port), "--no-scripts"}, fs.Args()...)
+	if err := run(installArgs...); err != nil {
+		fmt.Println("The playground install failed:", err)
+		return exitFailure
+	}
+	fmt.Println()
+	fmt.Println("Serving the playground; press Ctrl-C to stop it and delete everything")
+	// serve reports its own failures, and exits non-zero after Ctrl-C too.
+	var exitErr *exec.ExitError
+	if err := run("serve", "--dir", installDir, "--port", fmt.Sprint(*port)); err != nil && !errors.As(err, &exitErr) {
+		fmt.Println("Could not serve the playground:", err)
+		return exitFailure
+	}
+	return exitOK
+}
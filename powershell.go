@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// psModuleFile is the PowerShell module a Windows install writes into mcp/.
+const psModuleFile = "XmluiLauncher.psm1"
+
+// windowsScripts holds the Windows-only install flags.
+type windowsScripts struct {
+	// profile imports the module from the user's PowerShell profiles.
+	profile bool
+	// shortcut adds a Start menu shortcut that starts the app.
+	shortcut bool
+	// signWith is the thumbprint of a code-signing certificate in
+	// Cert:\CurrentUser\My to sign the generated .ps1 and .psm1 files with.
+	signWith string
+}
+
+// crlf gives s Windows line endings, whatever it had.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// psQuote quotes s as a PowerShell single-quoted string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// writeScript writes a generated Windows script with CRLF line endings,
+// removes any Mark of the Web so execution policies treat it as local, and
+// signs it if a certificate was given.
+func (w windowsScripts) writeScript(path, content string) error {
+	if err := fsys.WriteFile(path, []byte(crlf(content)), fileMode); err != nil {
+		return err
+	}
+	unblockFile(path)
+	if w.signWith != "" && (strings.HasSuffix(path, ".ps1") || strings.HasSuffix(path, ".psm1")) {
+		if err := signScript(path, w.signWith); err != nil {
+			return fmt.Errorf("signing %s: %w", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// psModule is the module Import-Module loads: commands to start the app and
+// the MCP server of this install from any PowerShell session. mcpDir is
+// where the MCP binaries are.
+func psModule(installDir, mcpDir, appDir, serverDir string, port int) string {
+	return fmt.Sprintf(`# XMLUI launcher module, generated by xmlui-bundler for %[1]s.
+# Import-Module this file, or install with --powershell-profile to load it in
+# every session.
+
+$XmluiInstallDir = %[2]s
+$XmluiMcpDir = %[3]s
+$XmluiAppDir = %[4]s
+$XmluiServerDir = %[6]s
+
+function Start-XmluiApp {
+    [CmdletBinding()]
+    param([int]$Port = %[5]d)
+    $env:PORT = $Port
+    Push-Location -LiteralPath $XmluiAppDir
+    try {
+        if (Test-Path -LiteralPath 'start.bat') { & cmd.exe /c start.bat }
+        elseif (Test-Path -LiteralPath 'xmlui-test-server.cmd') { & cmd.exe /c xmlui-test-server.cmd }
+        else { & (Join-Path $XmluiServerDir 'xmlui-test-server.exe') }
+    } finally {
+        Pop-Location
+    }
+}
+
+function Start-XmluiMcp {
+    $exe = Join-Path $XmluiMcpDir 'xmlui-mcp.exe'
+    if (-not (Test-Path -LiteralPath $exe)) { $exe = Join-Path $XmluiMcpDir 'xmlui-mcp.cmd' }
+    & $exe @args
+}
+
+function Set-XmluiLocation {
+    Set-Location -LiteralPath $XmluiInstallDir
+}
+
+if (($env:Path -split ';') -notcontains $XmluiMcpDir) {
+    $env:Path = "$XmluiMcpDir;$env:Path"
+}
+
+Export-ModuleMember -Function Start-XmluiApp, Start-XmluiMcp, Set-XmluiLocation
+`, installDir, psQuote(installDir), psQuote(mcpDir), psQuote(appDir), port, psQuote(serverDir))
+}
+
+// psCleanup is cleanup.ps1, the PowerShell twin of cleanup.bat.
+func psCleanup(launcher string) string {
+	return fmt.Sprintf(`Write-Host 'Cleaning up temporary files...'
+Set-Location -LiteralPath $PSScriptRoot
+Remove-Item -LiteralPath %s -ErrorAction SilentlyContinue
+Remove-Item -Path *.zip -ErrorAction SilentlyContinue
+Remove-Item -LiteralPath cleanup.bat, cleanup.ps1 -ErrorAction SilentlyContinue
+`, psQuote(launcher))
+}
+
+// installWindowsScripts writes the PowerShell module and cleanup.ps1 and, as
+// asked, hooks the module into the PowerShell profiles and adds a Start menu
+// shortcut. Problems are warnings: the install itself is complete.
+func installWindowsScripts(w windowsScripts, installDir, mcpDir, mcpBinDir, appDir, serverDir string, port int, cleanup bool) {
+	module := filepath.Join(mcpDir, psModuleFile)
+	if err := w.writeScript(module, psModule(installDir, mcpBinDir, appDir, serverDir, port)); err != nil {
+		warn("  Could not write %s: %v", psModuleFile, err)
+		return
+	}
+	fmt.Printf("  PowerShell: Import-Module %s (Start-XmluiApp, Start-XmluiMcp)\n", psQuote(module))
+	if cleanup {
+		if err := w.writeScript(filepath.Join(installDir, "cleanup.ps1"), psCleanup(filepath.Base(os.Args[0]))); err != nil {
+			warn("  Could not write cleanup.ps1: %v", err)
+		}
+	}
+	if w.profile {
+		block := profileBlockStart + "\n" + "Import-Module " + psQuote(module) + "\n" + profileBlockEnd + "\n"
+		profiles, err := addToPowerShellProfiles(block, w)
+		if err != nil {
+			warn("  Could not update the PowerShell profile: %v", err)
+		} else {
+			fmt.Printf("%s Added Import-Module to %s\n", glyphOK, strings.Join(profiles, ", "))
+		}
+	}
+	if w.shortcut {
+		name := "XMLUI " + filepath.Base(appDir)
+		target, args := filepath.Join(serverDir, "xmlui-test-server.exe"), ""
+		if _, err := os.Stat(filepath.Join(appDir, "start.bat")); err == nil {
+			target, args = os.Getenv("ComSpec"), "/c start.bat"
+			if target == "" {
+				target = `C:\Windows\System32\cmd.exe`
+			}
+		}
+		where, err := createShortcut(name, target, args, appDir, "Start the "+filepath.Base(appDir)+" app with the XMLUI test server")
+		if err != nil {
+			warn("  Could not create the Start menu shortcut: %v", err)
+		} else {
+			fmt.Printf("%s Added Start menu shortcut %s\n", glyphOK, where)
+		}
+	}
+}
+
+// addToPowerShellProfiles writes block into the current-user profile of
+// both Windows PowerShell 5.1 and PowerShell 7, replacing an earlier one.
+func addToPowerShellProfiles(block string, w windowsScripts) ([]string, error) {
+	docs, err := documentsDir()
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, shell := range []string{"WindowsPowerShell", "PowerShell"} {
+		p := filepath.Join(docs, shell, "Microsoft.PowerShell_profile.ps1")
+		existing, err := os.ReadFile(p)
+		if err != nil && !os.IsNotExist(err) {
+			return written, err
+		}
+		content := strings.ReplaceAll(string(existing), "\r\n", "\n")
+		// Editing the profile voids its signature; writeScript re-signs it
+		// with --sign-scripts.
+		if i := strings.Index(content, "# SIG # Begin signature block"); i >= 0 {
+			content = content[:i]
+		}
+		content = replaceProfileBlock(content, block)
+		if err := fsys.MkdirAll(filepath.Dir(p), dirMode); err != nil {
+			return written, err
+		}
+		if err := w.writeScript(p, content); err != nil {
+			return written, err
+		}
+		written = append(written, p)
+	}
+	return written, nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// errWindowsOnly is returned by the Windows-native helpers elsewhere.
+var errWindowsOnly = errors.New("only supported on Windows")
+
+// unblockFile has no Mark of the Web to remove outside Windows.
+func unblockFile(path string) {}
+
+func signScript(path, thumbprint string) error { return errWindowsOnly }
+
+func createShortcut(name, target, args, workDir, description string) (string, error) {
+	return "", errWindowsOnly
+}
+
+func documentsDir() (string, error) { return "", errWindowsOnly }
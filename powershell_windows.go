@@ -0,0 +1,135 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// unblockFile deletes path's Zone.Identifier stream, the Mark of the Web
+// that makes RemoteSigned refuse unsigned scripts.
+func unblockFile(path string) {
+	fsys.Remove(path + ":Zone.Identifier")
+}
+
+// signScript signs path with the code-signing certificate of thumbprint in
+// the user's certificate store, using PowerShell's own cmdlet.
+func signScript(path, thumbprint string) error {
+	script := fmt.Sprintf(`$c = Get-Item -LiteralPath %s -ErrorAction Stop; `+
+		`$s = Set-AuthenticodeSignature -LiteralPath %s -Certificate $c; `+
+		`if ($s.Status -ne 'Valid') { throw $s.StatusMessage }`,
+		psQuote(`Cert:\CurrentUser\My\`+thumbprint), psQuote(path))
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// documentsDir is the user's Documents folder, which may be redirected,
+// e.g. to OneDrive.
+func documentsDir() (string, error) {
+	return windows.KnownFolderPath(windows.FOLDERID_Documents, 0)
+}
+
+var (
+	ole32                = windows.NewLazySystemDLL("ole32.dll")
+	procCoCreateInstance = ole32.NewProc("CoCreateInstance")
+
+	clsidShellLink  = windows.GUID{Data1: 0x00021401, Data4: [8]byte{0xC0, 0, 0, 0, 0, 0, 0, 0x46}}
+	iidIShellLinkW  = windows.GUID{Data1: 0x000214F9, Data4: [8]byte{0xC0, 0, 0, 0, 0, 0, 0, 0x46}}
+	iidIPersistFile = windows.GUID{Data1: 0x0000010B, Data4: [8]byte{0xC0, 0, 0, 0, 0, 0, 0, 0x46}}
+)
+
+// comObject is a COM interface pointer: the object starts with its vtable.
+type comObject struct{ vtbl *[21]uintptr }
+
+// Vtable slots used below.
+const (
+	vtQueryInterface = 0
+	vtRelease        = 2
+	// IShellLinkW
+	vtSetDescription      = 7
+	vtSetWorkingDirectory = 9
+	vtSetArguments        = 11
+	vtSetPath             = 20
+	// IPersistFile
+	vtSave = 6
+)
+
+func (o *comObject) call(slot int, args ...uintptr) error {
+	hr, _, _ := syscall.SyscallN(o.vtbl[slot], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	if int32(hr) < 0 {
+		return syscall.Errno(hr)
+	}
+	return nil
+}
+
+func (o *comObject) callString(slot int, s string) error {
+	p, err := windows.UTF16PtrFromString(s)
+	if err != nil {
+		return err
+	}
+	return o.call(slot, uintptr(unsafe.Pointer(p)))
+}
+
+// createShortcut saves a shortcut named name in the user's Start menu
+// Programs folder through the shell's IShellLink, and returns its path.
+func createShortcut(name, target, args, workDir, description string) (string, error) {
+	programs, err := windows.KnownFolderPath(windows.FOLDERID_Programs, 0)
+	if err != nil {
+		return "", err
+	}
+	lnk := filepath.Join(programs, name+".lnk")
+
+	// COM calls must stay on the thread that initialized it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := windows.CoInitializeEx(0, windows.COINIT_APARTMENTTHREADED); err != nil && err != syscall.Errno(windows.S_FALSE) {
+		return "", err
+	}
+	defer windows.CoUninitialize()
+
+	var link *comObject
+	const clsctxInprocServer = 1
+	hr, _, _ := procCoCreateInstance.Call(uintptr(unsafe.Pointer(&clsidShellLink)), 0, clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidIShellLinkW)), uintptr(unsafe.Pointer(&link)))
+	if int32(hr) < 0 {
+		return "", fmt.Errorf("CoCreateInstance(ShellLink): %w", syscall.Errno(hr))
+	}
+	defer link.call(vtRelease)
+	for _, set := range []struct {
+		slot  int
+		value string
+	}{{vtSetPath, target}, {vtSetArguments, args}, {vtSetWorkingDirectory, workDir}, {vtSetDescription, description}} {
+		if err := link.callString(set.slot, set.value); err != nil {
+			return "", err
+		}
+	}
+
+	var file *comObject
+	if err := link.call(vtQueryInterface, uintptr(unsafe.Pointer(&iidIPersistFile)), uintptr(unsafe.Pointer(&file))); err != nil {
+		return "", err
+	}
+	defer file.call(vtRelease)
+	p, err := windows.UTF16PtrFromString(lnk)
+	if err != nil {
+		return "", err
+	}
+	if err := file.call(vtSave, uintptr(unsafe.Pointer(p)), 1); err != nil {
+		return "", err
+	}
+	audit.record(auditWrite, lnk, "", 0)
+	return lnk, nil
+}
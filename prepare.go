@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runMCPPrepare implements `mcp prepare`: what prepare-binaries.sh does,
+// the same way on every platform. It makes the installed binaries and
+// scripts executable and clears the download quarantine (macOS's
+// com.apple.quarantine, Windows' mark of the web) that would otherwise stop
+// them on first run, e.g. after the install was copied from another
+// machine.
+func runMCPPrepare(args []string) int {
+	fs := newFlagSet("mcp prepare")
+	dir := installDirFlag(fs)
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+
+	var paths []string
+	for _, c := range rcpt.Components {
+		for _, b := range c.Binaries {
+			paths = append(paths, filepath.Join(installDir, filepath.FromSlash(b.Path)))
+		}
+	}
+	ext := hostOS.scriptExt()
+	dirs := []string{filepath.Join(installDir, rcpt.toolsDir())}
+	if !rcpt.Flat {
+		dirs = append(dirs, filepath.Join(installDir, filepath.FromSlash(rcpt.appDir())))
+	}
+	paths = append(paths, installedScripts(ext, dirs...)...)
+
+	prepared, failed := 0, 0
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if hostOS.execBits() {
+			if err := chmodExec(p); err != nil {
+				fmt.Printf("%s %s: %v\n", glyphFail, receiptKey(installDir, p), err)
+				failed++
+				continue
+			}
+		}
+		if err := clearQuarantine(p); err != nil {
+			fmt.Printf("%s %s: could not clear the quarantine: %v\n", glyphFail, receiptKey(installDir, p), err)
+			failed++
+			continue
+		}
+		prepared++
+	}
+	fmt.Printf("%s Prepared %d binaries and scripts in %s\n", glyphOK, prepared, installDir)
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// checkQuarantine looks for the download quarantine (Windows' mark of the
+// web, macOS's com.apple.quarantine) on installed binaries, which makes
+// SmartScreen or Gatekeeper stop each one the first time it runs. With
+// unblock it clears it; otherwise it says how.
+func checkQuarantine(installDir string, paths []string, unblock bool) {
+	var marked []string
+	for _, p := range paths {
+		if !quarantined(p) {
+			continue
+		}
+		if unblock {
+			if err := clearQuarantine(p); err != nil {
+				warn("Could not clear the quarantine of %s: %v", receiptKey(installDir, p), err)
+				marked = append(marked, p)
+				continue
+			}
+			fmt.Printf("  %s Cleared the quarantine of %s\n", glyphOK, receiptKey(installDir, p))
+			continue
+		}
+		marked = append(marked, p)
+	}
+	if len(marked) == 0 {
+		return
+	}
+	warn("%d installed binaries are marked as downloaded from the internet; %s", len(marked), hostOS.quarantineEffect())
+	fmt.Println("  To unblock them, re-run with --unblock, run `xmlui-bundler mcp prepare`, or run:")
+	for _, p := range marked {
+		fmt.Printf("    %s\n", hostOS.unquarantineCommand(p))
+	}
+}
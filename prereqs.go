@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bashisms are constructs a plain POSIX sh (dash, busybox ash) rejects or
+// misreads, keyed by how a warning names them.
+var bashisms = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"[[ tests", regexp.MustCompile(`\[\[`)},
+	{"function definitions", regexp.MustCompile(`(^|[;&|]\s*)function\s+\w+`)},
+	{"source", regexp.MustCompile(`(^|[;&|]\s*)source\s`)},
+	{"arrays", regexp.MustCompile(`\w+=\(|\$\{\w+\[`)},
+	{"${var//...} substitutions", regexp.MustCompile(`\$\{[^}]*(//|\^\^|,,|:[0-9-])`)},
+	{"here-strings", regexp.MustCompile(`<<<`)},
+	{"$'...' strings", regexp.MustCompile(`\$'`)},
+	{"&> redirects", regexp.MustCompile(`&>`)},
+	{"declare, shopt, pushd or popd", regexp.MustCompile(`(^|[;&|]\s*)(declare|shopt|pushd|popd)\b`)},
+	{"BASH_SOURCE or RANDOM", regexp.MustCompile(`\$\{?(BASH_SOURCE|RANDOM)\b`)},
+}
+
+// scriptTools are the commands installed scripts commonly rely on that a
+// minimal container may lack.
+var scriptTools = []string{"dirname", "basename", "chmod", "xattr", "readlink", "realpath", "uname", "curl", "wget", "unzip", "tar"}
+
+// shebang splits a script's #! line into the interpreter path and its first
+// argument, e.g. "/usr/bin/env", "bash". It returns "" without one.
+func shebang(data []byte) (interp, arg string) {
+	if !bytes.HasPrefix(data, []byte("#!")) {
+		return "", ""
+	}
+	line, _, _ := bytes.Cut(data[2:], []byte("\n"))
+	fields := strings.Fields(strings.TrimSuffix(string(line), "\r"))
+	if len(fields) == 0 {
+		return "", ""
+	}
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+	return fields[0], arg
+}
+
+// interpreterName is the program a shebang runs: bash for both
+// #!/bin/bash and #!/usr/bin/env bash.
+func interpreterName(interp, arg string) string {
+	if filepath.Base(interp) == "env" && arg != "" {
+		return arg
+	}
+	return filepath.Base(interp)
+}
+
+// findBashisms names the bash-only constructs in script, with the line
+// each first appears on, ignoring comments.
+func findBashisms(script []byte) []string {
+	var found []string
+	lines := strings.Split(string(script), "\n")
+	for _, b := range bashisms {
+		for i, line := range lines {
+			if t := strings.TrimSpace(line); t == "" || strings.HasPrefix(t, "#") {
+				continue
+			}
+			if b.pattern.MatchString(line) {
+				found = append(found, fmt.Sprintf("%s (line %d)", b.name, i+1))
+				break
+			}
+		}
+	}
+	return found
+}
+
+// toolUse matches a command word at the start of a line, after an
+// operator, paren or backquote, or after then, do or else.
+var toolUse = regexp.MustCompile("(?m)(?:^|[;&|(`]|\\b(?:then|do|else))\\s*(" + strings.Join(scriptTools, "|") + ")(?:\\s|$)")
+
+// usedTools lists the scriptTools script runs, sorted.
+func usedTools(script []byte) []string {
+	seen := map[string]bool{}
+	var used []string
+	for _, m := range toolUse.FindAllSubmatch(script, -1) {
+		if tool := string(m[1]); !seen[tool] {
+			seen[tool] = true
+			used = append(used, tool)
+		}
+	}
+	sort.Strings(used)
+	return used
+}
+
+// installedScripts lists the shell or batch scripts directly in dirs.
+func installedScripts(ext string, dirs ...string) []string {
+	var scripts []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ext) {
+				scripts = append(scripts, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return scripts
+}
+
+// rehashRecorded updates the receipt's hash of path after the launcher
+// changed it, so doctor doesn't report it as damaged.
+func rehashRecorded(installDir string, rcpt *receipt, path string) {
+	key := receiptKey(installDir, path)
+	sum, err := hashFile(path)
+	if err != nil {
+		return
+	}
+	for i := range rcpt.Components {
+		if _, ok := rcpt.Components[i].Files[key]; ok {
+			rcpt.Components[i].Files[key] = sum
+		}
+	}
+}
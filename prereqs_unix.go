@@ -0,0 +1,84 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// scriptPrereqs checks that this machine can run the shell scripts directly
+// in dirs: that sh is on PATH (serve runs start.sh with it), that each
+// script's interpreter exists and that the tools it calls are installed. A
+// script asking for a missing bash or zsh that uses nothing beyond POSIX sh
+// is switched to #!/bin/sh when adapt is set, and its recorded hash
+// updated. It returns the problems left, one per line.
+func scriptPrereqs(installDir string, rcpt *receipt, adapt bool, dirs ...string) []string {
+	scripts := installedScripts(".sh", dirs...)
+	if len(scripts) == 0 {
+		return nil
+	}
+	var problems []string
+	sh, shErr := exec.LookPath("sh")
+	if shErr != nil {
+		problems = append(problems, "sh is not on PATH; serve and the installed scripts need a POSIX shell")
+	}
+	for _, script := range scripts {
+		data, err := os.ReadFile(script)
+		if err != nil {
+			continue
+		}
+		rel := receiptKey(installDir, script)
+		if interp, arg := shebang(data); interp != "" && !interpreterFound(interp, arg) {
+			name := interpreterName(interp, arg)
+			bashisms := findBashisms(data)
+			switch {
+			case adapt && shErr == nil && len(bashisms) == 0:
+				shell := "/bin/sh"
+				if _, err := os.Stat(shell); err != nil {
+					shell = sh
+				}
+				_, rest, _ := bytes.Cut(data, []byte("\n"))
+				info, _ := os.Stat(script)
+				if err := fsys.WriteFile(script, append([]byte("#!"+shell+"\n"), rest...), info.Mode().Perm()); err != nil {
+					problems = append(problems, fmt.Sprintf("%s needs %s, which this machine doesn't have, and could not be switched to sh: %v", rel, name, err))
+					continue
+				}
+				rehashRecorded(installDir, rcpt, script)
+				fmt.Printf("  %s asks for %s, which this machine doesn't have; it only uses POSIX sh, so it now runs with %s\n", rel, name, shell)
+			case len(bashisms) > 0:
+				problems = append(problems, fmt.Sprintf("%s needs %s, which this machine doesn't have (it uses %s); install %s, e.g. apk add %s or apt-get install %s", rel, name, strings.Join(bashisms, ", "), name, name, name))
+			default:
+				problems = append(problems, fmt.Sprintf("%s needs %s, which this machine doesn't have; install it or run the script with sh", rel, interp))
+			}
+		}
+		var missing []string
+		for _, tool := range usedTools(data) {
+			if _, err := exec.LookPath(tool); err != nil {
+				missing = append(missing, tool)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s runs %s, not found on PATH", rel, strings.Join(missing, ", ")))
+		}
+	}
+	return problems
+}
+
+// interpreterFound reports whether a #! line's interpreter exists: the path
+// itself, or for /usr/bin/env NAME, NAME on PATH.
+func interpreterFound(interp, arg string) bool {
+	if filepath.Base(interp) == "env" && arg != "" {
+		if _, err := os.Stat(interp); err != nil {
+			return false
+		}
+		_, err := exec.LookPath(arg)
+		return err == nil
+	}
+	info, err := os.Stat(interp)
+	return err == nil && !info.IsDir()
+}
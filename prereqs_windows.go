@@ -0,0 +1,47 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// scriptPrereqs checks that this machine can run the batch scripts directly
+// in dirs: that cmd.exe is there and that its command extensions, which
+// the scripts' if/set forms rely on, aren't turned off. adapt is unused: a
+// batch file has no interpreter to swap. It returns the problems found, one
+// per line.
+func scriptPrereqs(installDir string, rcpt *receipt, adapt bool, dirs ...string) []string {
+	scripts := installedScripts(".bat", dirs...)
+	if len(scripts) == 0 {
+		return nil
+	}
+	var problems []string
+	cmd := os.Getenv("ComSpec")
+	if cmd == "" {
+		cmd = filepath.Join(os.Getenv("SystemRoot"), "System32", "cmd.exe")
+	}
+	if _, err := os.Stat(cmd); err != nil {
+		problems = append(problems, fmt.Sprintf("cmd.exe was not found at %s; the installed .bat scripts need it", cmd))
+	}
+	for _, root := range []struct {
+		key  registry.Key
+		name string
+	}{{registry.CURRENT_USER, "HKCU"}, {registry.LOCAL_MACHINE, "HKLM"}} {
+		k, err := registry.OpenKey(root.key, `Software\Microsoft\Command Processor`, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		v, _, err := k.GetIntegerValue("EnableExtensions")
+		k.Close()
+		if err == nil && v == 0 {
+			problems = append(problems, fmt.Sprintf(`cmd command extensions are turned off (%s\Software\Microsoft\Command Processor\EnableExtensions = 0); the installed scripts need them when run by hand: set it to 1, or run them with cmd /e:on /c (serve already does)`, root.name))
+			break
+		}
+	}
+	return problems
+}
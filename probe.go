@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// probeTimeout bounds each `--version` run; some tools start a server instead
+// of answering, and merely executing at all is what we need to know.
+const probeTimeout = 5 * time.Second
+
+var versionPattern = regexp.MustCompile(`v?\d+\.\d+(?:\.\d+)?(?:[-+][0-9A-Za-z.-]+)?`)
+
+// probeBinary runs path with --version in a scratch directory with no stdin
+// and returns the version it reports, or "unknown" if it ran but printed none.
+// An error means the binary could not run on this machine at all.
+func probeBinary(path string) (string, error) {
+	scratch, err := fsys.MkdirTemp("", "xmlui-probe-")
+	if err != nil {
+		return "", err
+	}
+	defer fsys.RemoveAll(scratch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := installedCommand(ctx, path, "--version")
+	cmd.Dir = scratch
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return "unknown", nil
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%s could not be executed: %v\n  %s", path, err, probeGuidance(path, err, out.String()))
+		}
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return "", fmt.Errorf("%s was killed by %v\n  %s", path, status.Signal(), probeGuidance(path, err, out.String()))
+		}
+		if exitErr.ExitCode() == 126 || exitErr.ExitCode() == 127 {
+			return "", fmt.Errorf("%s failed to start (exit %d): %s\n  %s", path, exitErr.ExitCode(), strings.TrimSpace(out.String()), probeGuidance(path, err, out.String()))
+		}
+		// It ran but rejected --version; that still proves compatibility.
+	}
+
+	if v := versionPattern.FindString(out.String()); v != "" {
+		return v, nil
+	}
+	return "unknown", nil
+}
+
+// probeGuidance turns a failed probe into a hint about the likely cause.
+func probeGuidance(path string, err error, output string) string {
+	msg := err.Error() + " " + output
+	switch {
+	case errors.Is(err, syscall.ENOEXEC) || strings.Contains(msg, "exec format error") ||
+		strings.Contains(msg, "not a valid Win32 application"):
+		return fmt.Sprintf("The binary was built for a different CPU or OS than %s. Re-run on a matching machine or report the missing artifact.", thisMachine())
+	case hostOS.launchHint(path, err, msg) != "":
+		return hostOS.launchHint(path, err, msg)
+	case strings.Contains(msg, "error while loading shared libraries") || strings.Contains(msg, "GLIBC_"):
+		return "A required shared library is missing or too old. Install the library named above or use a newer distribution."
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Sprintf("The file is not executable. Run: chmod +x %q", path)
+	default:
+		return "Re-run with a fresh download; if it keeps failing, report it along with your OS and architecture."
+	}
+}
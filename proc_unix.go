@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ownProcessGroup makes cmd lead a new process group, so killProcessTree
+// also stops what it spawns, e.g. the server that start.sh runs.
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree kills a process started with ownProcessGroup and its
+// descendants.
+func killProcessTree(cmd *exec.Cmd) error {
+	return killProcessGroup(cmd.Process.Pid)
+}
+
+// killProcessGroup kills the process group led by pid.
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// ownProcessGroup makes cmd lead a new process group, so killProcessTree
+// also stops what it spawns, e.g. the server that start.bat runs.
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessTree kills a process started with ownProcessGroup and its
+// descendants.
+func killProcessTree(cmd *exec.Cmd) error {
+	return killProcessGroup(cmd.Process.Pid)
+}
+
+// killProcessGroup kills pid and its descendants.
+func killProcessGroup(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	const stillActive = 259
+	return syscall.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
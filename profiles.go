@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configFileName is the launcher's optional config file. It is looked for,
+// in order, at $XMLUI_BUNDLER_CONFIG, next to the launcher executable (so
+// an instructor can hand out both together), and in the user config dir.
+// The file, variable and dir of the launcher's old name are looked for
+// after each.
+const (
+	configFileName       = launcherName + ".json"
+	legacyConfigFileName = legacyLauncherName + ".json"
+)
+
+// launcherConfig is the config file's content.
+type launcherConfig struct {
+	// Profiles maps a --profile name to the flags it stands for.
+	Profiles map[string][]string `json:"profiles"`
+	// Layout overrides where the XMLUI snapshot keeps the component docs and
+	// source, should the monorepo move them.
+	Layout componentLayout `json:"layout"`
+	// Destinations maps "mcp" or "server" to where its binaries go, per
+	// GOOS or "default" (see releaseAsset.Dest), e.g.
+	// {"mcp": {"default": "bin", "windows": "{{.ToolsDir}}"}}.
+	Destinations map[string]map[string]string `json:"destinations"`
+	// Telemetry is "on" to send usage reports (see telemetry.go), which
+	// `telemetry on` sets; anything else leaves them off.
+	Telemetry string `json:"telemetry,omitempty"`
+	// TelemetryURL is where they go instead of this build's endpoint.
+	TelemetryURL string `json:"telemetryURL,omitempty"`
+	// CompatURL is where the compatibility matrix is fetched from instead
+	// of this build's (see compat.go).
+	CompatURL string `json:"compatURL,omitempty"`
+	// Artifacts maps "mcp", "server" or "components" to the store hosting
+	// it (see applyArtifactStores), e.g.
+	// {"mcp": "s3://artifacts/xmlui-mcp/v1.0.0/"}.
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	// Organization is the message and README every install gets (see
+	// orgNotes).
+	Organization *orgNotes `json:"organization,omitempty"`
+}
+
+// builtinProfiles are available without a config file, which can redefine
+// them.
+var builtinProfiles = map[string][]string{
+	// Identical, verified installs from a lockfile handed out with the
+	// launcher.
+	"classroom": {"--locked", "--verify-mcp", "--verify-server"},
+	// Fail on anything suspicious, with log-friendly output.
+	"ci": {"--strict", "--progress", "dots", "--verify-mcp", "--verify-server"},
+	// Just the files: no probes of the installed binaries.
+	"minimal": {"--skip-version-check"},
+}
+
+// configPaths lists where the config file may be, in order.
+func configPaths() []string {
+	var paths []string
+	for _, v := range []string{"XMLUI_BUNDLER_CONFIG", "XMLUI_LAUNCHER_CONFIG"} {
+		if p := os.Getenv(v); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), configFileName), filepath.Join(filepath.Dir(exe), legacyConfigFileName))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, launcherName, configFileName), filepath.Join(dir, legacyLauncherName, legacyConfigFileName))
+	}
+	return paths
+}
+
+// readConfig loads the first config file found, or returns an empty config
+// and "" if there is none.
+func readConfig() (*launcherConfig, string, error) {
+	for _, p := range configPaths() {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, p, err
+		}
+		var c launcherConfig
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, p, fmt.Errorf("%s: %w", p, err)
+		}
+		return &c, p, nil
+	}
+	return &launcherConfig{}, "", nil
+}
+
+// profile returns the flags of the named profile.
+func (c *launcherConfig) profile(name string) ([]string, bool) {
+	if flags, ok := c.Profiles[name]; ok {
+		return flags, true
+	}
+	flags, ok := builtinProfiles[name]
+	return flags, ok
+}
+
+func (c *launcherConfig) profileNames() []string {
+	seen := map[string]bool{}
+	for n := range builtinProfiles {
+		seen[n] = true
+	}
+	for n := range c.Profiles {
+		seen[n] = true
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandProfiles replaces each --profile NAME in args with the profile's
+// flags. They go first, so flags given on the command line win.
+func expandProfiles(args []string) ([]string, error) {
+	var names, rest []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, ok := strings.CutPrefix(strings.TrimPrefix(a, "-"), "-profile")
+		switch {
+		case !ok:
+			rest = append(rest, a)
+			continue
+		case name == "":
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("--profile needs a name")
+			}
+			i++
+			name = args[i]
+		case strings.HasPrefix(name, "="):
+			name = name[1:]
+		default:
+			rest = append(rest, a)
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return args, nil
+	}
+	cfg, path, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	var expanded []string
+	for _, name := range names {
+		flags, ok := cfg.profile(name)
+		if !ok {
+			where := "no config file found"
+			if path != "" {
+				where = "not in " + path
+			}
+			return nil, fmt.Errorf("unknown profile %q (%s; have: %s)", name, where, strings.Join(cfg.profileNames(), ", "))
+		}
+		fmt.Printf("Profile %s: %s\n", name, strings.Join(flags, " "))
+		expanded = append(expanded, flags...)
+	}
+	return append(expanded, rest...), nil
+}
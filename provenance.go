@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// receiptDownload records one release archive an install downloaded. Tag is
+// its release tag, or the version of an npm package.
+type receiptDownload struct {
+	// Platform is the build's os-arch, or "" for a platform-neutral archive
+	// such as the app's.
+	Platform     string    `json:"platform,omitempty"`
+	URL          string    `json:"url"`
+	Tag          string    `json:"tag,omitempty"`
+	SHA256       string    `json:"sha256"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+// newDownload records the archive with SHA-256 sum fetched from u as p's
+// release archive (any platform's, if p is zero).
+func newDownload(p platform, u, sum string) receiptDownload {
+	d := receiptDownload{
+		URL:          u,
+		Tag:          releaseTag(u),
+		SHA256:       sum,
+		DownloadedAt: time.Now().UTC(),
+	}
+	if p.OS != "" {
+		d.Platform = p.String()
+	}
+	return d
+}
+
+// releaseTag is the tag in a GitHub release download URL
+// (.../releases/download/TAG/ASSET), or "".
+func releaseTag(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "releases" && parts[i+1] == "download" {
+			return parts[i+2]
+		}
+	}
+	return ""
+}
+
+// download returns the archive binary rel (a receipt path) came from: the
+// one for its bin-<os>-<arch> directory in an --all-platforms install, else
+// the only one.
+func (c *receiptComponent) download(rel string) *receiptDownload {
+	if len(c.Downloads) == 1 {
+		return &c.Downloads[0]
+	}
+	for i, d := range c.Downloads {
+		if strings.Contains("/"+rel, "/bin-"+d.Platform+"/") {
+			return &c.Downloads[i]
+		}
+	}
+	return nil
+}
+
+// binaryProvenance is what `provenance` reports about one binary.
+type binaryProvenance struct {
+	Path      string           `json:"path"`
+	Component string           `json:"component"`
+	Version   string           `json:"version"`
+	SHA256    string           `json:"sha256,omitempty"`
+	Signature string           `json:"signature,omitempty"`
+	Download  *receiptDownload `json:"download,omitempty"`
+	// OnDisk is "unchanged", "modified" or "missing", compared with SHA256.
+	OnDisk string `json:"onDisk"`
+}
+
+// runProvenance implements `provenance`: for each installed binary, where it
+// came from (URL, release tag, archive checksum, download time), its own
+// checksum and signature status as recorded at install, and whether the file
+// on disk still matches.
+func runProvenance(args []string) int {
+	fs := newFlagSet("provenance")
+	dir := installDirFlag(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+
+	var report []binaryProvenance
+	for i := range rcpt.Components {
+		c := &rcpt.Components[i]
+		for _, b := range c.Binaries {
+			p := binaryProvenance{Path: b.Path, Component: c.Name, Version: b.Version, SHA256: b.SHA256, Signature: b.Signature, Download: c.download(b.Path)}
+			switch sum, err := hashFile(filepath.Join(installDir, filepath.FromSlash(b.Path))); {
+			case err != nil:
+				p.OnDisk = "missing"
+			case b.SHA256 == "":
+				p.SHA256, p.OnDisk = sum, "not recorded"
+			case sum == b.SHA256:
+				p.OnDisk = "unchanged"
+			default:
+				p.OnDisk = "modified"
+			}
+			report = append(report, p)
+		}
+	}
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return exitOK
+	}
+	if len(report) == 0 {
+		fmt.Println("The receipt records no binaries for", installDir)
+		return exitOK
+	}
+
+	fmt.Printf("Installed by %s %s on %s\n", launcherName, rcpt.LauncherVersion, rcpt.InstalledAt.Local().Format("2006-01-02 15:04"))
+	changed := 0
+	for _, p := range report {
+		fmt.Printf("\n%s (%s)\n", p.Path, p.Component)
+		fmt.Printf("  Version:    %s\n", p.Version)
+		if d := p.Download; d != nil {
+			tag := d.Tag
+			if tag == "" {
+				tag = "(not a GitHub release)"
+			}
+			fmt.Printf("  Source:     %s\n", d.URL)
+			fmt.Printf("  Release:    %s\n", tag)
+			fmt.Printf("  Archive:    sha256 %s\n", d.SHA256)
+			fmt.Printf("  Downloaded: %s\n", d.DownloadedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Println("  Source:     not recorded (run `xmlui-bundler update` to record it)")
+		}
+		fmt.Printf("  SHA-256:    %s\n", p.SHA256)
+		signature := p.Signature
+		if signature == "" {
+			signature = "not recorded"
+		}
+		fmt.Printf("  Signature:  %s\n", signature)
+		mark := glyphOK
+		if p.OnDisk == "modified" || p.OnDisk == "missing" {
+			mark = glyphFail
+			changed++
+		}
+		fmt.Printf("  On disk:    %s %s\n", mark, p.OnDisk)
+	}
+	if changed > 0 {
+		fmt.Printf("\n%d binaries differ from what was installed; `xmlui-bundler doctor --fix` restores them\n", changed)
+		return exitFailure
+	}
+	return exitOK
+}
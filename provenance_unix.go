@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// binarySignature describes the code signature of the binary at path:
+// codesign's verdict and signing authority on macOS. Linux binaries carry
+// none.
+func binarySignature(path string) string {
+	if hostOS.goos() != "darwin" {
+		return "none (Linux binaries are not signed)"
+	}
+	if out, err := exec.Command("codesign", "--verify", "--strict", path).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return "invalid: " + firstLine(msg)
+		}
+		return "unknown: " + err.Error()
+	}
+	// codesign -d reports on stderr.
+	out, _ := exec.Command("codesign", "-d", "--verbose=2", path).CombinedOutput()
+	for _, line := range strings.Split(string(out), "\n") {
+		if authority, ok := strings.CutPrefix(line, "Authority="); ok {
+			return "valid, signed by " + authority
+		}
+	}
+	return "valid (ad hoc)"
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// binarySignature describes the Authenticode signature of the binary at
+// path, as PowerShell's Get-AuthenticodeSignature reports it.
+func binarySignature(path string) string {
+	script := `$s = Get-AuthenticodeSignature -LiteralPath ` + psQuote(path) + `; ` +
+		`$s.Status.ToString(); if ($s.SignerCertificate) { $s.SignerCertificate.Subject }`
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return "unknown: " + err.Error()
+	}
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(string(out), "\r\n", "\n")), "\n")
+	switch {
+	case lines[0] == "NotSigned":
+		return "not signed"
+	case lines[0] == "Valid" && len(lines) > 1:
+		return "valid, signed by " + lines[1]
+	default:
+		return strings.ToLower(strings.Join(lines, ", "))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// defaultPruneRules drop the parts of the XMLUI components snapshot the MCP
+// knowledge base has no use for: tests, stories and build leftovers. Each
+// rule is a path.Match pattern tested against file and directory names.
+var defaultPruneRules = []string{
+	"*.spec.*",
+	"*.test.*",
+	"__tests__",
+	"__snapshots__",
+	"*.stories.*",
+	"*.scss.map",
+	"*.js.map",
+	"*.tsbuildinfo",
+	".DS_Store",
+}
+
+// pruneTree removes everything under dir whose name matches one of rules
+// and returns how many files and bytes went.
+func pruneTree(dir string, rules []string) (files int, bytes int64, err error) {
+	for _, r := range rules {
+		if _, err := path.Match(r, ""); err != nil {
+			return 0, 0, fmt.Errorf("bad prune rule %q: %w", r, err)
+		}
+	}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir || !matchesAny(info.Name(), rules) {
+			return err
+		}
+		n, size := 1, info.Size()
+		if info.IsDir() {
+			n, size = 0, 0
+			filepath.Walk(p, func(_ string, fi os.FileInfo, err error) error {
+				if err == nil && !fi.IsDir() {
+					n++
+					size += fi.Size()
+				}
+				return nil
+			})
+		}
+		if err := fsys.RemoveAll(p); err != nil {
+			return err
+		}
+		files += n
+		bytes += size
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return files, bytes, err
+}
+
+func matchesAny(name string, rules []string) bool {
+	for _, r := range rules {
+		if ok, _ := path.Match(r, name); ok {
+			return true
+		}
+	}
+	return false
+}
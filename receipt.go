@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// flatDirName holds everything a --flat install adds to a project.
+const flatDirName = ".xmlui"
+
+// The receipt records what the bundler put in an install dir. It is kept in
+// the install's state directory (see installStateDir).
+type receipt struct {
+	LauncherVersion string `json:"launcherVersion"`
+	// Layout is the version of the install's layout (see migrate.go); the
+	// receipts of launchers from before it was recorded have none.
+	Layout          int                `json:"layout,omitempty"`
+	InstalledAt     time.Time          `json:"installedAt"`
+	OS              string             `json:"os"`
+	Arch            string             `json:"arch"`
+	Port            int                `json:"port"`
+	AppDir          string             `json:"appDir,omitempty"`
+	AppSource       string             `json:"appSource,omitempty"`
+	AppRef          string             `json:"appRef,omitempty"`
+	AppProvider     string             `json:"appProvider,omitempty"`
+	AllPlatforms    bool               `json:"allPlatforms,omitempty"`
+	FullSource      bool               `json:"fullSource,omitempty"`
+	Flat            bool               `json:"flat,omitempty"`
+	NoScripts       bool               `json:"noScripts,omitempty"`
+	KeepLineEndings bool               `json:"keepLineEndings,omitempty"`
+	Prune           []string           `json:"prune,omitempty"`
+	Features        []string           `json:"features,omitempty"`
+	Channel         string             `json:"channel,omitempty"`
+	XMLUINPM        string             `json:"xmluiNpm,omitempty"`
+	XMLUIRuntime    string             `json:"xmluiRuntime,omitempty"`
+	Variant         string             `json:"variant,omitempty"`
+	MCPClients      []string           `json:"mcpClients,omitempty"`
+	Vars            map[string]string  `json:"vars,omitempty"`
+	BinDirs         map[string]string  `json:"binDirs,omitempty"`
+	Organization    *orgNotes          `json:"organization,omitempty"`
+	Components      []receiptComponent `json:"components"`
+}
+
+type receiptComponent struct {
+	Name     string          `json:"name"`
+	Source   string          `json:"source"`
+	Binaries []receiptBinary `json:"binaries,omitempty"`
+	// Downloads are the release archives the binaries came from, one per
+	// platform.
+	Downloads []receiptDownload `json:"downloads,omitempty"`
+	// Files maps each installed file (see receiptKey) to its SHA-256, so
+	// update can skip unchanged files.
+	Files map[string]string `json:"files,omitempty"`
+	// Unavailable, if set, says why the component was not installed, e.g.
+	// "no artifact for linux/arm64"; update tries it again.
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+type receiptBinary struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256,omitempty"`
+	// Signature is the code signature status at install, for the host
+	// platform's builds.
+	Signature string `json:"signature,omitempty"`
+}
+
+func newReceipt() *receipt {
+	return &receipt{
+		LauncherVersion: version,
+		Layout:          layoutVersion,
+		InstalledAt:     time.Now().UTC(),
+		OS:              hostOS.goos(),
+		Arch:            runtime.GOARCH,
+	}
+}
+
+// component returns the named component entry, adding it if needed.
+func (r *receipt) component(name, source string) *receiptComponent {
+	for i := range r.Components {
+		if r.Components[i].Name == name {
+			return &r.Components[i]
+		}
+	}
+	r.Components = append(r.Components, receiptComponent{Name: name, Source: source})
+	return &r.Components[len(r.Components)-1]
+}
+
+// port is the port the app was configured for.
+func (r *receipt) port() int {
+	if r.Port != 0 {
+		return r.Port
+	}
+	return 8080
+}
+
+// toolsDir is the directory, relative to the install dir, of the MCP tools
+// and knowledge base: mcp/, or .xmlui/ in a --flat install.
+func (r *receipt) toolsDir() string {
+	if r.Flat {
+		return flatDirName
+	}
+	return "mcp"
+}
+
+// toolsDirOf is the tools directory of the install in installDir (see
+// receipt.toolsDir), defaulting to mcp/ if it has no readable receipt.
+func toolsDirOf(installDir string) string {
+	if r, err := readReceipt(installDir); err == nil {
+		return filepath.Join(installDir, r.toolsDir())
+	}
+	return filepath.Join(installDir, "mcp")
+}
+
+// binDir is the directory, relative to the install dir, of component's
+// binaries: the tools dir for "mcp", the app dir (or the tools dir of a
+// --flat install) for "server", unless BinDirs says otherwise.
+func (r *receipt) binDir(component string) string {
+	if d, ok := r.BinDirs[component]; ok {
+		return d
+	}
+	if component == "server" && !r.Flat {
+		return r.appDir()
+	}
+	return r.toolsDir()
+}
+
+// binDirOf is component's binDir in the install in installDir, as an
+// absolute path, defaulting to the usual place if it has no readable
+// receipt.
+func binDirOf(installDir, component string) string {
+	r, err := readReceipt(installDir)
+	if err != nil {
+		r = &receipt{}
+	}
+	return filepath.Join(installDir, filepath.FromSlash(r.binDir(component)))
+}
+
+// appDir is the app directory relative to the install dir.
+func (r *receipt) appDir() string {
+	if r.AppDir != "" {
+		return r.AppDir
+	}
+	return repoName
+}
+
+func (r *receipt) write(installDir string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := installStatePath(installDir, stateReceiptFile)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(path, append(data, '\n'), fileMode); err != nil {
+		return err
+	}
+	return recordInstall(installDir)
+}
+
+func readReceipt(installDir string) (*receipt, error) {
+	path, err := installStatePath(installDir, stateReceiptFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Not migrated yet, e.g. because the state dir isn't writable.
+		data, err = os.ReadFile(filepath.Join(installDir, legacyReceiptFile))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// renderCheckFile, in the install's state dir, is the screenshot the last
+// `smoke --render-check` took.
+const renderCheckFile = "render-check.png"
+
+// renderBudget is how long headless Chrome lets the page's scripts run
+// before it reads the DOM, enough for XMLUI to fetch and mount the app.
+const renderBudget = 15 * time.Second
+
+// errSkipped marks a smoke check that could not run here; it isn't a
+// failure.
+type errSkipped struct{ reason string }
+
+func (e errSkipped) Error() string { return "skipped: " + e.reason }
+
+var (
+	htmlBody        = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	htmlElement     = regexp.MustCompile(`<[A-Za-z][^>]*>`)
+	htmlNonRendered = regexp.MustCompile(`(?is)<(script|style|noscript|template)[^>]*>.*?</(script|style|noscript|template)>`)
+	// chromeConsole matches the console messages Chrome logs to stderr,
+	// e.g. [...:INFO:CONSOLE(12)] "Uncaught TypeError: ...", source: ... (12)
+	chromeConsole = regexp.MustCompile(`:([A-Z]+):CONSOLE[(:][^\]]*\]\s*(.*)`)
+)
+
+// findChrome returns a Chrome, Chromium or Edge binary for headless use:
+// $CHROME_PATH, else the usual install locations, else "".
+func findChrome() string {
+	if p := os.Getenv("CHROME_PATH"); p != "" {
+		return p
+	}
+	for _, p := range hostOS.browserLocations() {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "microsoft-edge", "chrome"} {
+		if p, err := exec.LookPath(name); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// runHeadless runs chrome headless on url with extra flags and returns its
+// stdout and stderr.
+func runHeadless(chrome, url string, timeout time.Duration, extra ...string) ([]byte, []byte, error) {
+	profile, err := fsys.MkdirTemp("", "xmlui-render-check-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fsys.RemoveAll(profile)
+	args := []string{
+		"--headless=new", "--disable-gpu", "--no-first-run", "--no-default-browser-check",
+		"--hide-scrollbars", "--user-data-dir=" + profile,
+		fmt.Sprintf("--virtual-time-budget=%d", renderBudget.Milliseconds()),
+		"--enable-logging=stderr", "--v=0",
+	}
+	// Chrome refuses to sandbox itself as root, e.g. in a container.
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+	args = append(append(args, extra...), url)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, chrome, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("%s did not finish within %v", filepath.Base(chrome), timeout)
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// bodyElements counts the elements in html's body that can render.
+func bodyElements(html string) int {
+	m := htmlBody.FindStringSubmatch(html)
+	if m == nil {
+		return 0
+	}
+	return len(htmlElement.FindAllString(htmlNonRendered.ReplaceAllString(m[1], ""), -1))
+}
+
+// smokeRender loads the app in headless Chrome from a spare test server and
+// checks that XMLUI mounted something into the page, which an HTTP 200
+// can't tell from a white screen. It reports the page's console errors and
+// saves a screenshot in the install's state dir.
+func smokeRender(installDir, appDir string, timeout time.Duration) error {
+	chrome := findChrome()
+	if chrome == "" {
+		return errSkipped{"no Chrome, Chromium or Edge found (set CHROME_PATH)"}
+	}
+	base, _, stop, err := startSpareServer(appDir, binDirOf(installDir, "server"), 0, timeout)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	resp, err := http.Get(base + "/")
+	if err != nil {
+		return err
+	}
+	served, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	wait := renderBudget + timeout
+	dom, stderr, err := runHeadless(chrome, base+"/", wait, "--dump-dom")
+	if err != nil {
+		return fmt.Errorf("headless %s: %v", filepath.Base(chrome), err)
+	}
+	var consoleErrors []string
+	for _, line := range strings.Split(string(stderr), "\n") {
+		// Older Chromes log every console message at INFO.
+		if m := chromeConsole.FindStringSubmatch(line); m != nil && (m[1] == "ERROR" || strings.Contains(m[2], "Uncaught")) {
+			consoleErrors = append(consoleErrors, strings.TrimSpace(m[2]))
+		}
+	}
+	for _, msg := range consoleErrors {
+		fmt.Printf("  %s console: %s\n", glyphFail, msg)
+	}
+
+	if shot, err := installStatePath(installDir, renderCheckFile); err == nil {
+		if err := fsys.MkdirAll(filepath.Dir(shot), dirMode); err == nil {
+			if _, _, err := runHeadless(chrome, base+"/", wait, "--window-size=1280,800", "--screenshot="+shot); err == nil {
+				fmt.Printf("  %s screenshot saved to %s\n", glyphOK, shot)
+			} else {
+				warn("Could not take a screenshot: %v", err)
+			}
+		}
+	}
+
+	before, after := bodyElements(string(served)), bodyElements(string(dom))
+	if after <= before {
+		if len(consoleErrors) > 0 {
+			return fmt.Errorf("the app did not render (blank page; %d console errors, first: %s)", len(consoleErrors), consoleErrors[0])
+		}
+		return fmt.Errorf("the app did not render (blank page after %v)", renderBudget)
+	}
+	fmt.Printf("  %s the app rendered %d elements in %s\n", glyphOK, after-before, filepath.Base(chrome))
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultAppSource is the sample app installed when --app-source is not given.
+const defaultAppSource = "https://github.com/jonudell/" + repoName
+
+// repoSource is a git repository snapshot downloadable as an archive from a
+// hosting provider, or a plain archive URL.
+type repoSource struct {
+	Provider string // github, gitlab, bitbucket, gitea, or archive
+	URL      string // archive download URL
+	Name     string // directory name to install the tree as
+
+	// Base, Owner and Name locate the repository for API calls such as
+	// resolveCommit; they are unset for plain archives.
+	Base, Owner string
+}
+
+// parseRepoSource resolves spec, either a repository page URL such as
+// https://gitlab.com/owner/repo or a direct .zip/.tar.gz URL, at ref. provider
+// overrides host-based detection for self-hosted GitLab or Gitea instances.
+func parseRepoSource(spec, ref, provider string) (*repoSource, error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Host == "" && u.Scheme != "file" {
+		return nil, fmt.Errorf("app source %q is not a URL", spec)
+	}
+	if isArchiveName(u.Path) {
+		name := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(path.Base(u.Path), ".zip"), ".tar.gz"), ".tgz")
+		return &repoSource{Provider: "archive", URL: spec, Name: name}, nil
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("app source %q should look like https://host/owner/repo", spec)
+	}
+	// GitLab allows nested groups; the repository is always the last segment.
+	owner, repo := strings.Join(parts[:len(parts)-1], "/"), parts[len(parts)-1]
+
+	if provider == "" {
+		switch {
+		case u.Host == "github.com":
+			provider = "github"
+		case u.Host == "bitbucket.org":
+			provider = "bitbucket"
+		case strings.Contains(u.Host, "gitlab"):
+			provider = "gitlab"
+		case u.Host == "codeberg.org" || strings.Contains(u.Host, "gitea") || strings.Contains(u.Host, "forgejo"):
+			provider = "gitea"
+		default:
+			return nil, fmt.Errorf("can't tell which git host %s is; pass --app-provider github|gitlab|bitbucket|gitea", u.Host)
+		}
+	}
+
+	base := u.Scheme + "://" + u.Host
+	s := &repoSource{Provider: provider, Name: repo, Base: base, Owner: owner}
+	// A ref such as variant/NAME is one path segment in the archive URLs,
+	// and can't be part of a file name as it is.
+	escRef, fileRef := url.PathEscape(ref), strings.ReplaceAll(ref, "/", "-")
+	switch provider {
+	case "github":
+		// codeload takes the ref's slashes as they are.
+		if isCommitSHA(ref) {
+			s.URL = fmt.Sprintf("https://codeload.github.com/%s/%s/zip/%s", owner, repo, ref)
+		} else {
+			s.URL = fmt.Sprintf("https://codeload.github.com/%s/%s/zip/refs/heads/%s", owner, repo, escapeSegments(ref))
+		}
+	case "gitlab":
+		s.URL = fmt.Sprintf("%s/%s/%s/-/archive/%s/%s-%s.tar.gz", base, owner, repo, escRef, repo, url.PathEscape(fileRef))
+	case "bitbucket":
+		s.URL = fmt.Sprintf("%s/%s/%s/get/%s.tar.gz", base, owner, repo, escRef)
+	case "gitea":
+		s.URL = fmt.Sprintf("%s/%s/%s/archive/%s.tar.gz", base, owner, repo, escRef)
+	default:
+		return nil, fmt.Errorf("unknown app provider %q", provider)
+	}
+	return s, nil
+}
+
+// escapeSegments path-escapes each /-separated segment of p.
+func escapeSegments(p string) string {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segs, "/")
+}
+
+// isCommitSHA reports whether ref is a full (40 hex digit) commit hash.
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isArchiveName(p string) bool {
+	return strings.HasSuffix(p, ".zip") || strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
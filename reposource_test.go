@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestRepoSourceRefs(t *testing.T) {
+	for _, tc := range []struct{ spec, provider, ref, want string }{
+		{"https://github.com/o/r", "", "main", "https://codeload.github.com/o/r/zip/refs/heads/main"},
+		{"https://github.com/o/r", "", "variant/a b", "https://codeload.github.com/o/r/zip/refs/heads/variant/a%20b"},
+		{"https://gitlab.com/g/sub/r", "", "variant/NAME", "https://gitlab.com/g/sub/r/-/archive/variant%2FNAME/r-variant-NAME.tar.gz"},
+		{"https://bitbucket.org/o/r", "", "variant/NAME", "https://bitbucket.org/o/r/get/variant%2FNAME.tar.gz"},
+		{"https://codeberg.org/o/r", "", "variant/NAME", "https://codeberg.org/o/r/archive/variant%2FNAME.tar.gz"},
+		{"https://git.example.com/o/r", "gitea", "v1.0#rc", "https://git.example.com/o/r/archive/v1.0%23rc.tar.gz"},
+	} {
+		s, err := parseRepoSource(tc.spec, tc.ref, tc.provider)
+		if err != nil {
+			t.Errorf("parseRepoSource(%q, %q): %v", tc.spec, tc.ref, err)
+			continue
+		}
+		if s.URL != tc.want {
+			t.Errorf("parseRepoSource(%q, %q).URL = %q, want %q", tc.spec, tc.ref, s.URL, tc.want)
+		}
+	}
+}
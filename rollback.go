@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// installJournal records what an install changes in the install directory so
+// an interrupted or failed run can put everything back: the paths it noted
+// as created are removed, and files it replaced or deleted are restored from
+// backups kept in the staging area. Nothing the journal wasn't told about is
+// touched, so whatever else is in the install dir, including what another
+// program put there during the run, stays.
+type installJournal struct {
+	mu         sync.Mutex
+	installDir string
+	backupDir  string
+	backups    []journalBackup
+	created    []string
+	committed  bool
+}
+
+type journalBackup struct {
+	original, backup string
+}
+
+// journal is the active install's journal; its methods are no-ops when nil.
+var journal *installJournal
+
+// beginJournal starts a journal for installDir that keeps backups under
+// backupDir, which must be on the same filesystem.
+func beginJournal(installDir, backupDir string) (*installJournal, error) {
+	if _, err := os.Stat(installDir); err != nil {
+		return nil, err
+	}
+	if err := fsys.MkdirAll(backupDir, 0755); err != nil {
+		return nil, err
+	}
+	return &installJournal{installDir: installDir, backupDir: backupDir}, nil
+}
+
+// preserve must be called before path is overwritten or removed. An existing
+// file is moved aside (so path is free afterwards); a missing one is noted as
+// created so rollback removes it, and whatever the install puts inside it.
+func (j *installJournal) preserve(path string) error {
+	if j == nil {
+		return fsys.Remove(path)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		j.created = append(j.created, path)
+		return nil
+	}
+	backup := filepath.Join(j.backupDir, fmt.Sprintf("%06d", len(j.backups)))
+	if err := movePath(path, backup); err != nil {
+		return err
+	}
+	j.backups = append(j.backups, journalBackup{original: path, backup: backup})
+	return nil
+}
+
+// mkdirAll creates dir and any missing parents, noting the outermost one it
+// creates so rollback removes it with everything the install put inside.
+func (j *installJournal) mkdirAll(dir string) error {
+	top := ""
+	for p := dir; ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		}
+		top = p
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	if err := fsys.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	if j == nil || top == "" {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.created = append(j.created, top)
+	return nil
+}
+
+// moveAside renames path to aside, keeping it rather than a backup, and
+// renames it back on rollback.
+func (j *installJournal) moveAside(path, aside string) error {
+	if err := movePath(path, aside); err != nil {
+		return err
+	}
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.backups = append(j.backups, journalBackup{original: path, backup: aside})
+	return nil
+}
+
+// createdPaths returns the paths the install created, which were missing
+// when it began.
+func (j *installJournal) createdPaths() []string {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.created...)
+}
+
+// commit marks the install as successful so rollback does nothing.
+func (j *installJournal) commit() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.committed = true
+	j.mu.Unlock()
+}
+
+// journalMark is a point in a journal's record, for rollbackTo.
+type journalMark struct {
+	created, backups int
+}
+
+// mark returns the point the journal has reached, so one step's changes
+// can be undone without the rest.
+func (j *installJournal) mark() journalMark {
+	if j == nil {
+		return journalMark{}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return journalMark{len(j.created), len(j.backups)}
+}
+
+// rollbackTo undoes the changes recorded since m, as rollback does all of
+// them, and forgets them.
+func (j *installJournal) rollbackTo(m journalMark) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.committed {
+		return
+	}
+	j.undo(m)
+}
+
+// rollback undoes an uncommitted install, newest change first.
+func (j *installJournal) rollback() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.committed {
+		return
+	}
+	j.undo(journalMark{})
+	j.committed = true
+	fmt.Println("Rolled back partial changes in", j.installDir)
+}
+
+// undo reverts the changes recorded since m, newest first. j.mu is held.
+func (j *installJournal) undo(m journalMark) {
+	for i := len(j.created) - 1; i >= m.created; i-- {
+		fsys.RemoveAll(j.created[i])
+	}
+	for i := len(j.backups) - 1; i >= m.backups; i-- {
+		b := j.backups[i]
+		fsys.MkdirAll(filepath.Dir(b.original), dirMode)
+		// What is there now is the install's replacement.
+		fsys.RemoveAll(b.original)
+		if err := movePath(b.backup, b.original); err != nil {
+			fmt.Printf("%s Could not restore %s from %s: %v\n", labelWarning, b.original, b.backup, err)
+		}
+	}
+	j.created, j.backups = j.created[:m.created], j.backups[:m.backups]
+}
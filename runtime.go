@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The XMLUI standalone runtime is the one script a buildless XMLUI app
+// loads, as <script src="xmlui/xmlui-standalone.umd.js">. App repos don't
+// always commit it, and without it the app needs the network for a CDN
+// copy. --xmlui-runtime installs a prebuilt one into the app's xmlui/
+// directory so the app runs offline: from an XMLUI GitHub release, or from
+// the npm package's standalone build.
+const (
+	xmluiRuntimeComponent = "xmlui-runtime"
+	// xmluiRuntimeDir is where the runtime goes, relative to the app dir.
+	xmluiRuntimeDir = "xmlui"
+	// xmluiRuntimeFile is its name when the app doesn't name it.
+	xmluiRuntimeFile = "xmlui-standalone.umd.js"
+	// Specs besides versions, dist-tags and npm:SPEC.
+	runtimeAuto = "auto"
+	runtimeOff  = "off"
+)
+
+// xmluiRuntimeRepo is where the runtime is released.
+var xmluiRuntimeRepo = struct{ owner, repo string }{"xmlui-com", "xmlui"}
+
+var (
+	runtimeScriptRe  = regexp.MustCompile(`<script[^>]+src=["'](?:\./|/)?` + xmluiRuntimeDir + `/([^"'?#/]+\.js)[^"']*["']`)
+	runtimeVersionRe = regexp.MustCompile(`\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+)?`)
+)
+
+// runtimeReference is the runtime script appDir's index.html loads from
+// xmlui/, or "".
+func runtimeReference(appDir string) string {
+	data, err := os.ReadFile(filepath.Join(appDir, "index.html"))
+	if err != nil {
+		return ""
+	}
+	if m := runtimeScriptRe.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// runtimePlan is what installXMLUIRuntime should fetch for opts: the spec
+// to resolve and the file name to install it as, or an empty spec for
+// nothing. With auto, that is the script the app loads from xmlui/ when its
+// own download (appFiles) lacks it, at the version its name carries.
+func runtimePlan(opts installOptions, appDir, installDir string, appFiles map[string]string) (spec, name string) {
+	ref := runtimeReference(appDir)
+	name = ref
+	if name == "" {
+		name = xmluiRuntimeFile
+	}
+	switch opts.xmluiRuntime {
+	case runtimeOff:
+		return "", ""
+	case "", runtimeAuto:
+		if opts.flat || ref == "" {
+			return "", ""
+		}
+		if _, ok := appFiles[receiptKey(installDir, filepath.Join(appDir, xmluiRuntimeDir, ref))]; ok {
+			return "", ""
+		}
+		spec = "latest"
+		if v := runtimeVersionRe.FindString(ref); v != "" {
+			spec = v
+		}
+		return spec, name
+	}
+	return opts.xmluiRuntime, name
+}
+
+// githubRelease is the part of a GitHub release document we use.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// resolveRuntimeRelease finds the runtime asset of the XMLUI release spec
+// selects (latest, or a version tagged xmlui@V, vV or V), returning its URL
+// and version, or "" if there is none.
+func resolveRuntimeRelease(spec string) (string, string, error) {
+	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", xmluiRuntimeRepo.owner, xmluiRuntimeRepo.repo)
+	var tags []string
+	if spec == "latest" {
+		tags = []string{""}
+	} else {
+		tags = []string{"xmlui@" + spec, "v" + spec, spec}
+	}
+	var lastErr error
+	for _, tag := range tags {
+		var rel githubRelease
+		u := api + "/latest"
+		if tag != "" {
+			u = api + "/tags/" + url.PathEscape(tag)
+		}
+		if err := getGitHubJSON(u, &rel); err != nil {
+			lastErr = err
+			continue
+		}
+		asset := ""
+		for _, a := range rel.Assets {
+			if a.Name == xmluiRuntimeFile || asset == "" && strings.Contains(a.Name, "standalone") && strings.HasSuffix(a.Name, ".js") {
+				asset = a.URL
+			}
+		}
+		if asset == "" {
+			return "", "", nil
+		}
+		version := strings.TrimPrefix(strings.TrimPrefix(rel.TagName, "xmlui@"), "v")
+		return asset, version, nil
+	}
+	if strings.Contains(lastErr.Error(), "404") {
+		return "", "", nil
+	}
+	return "", "", lastErr
+}
+
+// runtimeVersionOf is the version in a runtime URL: a release's tag or an
+// npm tarball's name.
+func runtimeVersionOf(u string) string {
+	if v := npmVersionFromTarball(xmluiNPMPackage, u); v != "" {
+		return v
+	}
+	if _, rest, ok := strings.Cut(u, "/releases/download/"); ok {
+		tag, _, _ := strings.Cut(rest, "/")
+		if t, err := url.PathUnescape(tag); err == nil {
+			tag = t
+		}
+		return strings.TrimPrefix(strings.TrimPrefix(tag, "xmlui@"), "v")
+	}
+	return runtimeVersionRe.FindString(path.Base(u))
+}
+
+// resolveXMLUIRuntime finds the download of the runtime spec selects: the
+// release asset, or the npm package's tarball, with its integrity hash, for
+// npm:SPEC or when the release has none.
+func resolveXMLUIRuntime(spec string) (source, version, integrity string, err error) {
+	npmSpec, fromNPM := strings.CutPrefix(spec, "npm:")
+	if !fromNPM {
+		if source, version, err = resolveRuntimeRelease(spec); err != nil {
+			return "", "", "", err
+		}
+		if source != "" {
+			return source, version, "", nil
+		}
+		fmt.Printf("  No XMLUI %s release has the runtime; trying npm\n", spec)
+		npmSpec = spec
+	}
+	rel, err := resolveNPM(xmluiNPMPackage, npmSpec)
+	if err != nil {
+		return "", "", "", err
+	}
+	return rel.Tarball, rel.Version, rel.Integrity, nil
+}
+
+// installXMLUIRuntime puts the XMLUI runtime spec selects into the xmlui/
+// directory of appDir as name, component xmlui-runtime. With --locked the
+// file comes from the lockfile.
+func installXMLUIRuntime(opts installOptions, rcpt *receipt, stage, installDir, appDir, spec, name string) error {
+	var source, version, integrity string
+	if opts.lock == nil {
+		var err error
+		if source, version, integrity, err = resolveXMLUIRuntime(spec); err != nil {
+			return err
+		}
+	}
+	data, u, sum, err := opts.fetch(xmluiRuntimeComponent, platform{}, source, "XMLUI runtime")
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = runtimeVersionOf(u)
+	}
+
+	tmp := filepath.Join(stage, xmluiRuntimeComponent)
+	if err := fsys.MkdirAll(tmp, dirMode); err != nil {
+		return err
+	}
+	script := data
+	if strings.HasSuffix(u, ".tgz") {
+		if err := checkIntegrity(data, integrity); err != nil {
+			return fmt.Errorf("%s: %w", u, err)
+		}
+		pkg := filepath.Join(stage, xmluiRuntimeComponent+"-package")
+		if err := extractArchive(data, pkg, 1); err != nil {
+			return err
+		}
+		collectLicenses(xmluiRuntimeComponent, "XMLUI runtime "+version+" from npm", pkg)
+		if script, err = os.ReadFile(filepath.Join(pkg, filepath.FromSlash(xmluiNPMAssets), xmluiRuntimeFile)); err != nil {
+			return fmt.Errorf("%s@%s has no %s/%s", xmluiNPMPackage, version, xmluiNPMAssets, xmluiRuntimeFile)
+		}
+	}
+	if err := fsys.WriteFile(filepath.Join(tmp, name), script, fileMode); err != nil {
+		return err
+	}
+	dest := filepath.Join(appDir, xmluiRuntimeDir)
+	files, st, err := syncTree(tmp, dest, installDir, opts.previousFiles(xmluiRuntimeComponent), nil)
+	if err != nil {
+		return err
+	}
+	c := rcpt.component(xmluiRuntimeComponent, u)
+	d := newDownload(platform{}, u, sum)
+	d.Tag = version
+	c.Files, c.Downloads = files, []receiptDownload{d}
+	if opts.previous != nil {
+		fmt.Printf("  %s: %s\n", xmluiRuntimeComponent, st)
+	}
+	fmt.Printf("%s XMLUI runtime %s is in %s\n", glyphOK, version, receiptKey(installDir, filepath.Join(dest, name)))
+	return nil
+}
+
+// dropXMLUIRuntime removes the runtime a previous install put in, now that
+// none is wanted, leaving any file the app's own download (appFiles) now
+// provides in its place.
+func dropXMLUIRuntime(opts installOptions, installDir string, appFiles map[string]string) error {
+	old := opts.previousFiles(xmluiRuntimeComponent)
+	if len(old) == 0 {
+		return nil
+	}
+	for key := range old {
+		if _, ok := appFiles[key]; ok {
+			continue
+		}
+		path := filepath.Join(installDir, filepath.FromSlash(key))
+		if err := journal.preserve(path); err != nil {
+			return err
+		}
+		fsys.Remove(filepath.Dir(path))
+	}
+	fmt.Println("  Removed the XMLUI runtime")
+	return nil
+}
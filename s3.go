@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3: URLs are fetched with AWS Signature Version 4, using the credentials
+// the AWS CLI would: $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY (with
+// $AWS_SESSION_TOKEN), the $AWS_PROFILE profile of ~/.aws/credentials, an
+// ECS or EKS container role, or the EC2 instance role. Without any, requests
+// go unsigned, which public buckets answer. $AWS_ENDPOINT_URL_S3 (or
+// $AWS_ENDPOINT_URL) sends them to an S3-compatible store such as MinIO
+// instead.
+
+// awsCredentials sign S3 requests; the zero value sends them unsigned.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+}
+
+// awsMetadataTimeout bounds each request to the container or instance
+// metadata endpoints, which don't answer off AWS.
+const awsMetadataTimeout = 2 * time.Second
+
+// s3Credentials are found once per run.
+var s3Credentials struct {
+	once  sync.Once
+	creds awsCredentials
+	err   error
+}
+
+// s3RoundTrip sends req, for s3://bucket/key, to the bucket, signed. A
+// bucket in another region than the configured one is asked again there.
+func s3RoundTrip(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	s3Credentials.once.Do(func() {
+		s3Credentials.creds, s3Credentials.err = findAWSCredentials(base)
+	})
+	if s3Credentials.err != nil {
+		return nil, fmt.Errorf("AWS credentials: %w", s3Credentials.err)
+	}
+	region := awsRegion()
+	for try := 0; ; try++ {
+		r := storedRequest(req, s3URL(req.URL, region))
+		signS3(r, s3Credentials.creds, region, time.Now())
+		resp, err := base.RoundTrip(r)
+		if err != nil || try > 0 {
+			return storedResponse(req, resp, err)
+		}
+		// S3 says where the bucket is when asked in the wrong region.
+		other := resp.Header.Get("X-Amz-Bucket-Region")
+		if other == "" || other == region || resp.StatusCode/100 == 2 {
+			return storedResponse(req, resp, err)
+		}
+		resp.Body.Close()
+		region = other
+	}
+}
+
+// s3URL is the HTTPS URL of s3://bucket/key: virtual-hosted style on AWS,
+// path style on a custom endpoint or for bucket names with dots, which
+// don't match the wildcard certificate.
+func s3URL(u *url.URL, region string) *url.URL {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	var target *url.URL
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		target, _ = url.Parse(strings.TrimSuffix(endpoint, "/"))
+		if target == nil || target.Host == "" {
+			target = &url.URL{Scheme: "https", Host: endpoint}
+		}
+		target.Path += "/" + bucket + "/" + key
+	} else if strings.Contains(bucket, ".") {
+		target = &url.URL{Scheme: "https", Host: "s3." + region + ".amazonaws.com", Path: "/" + bucket + "/" + key}
+	} else {
+		target = &url.URL{Scheme: "https", Host: bucket + ".s3." + region + ".amazonaws.com", Path: "/" + key}
+	}
+	target.RawPath = awsEscapePath(target.Path)
+	return target
+}
+
+// awsEscapePath escapes p as SigV4 canonicalizes it: every byte but the
+// unreserved characters and slashes.
+func awsEscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signS3 adds the Signature Version 4 headers for S3 to req, a GET or HEAD
+// without a body, at now. Unsigned credentials leave it alone.
+func signS3(req *http.Request, creds awsCredentials, region string, now time.Time) {
+	if creds.AccessKeyID == "" {
+		return
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = "UNSIGNED-PAYLOAD"
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	req.Header.Set("X-Amz-Date", stamp)
+	if creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+	}
+
+	signed := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			signed = append(signed, lower)
+		}
+	}
+	sort.Strings(signed)
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.Join(req.Header.Values(name), ",")
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), awsCanonicalQuery(req.URL), headers.String(), strings.Join(signed, ";"), payload}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, strings.Join(signed, ";"), hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsCanonicalQuery is u's query sorted and escaped as SigV4 wants it.
+func awsCanonicalQuery(u *url.URL) string {
+	q := u.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, strings.ReplaceAll(url.QueryEscape(k), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsRegion is the region requests go to first, as the AWS CLI picks it,
+// defaulting to us-east-1; s3RoundTrip follows a bucket elsewhere.
+func awsRegion() string {
+	if r := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"); r != "" {
+		return r
+	}
+	section := "profile " + awsProfile()
+	if awsProfile() == "default" {
+		section = "default"
+	}
+	if r := iniSection(awsFile("AWS_CONFIG_FILE", "config"), section)["region"]; r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+func awsProfile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// awsFile is the AWS CLI's file name in ~/.aws, or where env says it is.
+func awsFile(env, name string) string {
+	if p := os.Getenv(env); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// findAWSCredentials goes down the credential chain. Only a source that is
+// configured but broken is an error; finding none means unsigned requests.
+func findAWSCredentials(base http.RoundTripper) (awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{id, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if keys := iniSection(awsFile("AWS_SHARED_CREDENTIALS_FILE", "credentials"), awsProfile()); keys["aws_access_key_id"] != "" {
+		return awsCredentials{keys["aws_access_key_id"], keys["aws_secret_access_key"], keys["aws_session_token"]}, nil
+	}
+	client := &http.Client{Transport: base, Timeout: awsMetadataTimeout}
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		return awsContainerCredentials(client, "http://169.254.170.2"+rel)
+	}
+	if full := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); full != "" {
+		return awsContainerCredentials(client, full)
+	}
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return awsCredentials{}, nil
+	}
+	// Off EC2 this fails quickly, and requests go unsigned.
+	creds, _ := awsInstanceCredentials(client)
+	return creds, nil
+}
+
+// awsContainerCredentials asks the ECS or EKS credentials endpoint at u.
+func awsContainerCredentials(client *http.Client, u string) (awsCredentials, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return awsCredentialsFrom(client, req)
+}
+
+// awsInstanceCredentials asks the EC2 instance metadata service (IMDSv2)
+// for the instance role's credentials.
+func awsInstanceCredentials(client *http.Client) (awsCredentials, error) {
+	const imds = "http://169.254.169.254/latest/"
+	ctx, cancel := context.WithTimeout(context.Background(), awsMetadataTimeout)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "PUT", imds+"api/token", nil)
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "300")
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	token, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("instance metadata token: %s", resp.Status)
+	}
+	get := func(path string) (*http.Request, error) {
+		req, err := http.NewRequest("GET", imds+path, nil)
+		if err == nil {
+			req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+		}
+		return req, err
+	}
+	req, _ = get("meta-data/iam/security-credentials/")
+	resp, err = client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	role, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("instance role: %s", resp.Status)
+	}
+	req, _ = get("meta-data/iam/security-credentials/" + strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]))
+	return awsCredentialsFrom(client, req)
+}
+
+// awsCredentialsFrom decodes the credentials a metadata endpoint answers
+// req with.
+func awsCredentialsFrom(client *http.Client, req *http.Request) (awsCredentials, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	var creds awsCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return awsCredentials{}, fmt.Errorf("%s: %w", req.URL, err)
+	}
+	return creds, nil
+}
+
+// iniSection returns the keys of [section] in the INI file at path, as the
+// AWS CLI writes them; a missing file or section has none.
+func iniSection(path, section string) map[string]string {
+	keys := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return keys
+	}
+	defer f.Close()
+	in := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			in = strings.TrimSpace(strings.Trim(line, "[]")) == section
+		case in:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				keys[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return keys
+}
+
+// firstEnv is the first of the variables names that is set.
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
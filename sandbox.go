@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// fsCapabilities are what the filesystem of the install dir can do. exFAT
+// and FAT can't change file modes or make symbolic links, nor can some
+// locked-down container mounts, and an install there degrades instead of
+// failing: see chmodExec and dirTarget.Symlink.
+type fsCapabilities struct {
+	chmod, symlink bool
+}
+
+// installFS is what the install dir's filesystem can do, as probed by
+// probeFilesystem or limited by --sandbox.
+var installFS = fsCapabilities{chmod: true, symlink: true}
+
+// sandboxed lists the executables and scripts chmodExec couldn't mark
+// executable, for the end of the install to say how to run them.
+var sandboxed []string
+
+// errSymlinksUnsupported is a symbolic link not tried, as installFS can't
+// make them.
+var errSymlinksUnsupported = errors.New("the filesystem doesn't support symbolic links")
+
+// execWrapperSuffix names the script written next to a binary that can't be
+// made executable, which runs a copy of it from the user's cache.
+const execWrapperSuffix = ".sh"
+
+// probeFilesystem tries changing a mode and making a symbolic link in dir.
+// The probe leaves nothing behind, so it bypasses fsys and the audit log.
+func probeFilesystem(dir string) fsCapabilities {
+	caps := fsCapabilities{chmod: true, symlink: true}
+	f, err := os.CreateTemp(dir, ".xmlui-fs-test-")
+	if err != nil {
+		// The install reports the real problem.
+		return caps
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+	// Windows modes are only the read-only bit; executables don't need one.
+	if hostOS.execBits() {
+		err := os.Chmod(name, 0700)
+		info, serr := os.Stat(name)
+		caps.chmod = err == nil && serr == nil && info.Mode().Perm() == 0700
+	}
+	link := name + ".link"
+	caps.symlink = os.Symlink(filepath.Base(name), link) == nil
+	os.Remove(link)
+	return caps
+}
+
+// describe says what an install does instead of what c can't do.
+func (c fsCapabilities) describe() string {
+	var parts []string
+	if !c.chmod {
+		parts = append(parts, "can't change file modes, so binaries that aren't executable get wrapper scripts")
+	}
+	if !c.symlink {
+		parts = append(parts, "can't make symbolic links, so their targets are copied")
+	}
+	return strings.Join(parts, "; it ")
+}
+
+// isExecutable reports whether path has an execute permission bit.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&0111 != 0
+}
+
+// sandboxExec is chmodExec where modes can't be changed. An executable
+// already marked so (as everything on exFAT mounted with the default
+// fmask is) needs nothing; a script runs with sh; a binary gets a wrapper.
+func sandboxExec(path string) error {
+	if isExecutable(path) {
+		return nil
+	}
+	if !containsString(sandboxed, path) {
+		sandboxed = append(sandboxed, path)
+	}
+	if isScript(path) {
+		return nil
+	}
+	return writeExecWrapper(path)
+}
+
+// isScript reports whether path starts with a #! line.
+func isScript(path string) bool {
+	head := make([]byte, 2)
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	f.Read(head)
+	return bytes.Equal(head, []byte("#!"))
+}
+
+// writeExecWrapper writes the script that runs the binary at path from a
+// copy in the user's cache, where it can be made executable. The copy is
+// refreshed whenever the binary changes, e.g. by an update.
+func writeExecWrapper(path string) error {
+	name := filepath.Base(path)
+	abs, _ := filepath.Abs(path)
+	sum := sha256.Sum256([]byte(abs))
+	script := fmt.Sprintf(`#!/bin/sh
+# Written by xmlui-bundler: this filesystem can't mark %[1]s
+# executable, so this runs a copy of it from the user's cache.
+src="$(dirname "$0")/%[1]s"
+dir="${XDG_CACHE_HOME:-$HOME/.cache}/xmlui-bundler/exec/%[2]s"
+if ! cmp -s "$src" "$dir/%[1]s" 2>/dev/null; then
+	mkdir -p "$dir" && cp "$src" "$dir/%[1]s.tmp" && chmod 755 "$dir/%[1]s.tmp" && mv -f "$dir/%[1]s.tmp" "$dir/%[1]s" || exit 1
+fi
+exec "$dir/%[1]s" "$@"
+`, name, hex.EncodeToString(sum[:6]))
+	return fsys.WriteFile(path+execWrapperSuffix, []byte(script), execMode())
+}
+
+// runCommand is how to run the executable or script at path, shown as
+// display: display itself, or through sh (and the binary's wrapper) where
+// the filesystem couldn't mark it executable.
+func runCommand(path, display string) string {
+	if !hostOS.execBits() || isExecutable(path) {
+		return display
+	}
+	if _, err := os.Stat(path + execWrapperSuffix); err == nil {
+		return "sh " + display + execWrapperSuffix
+	}
+	return "sh " + display
+}
+
+// installedCommand is exec.CommandContext for an installed executable,
+// run through sh (and its wrapper) where the filesystem couldn't mark it
+// executable.
+func installedCommand(ctx context.Context, path string, args ...string) *exec.Cmd {
+	if hostOS.execBits() && !isExecutable(path) {
+		if _, err := os.Stat(path + execWrapperSuffix); err == nil {
+			return exec.CommandContext(ctx, "sh", append([]string{path + execWrapperSuffix}, args...)...)
+		}
+		if isScript(path) {
+			return exec.CommandContext(ctx, "sh", append([]string{path}, args...)...)
+		}
+	}
+	return exec.CommandContext(ctx, path, args...)
+}
+
+// reportSandboxed explains, at the end of an install, how to run what
+// couldn't be marked executable.
+func reportSandboxed(installDir string) {
+	if len(sandboxed) == 0 {
+		return
+	}
+	fmt.Printf("%s This filesystem can't mark files executable; run these through sh:\n", labelWarning)
+	for _, p := range sandboxed {
+		fmt.Printf("  %s\n", runCommand(p, receiptKey(installDir, p)))
+	}
+}
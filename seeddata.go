@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// isSeedDatabase reports whether the file at path is a SQLite database.
+func isSeedDatabase(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+	default:
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(sqliteHeader))
+	n, _ := f.Read(header)
+	return n == len(header) && bytes.Equal(header, sqliteHeader)
+}
+
+// saveSeedData snapshots the app's seed databases into the install's state
+// directory, so reset-data can restore them after users have mangled the
+// demo data, and
+// returns their receipt keys and hashes. appFiles holds the upstream hashes
+// of the app's files: on update a database the user has already changed is
+// not re-snapshotted, and the earlier pristine copy is kept if it still
+// matches upstream.
+func saveSeedData(installDir string, appFiles map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(appFiles))
+	for key := range appFiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seedDir, err := installStatePath(installDir, stateSeedDir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, key := range keys {
+		upstream := appFiles[key]
+		db := filepath.Join(installDir, filepath.FromSlash(key))
+		pristine := filepath.Join(seedDir, filepath.FromSlash(key))
+		if h, err := hashFile(db); err == nil && h == upstream {
+			if !isSeedDatabase(db) {
+				continue
+			}
+			if err := fsys.MkdirAll(filepath.Dir(pristine), dirMode); err != nil {
+				return nil, err
+			}
+			if err := copyFile(db, pristine, fileMode); err != nil {
+				return nil, err
+			}
+		} else if !isSeedDatabase(pristine) {
+			if isSeedDatabase(db) {
+				warn("  %s has local changes and no pristine copy; reset-data will not cover it", key)
+			}
+			continue
+		} else if h, err := hashFile(pristine); err != nil || h != upstream {
+			warn("  %s has local changes and no pristine copy; reset-data will not cover it", key)
+			continue
+		}
+		files[key] = upstream
+	}
+	return files, nil
+}
+
+// runResetData implements `reset-data`: it restores the demo databases to
+// the state they were installed in.
+func runResetData(args []string) int {
+	fs := newFlagSet("reset-data")
+	dir := installDirFlag(fs)
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	var files map[string]string
+	for _, c := range rcpt.Components {
+		if c.Name == "seed-data" {
+			files = c.Files
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println("This install has no seed database to reset")
+		return exitFailure
+	}
+
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if !*yes {
+		fmt.Println("This discards all changes to the demo data in:")
+		for _, k := range keys {
+			fmt.Printf("  %s\n", k)
+		}
+		fmt.Println("Stop the test server first. Continue? [y/N]")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Cancelled")
+			return exitFailure
+		}
+	}
+
+	seedDir, err := installStatePath(installDir, stateSeedDir)
+	if err != nil {
+		fmt.Println("Could not locate the pristine copies:", err)
+		return exitFailure
+	}
+	for _, k := range keys {
+		pristine := filepath.Join(seedDir, filepath.FromSlash(k))
+		if h, err := hashFile(pristine); err != nil || h != files[k] {
+			fmt.Printf("Pristine copy of %s is missing or corrupt; run `xmlui-bundler update` to restore it\n", k)
+			return exitFailure
+		}
+		db := filepath.Join(installDir, filepath.FromSlash(k))
+		if err := copyFile(pristine, db, fileMode); err != nil {
+			fmt.Printf("Failed to restore %s: %v\n", k, err)
+			return exitFailure
+		}
+		// Stale journals would be replayed over the restored database.
+		for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+			fsys.Remove(db + suffix)
+		}
+		fmt.Printf("%s Restored %s\n", glyphOK, k)
+	}
+	return exitOK
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runServe implements `serve`: it starts the test server for the installed
+// app, waits until it answers HTTP, and then stays attached until it exits.
+func runServe(args []string) int {
+	fs := newFlagSet("serve")
+	dir := installDirFlag(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the server to become healthy")
+	port := fs.Int("port", 0, "port to check (default: the one recorded at install); with --all, the first port to hand out")
+	all := fs.Bool("all", false, "serve every install in --dir (the workspace), each on its own port")
+	statusAll := fs.Bool("status-all", false, "show the servers a serve --all in --dir is running")
+	stopAll := fs.Bool("stop-all", false, "stop the servers a serve --all in --dir is running")
+	watch := fs.Bool("watch", false, "reload open pages whenever a .xmlui or .css file in the app changes")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	switch {
+	case *watch && (*all || *statusAll || *stopAll):
+		fmt.Println("--watch serves a single app; it can't be combined with --all, --status-all or --stop-all")
+		return exitUsage
+	case *all:
+		return runServeAll(installDir, *port, *timeout)
+	case *statusAll:
+		return runServeStatusAll(installDir)
+	case *stopAll:
+		return runServeStopAll(installDir)
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	if *port == 0 {
+		*port = rcpt.Port
+	}
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	stateDir, err := installStateDir(installDir)
+	if err == nil {
+		err = fsys.MkdirAll(stateDir, dirMode)
+	}
+	if err != nil {
+		fmt.Println("Could not create the state directory:", err)
+		return exitFailure
+	}
+	// The log captures the server's output for troubleshooting; the PID file
+	// lets scripts find a running server.
+	logPath := filepath.Join(stateDir, serverLogFile)
+	pidPath := filepath.Join(stateDir, serverPIDFile)
+
+	// With --watch the server runs on a private port behind the live
+	// reload proxy, which takes the app's port.
+	serverPort := *port
+	if *watch {
+		if serverPort, err = freeLocalPort(); err != nil {
+			fmt.Println("Could not find a port for the test server:", err)
+			return exitFailure
+		}
+	}
+	cmd := serverCommand(appDir, filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("server"))), serverPort)
+	if err := startServer(cmd, logPath); err != nil {
+		fmt.Println("Failed to start test server:", err)
+		return exitFailure
+	}
+	fsys.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), fileMode)
+	defer fsys.Remove(pidPath)
+	// Ctrl-C reaches the server as well; wait for it to exit so the PID file
+	// is removed, and pass on a SIGTERM sent to us alone.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range sigs {
+			if sig != os.Interrupt {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d/", *port)
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	if err := waitHealthy(fmt.Sprintf("http://localhost:%d/", serverPort), *timeout, exited); err != nil {
+		fmt.Println("Test server did not become healthy:", err)
+		printLogTail(logPath, 50)
+		cmd.Process.Kill()
+		return exitFailure
+	}
+	if *watch {
+		target, _ := neturl.Parse(fmt.Sprintf("http://127.0.0.1:%d", serverPort))
+		lr := newLiveReloader(target)
+		srv, err := startLiveReload(lr, *port)
+		if err != nil {
+			fmt.Println("Could not start the live reload proxy:", err)
+			cmd.Process.Kill()
+			return exitFailure
+		}
+		defer srv.Close()
+		stop := make(chan struct{})
+		defer close(stop)
+		go watchSources(appDir, lr, stop)
+	}
+	fmt.Printf("%s Test server ready at %s\n", glyphOK, url)
+	if reason := rcpt.component("server", "").Unavailable; reason != "" {
+		warn("Serving %s (%s)", staticServerNote, reason)
+	}
+	if *watch {
+		fmt.Println("  Watching the app's .xmlui and .css files; open pages reload when they change")
+	}
+	fmt.Printf("  Logging to %s; press Ctrl-C to stop\n", logPath)
+
+	if err := <-exited; err != nil {
+		fmt.Println("Test server exited:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// serverCommand prepares the app's start script (or the server binary in
+// binDir if there is none, or `server static` without a test server). PORT is set for scripts that honor it, along with the
+// environment of the app's variant. A server moved out of the app dir by a
+// destination override is run directly, as the start scripts expect it
+// next to them.
+func serverCommand(appDir, binDir string, port int) *exec.Cmd {
+	script := func(name string) bool {
+		_, err := os.Stat(filepath.Join(appDir, name))
+		return err == nil && binDir == appDir
+	}
+	var cmd *exec.Cmd
+	if !hasTestServer(binDir) {
+		cmd = staticServerCommand(appDir)
+	} else if hostOS.goos() == "windows" {
+		if script("start.bat") {
+			// /e:on: the script's if/set forms need command extensions,
+			// which a policy may have turned off.
+			cmd = exec.Command("cmd", "/e:on", "/c", "start.bat")
+		} else if _, err := os.Stat(filepath.Join(binDir, "xmlui-test-server.cmd")); err == nil {
+			// Dispatch script from an --all-platforms install.
+			cmd = exec.Command("cmd", "/c", filepath.Join(binDir, "xmlui-test-server.cmd"))
+		} else {
+			cmd = exec.Command(filepath.Join(binDir, "xmlui-test-server.exe"))
+		}
+	} else {
+		// start.sh runs the server itself, which it can't on a filesystem
+		// that couldn't mark it executable.
+		if server := filepath.Join(binDir, "xmlui-test-server"); script("start.sh") && isExecutable(server) {
+			cmd = exec.Command("sh", "./start.sh")
+		} else {
+			cmd = installedCommand(context.Background(), server)
+		}
+	}
+	cmd.Dir = appDir
+	cmd.Env = append(append(os.Environ(), variantEnv(appDir)...), fmt.Sprintf("PORT=%d", port))
+	return cmd
+}
+
+// startServer starts cmd (see serverCommand) with output going to logPath.
+func startServer(cmd *exec.Cmd, logPath string) error {
+	logFile, err := fsys.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	return nil
+}
+
+// waitHealthy polls url until it returns a non-5xx response, the server
+// process exits, or timeout passes.
+func waitHealthy(url string, timeout time.Duration, exited <-chan error) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if err == nil {
+				return fmt.Errorf("server exited before answering %s", url)
+			}
+			return fmt.Errorf("server exited before answering %s: %v", url, err)
+		default:
+		}
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			lastErr = fmt.Errorf("%s returned %s", url, resp.Status)
+		} else {
+			lastErr = err
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return fmt.Errorf("no healthy response within %v (last error: %v)", timeout, lastErr)
+}
+
+// printLogTail prints the last n lines of the file at path.
+func printLogTail(path string, n int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Printf("(%s is empty)\n", path)
+		return
+	}
+	fmt.Printf("Last %d lines of %s:\n", len(lines), path)
+	fmt.Println("  " + strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveGroupsDir, in the state dir, holds one file per workspace that
+// `serve --all` is serving, named like an install's state dir.
+const serveGroupsDir = "groups"
+
+// serveGroup is what `serve --all` records about the servers it runs, for
+// --status-all and --stop-all.
+type serveGroup struct {
+	Workspace string        `json:"workspace"`
+	PID       int           `json:"pid"`
+	Started   time.Time     `json:"started"`
+	Servers   []groupServer `json:"servers"`
+}
+
+// groupServer is one app's server in a serveGroup.
+type groupServer struct {
+	Install string `json:"install"`
+	App     string `json:"app"`
+	Port    int    `json:"port"`
+	URL     string `json:"url"`
+	PID     int    `json:"pid"`
+	Log     string `json:"log"`
+}
+
+// serveGroupPath is the group file of workspace.
+func serveGroupPath(workspace string) (string, error) {
+	base, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	key, err := installKey(workspace)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, serveGroupsDir, key+".json"), nil
+}
+
+func readServeGroup(workspace string) (*serveGroup, string, error) {
+	path, err := serveGroupPath(workspace)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, err
+	}
+	var g serveGroup
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, path, fmt.Errorf("%s: %w", path, err)
+	}
+	return &g, path, nil
+}
+
+// workspaceInstalls returns the recorded installs in or under workspace
+// that still have a receipt, sorted by path.
+func workspaceInstalls(workspace string) ([]string, error) {
+	index, err := readInstallIndex()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, dir := range index {
+		if !hasPathPrefix(dir, workspace) {
+			continue
+		}
+		if _, err := readReceipt(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// portFree reports whether nothing listens on port.
+func portFree(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// assignPorts gives each wanted port, or the next one up that is neither
+// taken by another server nor assigned earlier in the list.
+func assignPorts(wanted []int) []int {
+	used := map[int]bool{}
+	ports := make([]int, len(wanted))
+	for i, p := range wanted {
+		for used[p] || !portFree(p) {
+			p++
+		}
+		used[p] = true
+		ports[i] = p
+	}
+	return ports
+}
+
+// runServeAll implements `serve --all`: it starts the test server of every
+// install under workspace, each on its own port, prints which app is at
+// which URL and stays attached until they have all exited. Ctrl-C, or
+// `serve --stop-all` from another terminal, stops the whole group. basePort,
+// if set, is the first port handed out; otherwise each app asks for its own.
+func runServeAll(workspace string, basePort int, timeout time.Duration) int {
+	if g, _, err := readServeGroup(workspace); err == nil && processAlive(g.PID) {
+		fmt.Printf("Servers for %s are already running (pid %d); see serve --status-all, or serve --stop-all\n", workspace, g.PID)
+		return exitFailure
+	}
+	dirs, err := workspaceInstalls(workspace)
+	if err != nil {
+		fmt.Println("Could not read the install index:", err)
+		return exitFailure
+	}
+	if len(dirs) == 0 {
+		fmt.Println("No installs found in", workspace)
+		return exitFailure
+	}
+
+	type member struct {
+		groupServer
+		appDir string
+		binDir string
+		cmd    *exec.Cmd
+		exited chan error
+	}
+	members := make([]*member, 0, len(dirs))
+	wanted := make([]int, 0, len(dirs))
+	for _, dir := range dirs {
+		rcpt, err := readReceipt(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(workspace, dir)
+		if err != nil {
+			rel = dir
+		}
+		members = append(members, &member{
+			groupServer: groupServer{Install: dir, App: filepath.Join(rel, filepath.FromSlash(rcpt.appDir()))},
+			appDir:      filepath.Join(dir, filepath.FromSlash(rcpt.appDir())),
+			binDir:      filepath.Join(dir, filepath.FromSlash(rcpt.binDir("server"))),
+		})
+		if basePort != 0 {
+			wanted = append(wanted, basePort)
+		} else {
+			wanted = append(wanted, rcpt.port())
+		}
+	}
+	ports := assignPorts(wanted)
+
+	var mu sync.Mutex
+	stopping := false
+	stopAll := func() {
+		mu.Lock()
+		stopping = true
+		mu.Unlock()
+		for _, m := range members {
+			if m.cmd != nil {
+				killProcessTree(m.cmd)
+			}
+		}
+	}
+	var running []*member
+	for i, m := range members {
+		m.Port = ports[i]
+		m.URL = fmt.Sprintf("http://localhost:%d/", m.Port)
+		if wanted[i] != m.Port && basePort == 0 {
+			fmt.Printf("  %s: port %d is taken, using %d\n", m.App, wanted[i], m.Port)
+		}
+		stateDir, err := installStateDir(m.Install)
+		if err == nil {
+			err = fsys.MkdirAll(stateDir, dirMode)
+		}
+		if err != nil {
+			fmt.Printf("%s %s: could not create the state directory: %v\n", glyphFail, m.App, err)
+			continue
+		}
+		m.Log = filepath.Join(stateDir, serverLogFile)
+		cmd := serverCommand(m.appDir, m.binDir, m.Port)
+		ownProcessGroup(cmd)
+		if err := startServer(cmd, m.Log); err != nil {
+			fmt.Printf("%s %s: failed to start test server: %v\n", glyphFail, m.App, err)
+			continue
+		}
+		m.cmd, m.PID, m.exited = cmd, cmd.Process.Pid, make(chan error, 1)
+		go func() { m.exited <- cmd.Wait() }()
+		pidPath := filepath.Join(stateDir, serverPIDFile)
+		fsys.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n", m.PID)), fileMode)
+		defer fsys.Remove(pidPath)
+		running = append(running, m)
+	}
+
+	// The group stops as one, whether we get Ctrl-C (which the servers, in
+	// process groups of their own, don't see) or SIGTERM.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		<-sigs
+		fmt.Println("Stopping all servers...")
+		stopAll()
+	}()
+
+	// The servers start in parallel; wait for each in turn.
+	var healthy []*member
+	for _, m := range running {
+		if err := waitHealthy(m.URL, timeout, m.exited); err != nil {
+			mu.Lock()
+			quiet := stopping
+			mu.Unlock()
+			if quiet {
+				break
+			}
+			fmt.Printf("%s %s did not become healthy: %v\n", glyphFail, m.App, err)
+			printLogTail(m.Log, 20)
+			killProcessTree(m.cmd)
+			continue
+		}
+		healthy = append(healthy, m)
+	}
+	mu.Lock()
+	interrupted := stopping
+	mu.Unlock()
+	if interrupted || len(healthy) == 0 {
+		stopAll()
+		for _, m := range running {
+			<-m.exited
+		}
+		if interrupted {
+			return exitFailure
+		}
+		fmt.Println("No test server became healthy")
+		return exitFailure
+	}
+
+	group := serveGroup{Workspace: workspace, PID: os.Getpid(), Started: time.Now()}
+	width := len("APP")
+	for _, m := range healthy {
+		group.Servers = append(group.Servers, m.groupServer)
+		width = max(width, len(m.App))
+	}
+	groupPath, err := serveGroupPath(workspace)
+	if err == nil {
+		err = fsys.MkdirAll(filepath.Dir(groupPath), dirMode)
+	}
+	if err == nil {
+		var data []byte
+		data, err = json.MarshalIndent(group, "", "  ")
+		if err == nil {
+			err = fsys.WriteFile(groupPath, append(data, '\n'), fileMode)
+		}
+	}
+	recorded := err == nil
+	if recorded {
+		defer func() {
+			if g, _, err := readServeGroup(workspace); err == nil && g.PID == group.PID {
+				fsys.Remove(groupPath)
+			}
+		}()
+	} else {
+		warn("Could not record the group; --status-all and --stop-all won't find it: %v", err)
+	}
+
+	fmt.Printf("%s %d of %d test servers ready\n", glyphOK, len(healthy), len(members))
+	fmt.Printf("  %-*s  %s\n", width, "APP", "URL")
+	for _, m := range healthy {
+		fmt.Printf("  %-*s  %s\n", width, m.App, m.URL)
+	}
+	fmt.Println("  Logs are in each install's state dir; press Ctrl-C to stop them all")
+
+	failed := len(healthy) < len(members)
+	for _, m := range healthy {
+		err := <-m.exited
+		mu.Lock()
+		quiet := stopping
+		mu.Unlock()
+		// --stop-all removes the group file before it stops the servers.
+		if _, statErr := os.Stat(groupPath); recorded && os.IsNotExist(statErr) {
+			quiet = true
+		}
+		if !quiet {
+			if err != nil {
+				fmt.Printf("%s %s exited: %v\n", glyphFail, m.App, err)
+				failed = true
+			} else {
+				fmt.Printf("%s exited\n", m.App)
+			}
+		}
+	}
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runServeStatusAll implements `serve --status-all`: whether each server of
+// workspace's group is up and answering.
+func runServeStatusAll(workspace string) int {
+	g, _, err := readServeGroup(workspace)
+	if os.IsNotExist(err) || err == nil && !processAlive(g.PID) {
+		fmt.Println("No servers are running for", workspace)
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Println("Could not read the server group:", err)
+		return exitFailure
+	}
+	width := len("APP")
+	for _, s := range g.Servers {
+		width = max(width, len(s.App))
+	}
+	fmt.Printf("Servers for %s (pid %d, since %s):\n", workspace, g.PID, g.Started.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  %-*s  %-24s  %s\n", width, "APP", "URL", "STATE")
+	client := &http.Client{Timeout: 2 * time.Second}
+	down := 0
+	for _, s := range g.Servers {
+		state := glyphOK + " up"
+		switch {
+		case !processAlive(s.PID):
+			state = glyphFail + " stopped"
+			down++
+		default:
+			resp, err := client.Get(s.URL)
+			if err != nil {
+				state = glyphFail + " not answering"
+				down++
+			} else {
+				resp.Body.Close()
+				if resp.StatusCode >= 500 {
+					state = fmt.Sprintf("%s %s", glyphFail, resp.Status)
+					down++
+				}
+			}
+		}
+		fmt.Printf("  %-*s  %-24s  %s\n", width, s.App, s.URL, state)
+	}
+	if down > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runServeStopAll implements `serve --stop-all`: it stops every server of
+// workspace's group; the `serve --all` that started them then exits.
+func runServeStopAll(workspace string) int {
+	g, path, err := readServeGroup(workspace)
+	if os.IsNotExist(err) {
+		fmt.Println("No servers are running for", workspace)
+		return exitOK
+	}
+	if err != nil {
+		fmt.Println("Could not read the server group:", err)
+		return exitFailure
+	}
+	// Removed first, so serve --all knows its servers were stopped on
+	// purpose.
+	fsys.Remove(path)
+	stopped := 0
+	for _, s := range g.Servers {
+		if !processAlive(s.PID) {
+			continue
+		}
+		if err := killProcessGroup(s.PID); err != nil {
+			fmt.Printf("%s Could not stop %s (pid %d): %v\n", glyphFail, s.App, s.PID, err)
+			continue
+		}
+		stopped++
+	}
+	fmt.Printf("%s Stopped %d test servers\n", glyphOK, stopped)
+	return exitOK
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultServerChecks are fetched by `server test`: the app's index page and
+// an API route answered from the seed database.
+var defaultServerChecks = []string{"/", "/api/invoices"}
+
+// stringsFlag is a repeatable flag collecting its values.
+type stringsFlag struct{ values *[]string }
+
+func (f stringsFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, ",")
+}
+
+func (f stringsFlag) Set(s string) error {
+	*f.values = append(*f.values, s)
+	return nil
+}
+
+// runServer implements the `server` command group.
+func runServer(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler server test [--dir DIR] [--check PATH]...")
+		fmt.Println("       xmlui-bundler server static [--port N] APPDIR")
+		return exitUsage
+	}
+	switch args[0] {
+	case "test":
+		return runServerTest(args[1:])
+	case "static":
+		return runServerStatic(args[1:])
+	default:
+		fmt.Printf("Unknown server command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// runServerTest implements `server test`: it starts the test server for the
+// installed app on a spare port, checks a few routes and shuts it down.
+func runServerTest(args []string) int {
+	fs := newFlagSet("server test")
+	dir := installDirFlag(fs)
+	port := fs.Int("port", 0, "port to run the server on (default: any free port)")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the server to become healthy")
+	var checks []string
+	fs.Var(stringsFlag{&checks}, "check", "route to GET and validate (repeatable; default: "+strings.Join(defaultServerChecks, ", ")+")")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	binDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("server")))
+	if err := testServer(appDir, binDir, *port, checks, *timeout); err != nil {
+		fmt.Println(glyphFail, "Test server smoke test failed:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// testServer starts the server in appDir, GETs each of checks (or
+// defaultServerChecks) and stops it again, printing each result. port 0
+// picks a free one.
+func testServer(appDir, binDir string, port int, checks []string, timeout time.Duration) error {
+	if len(checks) == 0 {
+		checks = defaultServerChecks
+	}
+	base, logPath, stop, err := startSpareServer(appDir, binDir, port, timeout)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	fmt.Printf("  %s server answering at %s\n", glyphOK, base)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	failed := 0
+	for _, path := range checks {
+		summary, err := checkRoute(client, base, path)
+		if err != nil {
+			fmt.Printf("  %s GET %s: %v\n", glyphFail, path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  %s GET %s: %s\n", glyphOK, path, summary)
+	}
+	if failed > 0 {
+		printLogTail(logPath, 50)
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	fmt.Println(glyphOK, "Test server passed the smoke test")
+	return nil
+}
+
+// startSpareServer starts the server in appDir on port (0 picks a free one)
+// with its output in a temporary log, and waits until it answers. stop
+// shuts it down and removes the log. On failure the log's tail is printed
+// and nothing is left running.
+func startSpareServer(appDir, binDir string, port int, timeout time.Duration) (base, logPath string, stop func(), err error) {
+	if port == 0 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", "", nil, err
+		}
+		port = l.Addr().(*net.TCPAddr).Port
+		l.Close()
+	}
+	logFile, err := fsys.CreateTemp("", "xmlui-server-test-*.log")
+	if err != nil {
+		return "", "", nil, err
+	}
+	logPath = logFile.Name()
+	logFile.Close()
+
+	cmd := serverCommand(appDir, binDir, port)
+	ownProcessGroup(cmd)
+	if err := startServer(cmd, logPath); err != nil {
+		fsys.Remove(logPath)
+		return "", "", nil, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	stop = func() {
+		killProcessTree(cmd)
+		<-exited
+		fsys.Remove(logPath)
+	}
+
+	base = fmt.Sprintf("http://localhost:%d", port)
+	if err := waitHealthy(base+"/", timeout, exited); err != nil {
+		printLogTail(logPath, 50)
+		stop()
+		return "", "", nil, err
+	}
+	return base, logPath, stop, nil
+}
+
+// checkRoute GETs path and validates the response: it must be 200 and
+// non-empty, and JSON responses (or anything under /api/) must parse, with
+// at least one row if they are a list, since the seed data has some.
+func checkRoute(client *http.Client, base, path string) (string, error) {
+	resp, err := client.Get(base + path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	if len(body) == 0 {
+		return "", fmt.Errorf("empty response")
+	}
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ctype != "application/json" && !strings.HasPrefix(path, "/api/") {
+		return fmt.Sprintf("%s, %s", ctype, humanBytes(int64(len(body)))), nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", fmt.Errorf("response is not JSON: %v", err)
+	}
+	if rows, ok := v.([]any); ok {
+		if len(rows) == 0 {
+			return "", fmt.Errorf("empty list; is the seed database missing?")
+		}
+		return fmt.Sprintf("JSON, %d rows", len(rows)), nil
+	}
+	return "JSON", nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultServiceName names the login service `service install` registers.
+const defaultServiceName = "xmlui-test-server"
+
+// updateCheckSuffix names the periodic update check `service install
+// --update-checks` registers beside the service.
+const updateCheckSuffix = "-update-check"
+
+// serviceNamePattern keeps service names valid as unit, plist and task names.
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// loginService is what `service install` registers with the OS: this
+// launcher running `serve` for one install.
+type loginService struct {
+	name       string
+	installDir string
+	stateDir   string
+	// command is the launcher executable and its arguments.
+	command []string
+	// schedule, daily or weekly, runs command periodically instead of at
+	// login.
+	schedule string
+}
+
+// runService implements the `service` command group.
+func runService(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler service install|uninstall [--dir DIR] [--name NAME] [--update-checks daily|weekly]")
+		return exitUsage
+	}
+	switch args[0] {
+	case "install":
+		return runServiceInstall(args[1:])
+	case "uninstall":
+		return runServiceUninstall(args[1:])
+	default:
+		fmt.Printf("Unknown service command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// runServiceInstall implements `service install`: it registers a user-level
+// service (a systemd user unit, a launchd agent or a Scheduled Task) that
+// starts the test server at login, e.g. for kiosk-style demo machines, and
+// starts it now. --update-checks also registers a periodic
+// `check-updates --notify` for the install.
+func runServiceInstall(args []string) int {
+	fs := newFlagSet("service install")
+	dir := installDirFlag(fs)
+	name := fs.String("name", defaultServiceName, "service name, to run more than one install's server")
+	port := fs.Int("port", 0, "port to serve on (default: the one recorded at install)")
+	updateChecks := fs.String("update-checks", "", "also check for updates, daily or weekly, and show a desktop notification when there are some (see check-updates)")
+	fs.Parse(args)
+
+	if !serviceNamePattern.MatchString(*name) {
+		fmt.Printf("Invalid --name %q: use letters, digits, '.', '_' and '-'\n", *name)
+		return exitUsage
+	}
+	if *updateChecks != "" && *updateChecks != "daily" && *updateChecks != "weekly" {
+		fmt.Printf("Invalid --update-checks %q: want daily or weekly\n", *updateChecks)
+		return exitUsage
+	}
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	if _, err := readReceipt(installDir); err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	// The service runs this executable, so it must stay where it is.
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Println("Could not locate this executable:", err)
+		return exitFailure
+	}
+	stateDir, err := installStateDir(installDir)
+	if err == nil {
+		err = fsys.MkdirAll(stateDir, dirMode)
+	}
+	if err != nil {
+		fmt.Println("Could not create the state directory:", err)
+		return exitFailure
+	}
+	svc := loginService{
+		name:       *name,
+		installDir: installDir,
+		stateDir:   stateDir,
+		command:    []string{exe, "serve", "--dir", installDir},
+	}
+	if *port != 0 {
+		svc.command = append(svc.command, "--port", strconv.Itoa(*port))
+	}
+
+	where, err := installLoginService(svc)
+	if err != nil {
+		fmt.Println("Failed to install the service:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Installed service %s (%s)\n", glyphOK, svc.name, where)
+	fmt.Println("  It starts the test server at login; it runs", exe+",", "so keep that file in place")
+	if *updateChecks != "" {
+		check := loginService{
+			name:       svc.name + updateCheckSuffix,
+			installDir: installDir,
+			stateDir:   stateDir,
+			command:    []string{exe, "check-updates", "--dir", installDir, "--notify"},
+			schedule:   *updateChecks,
+		}
+		where, err := installLoginService(check)
+		if err != nil {
+			fmt.Println("Failed to schedule the update checks:", err)
+			return exitFailure
+		}
+		fmt.Printf("%s Scheduled %s update checks (%s)\n", glyphOK, check.schedule, where)
+	}
+	fmt.Printf("  Remove it with: xmlui-bundler service uninstall --name %s\n", svc.name)
+	return exitOK
+}
+
+// runServiceUninstall implements `service uninstall`: it stops and removes
+// what `service install` registered, update checks included.
+func runServiceUninstall(args []string) int {
+	fs := newFlagSet("service uninstall")
+	name := fs.String("name", defaultServiceName, "name the service was installed with")
+	fs.Parse(args)
+
+	if !serviceNamePattern.MatchString(*name) {
+		fmt.Printf("Invalid --name %q\n", *name)
+		return exitUsage
+	}
+	where, err := uninstallLoginService(*name)
+	if err != nil {
+		fmt.Println("Failed to uninstall the service:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Removed service %s (%s)\n", glyphOK, *name, where)
+	if where, err := uninstallLoginService(*name + updateCheckSuffix); err == nil {
+		fmt.Printf("%s Removed the update checks (%s)\n", glyphOK, where)
+	}
+	return exitOK
+}
+
+// runServiceTool runs systemctl, launchctl or schtasks, putting its output
+// into the error if it fails.
+func runServiceTool(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, msg)
+		}
+		return fmt.Errorf("%s %s: %v", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// launchdLabelPrefix namespaces the launch agents on macOS.
+const launchdLabelPrefix = "com.xmlui."
+
+// installLoginService writes a systemd user unit (or a launchd agent on
+// macOS), enables it and starts it. It returns the file it wrote.
+func installLoginService(svc loginService) (string, error) {
+	if hostOS.goos() == "darwin" {
+		return installLaunchAgent(svc)
+	}
+	unitPath, err := systemdUnitPath(svc.name)
+	if err != nil {
+		return "", err
+	}
+	quoted := make([]string, len(svc.command))
+	for i, a := range svc.command {
+		quoted[i] = systemdQuote(a)
+	}
+	if svc.schedule != "" {
+		return installSystemdTimer(svc, unitPath, strings.Join(quoted, " "))
+	}
+	unit := strings.Join([]string{
+		"[Unit]",
+		"Description=XMLUI test server for " + strings.ReplaceAll(svc.installDir, "%", "%%"),
+		"After=network.target",
+		"",
+		"[Service]",
+		"ExecStart=" + strings.Join(quoted, " "),
+		"Restart=on-failure",
+		"RestartSec=5",
+		"",
+		"[Install]",
+		"WantedBy=default.target",
+		"",
+	}, "\n")
+	if err := fsys.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return "", err
+	}
+	if err := fsys.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return "", err
+	}
+	err = runServiceTool("systemctl", "--user", "daemon-reload")
+	if err == nil {
+		err = runServiceTool("systemctl", "--user", "enable", "--now", svc.name+".service")
+	}
+	if err != nil {
+		// Without a user systemd (e.g. in a container) the unit is no use.
+		fsys.Remove(unitPath)
+		return "", err
+	}
+	return unitPath, nil
+}
+
+// installSystemdTimer writes a oneshot unit that runs execStart and a timer
+// that starts it on svc's schedule, catching up on runs missed while the
+// machine was off. It returns the timer's file.
+func installSystemdTimer(svc loginService, unitPath, execStart string) (string, error) {
+	timerPath := strings.TrimSuffix(unitPath, ".service") + ".timer"
+	unit := strings.Join([]string{
+		"[Unit]",
+		"Description=XMLUI update check for " + strings.ReplaceAll(svc.installDir, "%", "%%"),
+		"",
+		"[Service]",
+		"Type=oneshot",
+		"ExecStart=" + execStart,
+		"",
+	}, "\n")
+	timer := strings.Join([]string{
+		"[Unit]",
+		"Description=" + svc.schedule + " XMLUI update check",
+		"",
+		"[Timer]",
+		"OnCalendar=" + svc.schedule,
+		"Persistent=true",
+		"",
+		"[Install]",
+		"WantedBy=timers.target",
+		"",
+	}, "\n")
+	if err := fsys.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return "", err
+	}
+	if err := fsys.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return "", err
+	}
+	if err := fsys.WriteFile(timerPath, []byte(timer), 0644); err != nil {
+		return "", err
+	}
+	err := runServiceTool("systemctl", "--user", "daemon-reload")
+	if err == nil {
+		err = runServiceTool("systemctl", "--user", "enable", "--now", svc.name+".timer")
+	}
+	if err != nil {
+		fsys.Remove(unitPath)
+		fsys.Remove(timerPath)
+		return "", err
+	}
+	return timerPath, nil
+}
+
+// uninstallLoginService stops and removes what installLoginService made.
+func uninstallLoginService(name string) (string, error) {
+	if hostOS.goos() == "darwin" {
+		return uninstallLaunchAgent(name)
+	}
+	unitPath, err := systemdUnitPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(unitPath); err != nil {
+		return "", fmt.Errorf("no service %s: %w", name, err)
+	}
+	// Disabling fails harmlessly if the unit was never loaded.
+	timerPath := strings.TrimSuffix(unitPath, ".service") + ".timer"
+	if _, err := os.Stat(timerPath); err == nil {
+		runServiceTool("systemctl", "--user", "disable", "--now", name+".timer")
+		if err := fsys.Remove(timerPath); err != nil {
+			return timerPath, err
+		}
+	}
+	runServiceTool("systemctl", "--user", "disable", "--now", name+".service")
+	if err := fsys.Remove(unitPath); err != nil {
+		return unitPath, err
+	}
+	return unitPath, runServiceTool("systemctl", "--user", "daemon-reload")
+}
+
+func systemdUnitPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// systemdQuote quotes an ExecStart argument, escaping what systemd would
+// otherwise expand.
+func systemdQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(s) + `"`
+}
+
+func installLaunchAgent(svc loginService) (string, error) {
+	plistPath, err := launchAgentPath(svc.name)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	plistString := func(key, value string) {
+		fmt.Fprintf(&b, "\t<key>%s</key>\n\t<string>%s</string>\n", key, xmlEscape(value))
+	}
+	plistString("Label", launchdLabelPrefix+svc.name)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, a := range svc.command {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(a))
+	}
+	b.WriteString("\t</array>\n")
+	logName := "service.log"
+	if svc.schedule != "" {
+		// At 9:00, and on Mondays for weekly; a run missed while asleep
+		// happens on waking.
+		b.WriteString("\t<key>StartCalendarInterval</key>\n\t<dict>\n")
+		if svc.schedule == "weekly" {
+			b.WriteString("\t\t<key>Weekday</key>\n\t\t<integer>1</integer>\n")
+		}
+		b.WriteString("\t\t<key>Hour</key>\n\t\t<integer>9</integer>\n\t\t<key>Minute</key>\n\t\t<integer>0</integer>\n\t</dict>\n")
+		logName = "update-check.log"
+	} else {
+		b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+		b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	}
+	// launchd has no journal; keep the launcher's own output with the state.
+	plistString("StandardOutPath", filepath.Join(svc.stateDir, logName))
+	plistString("StandardErrorPath", filepath.Join(svc.stateDir, logName))
+	b.WriteString("</dict>\n</plist>\n")
+
+	if err := fsys.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return "", err
+	}
+	// Replacing a loaded agent requires unloading it first.
+	runServiceTool("launchctl", "bootout", launchdDomain(), plistPath)
+	if err := fsys.WriteFile(plistPath, b.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return plistPath, runServiceTool("launchctl", "bootstrap", launchdDomain(), plistPath)
+}
+
+func uninstallLaunchAgent(name string) (string, error) {
+	plistPath, err := launchAgentPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(plistPath); err != nil {
+		return "", fmt.Errorf("no service %s: %w", name, err)
+	}
+	runServiceTool("launchctl", "bootout", launchdDomain(), plistPath)
+	return plistPath, fsys.Remove(plistPath)
+}
+
+func launchAgentPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabelPrefix+name+".plist"), nil
+}
+
+// launchdDomain is the logged-in user's GUI domain.
+func launchdDomain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
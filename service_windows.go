@@ -0,0 +1,44 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// installLoginService registers a Scheduled Task that runs at logon, with
+// the user's rights, and starts it now, or one that runs on svc.schedule. It
+// returns the task's name.
+func installLoginService(svc loginService) (string, error) {
+	quoted := make([]string, len(svc.command))
+	for i, a := range svc.command {
+		quoted[i] = `"` + a + `"`
+	}
+	task := `\` + svc.name
+	// The /TR command line is limited to 261 characters.
+	tr := strings.Join(quoted, " ")
+	if len(tr) > 261 {
+		return "", fmt.Errorf("the task command is too long for schtasks (%d characters); install closer to the drive root", len(tr))
+	}
+	if svc.schedule != "" {
+		// At 9:00, and on Mondays for weekly.
+		return "Scheduled Task " + task, runServiceTool("schtasks", "/Create", "/TN", task, "/TR", tr, "/SC", strings.ToUpper(svc.schedule), "/ST", "09:00", "/RL", "LIMITED", "/F")
+	}
+	if err := runServiceTool("schtasks", "/Create", "/TN", task, "/TR", tr, "/SC", "ONLOGON", "/RL", "LIMITED", "/F"); err != nil {
+		return "", err
+	}
+	return "Scheduled Task " + task, runServiceTool("schtasks", "/Run", "/TN", task)
+}
+
+// uninstallLoginService stops and deletes the Scheduled Task.
+func uninstallLoginService(name string) (string, error) {
+	task := `\` + name
+	if err := exec.Command("schtasks", "/Query", "/TN", task).Run(); err != nil {
+		return "", fmt.Errorf("no service %s", name)
+	}
+	// Ending fails harmlessly if the task is not running.
+	runServiceTool("schtasks", "/End", "/TN", task)
+	return "Scheduled Task " + task, runServiceTool("schtasks", "/Delete", "/TN", task, "/F")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// smokeCheck is one step of `smoke`.
+type smokeCheck struct {
+	name string
+	run  func() error
+}
+
+// appAssetRef finds the local files index.html loads.
+var appAssetRef = regexp.MustCompile(`(?i)\b(?:src|href)\s*=\s*["']([^"'#?]+)`)
+
+// runSmoke implements `smoke`: it runs every post-install validation in a
+// row (the layout and file hashes, --version probes, the MCP handshake, the
+// test server's routes and the app's assets) and ends with one pass/fail
+// summary, e.g. to check each machine of a classroom.
+func runSmoke(args []string) int {
+	fs := newFlagSet("smoke")
+	dir := installDirFlag(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "how long the MCP and server checks may each take")
+	query := fs.String("query", "Button", "text to search the component docs for in the MCP check")
+	renderCheck := fs.Bool("render-check", false, "also load the app in headless Chrome, if there is one, and check that it renders")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	mcpDir := filepath.Join(installDir, rcpt.toolsDir())
+	appDir := filepath.Join(installDir, filepath.FromSlash(rcpt.appDir()))
+	mcpBinDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("mcp")))
+	serverBinDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("server")))
+
+	checks := []smokeCheck{
+		{"layout", func() error { return smokeLayout(installDir, mcpDir, appDir, rcpt) }},
+		{"binaries", func() error { return smokeBinaries(installDir, rcpt) }},
+		{"mcp", func() error {
+			if reason := rcpt.component("mcp", "").Unavailable; reason != "" {
+				return errSkipped{"the MCP tools are not installed (" + reason + ")"}
+			}
+			return testMCP(mcpDir, mcpBinDir, nil, *query, *timeout)
+		}},
+		{"server", func() error {
+			if reason := rcpt.component("server", "").Unavailable; reason != "" {
+				return errSkipped{"the test server is not installed (" + reason + ")"}
+			}
+			return testServer(appDir, serverBinDir, 0, nil, *timeout)
+		}},
+		{"app assets", func() error { return smokeAppAssets(appDir) }},
+	}
+	if rcpt.Flat {
+		// The app is the user's own project, not the invoice sample.
+		checks = checks[:3]
+	}
+	if *renderCheck {
+		checks = append(checks, smokeCheck{"render", func() error { return smokeRender(installDir, appDir, *timeout) }})
+	}
+	failed := map[string]error{}
+	skipped := map[string]error{}
+	for i, c := range checks {
+		fmt.Println(console.paint(styleStep, fmt.Sprintf("Check %d/%d: %s", i+1, len(checks), c.name)))
+		if err := c.run(); errors.As(err, new(errSkipped)) {
+			skipped[c.name] = err
+			fmt.Printf("  - %v\n", err)
+		} else if err != nil {
+			failed[c.name] = err
+			fmt.Printf("  %s %v\n", glyphFail, err)
+		}
+	}
+
+	fmt.Println("\nSummary:")
+	for _, c := range checks {
+		if err, ok := failed[c.name]; ok {
+			fmt.Printf("  %s %s: %s\n", glyphFail, c.name, firstLine(err.Error()))
+		} else if err, ok := skipped[c.name]; ok {
+			fmt.Printf("  - %s: %s\n", c.name, err)
+		} else {
+			fmt.Printf("  %s %s\n", glyphOK, c.name)
+		}
+	}
+	ran := len(checks) - len(skipped)
+	if len(failed) > 0 {
+		fmt.Printf("FAIL: %d of %d checks failed in %s\n", len(failed), ran, installDir)
+		return exitFailure
+	}
+	fmt.Printf("PASS: all %d checks passed in %s\n", ran, installDir)
+	return exitOK
+}
+
+// smokeLayout checks that the install's directories and tools are where
+// they belong and that every recorded file is present and intact.
+func smokeLayout(installDir, mcpDir, appDir string, rcpt *receipt) error {
+	var missing []string
+	for _, d := range []string{appDir, mcpDir, filepath.Join(mcpDir, "docs"), filepath.Join(mcpDir, "src")} {
+		if info, err := os.Stat(d); err != nil || !info.IsDir() {
+			missing = append(missing, receiptKey(installDir, d)+"/")
+		}
+	}
+	for _, name := range []string{"xmlui-mcp", "xmlui-mcp-client"} {
+		if rcpt.component("mcp", "").Unavailable != "" {
+			break
+		}
+		binDir := filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("mcp")))
+		if _, err := mcpBinary(binDir, name); err != nil {
+			missing = append(missing, receiptKey(installDir, filepath.Join(binDir, name)))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	problems, checked := checkInstall(installDir, rcpt)
+	for _, p := range problems {
+		fmt.Println("  "+glyphFail, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d files are missing or corrupted (see xmlui-bundler doctor)", len(problems), checked)
+	}
+	fmt.Printf("  %s %d files match the receipt\n", glyphOK, checked)
+	return nil
+}
+
+// smokeBinaries runs the installed tools for this machine with --version.
+func smokeBinaries(installDir string, rcpt *receipt) error {
+	hostBin := ""
+	if rcpt.AllPlatforms {
+		host, ok := hostPlatform()
+		if !ok {
+			return fmt.Errorf("this install has no build for this machine")
+		}
+		hostBin = host.binDir()
+	}
+	probed := 0
+	for _, c := range rcpt.Components {
+		for _, b := range c.Binaries {
+			p := filepath.Join(installDir, filepath.FromSlash(b.Path))
+			if hostBin != "" && filepath.Base(filepath.Dir(p)) != hostBin {
+				continue
+			}
+			v, err := probeBinary(p)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  %s %s: %s\n", glyphOK, path.Base(b.Path), v)
+			probed++
+		}
+	}
+	if probed == 0 {
+		return fmt.Errorf("the receipt records no binaries for this machine")
+	}
+	return nil
+}
+
+// smokeAppAssets checks that the app has its entry points and that every
+// local file index.html refers to exists.
+func smokeAppAssets(appDir string) error {
+	var missing []string
+	for _, name := range []string{"index.html", "Main.xmlui"} {
+		if _, err := os.Stat(filepath.Join(appDir, name)); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(appDir, "index.html"))
+	refs := 0
+	if err == nil {
+		for _, m := range appAssetRef.FindAllStringSubmatch(string(data), -1) {
+			ref := m[1]
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "mailto:") {
+				continue
+			}
+			rel := path.Clean(strings.TrimPrefix(ref, "/"))
+			if rel == "." || strings.HasPrefix(rel, "../") {
+				continue
+			}
+			refs++
+			if _, err := os.Stat(filepath.Join(appDir, filepath.FromSlash(rel))); err != nil {
+				missing = append(missing, rel)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the app is missing %s", strings.Join(missing, ", "))
+	}
+	fmt.Printf("  %s index.html, Main.xmlui and %d referenced files present\n", glyphOK, refs)
+	return nil
+}
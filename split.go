@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+// Components too large for a single release asset may be published as split
+// archives: ASSET.001, ASSET.002 and so on, the pieces of ASSET cut with
+// e.g. `split -d -a 3 --numeric-suffixes=1`, next to ASSET.sha256 for the
+// whole. When ASSET itself is not found, downloadAsset fetches the parts in
+// order and joins them before extraction.
+
+// maxArchiveParts bounds the part numbers the three-digit suffix allows.
+const maxArchiveParts = 999
+
+// downloadAsset downloads url, or its parts if it was split, and returns it
+// with its SHA-256. want is the expected checksum if the lockfile pins one;
+// otherwise a split archive is checked against the published url.sha256.
+func downloadAsset(url, label, want string) ([]byte, string, error) {
+	data, sum, err := downloadWithProgress(url, label)
+	var statusErr *httpStatusError
+	if err == nil || !errors.As(err, &statusErr) || statusErr.code != http.StatusNotFound {
+		return data, sum, err
+	}
+	first := fmt.Sprintf("%s.%03d", url, 1)
+	if status, herr := headStatus(first); herr != nil || status != http.StatusOK {
+		return nil, "", err
+	}
+	fmt.Printf("  %s is published in parts; joining them\n", path.Base(url))
+	if want == "" {
+		if want = publishedChecksum(url); want == "" {
+			return nil, "", fmt.Errorf("%s is split into parts but %s.sha256 is missing, so the joined archive can't be verified", url, path.Base(url))
+		}
+	}
+	// The parts are hashed as one stream as they arrive.
+	var joined bytes.Buffer
+	h := sha256.New()
+	parts := 0
+	for n := 1; n <= maxArchiveParts; n++ {
+		partURL := fmt.Sprintf("%s.%03d", url, n)
+		if n > 1 {
+			status, err := headStatus(partURL)
+			if err != nil {
+				return nil, "", err
+			}
+			if status == http.StatusNotFound {
+				break
+			}
+		}
+		if _, err := streamDownload(partURL, fmt.Sprintf("%s (part %d)", label, n), io.MultiWriter(&joined, h), nil); err != nil {
+			return nil, "", err
+		}
+		parts++
+	}
+	data = joined.Bytes()
+	if sum = hex.EncodeToString(h.Sum(nil)); sum != want {
+		return nil, "", &integrityError{fmt.Sprintf("the %d parts of %s joined do not match checksum %s (got %s)", parts, url, want, sum)}
+	}
+	fmt.Printf("  %s Joined %d parts (%s), checksum verified\n", glyphOK, parts, humanBytes(int64(len(data))))
+	return data, sum, nil
+}
+
+// headStatus asks whether url exists without downloading it.
+func headStatus(url string) (int, error) {
+	req, err := http.NewRequestWithContext(downloadCtx, "HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	applyRequestHeaders(req)
+	resp, err := network.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
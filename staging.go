@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// stagingDirName holds per-run scratch directories. It normally lives inside
+// the install dir so staged trees can be renamed into place without crossing
+// filesystems; --ephemeral puts it under os.TempDir() instead.
+const stagingDirName = ".xmlui-staging"
+
+// stagingPrefix marks directories created by stageDir, so the sweeper never
+// touches anything it didn't make.
+const stagingPrefix = "run-"
+
+var (
+	exitMu   sync.Mutex
+	cleanups []func()
+	// interrupted is set once SIGINT or SIGTERM arrives, and resume to what
+	// handleSignals was told to print after the cleanups.
+	interrupted atomic.Bool
+	resume      string
+)
+
+// atExit registers f to run (in LIFO order) when the process leaves through
+// exit, including after SIGINT/SIGTERM.
+func atExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	cleanups = append(cleanups, f)
+}
+
+// runCleanups runs and clears the registered cleanups.
+func runCleanups() {
+	exitMu.Lock()
+	fs := cleanups
+	cleanups = nil
+	exitMu.Unlock()
+	for i := len(fs) - 1; i >= 0; i-- {
+		fs[i]()
+	}
+}
+
+// exit runs cleanups and terminates; use it instead of os.Exit once staging
+// directories may exist. After a signal the code is exitInterrupted,
+// whatever failure the interruption caused.
+func exit(code int) {
+	if interrupted.Load() {
+		code = exitInterrupted
+	}
+	runCleanups()
+	if interrupted.Load() {
+		exitMu.Lock()
+		fmt.Println(resume)
+		exitMu.Unlock()
+	}
+	endTelemetry(code)
+	os.Exit(code)
+}
+
+// handleSignals makes SIGINT and SIGTERM cancel in-flight downloads and
+// copies, so the main goroutine's work fails and it leaves through exit,
+// rolling back there instead of under the work still in progress. Work
+// that can't be cancelled ends at the next checkInterrupted. resume is
+// printed last to tell the user how to pick up again. A second signal
+// exits at once, leaving the staging dir for the next run to sweep.
+func handleSignals(msg string) {
+	exitMu.Lock()
+	resume = msg
+	exitMu.Unlock()
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		fmt.Printf("\nReceived %v while %s, stopping...\n", sig, status.current())
+		interrupted.Store(true)
+		cancelDownloads()
+		sig = <-ch
+		fmt.Printf("\nReceived %v again, exiting without rolling back\n", sig)
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// checkInterrupted leaves through exit if a signal has arrived. The install
+// calls it between steps and before committing.
+func checkInterrupted() {
+	if interrupted.Load() {
+		exit(exitInterrupted)
+	}
+}
+
+// stageDir creates a fresh, uniquely named scratch directory for this run
+// under parent and arranges for its removal on exit. Concurrent runs each get
+// their own directory.
+func stageDir(parent string) (string, error) {
+	root := filepath.Join(parent, stagingDirName)
+	if err := fsys.MkdirAll(root, dirMode); err != nil {
+		return "", err
+	}
+	dir, err := fsys.MkdirTemp(root, stagingPrefix)
+	if err != nil {
+		return "", err
+	}
+	atExit(func() {
+		fsys.RemoveAll(dir)
+		// Only succeeds when no other run is still using the root.
+		fsys.Remove(root)
+	})
+	return dir, nil
+}
+
+// staleStagingDirs lists staging directories under parent older than
+// maxAge. Younger ones may belong to a run in progress.
+func staleStagingDirs(parent string, maxAge time.Duration) []string {
+	root := filepath.Join(parent, stagingDirName)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var stale []string
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), stagingPrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		stale = append(stale, filepath.Join(root, e.Name()))
+	}
+	return stale
+}
+
+// sweepStaleStaging removes staging directories left behind by crashed runs
+// that are older than maxAge.
+func sweepStaleStaging(parent string, maxAge time.Duration) {
+	for _, path := range staleStagingDirs(parent, maxAge) {
+		if err := fsys.RemoveAll(path); err != nil {
+			fmt.Printf("%s Could not remove stale staging directory %s: %v\n", labelWarning, path, err)
+			continue
+		}
+		fmt.Printf("  Removed stale staging directory %s\n", path)
+	}
+	fsys.Remove(filepath.Join(parent, stagingDirName))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStallTimeout is how long a download may go without receiving a
+// byte before it is given up on. CDN connections that stop sending without
+// closing never end by themselves.
+const defaultStallTimeout = 30 * time.Second
+
+// stallError is a transfer that received nothing for the stall timeout. It
+// is a net.Error, so it is reported with the network failures.
+type stallError struct {
+	url      string
+	received int64
+	after    time.Duration
+}
+
+func (e *stallError) Error() string {
+	return fmt.Sprintf("download stalled: nothing received for %v after %s from %s", e.after, humanBytes(e.received), e.url)
+}
+
+func (e *stallError) Timeout() bool   { return true }
+func (e *stallError) Temporary() bool { return true }
+
+// errNoResume is a stalled download the server can't continue from where it
+// stopped.
+var errNoResume = errors.New("the server can't resume it")
+
+// restartStalled runs download, and runs it again from the start while it
+// stalls where the server can't resume it, up to the retry policy's
+// attempts.
+func restartStalled(download func() error) error {
+	attempts := max(network.retry.attempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := download()
+		if !errors.Is(err, errNoResume) || attempt == attempts {
+			return err
+		}
+		fmt.Printf("  %v; starting over (attempt %d of %d)\n", err, attempt+1, attempts)
+	}
+}
+
+// stallWatch cancels a request when neither its response nor any of its
+// body arrives for timeout; watched readers push the deadline back.
+type stallWatch struct {
+	timer   *time.Timer
+	timeout time.Duration
+	stalled atomic.Bool
+}
+
+// watchStalls returns a context for a request that is canceled if it
+// stalls, and the function to release it. A timeout of 0 never stalls.
+func watchStalls(parent context.Context, timeout time.Duration) (context.Context, *stallWatch, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	w := &stallWatch{timeout: timeout}
+	if timeout <= 0 {
+		return ctx, w, cancel
+	}
+	w.timer = time.AfterFunc(timeout, func() {
+		w.stalled.Store(true)
+		cancel()
+	})
+	return ctx, w, func() {
+		w.timer.Stop()
+		cancel()
+	}
+}
+
+// reader passes r through, pushing the deadline back whenever bytes arrive.
+func (w *stallWatch) reader(r io.Reader) io.Reader {
+	if w.timer == nil {
+		return r
+	}
+	return stallReader{r, w}
+}
+
+// err turns the error of a stalled request into a stallError.
+func (w *stallWatch) err(err error, url string, received int64) error {
+	if err != nil && w.stalled.Load() && downloadCtx.Err() == nil {
+		return &stallError{url: url, received: received, after: w.timeout}
+	}
+	return err
+}
+
+type stallReader struct {
+	r io.Reader
+	w *stallWatch
+}
+
+func (r stallReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 && !r.w.stalled.Load() {
+		r.w.timer.Reset(r.w.timeout)
+	}
+	return n, err
+}
+
+// resumeHeader is the request header that continues a download of url,
+// first answered by first, from offset, or nil if the server can't: it
+// has to accept byte ranges and give a validator, so a file that changed
+// in the meantime is sent whole instead.
+func resumeHeader(first *http.Response, header http.Header, offset int64) http.Header {
+	if first.Header.Get("Accept-Ranges") != "bytes" {
+		return nil
+	}
+	validator := first.Header.Get("ETag")
+	if validator == "" {
+		validator = first.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return nil
+	}
+	h := header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Del("If-None-Match")
+	h.Del("If-Modified-Since")
+	h.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	h.Set("If-Range", validator)
+	return h
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Bookkeeping for an install (receipt, pristine seed databases, server log
+// and PID file) lives in a per-user state directory rather than in the
+// install dir, so the installed tree holds only the app and tools. Each
+// install gets its own subdirectory of installsDirName, named after its path.
+const (
+	installsDirName  = "installs"
+	installIndexFile = "installs.json"
+	stateReceiptFile = "receipt.json"
+	stateSeedDir     = "seed"
+	serverLogFile    = "server.log"
+	serverPIDFile    = "server.pid"
+)
+
+// Names the launcher used to write into the install dir itself. They are
+// still read, and moved into the state dir, so old installs keep working.
+const (
+	legacyReceiptFile   = "xmlui-receipt.json"
+	legacySeedDirName   = ".xmlui-seed"
+	legacyServerLogFile = "xmlui-test-server.log"
+)
+
+// stateDir is the per-user state directory: $XDG_STATE_HOME/xmlui-bundler,
+// ~/.local/state/xmlui-bundler, or %LOCALAPPDATA%\xmlui-bundler\state on
+// Windows (see launcherDir for the old name's).
+func stateDir() (string, error) {
+	if base := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(base) {
+		return launcherDir(base), nil
+	}
+	if hostOS.goos() == "windows" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(launcherDir(base), "state"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return launcherDir(filepath.Join(home, ".local", "state")), nil
+}
+
+// installKey names an install's state subdirectory: the install dir's base
+// name, readable in a listing, plus a hash of its absolute path.
+func installKey(installDir string) (string, error) {
+	abs, err := filepath.Abs(installDir)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.Clean(abs)
+	if hostOS.foldsCase() {
+		abs = strings.ToLower(abs)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(abs))
+	return strings.Trim(name, ".") + "-" + hex.EncodeToString(sum[:6]), nil
+}
+
+// installStateDir returns the state directory of the install in installDir,
+// moving any bookkeeping an older launcher left in the install dir into it.
+// The directory itself is created on first write.
+func installStateDir(installDir string) (string, error) {
+	base, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	key, err := installKey(installDir)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, installsDirName, key)
+	migrateLegacyState(installDir, dir)
+	return dir, nil
+}
+
+// installStatePath returns name inside installDir's state directory.
+func installStatePath(installDir, name string) (string, error) {
+	dir, err := installStateDir(installDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// migrateLegacyState moves the files older launchers kept in installDir into
+// dir. Anything that can't be moved is left for the next run.
+func migrateLegacyState(installDir, dir string) {
+	moves := map[string]string{
+		legacyReceiptFile:   stateReceiptFile,
+		legacySeedDirName:   stateSeedDir,
+		legacyServerLogFile: serverLogFile,
+	}
+	for old, name := range moves {
+		src := filepath.Join(installDir, old)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(dir, name)
+		if _, err := os.Lstat(dst); err == nil {
+			// Already migrated; the state dir copy is newer.
+			fsys.RemoveAll(src)
+			continue
+		}
+		if err := fsys.MkdirAll(dir, dirMode); err != nil {
+			return
+		}
+		movePath(src, dst)
+	}
+}
+
+// installIndex maps install state keys to install dirs, so stale state can
+// be found once an install dir is gone.
+type installIndex map[string]string
+
+func readInstallIndex() (installIndex, error) {
+	base, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	index := installIndex{}
+	data, err := os.ReadFile(filepath.Join(base, installIndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (index installIndex) write() error {
+	base, err := stateDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(filepath.Join(base, installIndexFile), append(data, '\n'), fileMode)
+}
+
+// recordInstall adds installDir to the index.
+func recordInstall(installDir string) error {
+	index, err := readInstallIndex()
+	if err != nil {
+		return err
+	}
+	key, err := installKey(installDir)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(installDir)
+	if err != nil {
+		return err
+	}
+	if index[key] == abs {
+		return nil
+	}
+	index[key] = abs
+	return index.write()
+}
+
+// orphanedStateDirs returns the state directories of indexed installs whose
+// install dir no longer exists, sorted, and drops them from index.
+func orphanedStateDirs(index installIndex) []string {
+	base, err := stateDir()
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for key, installDir := range index {
+		if _, err := os.Stat(installDir); err == nil {
+			continue
+		}
+		delete(index, key)
+		dir := filepath.Join(base, installsDirName, key)
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// handOffState copies the receipt and seed databases of the install in
+// installDir into it, under the names older launchers used, for an install
+// provisioned for another machine: the launcher there moves them into its
+// own state dir on first use (see migrateLegacyState), so update, smoke and
+// reset-data know the install. A launcher command run here on the install
+// first takes them back.
+func handOffState(installDir string) error {
+	dir, err := installStateDir(installDir)
+	if err != nil {
+		return err
+	}
+	for name, legacy := range map[string]string{stateReceiptFile: legacyReceiptFile, stateSeedDir: legacySeedDirName} {
+		src, dst := filepath.Join(dir, name), filepath.Join(installDir, legacy)
+		info, err := os.Stat(src)
+		if err != nil {
+			continue
+		}
+		if err := journal.preserve(dst); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = copyTree(src, dst)
+		} else {
+			err = copyFile(src, dst, fileMode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// staticServerNote labels the degraded mode wherever it shows: without the
+// test server there is no API, so the UI loads but its data calls fail.
+const staticServerNote = "static files only, without the test server's API"
+
+// hasTestServer reports whether binDir has a test server to run: the binary
+// or, after an --all-platforms install, its dispatch script.
+func hasTestServer(binDir string) bool {
+	for _, name := range []string{"xmlui-test-server", "xmlui-test-server.exe", "xmlui-test-server.cmd"} {
+		if _, err := os.Stat(filepath.Join(binDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// staticServerCommand runs this launcher's `server static` for appDir, the
+// stand-in serverCommand uses when there is no test server.
+func staticServerCommand(appDir string) *exec.Cmd {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	return exec.Command(self, "server", "static", appDir)
+}
+
+// offerStaticServer handles a test server for host that can't be installed
+// (err, while doing what): with --static-fallback, or if the user agrees,
+// the install goes on without it and serve falls back to `server static`.
+// Otherwise the install fails. It returns the reason to record.
+func offerStaticServer(opts installOptions, host platform, what string, err error) string {
+	if !opts.staticFallback {
+		answer := ""
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Printf("%s: %v\n", what, err)
+			fmt.Println("Continue without it, serving the app as static files (no API)? [y/N]")
+			answer, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("  --static-fallback installs without the test server instead")
+			fatal(what, err)
+		}
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return fmt.Sprintf("no artifact for %s/%s", host.OS, host.Arch)
+	}
+	return firstLine(err.Error())
+}
+
+// runServerStatic implements `server static`: a plain file server for the
+// app in APPDIR on $PORT (or --port), for installs without the test server.
+func runServerStatic(args []string) int {
+	fs := newFlagSet("server static")
+	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	if port == 0 {
+		port = 8080
+	}
+	fs.IntVar(&port, "port", port, "port to serve on (default: $PORT or 8080)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: xmlui-bundler server static [--port N] APPDIR")
+		return exitUsage
+	}
+	appDir := fs.Arg(0)
+	if info, err := os.Stat(appDir); err != nil || !info.IsDir() {
+		fmt.Printf("%s is not an app directory\n", appDir)
+		return exitFailure
+	}
+	files := http.FileServer(http.Dir(appDir))
+	srv := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", port),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-XMLUI-Server", "static")
+			w.Header().Set("Cache-Control", "no-cache")
+			files.ServeHTTP(w, r)
+		}),
+	}
+	fmt.Printf("Serving %s on http://localhost:%d/ (%s)\n", appDir, port, staticServerNote)
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Println("Static server stopped:", err)
+		return exitFailure
+	}
+	return exitOK
+}
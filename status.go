@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// componentStatus is the per-component progress served by --status-addr.
+type componentStatus struct {
+	Name            string   `json:"name"`
+	State           string   `json:"state"`
+	BytesDownloaded int64    `json:"bytesDownloaded"`
+	BytesTotal      int64    `json:"bytesTotal,omitempty"`
+	FilesExtracted  int      `json:"filesExtracted"`
+	FilesTotal      int      `json:"filesTotal,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+// installStatus tracks install progress. The pipeline runs one component at a
+// time, so progress reported by the download and extract helpers is
+// attributed to whichever component began most recently.
+type installStatus struct {
+	mu         sync.Mutex
+	StartedAt  time.Time          `json:"startedAt"`
+	State      string             `json:"state"`
+	Components []*componentStatus `json:"components"`
+	active     *componentStatus
+}
+
+// installSteps is how many steps an install announces with step.
+const installSteps = 5
+
+// status is always non-nil; without --status-addr nobody reads it.
+var status = &installStatus{StartedAt: time.Now().UTC(), State: "running"}
+
+// begin starts tracking a component in the downloading state.
+func (s *installStatus) begin(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &componentStatus{Name: name, State: "downloading"}
+	s.Components = append(s.Components, c)
+	s.active = c
+	events.emit(event{Event: "component-start", Component: name, State: c.State})
+}
+
+// setState updates the active component's state.
+func (s *installStatus) setState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		s.active.State = state
+		c := s.active
+		events.emit(event{Event: "state", Component: c.Name, State: state, Bytes: c.BytesDownloaded, Total: c.BytesTotal, Files: c.FilesExtracted})
+	}
+}
+
+func (s *installStatus) setTotal(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil && n > 0 {
+		s.active.BytesTotal = n
+	}
+}
+
+func (s *installStatus) addBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		s.active.BytesDownloaded += n
+		events.progress(s.active.Name, s.active.BytesDownloaded, s.active.BytesTotal)
+	}
+}
+
+func (s *installStatus) addFile() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		s.active.FilesExtracted++
+	}
+}
+
+// setFilesTotal records how many entries the archive being extracted has.
+func (s *installStatus) setFilesTotal(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		s.active.FilesTotal = n
+	}
+}
+
+// fail records err, reported as msg, against the active component and marks
+// the install failed.
+func (s *installStatus) fail(msg string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	component := ""
+	if s.active != nil {
+		s.active.State = "failed"
+		s.active.Errors = append(s.active.Errors, err.Error())
+		component = s.active.Name
+	}
+	s.State = "failed"
+	events.emit(event{Event: "error", Component: component, Message: msg, Error: err.Error()})
+}
+
+// current describes what the install is doing, e.g. "extracting mcp".
+func (s *installStatus) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		return "starting"
+	}
+	return s.active.State + " " + s.active.Name
+}
+
+// finish marks the install as complete.
+func (s *installStatus) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil && s.active.State != "failed" {
+		s.active.State = "done"
+	}
+	s.State = "done"
+	events.emit(event{Event: "finish", State: s.State})
+}
+
+// step announces step n of the install and records it in the events file,
+// unless the install has been interrupted.
+func (s *installStatus) step(n int, title string) {
+	checkInterrupted()
+	fmt.Println(console.paint(styleStep, fmt.Sprintf("Step %d/%d: %s", n, installSteps, title)))
+	events.emit(event{Event: "step", Step: n, Message: title})
+}
+
+// state is the install's overall state, e.g. "running" or "failed".
+func (s *installStatus) state() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.State
+}
+
+func (s *installStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// serveStatus starts the JSON status endpoint on addr (use port 0 to pick a
+// free one) and returns the URL it is reachable at.
+func serveStatus(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", status)
+	mux.Handle("/", status)
+	go http.Serve(ln, mux)
+	return fmt.Sprintf("http://%s/status", ln.Addr()), nil
+}
+
+// countingReader reports bytes read to the status tracker.
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	status.addBytes(int64(n))
+	return n, err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Artifacts can be fetched from storage other than web servers: s3://bucket/key
+// (Amazon S3 or anything speaking its API), gs://bucket/object (Google Cloud
+// Storage) and file:///path (a local or mounted directory). storageTransport
+// turns those URLs into requests to the store, as the one transport every
+// request goes through, so downloads, checksums, split archives, resumes and
+// conditional requests work the same for all of them. The config file's
+// "artifacts" points the release downloads at a store (see
+// applyArtifactStores); --app-source and lockfile entries may use the
+// schemes directly.
+
+// storageTransport carries requests for s3:, gs: and file: URLs itself, and
+// the rest with base.
+type storageTransport struct {
+	base http.RoundTripper
+}
+
+func (t storageTransport) transport() http.RoundTripper {
+	if t.base == nil {
+		return http.DefaultTransport
+	}
+	return t.base
+}
+
+func (t storageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Scheme {
+	case "file":
+		return fileResponse(req)
+	case "s3":
+		return s3RoundTrip(t.transport(), req)
+	case "gs":
+		return gcsRoundTrip(t.transport(), req)
+	}
+	return t.transport().RoundTrip(req)
+}
+
+// storageSchemes are the URL schemes artifacts can be fetched with.
+var storageSchemes = []string{"https", "http", "s3", "gs", "file"}
+
+// checkStorageURL rejects a URL no transport can fetch.
+func checkStorageURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if !containsString(storageSchemes, u.Scheme) {
+		return fmt.Errorf("%q: want a URL with one of the schemes %s", raw, strings.Join(storageSchemes, ", "))
+	}
+	if u.Scheme != "file" && u.Host == "" {
+		return fmt.Errorf("%q has no host or bucket", raw)
+	}
+	return nil
+}
+
+// storedRequest is req sent to target instead, the store's own URL for it.
+func storedRequest(req *http.Request, target *url.URL) *http.Request {
+	r := req.Clone(req.Context())
+	r.URL = target
+	r.Host = target.Host
+	return r
+}
+
+// storedResponse makes resp, the store's answer to a request, the answer to
+// req, the s3: or gs: request it was made for.
+func storedResponse(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// fileResponse answers a GET or HEAD of a file: URL like a web server does:
+// 404 for a missing file, and byte ranges from an offset for resumes.
+func fileResponse(req *http.Request) (*http.Response, error) {
+	path := localPathOf(req.URL.String())
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return statusResponse(req, http.StatusNotFound), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		return statusResponse(req, http.StatusNotFound), nil
+	}
+	resp := statusResponse(req, http.StatusOK)
+	modified := info.ModTime().UTC().Format(http.TimeFormat)
+	resp.Header.Set("Last-Modified", modified)
+	resp.Header.Set("Accept-Ranges", "bytes")
+	resp.Header.Set("Content-Type", "application/octet-stream")
+	size := info.Size()
+	var offset int64
+	if r := req.Header.Get("Range"); r != "" && (req.Header.Get("If-Range") == "" || req.Header.Get("If-Range") == modified) {
+		if n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r, "bytes="), "-"), 10, 64); err == nil && n >= 0 && n < size {
+			offset = n
+			resp.StatusCode, resp.Status = http.StatusPartialContent, "206 Partial Content"
+			resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", n, size-1, size))
+		}
+	}
+	resp.ContentLength = size - offset
+	resp.Header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	if req.Method == http.MethodHead {
+		f.Close()
+		return resp, nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	resp.Body = f
+	return resp, nil
+}
+
+// statusResponse is an empty response to req with code.
+func statusResponse(req *http.Request, code int) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Date": {time.Now().UTC().Format(http.TimeFormat)}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// applyArtifactStores points the components the config file's "artifacts"
+// names at the store hosting them: the MCP tools and test server at a
+// directory holding their release assets, named as on GitHub, and the XMLUI
+// components at a snapshot archive.
+func applyArtifactStores() error {
+	cfg, path, err := readConfig()
+	if err != nil {
+		return err
+	}
+	components := make([]string, 0, len(cfg.Artifacts))
+	for c := range cfg.Artifacts {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+	for _, c := range components {
+		u := cfg.Artifacts[c]
+		if err := checkStorageURL(u); err != nil {
+			return fmt.Errorf("%s: artifacts.%s: %w", path, c, err)
+		}
+		switch c {
+		case "components":
+			xmluiComponentsURL = u
+		case "mcp", "server":
+			a := releaseAssets[c]
+			a.BaseURL = strings.TrimSuffix(u, "/") + "/"
+			releaseAssets[c] = a
+		default:
+			return fmt.Errorf("%s: artifacts.%s: want mcp, server or components", path, c)
+		}
+		fmt.Printf("  %s from %s\n", c, u)
+	}
+	return nil
+}
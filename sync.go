@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// syncStats counts what syncTree did to a destination tree. Kept lists the
+// receipt keys of the files it left alone because they had been changed
+// since they were installed, or weren't installed by us.
+type syncStats struct {
+	Added, Updated, Removed, Unchanged int
+	Kept                               []string
+}
+
+func (s syncStats) String() string {
+	out := fmt.Sprintf("updated %d files, added %d, removed %d (%d unchanged)", s.Updated, s.Added, s.Removed, s.Unchanged)
+	if len(s.Kept) > 0 {
+		out += fmt.Sprintf("; kept %d files changed locally (%s), new versions beside them as <file>%s", len(s.Kept), strings.Join(s.Kept, ", "), syncNewSuffix)
+	}
+	return out
+}
+
+func (s *syncStats) add(o syncStats) {
+	s.Added += o.Added
+	s.Updated += o.Updated
+	s.Removed += o.Removed
+	s.Unchanged += o.Unchanged
+	s.Kept = append(s.Kept, o.Kept...)
+}
+
+// syncNewSuffix names the new version of a file syncTree kept the local
+// changes of, for the user to merge.
+const syncNewSuffix = ".new"
+
+// hashFile returns the hex SHA-256 of a file's contents, or for a symbolic
+// link of where it points, so a link extracted from an archive hashes the
+// same whether or not its target exists.
+func hashFile(path string) (string, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256([]byte("symlink:" + filepath.ToSlash(target)))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// receiptKey is how receipts name a file: slash-separated and relative to the
+// install dir.
+func receiptKey(installDir, path string) string {
+	rel, err := filepath.Rel(installDir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// hashTree returns receipt keys and hashes for every file under dir.
+func hashTree(dir, installDir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		h, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files[receiptKey(installDir, path)] = h
+		return nil
+	})
+	return files, err
+}
+
+// syncTree makes dst match the staged tree src, writing only files whose
+// hash differs from old (the receipt's previous hashes) and deleting files
+// recorded in old under dst that src no longer has. Staged files are moved,
+// not copied. What is on disk is hashed first: a file that no longer matches
+// old, or that old doesn't record, is the user's, so it is kept and the new
+// version written beside it with syncNewSuffix, and a changed file src no
+// longer has is kept too; both are listed in the stats' Kept. keep, if
+// non-nil, limits which src-relative paths take part. It returns the new
+// hashes for dst.
+func syncTree(src, dst, installDir string, old map[string]string, keep func(rel string) bool) (map[string]string, syncStats, error) {
+	var st syncStats
+	files := map[string]string{}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if keep != nil && !keep(filepath.ToSlash(rel)) {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		key := receiptKey(installDir, target)
+		h, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files[key] = h
+
+		prev, known := old[key]
+		_, statErr := os.Lstat(target)
+		exists, conflict := statErr == nil, false
+		// Empty, so never a match, for what can't be hashed, like a dir.
+		cur, _ := hashFile(target)
+		switch {
+		case exists && cur == h, exists && known && prev == h:
+			// Current, or unchanged upstream with any local changes kept.
+			st.Unchanged++
+			return nil
+		case exists && (!known || cur != prev):
+			st.Kept = append(st.Kept, key)
+			target += syncNewSuffix
+			_, err := os.Lstat(target)
+			exists, conflict = err == nil, true
+		}
+		if err := fsys.MkdirAll(filepath.Dir(target), dirMode); err != nil {
+			return err
+		}
+		// Moving the old file aside also matters on Windows, where rename
+		// does not replace existing files.
+		if exists || journal != nil {
+			if err := journal.preserve(target); err != nil {
+				return err
+			}
+		}
+		if err := movePath(path, target); err != nil {
+			return err
+		}
+		switch {
+		case conflict:
+		case exists:
+			st.Updated++
+		default:
+			st.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, st, err
+	}
+
+	prefix := receiptKey(installDir, dst) + "/"
+	var gone []string
+	for key := range old {
+		if strings.HasPrefix(key, prefix) {
+			if _, ok := files[key]; !ok {
+				gone = append(gone, key)
+			}
+		}
+	}
+	sort.Strings(gone)
+	for _, key := range gone {
+		path := filepath.Join(installDir, filepath.FromSlash(key))
+		cur, err := hashFile(path)
+		if err != nil {
+			// Already gone.
+			continue
+		}
+		if cur != old[key] {
+			st.Kept = append(st.Kept, key)
+			continue
+		}
+		if err := journal.preserve(path); err != nil {
+			return nil, st, err
+		}
+		st.Removed++
+	}
+	return files, st, nil
+}
+
+// mergeHashes copies src into dst, allocating dst if needed.
+func mergeHashes(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// recommendedInstallName is the directory under the home directory that
+// installDirGuard suggests instead of a synced or transient folder.
+const recommendedInstallName = "xmlui"
+
+// windowsPathBudget is how long an install dir can be on Windows before the
+// deepest files of the XMLUI source risk passing MAX_PATH (260 characters).
+const windowsPathBudget = 120
+
+// syncedFolder returns a description of the synced or transient folder dir
+// is in (Downloads, Desktop, OneDrive, Dropbox or iCloud Drive), or "".
+func syncedFolder(dir string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	type root struct{ path, name string }
+	roots := []root{
+		{filepath.Join(home, "Downloads"), "Downloads"},
+		{filepath.Join(home, "Desktop"), "Desktop"},
+		{filepath.Join(home, "Dropbox"), "Dropbox"},
+		{filepath.Join(home, "Library", "Mobile Documents", "com~apple~CloudDocs"), "iCloud Drive"},
+		{filepath.Join(home, "iCloudDrive"), "iCloud Drive"},
+		// macOS File Provider location of OneDrive, Dropbox, Google Drive...
+		{filepath.Join(home, "Library", "CloudStorage"), "a cloud storage folder"},
+	}
+	// OneDrive sets these on Windows, and Known Folder Move can put Desktop
+	// and Documents under them.
+	for _, env := range []string{"OneDrive", "OneDriveConsumer", "OneDriveCommercial"} {
+		if dir := os.Getenv(env); dir != "" {
+			roots = append(roots, root{dir, "OneDrive"})
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(home, "OneDrive*"))
+	for _, m := range matches {
+		roots = append(roots, root{m, "OneDrive"})
+	}
+	// OneDrive first, so a Desktop redirected there is reported as OneDrive.
+	for i := len(roots) - 1; i >= 0; i-- {
+		if hasPathPrefix(dir, roots[i].path) {
+			return roots[i].name
+		}
+	}
+	return ""
+}
+
+// installDirGuard warns when a new install would go in a synced or
+// transient folder, where sync clients lock files mid-update and (on
+// Windows) long paths break, and offers to install in ~/xmlui instead. It
+// returns the directory to install in.
+func installDirGuard(opts installOptions, installDir string) string {
+	folder := syncedFolder(installDir)
+	if folder == "" {
+		return installDir
+	}
+	warn("%s is in %s", installDir, folder)
+	if folder == "Downloads" || folder == "Desktop" {
+		fmt.Println("  Files there are easily cleaned out by mistake, and the folder may be synced to the cloud")
+	}
+	fmt.Println("  Sync clients lock files while uploading them, which can make installs and updates fail part way")
+	if hostOS.goos() == "windows" && len(installDir) > windowsPathBudget {
+		fmt.Printf("  The path is %d characters long; files deep in the XMLUI source may pass Windows' 260-character limit\n", len(installDir))
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || opts.previous != nil {
+		// An update stays where the install is.
+		return installDir
+	}
+	recommended := filepath.Join(home, recommendedInstallName)
+	answer := ""
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("Install in %s instead? [y/N]\n", recommended)
+		answer, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		fmt.Printf("  --dir %s installs in a recommended location instead\n", recommended)
+		return installDir
+	}
+	fmt.Printf("Installing in %s\n", recommended)
+	return recommended
+}
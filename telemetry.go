@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Telemetry is strictly opt-in: nothing is sent unless "telemetry" is "on"
+// in the config file (which `telemetry on` sets after showing what it
+// means) or a run is given --telemetry on. Each report is one telemetryReport
+// and nothing else: no install paths, user or machine names, IDs or
+// timestamps, so reports can't be told apart or linked to each other.
+// DO_NOT_TRACK=1 turns it off whatever the settings.
+
+// telemetryEndpoint is where reports go, set at build time with
+// -ldflags "-X main.telemetryEndpoint=https://..." or by "telemetryURL" in
+// the config file. A build without one never sends anything.
+var telemetryEndpoint = ""
+
+const (
+	telemetryOn  = "on"
+	telemetryOff = "off"
+	// telemetryLog keeps, in the state dir, every report sent, for
+	// `telemetry status` to show.
+	telemetryLog     = "telemetry.ndjson"
+	telemetryShown   = 10
+	telemetryTimeout = 3 * time.Second
+)
+
+// telemetryReport is everything a report says.
+type telemetryReport struct {
+	LauncherVersion string `json:"launcherVersion"`
+	OS              string `json:"os"`
+	Arch            string `json:"arch"`
+	Command         string `json:"command"`
+	Outcome         string `json:"outcome"`
+	ExitCode        int    `json:"exitCode"`
+}
+
+// telemetryRun is the report to send when this run ends, if any.
+var telemetryRun struct {
+	command string
+	sent    bool
+}
+
+// telemetryFlag is --telemetry for install and update: on or off for this
+// run, overriding the config file.
+type telemetryFlag struct{ p *string }
+
+func (f telemetryFlag) String() string {
+	if f.p == nil || *f.p == "" {
+		return telemetryOff
+	}
+	return *f.p
+}
+
+func (f telemetryFlag) Set(s string) error {
+	switch s {
+	case telemetryOn, telemetryOff:
+		*f.p = s
+		return nil
+	}
+	return fmt.Errorf("want on or off")
+}
+
+// telemetrySetting works out whether reports are sent, given the
+// --telemetry of this run ("" if not given), and says why.
+func telemetrySetting(flagValue string) (on bool, why string) {
+	if os.Getenv("DO_NOT_TRACK") == "1" {
+		return false, "DO_NOT_TRACK=1 is set"
+	}
+	if flagValue != "" {
+		return flagValue == telemetryOn, "--telemetry " + flagValue
+	}
+	cfg, path, err := readConfig()
+	if err != nil || cfg.Telemetry == "" {
+		return false, "the default"
+	}
+	return cfg.Telemetry == telemetryOn, fmt.Sprintf("\"telemetry\": %q in %s", cfg.Telemetry, path)
+}
+
+// telemetryURL is where reports go, or "" if nowhere.
+func telemetryURL() string {
+	if cfg, _, err := readConfig(); err == nil && cfg.TelemetryURL != "" {
+		return cfg.TelemetryURL
+	}
+	return telemetryEndpoint
+}
+
+// beginTelemetry arranges for one report on how command ends, if telemetry
+// is on for this run.
+func beginTelemetry(command, flagValue string) {
+	if on, _ := telemetrySetting(flagValue); on && telemetryURL() != "" {
+		telemetryRun.command = command
+	}
+}
+
+// endTelemetry sends the report begun by beginTelemetry, once. A report
+// that can't be sent quickly is dropped; it never holds up or fails a run.
+func endTelemetry(code int) {
+	if telemetryRun.command == "" || telemetryRun.sent {
+		return
+	}
+	telemetryRun.sent = true
+	r := telemetryReport{
+		LauncherVersion: version,
+		OS:              hostOS.goos(),
+		Arch:            runtime.GOARCH,
+		Command:         telemetryRun.command,
+		Outcome:         "success",
+		ExitCode:        code,
+	}
+	if code != 0 {
+		r.Outcome = "failure"
+	}
+	body, _ := json.Marshal(r)
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", telemetryURL(), bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := network.httpClient().Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return
+	}
+	if dir, err := stateDir(); err == nil && fsys.MkdirAll(dir, dirMode) == nil {
+		if f, err := fsys.OpenFile(filepath.Join(dir, telemetryLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, fileMode); err == nil {
+			line, _ := json.Marshal(struct {
+				SentAt time.Time `json:"sentAt"`
+				telemetryReport
+			}{time.Now().UTC(), r})
+			f.Write(append(line, '\n'))
+			f.Close()
+		}
+	}
+	fmt.Println("  Sent an anonymous usage report; `xmlui-bundler telemetry status` shows what it said")
+}
+
+// runTelemetry implements `telemetry status|on|off`.
+func runTelemetry(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: xmlui-bundler telemetry status|on|off")
+		return exitUsage
+	}
+	switch args[0] {
+	case "status":
+		return runTelemetryStatus()
+	case telemetryOn, telemetryOff:
+		return runTelemetrySet(args[0], args[1:])
+	default:
+		fmt.Printf("Unknown telemetry command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// telemetryDisclosure says what turning telemetry on means.
+func telemetryDisclosure() {
+	fmt.Println("Usage reports help the maintainers see which platforms the launcher runs on")
+	fmt.Println("and how often installs fail. When an install or update finishes, one report is")
+	fmt.Println("sent with exactly these fields (the values are this machine's):")
+	example, _ := json.MarshalIndent(telemetryReport{
+		LauncherVersion: version, OS: hostOS.goos(), Arch: runtime.GOARCH,
+		Command: "install", Outcome: "success", ExitCode: 0,
+	}, "  ", "  ")
+	fmt.Println("  " + string(example))
+	fmt.Println("No paths, names, IDs or timestamps are sent. Every report sent is listed by")
+	fmt.Println("`xmlui-bundler telemetry status`; `xmlui-bundler telemetry off` stops them.")
+}
+
+func runTelemetryStatus() int {
+	on, why := telemetrySetting("")
+	state := telemetryOff
+	if on {
+		state = telemetryOn
+	}
+	fmt.Printf("Telemetry is %s (%s)\n", state, why)
+	if u := telemetryURL(); u != "" {
+		fmt.Println("  Reports go to", u)
+	} else {
+		fmt.Println("  This build has no report endpoint, so nothing is sent even when on")
+	}
+	fmt.Println()
+	telemetryDisclosure()
+
+	dir, err := stateDir()
+	if err != nil {
+		return exitOK
+	}
+	data, err := os.ReadFile(filepath.Join(dir, telemetryLog))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if err != nil || lines[0] == "" {
+		fmt.Println("\nNo reports have been sent from this machine")
+		return exitOK
+	}
+	fmt.Printf("\n%d report(s) sent from this machine", len(lines))
+	if len(lines) > telemetryShown {
+		fmt.Printf(", the last %d", telemetryShown)
+		lines = lines[len(lines)-telemetryShown:]
+	}
+	fmt.Println(":")
+	for _, l := range lines {
+		fmt.Println("  " + l)
+	}
+	return exitOK
+}
+
+// runTelemetrySet implements `telemetry on` and `telemetry off`, which set
+// "telemetry" in the config file. Turning it on shows what is sent first
+// and asks, or needs --yes without a terminal.
+func runTelemetrySet(value string, args []string) int {
+	fs := newFlagSet("telemetry " + value)
+	yes := fs.Bool("yes", false, "turn telemetry on without asking, having read \"xmlui-bundler telemetry status\"")
+	fs.Parse(args)
+
+	if value == telemetryOn && !*yes {
+		telemetryDisclosure()
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("\nRun `xmlui-bundler telemetry on --yes` to agree to this")
+			return exitFailure
+		}
+		fmt.Print("\nSend these reports? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Telemetry stays off")
+			return exitOK
+		}
+	}
+	path, err := setConfigValue("telemetry", value)
+	if err != nil {
+		fmt.Println("Could not save the setting:", err)
+		return exitFailure
+	}
+	fmt.Printf("%s Telemetry is %s, set in %s\n", glyphOK, value, path)
+	if value == telemetryOn && os.Getenv("DO_NOT_TRACK") == "1" {
+		fmt.Println("  DO_NOT_TRACK=1 is set, though, so nothing is sent while it is")
+	}
+	return exitOK
+}
+
+// setConfigValue sets key in the config file readConfig uses, or in the
+// per-user one if there is none, keeping the rest of the file as it is. It
+// returns the file's path.
+func setConfigValue(key string, value any) (string, error) {
+	_, path, err := readConfig()
+	if err != nil {
+		return path, err
+	}
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, launcherName, configFileName)
+	}
+	doc := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return path, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return path, err
+	}
+	doc[key] = raw
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return path, err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return path, err
+	}
+	return path, fsys.WriteFile(path, append(data, '\n'), fileMode)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templatedAppFiles are the app files scanned for {{xmlui.NAME}} placeholders.
+var templatedAppFiles = []string{"config.json", "index.html"}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{\s*xmlui\.([A-Za-z0-9_]+)\s*\}\}`)
+	varNamePattern     = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// varsFlag collects repeatable --set name=value flags.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	var parts []string
+	for k, val := range v {
+		parts = append(parts, k+"="+val)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || !varNamePattern.MatchString(name) {
+		return fmt.Errorf("want name=value with a name of letters, digits or _, got %q", s)
+	}
+	v[name] = value
+	return nil
+}
+
+// applyTemplateVars replaces {{xmlui.NAME}} placeholders in the app's
+// config.json and index.html with vars, so pre-configured installs point at
+// the right backend. Placeholders without a value are left alone and
+// reported.
+func applyTemplateVars(appDir string, vars map[string]string) error {
+	for _, name := range templatedAppFiles {
+		path := filepath.Join(appDir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var missing []string
+		out := placeholderPattern.ReplaceAllStringFunc(string(data), func(m string) string {
+			key := placeholderPattern.FindStringSubmatch(m)[1]
+			if v, ok := vars[key]; ok {
+				return v
+			}
+			missing = append(missing, key)
+			return m
+		})
+		for _, key := range missing {
+			warn("  %s uses {{xmlui.%s}} but no value was given (use --set %s=...)", name, key, key)
+		}
+		if out == string(data) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(path, []byte(out), info.Mode().Perm()); err != nil {
+			return err
+		}
+		fmt.Printf("  Applied template variables to %s\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Command fixturetool stands in for the MCP tools and test server in the
+// acceptance test's fixture releases: built under each tool's name, it
+// answers --version as that tool would, and otherwise does nothing.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// version is the version every fixture tool reports.
+const version = "v1.0.0"
+
+func main() {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if len(os.Args) > 1 && os.Args[1] == "--version" {
+		fmt.Println(name, version)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An app offers variants (a sqlite or postgres backend, say) either as
+// directories variants/NAME, laid over the app root by --variant NAME, or
+// as branches variant/NAME of its repo. A variant may carry a variantFile
+// that tells serve how to run the test server for it.
+const (
+	variantsDir   = "variants"
+	variantFile   = "variant.json"
+	variantBranch = "variant/"
+)
+
+// variantConfig is a variant's variantFile.
+type variantConfig struct {
+	Description string `json:"description,omitempty"`
+	// Env is set for the test server, e.g. the database it should use.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// errNoVariants is an app without a variants directory.
+var errNoVariants = errors.New("the app has no " + variantsDir + " directory")
+
+// appVariants lists the variants in appRoot's variants directory, sorted.
+func appVariants(appRoot string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(appRoot, variantsDir))
+	if os.IsNotExist(err) {
+		return nil, errNoVariants
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// applyVariant lays variants/NAME over appRoot, replacing the files it
+// shares with the default app, and removes the variants directory. Without
+// a name it only removes the directory, so the default app is installed.
+func applyVariant(appRoot, name string) error {
+	names, err := appVariants(appRoot)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(appRoot, variantsDir)
+	if name != "" {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			return fmt.Errorf("the app has no variant %q (variants: %s)", name, strings.Join(names, ", "))
+		}
+		src := filepath.Join(dir, name)
+		err = filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(src, path)
+			dest := filepath.Join(appRoot, rel)
+			if d.IsDir() {
+				return fsys.MkdirAll(dest, dirMode)
+			}
+			if err := fsys.RemoveAll(dest); err != nil {
+				return err
+			}
+			return fsys.Rename(path, dest)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return fsys.RemoveAll(dir)
+}
+
+// readVariantConfig reads the variantFile of the app in appDir; an app
+// without one has the zero config.
+func readVariantConfig(appDir string) (variantConfig, error) {
+	var c variantConfig
+	data, err := os.ReadFile(filepath.Join(appDir, variantFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", variantFile, err)
+	}
+	return c, nil
+}
+
+// variantEnv is the environment the app's variant wants for the test
+// server, as KEY=VALUE pairs in a stable order.
+func variantEnv(appDir string) []string {
+	c, err := readVariantConfig(appDir)
+	if err != nil {
+		warn("Ignoring the app's %v", err)
+		return nil
+	}
+	env := make([]string, 0, len(c.Env))
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
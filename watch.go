@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// runWatch implements `watch`: it polls the installed app's source branch
+// and, whenever it moves to a new commit, downloads that snapshot and
+// replaces just the app files that changed, which the test server picks up on
+// the next page load. Seed databases are left alone, so the server can keep
+// running, optionally as a child of watch itself with --serve.
+func runWatch(args []string) int {
+	fs := newFlagSet("watch")
+	dir := installDirFlag(fs)
+	interval := fs.Duration("interval", 30*time.Second, "how often to check the app branch for new commits")
+	webhook := fs.String("webhook", "", "also listen on this address, e.g. 127.0.0.1:9090, and check right away on any POST (point a push webhook at it)")
+	serve := fs.Bool("serve", false, "run the test server while watching, as in: xmlui-bundler serve")
+	fs.Var(headerFlag{requestHeaders}, "header", "extra request header 'Key: Value' for all downloads (repeatable)")
+	fs.Parse(args)
+
+	installDir, err := resolveInstallDir(*dir)
+	if err != nil {
+		fmt.Println("Invalid --dir:", err)
+		return exitUsage
+	}
+	rcpt, err := readReceipt(installDir)
+	if err != nil {
+		fmt.Printf("No install found in %s: %v\n", installDir, err)
+		return exitFailure
+	}
+	if rcpt.Flat {
+		fmt.Println("This is a --flat install: the app is your own project, not a downloaded one, so there is nothing to watch")
+		return exitFailure
+	}
+	keepLineEndings = rcpt.KeepLineEndings
+	spec, ref := rcpt.AppSource, rcpt.AppRef
+	if spec == "" {
+		spec, ref = defaultAppSource, branchName
+	}
+	src, err := parseRepoSource(spec, ref, rcpt.AppProvider)
+	if err != nil {
+		fmt.Println("Failed to resolve app source:", err)
+		return exitFailure
+	}
+	stage, err := stageDir(installDir)
+	if err != nil {
+		fmt.Println("Failed to create staging directory:", err)
+		return exitFailure
+	}
+	defer runCleanups()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	exited := make(chan error, 1)
+	if *serve {
+		port := rcpt.port()
+		logPath, err := installStatePath(installDir, serverLogFile)
+		if err == nil {
+			err = fsys.MkdirAll(filepath.Dir(logPath), dirMode)
+		}
+		cmd := serverCommand(filepath.Join(installDir, filepath.FromSlash(rcpt.appDir())), filepath.Join(installDir, filepath.FromSlash(rcpt.binDir("server"))), port)
+		if err == nil {
+			err = startServer(cmd, logPath)
+		}
+		if err != nil {
+			fmt.Println("Failed to start test server:", err)
+			return exitFailure
+		}
+		go func() { exited <- cmd.Wait() }()
+		defer cmd.Process.Kill()
+		fmt.Printf("Test server running at http://localhost:%d/, logging to %s\n", port, logPath)
+	}
+
+	poke := make(chan struct{}, 1)
+	if *webhook != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "POST to trigger a check", http.StatusMethodNotAllowed)
+				return
+			}
+			select {
+			case poke <- struct{}{}:
+			default:
+			}
+			w.WriteHeader(http.StatusAccepted)
+		})
+		ln, err := net.Listen("tcp", *webhook)
+		if err != nil {
+			fmt.Println("Failed to listen for webhooks:", err)
+			return exitFailure
+		}
+		go http.Serve(ln, mux)
+		fmt.Printf("Listening for webhooks on http://%s/\n", ln.Addr())
+	}
+
+	w := &appWatcher{installDir: installDir, stage: stage, src: src, spec: spec, ref: ref, provider: rcpt.AppProvider}
+	fmt.Printf("Watching %s (%s) every %v; press Ctrl-C to stop\n", spec, ref, *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(); err != nil {
+			fmt.Println(glyphFail, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-poke:
+		case sig := <-sigs:
+			fmt.Printf("\nReceived %v, stopped watching\n", sig)
+			return exitOK
+		case err := <-exited:
+			fmt.Println("Test server exited:", err)
+			return exitFailure
+		}
+	}
+}
+
+// appWatcher remembers what the last check of the app source saw.
+type appWatcher struct {
+	installDir, stage   string
+	src                 *repoSource
+	spec, ref, provider string
+	commit, archiveHash string
+	checks              int
+}
+
+// check syncs the app if its source has changed since the last check. Git
+// sources are compared by commit, so an unchanged branch costs one API call;
+// plain archives can only be downloaded and compared by checksum.
+func (w *appWatcher) check() error {
+	w.checks++
+	url := w.src.URL
+	if w.src.Provider != "archive" {
+		commit, err := resolveCommit(w.src, w.ref)
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", w.spec, err)
+		}
+		if commit == w.commit {
+			return nil
+		}
+		pinned, err := parseRepoSource(w.spec, commit, w.provider)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s  %s is at %.12s\n", time.Now().Format("15:04:05"), w.ref, commit)
+		url, w.commit = pinned.URL, commit
+	}
+	data, h, err := downloadAsset(url, "app", "")
+	if err != nil {
+		w.commit = ""
+		return err
+	}
+	if h == w.archiveHash {
+		return nil
+	}
+	w.archiveHash = h
+	if err := w.sync(data, url); err != nil {
+		w.commit, w.archiveHash = "", ""
+		return fmt.Errorf("updating app: %w", err)
+	}
+	return nil
+}
+
+// sync replaces the app files that differ from the snapshot in data,
+// rolling back if anything fails, and records the new hashes in the receipt.
+func (w *appWatcher) sync(data []byte, url string) (err error) {
+	rcpt, err := readReceipt(w.installDir)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(w.stage, fmt.Sprintf("app-%d", w.checks))
+	defer fsys.RemoveAll(tmp)
+	if err := extractArchive(data, filepath.Join(tmp, "src"), 0); err != nil {
+		return err
+	}
+	root, err := archiveRoot(filepath.Join(tmp, "src"))
+	if err != nil {
+		return err
+	}
+	if journal, err = beginJournal(w.installDir, filepath.Join(tmp, "backup")); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			journal.rollback()
+		}
+		journal = nil
+	}()
+
+	// The running server owns the seed databases; reset-data restores them.
+	var seed, old map[string]string
+	for _, c := range rcpt.Components {
+		switch c.Name {
+		case "app":
+			old = c.Files
+		case "seed-data":
+			seed = c.Files
+		}
+	}
+	prev := map[string]string{}
+	for k, v := range old {
+		if _, ok := seed[k]; !ok {
+			prev[k] = v
+		}
+	}
+	appDir := filepath.Join(w.installDir, filepath.FromSlash(rcpt.appDir()))
+	keep := func(rel string) bool {
+		_, ok := seed[receiptKey(w.installDir, filepath.Join(appDir, filepath.FromSlash(rel)))]
+		return !ok
+	}
+	files, st, err := syncTree(root, appDir, w.installDir, prev, keep)
+	if err != nil {
+		return err
+	}
+	for k := range seed {
+		if h, ok := old[k]; ok {
+			files[k] = h
+		}
+	}
+
+	vars := map[string]string{"port": fmt.Sprint(rcpt.port()), "appName": filepath.Base(appDir)}
+	for k, v := range rcpt.Vars {
+		vars[k] = v
+	}
+	if err := applyTemplateVars(appDir, vars); err != nil {
+		return err
+	}
+	c := rcpt.component("app", url)
+	c.Source, c.Files, c.Downloads = url, files, []receiptDownload{newDownload(platform{}, url, w.archiveHash)}
+	if err := rcpt.write(w.installDir); err != nil {
+		return err
+	}
+	journal.commit()
+	fmt.Printf("%s app: %s\n", glyphOK, st)
+	return nil
+}
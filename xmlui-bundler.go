@@ -1,407 +1,287 @@
 package main
 
 import (
-	"archive/tar"
-	"archive/zip"
 	"bytes"
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"time"
 )
 
+// version is the bundler release, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
 const (
 	repoName     = "xmlui-invoice"
 	branchName   = "main"
-	appZipURL    = "https://codeload.github.com/jonudell/" + repoName + "/zip/refs/heads/" + branchName
+	xmluiRepo    = "https://github.com/xmlui-com/xmlui"
 	xmluiRepoZip = "https://codeload.github.com/xmlui-com/xmlui/zip/refs/heads/main"
 )
 
-func getPlatformSpecificMCPURL() string {
-	baseURL := "https://github.com/jonudell/xmlui-mcp/releases/download/v1.0.0/"
-	arch := runtime.GOARCH
-	switch runtime.GOOS {
-	case "darwin":
-		if arch == "arm64" {
-			return baseURL + "xmlui-mcp-mac-arm.tar.gz"
-		}
-		return baseURL + "xmlui-mcp-mac-amd.tar.gz"
-	case "linux":
-		return baseURL + "xmlui-mcp-linux-amd64.zip"
-	case "windows":
-		return baseURL + "xmlui-mcp-windows-amd64.zip"
-	default:
-		return baseURL + "xmlui-mcp-mac-arm.tar.gz"
+// downloadWithProgress downloads url into memory and returns it with its
+// SHA-256, computed as the bytes arrive rather than in a second pass. A
+// transfer that stalls where the server can't resume it is started over.
+func downloadWithProgress(url, filename string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	h := sha256.New()
+	err := restartStalled(func() error {
+		buf.Reset()
+		h.Reset()
+		_, err := streamDownload(url, filename, io.MultiWriter(&buf, h), buf.Grow)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
 	}
+	return buf.Bytes(), hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func getPlatformSpecificServerURL() string {
-	baseURL := "https://github.com/JonUdell/xmlui-test-server/releases/download/v1.0.0/"
-	arch := runtime.GOARCH
-	switch runtime.GOOS {
-	case "darwin":
-		if arch == "arm64" {
-			return baseURL + "xmlui-test-server-mac-arm.tar.gz"
-		}
-		return baseURL + "xmlui-test-server-mac-amd.tar.gz"
-	case "linux":
-		return baseURL + "xmlui-test-server-linux-amd64.tar.gz"
-	case "windows":
-		return baseURL + "xmlui-test-server-windows-amd64.zip"
-	default:
-		return baseURL + "xmlui-test-server-mac-arm.tar.gz"
-	}
+// streamDownload copies url to w with a progress display and returns the
+// number of bytes written. grow, if not nil, is told the expected size
+// first.
+func streamDownload(url, filename string, w io.Writer, grow func(int)) (int64, error) {
+	_, n, err := streamDownloadFrom(url, filename, nil, func(*http.Response) (io.Writer, error) { return w, nil }, grow)
+	return n, err
 }
 
-func downloadWithProgress(url, filename string) ([]byte, error) {
+// streamDownloadFrom is streamDownload with extra request headers. A Range
+// header makes 206 Partial Content an answer too, and a conditional one
+// (If-None-Match, If-Modified-Since) 304 Not Modified, which is returned
+// without calling open. Otherwise open picks the writer once the response,
+// which is returned as well, is in.
+//
+// A transfer that stalls (see defaultStallTimeout) is continued with a
+// Range request where it stopped, or asked for again if nothing had arrived,
+// up to the retry policy's attempts. If the server can't resume it, the
+// error wraps errNoResume.
+func streamDownloadFrom(url, filename string, header http.Header, open func(*http.Response) (io.Writer, error), grow func(int)) (*http.Response, int64, error) {
 	fmt.Printf("Downloading %s...\n", filename)
-	fmt.Printf("  From: %s\n", url)
+	fmt.Println(console.fit("  From: ", url))
+
+	attempts := max(network.retry.attempts, 1)
+	var first *http.Response
+	var w io.Writer
+	var base, total int64
+	h := header
+	for attempt := 1; ; attempt++ {
+		resp, n, err := streamOnce(url, h, func(resp *http.Response) (io.Writer, error) {
+			if first != nil {
+				if resp.StatusCode != http.StatusPartialContent {
+					return nil, errNoResume
+				}
+				return w, nil
+			}
+			first = resp
+			if resp.StatusCode == http.StatusPartialContent {
+				fmt.Sscanf(h.Get("Range"), "bytes=%d-", &base)
+			}
+			var err error
+			w, err = open(resp)
+			return w, err
+		}, grow)
+		total += n
+		var stall *stallError
+		if !errors.As(err, &stall) || attempt == attempts {
+			if first == nil {
+				first = resp
+			}
+			return first, total, err
+		}
+		stall.received = total
+		if total == 0 {
+			// Nothing written yet: ask again as the first time.
+			first, h = nil, header
+		} else if h = resumeHeader(first, header, base+total); h == nil {
+			return first, total, fmt.Errorf("%w; %w", stall, errNoResume)
+		}
+		fmt.Printf("  %v; retrying (attempt %d of %d)\n", stall, attempt+1, attempts)
+	}
+}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+// streamOnce makes one request of streamDownloadFrom, canceling it if it
+// stalls.
+func streamOnce(url string, header http.Header, open func(*http.Response) (io.Writer, error), grow func(int)) (*http.Response, int64, error) {
+	ctx, watch, release := watchStalls(downloadCtx, network.retry.stall)
+	defer release()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	applyRequestHeaders(req)
+	for k, v := range header {
+		req.Header[k] = v
 	}
 
 	if strings.Contains(url, "codeload.github.com/xmlui-com/xmlui") {
-		token := os.Getenv("GITHUB_TOKEN")
+		token := network.token(req.URL.Host)
 		if token != "" {
 			fmt.Println("  Using authentication token for private repository")
 			req.SetBasicAuth(token, "x-oauth-basic")
 		} else {
-			fmt.Println("  Warning: No authentication token found for private repository")
+			warn("  No authentication token found for private repository (set GITHUB_TOKEN or run xmlui-bundler auth login)")
 		}
 	}
 
-	resp, err := client.Do(req)
+	resp, err := network.do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("%w%s", watch.err(err, url, 0), tlsHint(err))
 	}
 	defer resp.Body.Close()
+	rec := metrics.last(req.URL.String())
 
-	if resp.StatusCode != http.StatusOK {
+	conditional := header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != ""
+	switch {
+	case resp.StatusCode == http.StatusNotModified && conditional:
+		return resp, 0, nil
+	case resp.StatusCode == http.StatusOK, resp.StatusCode == http.StatusPartialContent && header.Get("Range") != "":
+	default:
+		metrics.update(rec, func(r *requestRecord) { r.Error = resp.Status })
 		if strings.Contains(url, "codeload.github.com/xmlui-com/xmlui") && resp.StatusCode == http.StatusUnauthorized {
-			return nil, fmt.Errorf("authentication failed for private repository: %s (status: %s) - check PAT_TOKEN", url, resp.Status)
+			return nil, 0, fmt.Errorf("authentication failed for private repository: %s (status: %s) - check PAT_TOKEN", url, resp.Status)
 		}
-		return nil, fmt.Errorf("request failed: %s for URL: %s", resp.Status, url)
+		return nil, 0, &httpStatusError{url: url, status: resp.Status, code: resp.StatusCode}
 	}
-
-	data, err := io.ReadAll(resp.Body)
+	w, err := open(resp)
 	if err != nil {
-		return nil, err
+		return resp, 0, err
 	}
-	fmt.Printf("  Downloaded: %d bytes\n", len(data))
-	return data, nil
-}
 
-func unzipTo(data []byte, dest string) error {
-	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
-	if err != nil {
-		return err
+	status.setTotal(resp.ContentLength)
+	bar := newProgress(resp.ContentLength)
+	started := time.Now()
+	if grow != nil && resp.ContentLength > 0 {
+		grow(int(resp.ContentLength))
 	}
-	for _, f := range r.File {
-		fpath := filepath.Join(dest, f.Name)
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
-		os.MkdirAll(filepath.Dir(fpath), os.ModePerm)
-		in, err := f.Open()
-		if err != nil {
-			return err
-		}
-		out, err := os.Create(fpath)
+	n, err := io.Copy(w, progressReader{countingReader{watch.reader(resp.Body)}, bar})
+	bar.finish()
+	err = watch.err(err, url, n)
+	metrics.update(rec, func(r *requestRecord) {
+		r.Bytes = n
+		r.DurationMs = time.Since(started).Milliseconds()
 		if err != nil {
-			return err
+			r.Error = err.Error()
 		}
-		io.Copy(out, in)
-		in.Close()
-		out.Close()
+	})
+	if err != nil {
+		return resp, n, err
 	}
-	return nil
+	fmt.Printf("  Downloaded: %d bytes\n", n)
+	return resp, n, nil
 }
 
-func untarGzTo(data []byte, dest string) error {
-	gzReader, err := gzip.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-	tarReader := tar.NewReader(gzReader)
-	for {
-		hdr, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		fpath := filepath.Join(dest, hdr.Name)
-		if hdr.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
-		os.MkdirAll(filepath.Dir(fpath), os.ModePerm)
-		out, err := os.Create(fpath)
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(out, tarReader); err != nil {
-			return err
-		}
-		out.Close()
+// httpStatusError is a download that got a response other than 200 OK.
+type httpStatusError struct {
+	url, status string
+	code        int
+}
 
-		// Set executable bit for script files and binaries
-		if strings.HasSuffix(fpath, ".sh") || filepath.Base(fpath) == "xmlui-mcp" ||
-		   filepath.Base(fpath) == "xmlui-mcp-client" || filepath.Base(fpath) == "xmlui-test-server" {
-			os.Chmod(fpath, 0755)
-			// Note: No need to remove quarantine on macOS for tar.gz files
-			// as the attribute won't be set on extraction
-		}
-	}
-	return nil
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("request failed: %s for URL: %s", e.status, e.url)
 }
 
-func moveIntoPlace(srcParent, repoName, installDir string) (string, error) {
-	repoPrefix := repoName + "-"
-	entries, err := os.ReadDir(srcParent)
+// archiveRoot returns the root of an archive extracted into dir: its single
+// top-level directory if it has one (whatever it is called: repo-main,
+// repo-1.2.3, owner-repo-abc123), otherwise dir itself. Archiver metadata
+// such as pax_global_header and __MACOSX is ignored. Several top-level
+// directories and no files is ambiguous and an error, rather than a guess at
+// which one is the tree.
+func archiveRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return "", err
 	}
+	var content, dirs []os.DirEntry
 	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), repoPrefix) {
-			tmp := filepath.Join(srcParent, e.Name())
-			final := filepath.Join(installDir, repoName)
-			if err := os.Rename(tmp, final); err != nil {
-				return "", err
-			}
-			return final, nil
+		switch e.Name() {
+		case "pax_global_header", "__MACOSX", ".DS_Store":
+			continue
 		}
-	}
-	return "", fmt.Errorf("repo dir not found")
-}
-
-func main() {
-	installDir, _ := os.Getwd()
-	os.MkdirAll(installDir, 0755)
-
-	fmt.Println("Step 1/5: Downloading XMLUI invoice app...")
-	appZip, err := downloadWithProgress(appZipURL, "XMLUI invoice app")
-	if err != nil {
-		fmt.Println("Failed to download app:", err)
-		os.Exit(1)
-	}
-	if err := unzipTo(appZip, installDir); err != nil {
-		fmt.Println("Failed to extract app:", err)
-		os.Exit(1)
-	}
-
-	appDir, err := moveIntoPlace(installDir, repoName, installDir)
-	if err != nil {
-		fmt.Println("Failed to organize app directory:", err)
-		os.Exit(1)
-	}
-
-	fmt.Println("Step 2/5: Downloading XMLUI components...")
-	xmluiZip, err := downloadWithProgress(xmluiRepoZip, "XMLUI repo")
-	if err != nil {
-		fmt.Println("Failed to download XMLUI source:", err)
-		os.Exit(1)
-	}
-	// Extract XMLUI components and place them in the mcp/docs and mcp/src directories
-	tmpDir := filepath.Join(installDir, "xmlui-source")
-	os.MkdirAll(tmpDir, 0755)
-	if err := unzipTo(xmluiZip, tmpDir); err != nil {
-		fmt.Println("Failed to extract XMLUI source:", err)
-		os.Exit(1)
-	}
-
-	// Find the root of the extracted XMLUI source
-	var sourceRoot string
-	entries, _ := os.ReadDir(tmpDir)
-	for _, e := range entries {
-		if e.IsDir() && strings.HasPrefix(e.Name(), "xmlui-") {
-			sourceRoot = filepath.Join(tmpDir, e.Name())
-			break
+		content = append(content, e)
+		if e.IsDir() {
+			dirs = append(dirs, e)
 		}
 	}
-
-	// Setup mcp dir with docs and src
-	mcpDir := filepath.Join(installDir, "mcp")
-	os.MkdirAll(mcpDir, 0755)
-
-	// First ensure docs and src directories are created under mcp
-	docsDir := filepath.Join(mcpDir, "docs")
-	srcDir := filepath.Join(mcpDir, "src")
-	os.MkdirAll(docsDir, 0755)
-	os.MkdirAll(srcDir, 0755)
-
-	// Copy components
-	if sourceRoot != "" {
-		// Set up components directories
-		os.MkdirAll(filepath.Join(docsDir, "pages", "components"), 0755)
-		os.MkdirAll(filepath.Join(srcDir, "components"), 0755)
-
-		// Copy component docs
-		copyFiles(filepath.Join(sourceRoot, "docs", "pages", "components"), filepath.Join(docsDir, "pages", "components"))
-
-		// Copy component source
-		copyFiles(filepath.Join(sourceRoot, "xmlui", "src", "components"), filepath.Join(srcDir, "components"))
-
-		fmt.Println("✓ Extracted components")
+	if len(content) == 0 {
+		return "", fmt.Errorf("archive is empty")
 	}
-
-	// Clean up the source directory
-	_ = os.RemoveAll(tmpDir)
-
-	fmt.Println("Step 3/5: Downloading MCP tools...")
-	mcpUrl := getPlatformSpecificMCPURL()
-	mcpArchive, err := downloadWithProgress(mcpUrl, "MCP tools")
-	if err != nil {
-		fmt.Println("Failed to download MCP tools:", err)
-		os.Exit(1)
+	if len(content) == 1 && content[0].IsDir() {
+		return filepath.Join(dir, content[0].Name()), nil
 	}
-
-	tmpMCP := filepath.Join(installDir, "mcpTmp")
-	os.MkdirAll(tmpMCP, 0755)
-
-	// Extract based on file type
-	if strings.HasSuffix(mcpUrl, ".zip") {
-		err = unzipTo(mcpArchive, tmpMCP)
-	} else {
-		err = untarGzTo(mcpArchive, tmpMCP)
-	}
-
-	if err != nil {
-		fmt.Println("Failed to extract MCP tools:", err)
-		os.Exit(1)
-	}
-
-	var expectedFiles []string
-	if runtime.GOOS == "windows" {
-		expectedFiles = []string{"xmlui-mcp.exe", "xmlui-mcp-client.exe", "run-mcp-client.bat"}
-	} else {
-		expectedFiles = []string{"xmlui-mcp", "xmlui-mcp-client", "prepare-binaries.sh", "run-mcp-client.sh"}
-	}
-
-	for _, name := range expectedFiles {
-		src := filepath.Join(tmpMCP, name)
-		dst := filepath.Join(mcpDir, name)
-		if err := os.Rename(src, dst); err != nil {
-			fmt.Printf("  Skipping %s (not found?): %v\n", name, err)
-			continue
-		}
-		fmt.Printf("  Moved %s to %s\n", name, dst)
-
-		// Set executable permission for non-Windows executables
-		if runtime.GOOS != "windows" && (strings.HasSuffix(name, ".sh") || !strings.Contains(name, ".")) {
-			os.Chmod(dst, 0755)
-		}
-	}
-
-	// Clean up the temporary MCP directory
-	_ = os.RemoveAll(tmpMCP)
-
-	// Move docs and src under mcp if they exist at the root level
-	if _, err := os.Stat(filepath.Join(installDir, "docs")); err == nil {
-		if err := os.Rename(filepath.Join(installDir, "docs"), docsDir); err != nil {
-			fmt.Printf("Warning: Could not move docs directory: %v\n", err)
+	if len(dirs) == len(content) {
+		names := make([]string, len(dirs))
+		for i, d := range dirs {
+			names[i] = d.Name()
 		}
+		return "", fmt.Errorf("archive has %d top-level directories (%s) and no files, so its root is ambiguous", len(dirs), strings.Join(names, ", "))
 	}
+	return dir, nil
+}
 
-	if _, err := os.Stat(filepath.Join(installDir, "src")); err == nil {
-		if err := os.Rename(filepath.Join(installDir, "src"), srcDir); err != nil {
-			fmt.Printf("Warning: Could not move src directory: %v\n", err)
+// moveIntoPlace moves root, the tree this run extracted (see archiveRoot),
+// to installDir/<src.Name>. The tree is tracked from extraction rather than
+// found by scanning installDir, so directories there that share the name,
+// such as an earlier run's xmlui-invoice-main, are never picked up instead.
+// Something already at the target is moved aside to <name>.previous-TIME
+// (and back on rollback); it and those look-alikes are reported as left
+// behind.
+func moveIntoPlace(root string, src *repoSource, installDir string) (string, error) {
+	final := filepath.Join(installDir, src.Name)
+	var aside string
+	if _, err := os.Lstat(final); err == nil {
+		aside = fmt.Sprintf("%s.previous-%s", final, time.Now().Format("20060102-150405"))
+		if err := journal.moveAside(final, aside); err != nil {
+			return "", fmt.Errorf("%s is in the way and could not be moved aside: %w", final, err)
 		}
+		warn("  %s was already there, probably from an earlier failed run; moved it to %s", final, filepath.Base(aside))
+	} else if err := journal.preserve(final); err != nil {
+		return "", err
 	}
-
-	fmt.Println("Step 4/5: Downloading XMLUI test server...")
-	serverURL := getPlatformSpecificServerURL()
-	serverArchive, err := downloadWithProgress(serverURL, "test server")
-	if err != nil {
-		fmt.Println("Failed to download server:", err)
-		os.Exit(1)
-	}
-
-	if strings.HasSuffix(serverURL, ".zip") {
-		err = unzipTo(serverArchive, appDir)
-	} else {
-		err = untarGzTo(serverArchive, appDir)
-	}
-
-	if err != nil {
-		fmt.Println("Failed to extract server:", err)
-		os.Exit(1)
-	}
-
-	// Set executable permission for start.sh
-	startScriptPath := filepath.Join(appDir, "start.sh")
-	if runtime.GOOS != "windows" {
-		os.Chmod(startScriptPath, 0755)
+	if err := movePath(root, final); err != nil {
+		return "", err
 	}
-
-	// The final bundle should contain only these files/directories:
-	// - xmlui-invoice/  (the invoice app)
-	// - mcp/  (with docs/ and src/ inside it)
-	// - XMLUI_GETTING_STARTED_README.md
-
-	// Write a cleanup script that will remove files not in the include list
-	if runtime.GOOS == "windows" {
-		cleanupScript := "@echo off\r\n"
-		cleanupScript += "echo Cleaning up temporary files...\r\n"
-		cleanupScript += fmt.Sprintf("if exist \"%s\" del \"%s\"\r\n", filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
-		cleanupScript += "if exist *.zip del *.zip\r\n"
-		cleanupScript += "del cleanup.bat\r\n"
-		os.WriteFile(filepath.Join(installDir, "cleanup.bat"), []byte(cleanupScript), 0755)
-		fmt.Println("Note: Run cleanup.bat to remove the bundler executable and temporary files")
-	} else {
-		cleanupScript := "#!/bin/sh\n"
-		cleanupScript += "echo Cleaning up temporary files...\n"
-		cleanupScript += fmt.Sprintf("rm -f \"%s\"\n", filepath.Base(os.Args[0]))
-		cleanupScript += "rm -f *.zip\n"
-		cleanupScript += "rm -f *.tar.gz\n"
-		cleanupScript += "rm -f cleanup.sh\n"
-		os.WriteFile(filepath.Join(installDir, "cleanup.sh"), []byte(cleanupScript), 0755)
-		os.Chmod(filepath.Join(installDir, "cleanup.sh"), 0755)
-		fmt.Println("Note: Run ./cleanup.sh to remove the bundler executable and temporary files")
+	entries, _ := os.ReadDir(installDir)
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != filepath.Base(aside) && (strings.HasPrefix(e.Name(), src.Name+"-") || strings.HasPrefix(e.Name(), src.Name+".previous-")) {
+			fmt.Printf("  Left behind %s, which looks like an earlier copy of %s; remove it if you don't need it\n", filepath.Join(installDir, e.Name()), src.Name)
+		}
 	}
-
-	fmt.Println("✓ Organized layout complete")
-	fmt.Printf("\nInstall location: %s\n", installDir)
+	return final, nil
 }
 
-// copyFiles recursively copies files from src to dst directory
-func copyFiles(src, dst string) error {
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			os.MkdirAll(dstPath, 0755)
-			if err := copyFiles(srcPath, dstPath); err != nil {
-				return err
-			}
-		} else {
-			// Copy the file
-			data, err := os.ReadFile(srcPath)
-			if err != nil {
-				return err
-			}
+// fatal reports a failed step, records it for --status-addr, and exits with
+// the code of its class (see exitCodeOf) after running cleanups. With
+// --keep-going it ends only the component being attempted. After a signal it
+// just exits.
+func fatal(msg string, err error) {
+	// Failures the interruption caused aren't worth reporting.
+	checkInterrupted()
+	fmt.Println(console.paint(styleError, msg+":"), err)
+	status.fail(msg, err)
+	code := exitCodeOf(err)
+	failComponent(msg, err, code)
+	if events != nil {
+		fmt.Println("  Everything this run did is recorded in", events.path)
+	}
+	exit(code)
+}
 
-			err = os.WriteFile(dstPath, data, 0644)
-			if err != nil {
-				return err
-			}
-		}
+func main() {
+	args := plainOutput(os.Args[1:])
+	switch {
+	case len(args) == 1 && isHelpFlag(args[0]):
+		printCommands(os.Stdout)
+	case len(args) == 1 && isVersionFlag(args[0]):
+		os.Exit(runVersion(nil))
+	case len(args) > 0 && !strings.HasPrefix(args[0], "-"):
+		os.Exit(dispatch(args))
+	default:
+		os.Exit(runInstall(args))
 	}
-
-	return nil
 }
\ No newline at end of file